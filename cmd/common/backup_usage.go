@@ -0,0 +1,63 @@
+package common
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const backupUsageShortDescription = "Reports the storage usage and cost by backups, logs and garbage"
+
+// BackupUsageAttributorFactory makes the database specific attributor of the objects listed in the storage folder
+type BackupUsageAttributorFactory func(folder storage.Folder, objects []storage.Object) (storagetools.Attributor, error)
+
+// NewBackupUsageCmd makes the backup-usage command. The objects are attributed by the storage layout, which is
+// common for all databases, if newAttributor is nil.
+func NewBackupUsageCmd(newAttributor BackupUsageAttributorFactory) *cobra.Command {
+	var (
+		prices        []string
+		byCategory    bool
+		pretty        bool
+		json          bool
+		targetStorage string
+	)
+	cmd := &cobra.Command{
+		Use:   "backup-usage",
+		Short: backupUsageShortDescription,
+		Long: "The command attributes every object in the storage to a backup, a log range, a restore point or " +
+			"garbage, and reports sizes, object counts and the estimated monthly cost per storage class. " +
+			"Backups are described with the database specifics, e.g. permanent, full or delta with their chains. " +
+			"Files of incomplete backups are reported as garbage.",
+		Args: cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			storagePrices, err := storagetools.DefaultStoragePrices().ParsePrices(prices)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = exec.OnStorage(targetStorage, func(folder storage.Folder) error {
+				var attributorFunc func(objects []storage.Object) (storagetools.Attributor, error)
+				if newAttributor != nil {
+					attributorFunc = func(objects []storage.Object) (storagetools.Attributor, error) {
+						return newAttributor(folder, objects)
+					}
+				}
+				return storagetools.HandleStorageUsage(folder, attributorFunc, storagePrices, byCategory, pretty, json, os.Stdout)
+			})
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&prices, "price", nil,
+		"price of the storage class in USD per GB-month in the CLASS=price format, e.g. STANDARD=0.02. "+
+			"Use 'default' class for objects with unknown storage class")
+	cmd.Flags().BoolVar(&byCategory, "by-category", false, "sum up the usage by categories only")
+	cmd.Flags().BoolVar(&pretty, "pretty", false, "Prints more readable output in table format")
+	cmd.Flags().BoolVar(&json, "json", false, "Prints output in JSON format, multiline and indented if combined with --pretty flag")
+	cmd.Flags().StringVar(&targetStorage, "target-storage", consts.DefaultStorage,
+		"name of the storage to report the usage of")
+	return cmd
+}
@@ -64,6 +64,9 @@ func Init(cmd *cobra.Command, dbName string) {
 	// Add storage tools
 	cmd.AddCommand(st.StorageToolsCmd)
 
+	// Add storage replication
+	cmd.AddCommand(ReplicateCmd)
+
 	// profiler
 	persistentPreRun := cmd.PersistentPreRun
 	persistentPostRun := cmd.PersistentPostRun
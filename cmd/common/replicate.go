@@ -0,0 +1,117 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
+	"github.com/wal-g/wal-g/internal/storagetools/transfer"
+)
+
+const replicateShortDescription = "Continuously replicates backups and logs from one storage to another"
+
+// ReplicateCmd represents the replicate command
+var ReplicateCmd = &cobra.Command{
+	Use:   "replicate --source='source_storage' [--target='target_storage']",
+	Short: replicateShortDescription,
+	Long: "The command watches the source storage and copies new complete backups, WAL, binlogs and oplog archives " +
+		"to the target storage in the dependency order: backup data before the sentinel, logs in the order of their names. " +
+		"Deletions from the source storage are applied to the target one after the grace period. " +
+		"The replication state is kept in the target storage, so the command resumes after restarts.",
+	Args: cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		err := validateReplicateFlags()
+		if err != nil {
+			tracelog.ErrorLogger.FatalError(fmt.Errorf("invalid flags: %w", err))
+		}
+		replicate()
+	},
+}
+
+var (
+	replicateSource                   string
+	replicateTarget                   string
+	replicateInterval                 time.Duration
+	replicateDeletionGracePeriod      time.Duration
+	replicateNoDeletions              bool
+	replicateOnce                     bool
+	replicateConcurrency              int
+	replicateMaxFiles                 uint
+	replicateAppearanceChecks         uint
+	replicateAppearanceChecksInterval time.Duration
+)
+
+func replicate() {
+	cfg := &transfer.ReplicatorConfig{
+		Interval:            replicateInterval,
+		ApplyDeletions:      !replicateNoDeletions,
+		DeletionGracePeriod: replicateDeletionGracePeriod,
+		MaxFiles:            int(replicateMaxFiles),
+		Handler: &transfer.HandlerConfig{
+			Concurrency:              replicateConcurrency,
+			AppearanceChecks:         replicateAppearanceChecks,
+			AppearanceChecksInterval: replicateAppearanceChecksInterval,
+		},
+	}
+
+	replicator, err := transfer.NewReplicator(replicateSource, replicateTarget, cfg)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	if replicateOnce {
+		tracelog.ErrorLogger.FatalOnError(replicator.Sync())
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	tracelog.ErrorLogger.FatalOnError(replicator.Run(ctx))
+}
+
+func init() {
+	ReplicateCmd.Flags().StringVarP(&replicateSource, "source", "s", "",
+		"storage name to replicate files from. Use 'default' to select the primary storage")
+	ReplicateCmd.Flags().StringVarP(&replicateTarget, "target", "t", consts.DefaultStorage,
+		"storage name to replicate files to")
+	ReplicateCmd.Flags().DurationVar(&replicateInterval, "interval", time.Minute,
+		"time interval between checks of the source storage for new files")
+	ReplicateCmd.Flags().DurationVar(&replicateDeletionGracePeriod, "deletion-grace-period", 24*time.Hour,
+		"time to keep files in the target storage after they are deleted from the source one")
+	ReplicateCmd.Flags().BoolVar(&replicateNoDeletions, "no-deletions", false,
+		"don't delete files from the target storage when they are deleted from the source one")
+	ReplicateCmd.Flags().BoolVar(&replicateOnce, "once", false,
+		"perform a single replication pass and exit")
+	ReplicateCmd.Flags().IntVarP(&replicateConcurrency, "concurrency", "c", 10,
+		"number of concurrent workers to copy files. Value 1 turns concurrency off")
+	ReplicateCmd.Flags().UintVarP(&replicateMaxFiles, "max-files", "m", math.MaxInt,
+		"max number of files to copy in a single replication pass")
+	ReplicateCmd.Flags().UintVar(&replicateAppearanceChecks, "appearance-checks", 3,
+		"number of times to check if a file is appeared for reading in the target storage after writing it. Value 0 turns checking off")
+	ReplicateCmd.Flags().DurationVar(&replicateAppearanceChecksInterval, "appearance-checks-interval", time.Second,
+		"minimum time interval between performing checks for files to appear in the target storage")
+}
+
+func validateReplicateFlags() error {
+	if replicateSource == "" {
+		return fmt.Errorf("source storage must be specified")
+	}
+	if replicateSource == "all" || replicateTarget == "all" {
+		return fmt.Errorf("explicit source and target storages must be specified instead of 'all'")
+	}
+	if replicateSource == replicateTarget {
+		return fmt.Errorf("source and target storages must be different")
+	}
+	if replicateConcurrency < 1 {
+		return fmt.Errorf("concurrency level must be >= 1 (which turns it off)")
+	}
+	if replicateInterval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	return nil
+}
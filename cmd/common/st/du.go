@@ -0,0 +1,50 @@
+package st
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const duShortDescription = "Reports the storage usage and cost by backups, logs, restore points and garbage"
+
+// duCmd represents the du command
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: duShortDescription,
+	Long: "The command walks the storage and attributes every object to a backup, a WAL, binlog or oplog range, " +
+		"a restore point or garbage (e.g. files of incomplete backups). Sizes, object counts and the estimated " +
+		"monthly cost are reported per storage class. Prices are in USD per GB-month, AWS S3 prices are used " +
+		"by default and can be overridden with --price.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		prices, err := storagetools.DefaultStoragePrices().ParsePrices(duPrices)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		err = exec.OnStorage(targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleStorageUsage(folder, nil, prices, duByCategory, duPretty, duJSON, os.Stdout)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+var (
+	duPrices     []string
+	duByCategory bool
+	duPretty     bool
+	duJSON       bool
+)
+
+func init() {
+	duCmd.Flags().StringArrayVar(&duPrices, "price", nil,
+		"price of the storage class in USD per GB-month in the CLASS=price format, e.g. STANDARD=0.02. "+
+			"Use 'default' class for objects with unknown storage class")
+	duCmd.Flags().BoolVar(&duByCategory, "by-category", false, "sum up the usage by categories only")
+	duCmd.Flags().BoolVar(&duPretty, "pretty", false, "Prints more readable output in table format")
+	duCmd.Flags().BoolVar(&duJSON, "json", false, "Prints output in JSON format, multiline and indented if combined with --pretty flag")
+	StorageToolsCmd.AddCommand(duCmd)
+}
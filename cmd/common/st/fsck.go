@@ -0,0 +1,56 @@
+package st
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const fsckShortDescription = "Checks the storage for orphaned, broken and missing backup and log files"
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: fsckShortDescription,
+	Long: "The command cross-checks the storage layout: sentinels without backup data, backup data without sentinels " +
+		"and the database-specific issues, e.g. broken delta chains and WAL gaps for PostgreSQL, oplog archive gaps " +
+		"for MongoDB and restore points of Greenplum. The found issues are printed, the command fails if any of them " +
+		"haven't been repaired. With --repair, the garbage objects of the issues are deleted.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		checks := fsckChecksFactory(fsckMinOrphanAge)
+		err := exec.OnStorage(targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleFsck(folder, checks, fsckRepair, fsckPretty, fsckJSON, os.Stdout)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+var (
+	fsckRepair       bool
+	fsckMinOrphanAge time.Duration
+	fsckPretty       bool
+	fsckJSON         bool
+
+	// fsckChecksFactory makes the checks for the database the command is built for
+	fsckChecksFactory = storagetools.DefaultFsckChecks
+)
+
+// SetFsckChecks replaces the default storage checks performed by the fsck command with the database-specific ones
+func SetFsckChecks(factory func(minOrphanAge time.Duration) []storagetools.FsckCheck) {
+	fsckChecksFactory = factory
+}
+
+func init() {
+	fsckCmd.Flags().BoolVar(&fsckRepair, "repair", false, "delete the garbage objects of the found issues")
+	fsckCmd.Flags().DurationVar(&fsckMinOrphanAge, "min-orphan-age", 24*time.Hour,
+		"minimum age of the backup data without a sentinel to consider it orphaned rather than being uploaded")
+	fsckCmd.Flags().BoolVar(&fsckPretty, "pretty", false, "Prints more readable output in table format")
+	fsckCmd.Flags().BoolVar(&fsckJSON, "json", false, "Prints output in JSON format, multiline and indented if combined with --pretty flag")
+	StorageToolsCmd.AddCommand(fsckCmd)
+}
@@ -0,0 +1,37 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const reindexShortDescription = "Rebuilds the backup catalogs of the storage"
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: reindexShortDescription,
+	Long: "The command lists every backups folder of the storage and replaces its backup catalog with the one " +
+		"made of the sentinels and metadata found. Run it after enabling " + conf.BackupCatalogSetting +
+		" and whenever the catalog is lost or outdated, e.g. after the backups were changed with the catalog disabled.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !viper.GetBool(conf.BackupCatalogSetting) {
+			tracelog.WarningLogger.Printf("%s is disabled, so the rebuilt catalogs will be neither used nor updated",
+				conf.BackupCatalogSetting)
+		}
+		err := exec.OnStorage(targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleReindex(folder)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	StorageToolsCmd.AddCommand(reindexCmd)
+}
@@ -6,7 +6,10 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/copy"
 )
 
 const transferShortDescription = "Moves objects from one storage to another (Postgres only)"
@@ -35,6 +38,8 @@ var (
 	transferMaxFiles                 uint
 	transferAppearanceChecks         uint
 	transferAppearanceChecksInterval time.Duration
+	transferRecompress               string
+	transferTargetConfig             string
 )
 
 func init() {
@@ -55,6 +60,12 @@ func init() {
 	transferCmd.PersistentFlags().DurationVar(&transferAppearanceChecksInterval, "appearance-checks-interval", time.Second,
 		"minimum time interval between performing checks for files to appear in the target storage")
 
+	transferCmd.PersistentFlags().StringVar(&transferRecompress, "recompress", "",
+		"decode files and compress them with the given method in the target storage, instead of moving them byte-for-byte")
+	transferCmd.PersistentFlags().StringVar(&transferTargetConfig, "target-config", "",
+		"config with the compression and encryption settings to re-encode files with for the target storage. "+
+			"By default, the current config is used")
+
 	StorageToolsCmd.AddCommand(transferCmd)
 }
 
@@ -76,3 +87,16 @@ func validateCommonFlags() error {
 	}
 	return nil
 }
+
+func configureTransferRecoder() *copy.Recoder {
+	if transferRecompress == "" && transferTargetConfig == "" {
+		return nil
+	}
+	targetConfig := viper.GetViper()
+	if transferTargetConfig != "" {
+		targetConfig = internal.ConfigFromFile(transferTargetConfig)
+	}
+	recoder, err := copy.RecoderFromConfigs(viper.GetViper(), targetConfig, transferRecompress)
+	tracelog.ErrorLogger.FatalOnError(err)
+	return recoder
+}
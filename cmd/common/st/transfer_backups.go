@@ -29,6 +29,7 @@ var backupsCmd = &cobra.Command{
 			Concurrency:              transferConcurrency,
 			AppearanceChecks:         transferAppearanceChecks,
 			AppearanceChecksInterval: transferAppearanceChecksInterval,
+			Recoder:                  configureTransferRecoder(),
 		}
 
 		handler, err := transfer.NewHandler(transferSourceStorage, targetStorage, fileLister, cfg)
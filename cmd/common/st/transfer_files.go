@@ -27,6 +27,7 @@ func transferFiles(prefix string) {
 		Concurrency:              transferConcurrency,
 		AppearanceChecks:         transferAppearanceChecks,
 		AppearanceChecksInterval: transferAppearanceChecksInterval,
+		Recoder:                  configureTransferRecoder(),
 	}
 
 	handler, err := transfer.NewHandler(transferSourceStorage, targetStorage, separateFileLister, cfg)
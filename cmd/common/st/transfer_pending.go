@@ -0,0 +1,46 @@
+package st
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const pendingShortDescription = "Copies files pending replication after quorum uploads to the storages that missed them"
+
+// pendingCmd represents the pending-replication command
+var pendingCmd = &cobra.Command{
+	Use:   "pending-replication [--target='target_storage']",
+	Short: pendingShortDescription,
+	Long: "When files are uploaded with WALG_FAILOVER_STORAGES_PUT_QUORUM, storages that haven't got them are recorded " +
+		"to the replication journal. This command copies such files to the recorded storages and cleans up the journal. " +
+		"By default, all storages are caught up. (Postgres only)",
+	Args: cobra.NoArgs,
+	// The source storage isn't required, as files are taken from the storages where the journal is stored.
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		return nil
+	},
+	Run: func(cmd *cobra.Command, _ []string) {
+		target := ""
+		if cmd.Flags().Changed("target") {
+			target = targetStorage
+		}
+		transferPending(cmd, target)
+	},
+}
+
+func transferPending(cmd *cobra.Command, target string) {
+	multiSt, err := exec.ConfigureMultiStorage()
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(multiSt, "close multi-storage")
+
+	replicated, err := multistorage.DrainReplicationJournal(cmd.Context(), multiSt.RootFolder(), target)
+	tracelog.InfoLogger.Printf("Replicated %d files", replicated)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+func init() {
+	transferCmd.AddCommand(pendingCmd)
+}
@@ -0,0 +1,11 @@
+package gp
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/internal/databases/greenplum"
+)
+
+func init() {
+	// segment backups are reported by the segments folder, since they have no sentinels of their own
+	cmd.AddCommand(common.NewBackupUsageCmd(greenplum.NewBackupUsageAttributor))
+}
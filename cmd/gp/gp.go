@@ -12,6 +12,7 @@ import (
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/st"
 
 	"github.com/wal-g/wal-g/cmd/pg"
 
@@ -56,6 +57,7 @@ var SegContentID string
 
 func init() {
 	common.Init(cmd, conf.GP)
+	st.SetFsckChecks(greenplum.FsckChecks)
 
 	_ = cmd.MarkFlagRequired("config") // config is required for Greenplum WAL-G
 	// wrap the Postgres command so it can be used in the same binary
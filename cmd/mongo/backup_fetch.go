@@ -9,6 +9,7 @@ import (
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/mongo"
 	"github.com/wal-g/wal-g/utility"
 )
 
@@ -37,7 +38,8 @@ var backupFetchCmd = &cobra.Command{
 		backupSelector, err := internal.NewBackupNameSelector(args[0], true)
 		tracelog.ErrorLogger.FatalOnError(err)
 
-		internal.HandleBackupFetch(storage.RootFolder(), backupSelector, internal.GetBackupToCommandFetcher(restoreCmd))
+		err = mongo.HandleBackupFetch(storage.RootFolder(), backupSelector, restoreCmd)
+		tracelog.ErrorLogger.FatalOnError(err)
 	},
 }
 
@@ -0,0 +1,11 @@
+package mongo
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/internal/databases/mongo"
+)
+
+func init() {
+	// backups are marked as permanent, logical or binary
+	cmd.AddCommand(common.NewBackupUsageCmd(mongo.NewBackupUsageAttributor))
+}
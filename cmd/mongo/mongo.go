@@ -6,11 +6,13 @@ import (
 	"strings"
 
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/st"
 
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/mongo"
 )
 
 var dbShortDescription = "MongoDB backup tool"
@@ -43,6 +45,7 @@ func Execute() {
 
 func init() {
 	common.Init(cmd, conf.MONGO)
+	st.SetFsckChecks(mongo.FsckChecks)
 	conf.AddTurboFlag(cmd)
 	conf.RequiredSettings[conf.MongoDBUriSetting] = true
 }
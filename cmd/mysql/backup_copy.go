@@ -2,6 +2,8 @@ package mysql
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/copy"
 	db "github.com/wal-g/wal-g/internal/databases/mysql"
 )
 
@@ -28,6 +30,13 @@ const (
 	prefixFlag        = "add-prefix"
 	prefixShorthand   = "p"
 	prefixDescription = "add prefix to path"
+
+	recompressFlag        = "recompress"
+	recompressDescription = "decode the objects and compress them with the given method in the target storage"
+
+	targetConfigFlag        = "target-config"
+	targetConfigDescription = "config with the compression and encryption settings to re-encode the objects with " +
+		"(the --to config by default if --recompress is set)"
 )
 
 var (
@@ -36,17 +45,21 @@ var (
 	fromConfigFile string
 	toConfigFile   string
 	all            bool
+	recompress     string
+	targetConfig   string
 
 	copyCmd = &cobra.Command{
 		Use:   copyName,
 		Short: copyShortDescription,
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
+			recoder, err := copy.ConfigureCopyRecoder(fromConfigFile, toConfigFile, targetConfig, recompress)
+			tracelog.ErrorLogger.FatalOnError(err)
 			if all {
-				db.HandleCopyAll(fromConfigFile, toConfigFile)
+				db.HandleCopyAll(fromConfigFile, toConfigFile, recoder)
 				return
 			}
-			db.HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix)
+			db.HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix, recoder)
 		},
 	}
 )
@@ -57,6 +70,8 @@ func init() {
 	copyCmd.Flags().StringVarP(&backupName, backupNameFlag, backupShorthand, "", backupShortDescription)
 	copyCmd.Flags().StringVarP(&prefix, prefixFlag, prefixShorthand, "", prefixDescription)
 	copyCmd.Flags().BoolVarP(&all, copyAllFlag, allShorthand, false, copyAllSDescription)
+	copyCmd.Flags().StringVar(&recompress, recompressFlag, "", recompressDescription)
+	copyCmd.Flags().StringVar(&targetConfig, targetConfigFlag, "", targetConfigDescription)
 
 	cmd.AddCommand(copyCmd)
 }
@@ -0,0 +1,11 @@
+package mysql
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
+)
+
+func init() {
+	// backups are marked as permanent, full or incremental with their chains
+	cmd.AddCommand(common.NewBackupUsageCmd(mysql.NewBackupUsageAttributor))
+}
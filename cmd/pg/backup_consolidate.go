@@ -0,0 +1,66 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/internal/multistorage/policies"
+)
+
+const backupConsolidateShortDescription = "Builds a full backup from a delta backup and its chain of increments"
+
+var (
+	// backupConsolidateCmd represents the backup-consolidate command
+	backupConsolidateCmd = &cobra.Command{
+		Use:   "backup-consolidate delta_backup_name",
+		Short: backupConsolidateShortDescription,
+		Long: "The command restores the delta backup with its chain of increments into a staging directory " +
+			"and uploads the result as a new full backup with the same LSNs. The database isn't involved. " +
+			"The new backup is named after the start WAL segment of the delta backup, " +
+			"so the delta chain can be deleted afterwards. " +
+			"The staging directory needs as much free space as the data directory of the cluster, " +
+			"the command checks it before restoring.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			storage, err := postgres.ConfigureMultiStorage(true)
+			tracelog.ErrorLogger.FatalfOnError("Failed to configure multi-storage: %v", err)
+
+			rootFolder := multistorage.SetPolicies(storage.RootFolder(), policies.TakeFirstStorage)
+			if targetStorage == "" {
+				rootFolder, err = multistorage.UseFirstAliveStorage(rootFolder)
+			} else {
+				rootFolder, err = multistorage.UseSpecificStorage(targetStorage, rootFolder)
+			}
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			uploader, err := internal.ConfigureUploaderToFolder(rootFolder)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			stagingDirectory := backupConsolidateStagingDir
+			if stagingDirectory == "" {
+				stagingDirectory = os.TempDir()
+			}
+			err = postgres.HandleBackupConsolidate(uploader, args[0], stagingDirectory, backupConsolidatePermanent)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	backupConsolidateStagingDir string
+	backupConsolidatePermanent  bool
+)
+
+func init() {
+	backupConsolidateCmd.Flags().StringVar(&backupConsolidateStagingDir, "staging-dir", "",
+		"directory to restore the delta chain to, the system temporary directory by default")
+	backupConsolidateCmd.Flags().BoolVarP(&backupConsolidatePermanent, permanentFlag, permanentShorthand,
+		false, "Marks the new full backup permanent")
+	backupConsolidateCmd.Flags().StringVar(&targetStorage, "target-storage", "",
+		targetStorageDescription)
+
+	Cmd.AddCommand(backupConsolidateCmd)
+}
@@ -2,6 +2,7 @@ package pg
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,6 +26,8 @@ For information about pattern syntax view: https://golang.org/pkg/path/filepath/
 	restoreOnlyDescription        = `[Experimental] Downloads only databases or tables specified by passed names.
 Separate parameters with comma. Use 'database' or 'database/namespace.table' as a parameter ('public' namespace can be omitted).  
 Sets reverse delta unpack & skip redundant tars options automatically. Always downloads system databases and tables.`
+	verifyFetchedDescription = "Verify page headers and checksums of the fetched data directory, " +
+		"skipping the pages changed since the backup start"
 )
 
 var fileMask string
@@ -33,6 +36,7 @@ var reverseDeltaUnpack bool
 var skipRedundantTars bool
 var fetchTargetUserData string
 var partialRestoreArgs []string
+var verifyFetched bool
 
 var backupFetchCmd = &cobra.Command{
 	Use:   "backup-fetch destination_directory [backup_name | --target-user-data <data>]",
@@ -82,6 +86,11 @@ var backupFetchCmd = &cobra.Command{
 		}
 
 		internal.HandleBackupFetch(rootFolder, targetBackupSelector, pgFetcher)
+
+		if verifyFetched {
+			err = postgres.HandlePgDataVerify(args[0], false, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
 	},
 }
 
@@ -114,6 +123,8 @@ func init() {
 		nil, restoreOnlyDescription)
 	backupFetchCmd.Flags().StringVar(&targetStorage, "target-storage",
 		"", targetStorageDescription)
+	backupFetchCmd.Flags().BoolVar(&verifyFetched, "verify",
+		false, verifyFetchedDescription)
 
 	Cmd.AddCommand(backupFetchCmd)
 }
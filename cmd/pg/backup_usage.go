@@ -0,0 +1,11 @@
+package pg
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func init() {
+	// WAL older than the oldest backup is reported as garbage, since no backup can use it
+	Cmd.AddCommand(common.NewBackupUsageCmd(postgres.NewBackupUsageAttributor))
+}
@@ -2,6 +2,8 @@ package pg
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/copy"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 )
 
@@ -26,6 +28,13 @@ const (
 	withoutHistoryFlag        = "without-history"
 	withoutHistoryShorthand   = "w"
 	withoutHistoryDescription = "Copy backup without history"
+
+	recompressFlag        = "recompress"
+	recompressDescription = "Decode the objects and compress them with the given method in the target storage"
+
+	targetConfigFlag        = "target-config"
+	targetConfigDescription = "Config with the compression and encryption settings to re-encode the objects with " +
+		"(the --to config by default if --recompress is set)"
 )
 
 var (
@@ -33,6 +42,8 @@ var (
 	fromConfigFile string
 	toConfigFile   string
 	withoutHistory = false
+	recompress     string
+	targetConfig   string
 
 	backupCopyCmd = &cobra.Command{
 		Use:   backupCopyUsage,
@@ -48,7 +59,9 @@ var (
 )
 
 func runBackupCopy(cmd *cobra.Command, args []string) {
-	postgres.HandleCopy(fromConfigFile, toConfigFile, backupName, withoutHistory)
+	recoder, err := copy.ConfigureCopyRecoder(fromConfigFile, toConfigFile, targetConfig, recompress)
+	tracelog.ErrorLogger.FatalOnError(err)
+	postgres.HandleCopy(fromConfigFile, toConfigFile, backupName, withoutHistory, recoder)
 }
 
 func init() {
@@ -62,6 +75,8 @@ func init() {
 		withoutHistoryShorthand,
 		false,
 		withoutHistoryDescription)
+	backupCopyCmd.Flags().StringVar(&recompress, recompressFlag, "", recompressDescription)
+	backupCopyCmd.Flags().StringVar(&targetConfig, targetConfigFlag, "", targetConfigDescription)
 
 	_ = backupCopyCmd.MarkFlagRequired(toFlag)
 	_ = backupCopyCmd.MarkFlagRequired(fromFlag)
@@ -0,0 +1,128 @@
+package pg
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var logicalDeleteConfirmed = false
+var logicalDeleteTargetUserData = ""
+var logicalDeleteDatabase = ""
+
+// logicalBackupDeleteCmd represents the logical-backup-delete command
+var logicalBackupDeleteCmd = &cobra.Command{
+	Use:   "logical-backup-delete",
+	Short: "Clears old pg_dump backups, the physical backups and WAL are kept",
+	Long: "The before and retain retention is applied to the backups of every database separately, " +
+		"--db limits any deletion to the backups of one database.",
+}
+
+var logicalDeleteBeforeCmd = &cobra.Command{
+	Use:     internal.DeleteBeforeUsageExample,
+	Example: internal.DeleteBeforeExamples,
+	Args:    internal.DeleteBeforeArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		modifier, beforeStr := internal.ExtractDeleteModifierFromArgs(args)
+		handleLogicalDeleteRetention(logicalRetentionRelativeBackup(beforeStr),
+			func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+				return handler.FindTargetBefore(beforeStr, modifier)
+			})
+	},
+}
+
+var logicalDeleteRetainCmd = &cobra.Command{
+	Use:       internal.DeleteRetainUsageExample,
+	Example:   internal.DeleteRetainExamples,
+	ValidArgs: internal.StringModifiers,
+	Args:      internal.DeleteRetainArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		modifier, retentionStr := internal.ExtractDeleteModifierFromArgs(args)
+		retentionCount, err := strconv.Atoi(retentionStr)
+		tracelog.ErrorLogger.FatalOnError(err)
+		afterValue, _ := cmd.Flags().GetString(afterFlag)
+		if afterValue == "" {
+			handleLogicalDeleteRetention("", func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+				return handler.FindTargetRetain(retentionCount, modifier)
+			})
+		} else {
+			handleLogicalDeleteRetention(logicalRetentionRelativeBackup(afterValue),
+				func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+					return handler.FindTargetRetainAfter(retentionCount, afterValue, modifier)
+				})
+		}
+	},
+}
+
+var logicalDeleteEverythingCmd = &cobra.Command{
+	Use:       internal.DeleteEverythingUsageExample,
+	Example:   internal.DeleteEverythingExamples,
+	ValidArgs: internal.StringModifiersDeleteEverything,
+	Args:      internal.DeleteEverythingArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		deleteHandler, permanentBackups := newLogicalDeleteHandler()
+		deleteHandler.HandleDeleteEverything(args, permanentBackups, logicalDeleteConfirmed)
+	},
+}
+
+var logicalDeleteTargetCmd = &cobra.Command{
+	Use:     "target backup_name | --target-user-data <data>",
+	Example: "  target stream_20240301T120000Z	delete logical backup by name",
+	Args:    internal.DeleteTargetArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		deleteHandler, _ := newLogicalDeleteHandler()
+		targetBackupSelector, err := internal.CreateTargetDeleteBackupSelector(cmd, args,
+			logicalDeleteTargetUserData, postgres.NewLogicalBackupMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		deleteHandler.HandleDeleteTarget(targetBackupSelector, logicalDeleteConfirmed, false)
+	},
+}
+
+func newLogicalDeleteHandler() (*internal.DeleteHandler, []string) {
+	deleteHandler, permanentBackups, err := postgres.NewLogicalDeleteHandler(logicalBackupsFolder(),
+		logicalDeleteDatabase)
+	tracelog.ErrorLogger.FatalOnError(err)
+	return deleteHandler, permanentBackups
+}
+
+func handleLogicalDeleteRetention(relativeToBackup string,
+	findTarget func(handler *internal.DeleteHandler) (internal.BackupObject, error)) {
+	err := postgres.HandleLogicalDeleteRetention(logicalBackupsFolder(), logicalDeleteDatabase, relativeToBackup,
+		logicalDeleteConfirmed, findTarget)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+// logicalRetentionRelativeBackup returns the backup name the retention is relative to, empty for a time
+func logicalRetentionRelativeBackup(value string) string {
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return ""
+	}
+	return value
+}
+
+func logicalBackupsFolder() storage.Folder {
+	configuredStorage, err := internal.ConfigureStorage()
+	tracelog.ErrorLogger.FatalOnError(err)
+	return configuredStorage.RootFolder().GetSubFolder(postgres.LogicalBackupPath)
+}
+
+func init() {
+	Cmd.AddCommand(logicalBackupDeleteCmd)
+
+	logicalDeleteTargetCmd.Flags().StringVar(
+		&logicalDeleteTargetUserData, internal.DeleteTargetUserDataFlag, "", internal.DeleteTargetUserDataDescription)
+	logicalDeleteRetainCmd.Flags().StringP(afterFlag, "a", "", "Set the time after which retain backups")
+
+	logicalBackupDeleteCmd.AddCommand(logicalDeleteRetainCmd, logicalDeleteBeforeCmd, logicalDeleteEverythingCmd,
+		logicalDeleteTargetCmd)
+	logicalBackupDeleteCmd.PersistentFlags().BoolVar(&logicalDeleteConfirmed, internal.ConfirmFlag, false,
+		"Confirms backup deletion")
+	logicalBackupDeleteCmd.PersistentFlags().StringVar(&logicalDeleteDatabase, "db", "",
+		"Deletes only the backups of the database")
+}
@@ -0,0 +1,68 @@
+package pg
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const logicalBackupFetchShortDescription = "Fetches a pg_dump backup from storage"
+
+var (
+	// logicalBackupFetchCmd represents the logical-backup-fetch command
+	logicalBackupFetchCmd = &cobra.Command{
+		Use:   "logical-backup-fetch [backup_name | --target-user-data <data>]",
+		Short: logicalBackupFetchShortDescription,
+		Long: "The command writes the dump in the pg_dump custom format to stdout. With --restore-db the dump " +
+			"is piped into pg_restore connected to the given database, which may belong to another cluster " +
+			"or major version. The latest backup is fetched by default.",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			targetName := ""
+			if len(args) > 0 {
+				targetName = args[0]
+			} else if logicalBackupTargetUserData == "" {
+				targetName = internal.LatestString
+			}
+			selector, err := internal.NewTargetBackupSelector(logicalBackupTargetUserData, targetName,
+				postgres.NewLogicalBackupMetaFetcher())
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+			folder := storage.RootFolder().GetSubFolder(postgres.LogicalBackupPath)
+
+			if logicalBackupRestoreDatabase == "" {
+				_, err = postgres.FetchLogicalBackup(folder, selector, os.Stdout)
+				tracelog.ErrorLogger.FatalOnError(err)
+				return
+			}
+			restoreCmd := postgres.NewLogicalRestoreCommand(context.Background(), logicalBackupPgBinDirectory,
+				logicalBackupRestoreDatabase, logicalBackupRestoreOptions)
+			err = postgres.HandleLogicalBackupFetch(folder, selector, restoreCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	logicalBackupTargetUserData  string
+	logicalBackupRestoreDatabase string
+	logicalBackupRestoreOptions  []string
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupFetchCmd)
+
+	flags := logicalBackupFetchCmd.Flags()
+	flags.StringVar(&logicalBackupTargetUserData, "target-user-data", "", targetUserDataDescription)
+	flags.StringVar(&logicalBackupRestoreDatabase, "restore-db", "",
+		"database to restore the dump into with pg_restore instead of writing it to stdout")
+	flags.StringVar(&logicalBackupPgBinDirectory, "pg-bin-dir", "",
+		"directory with the PostgreSQL binaries, pg_restore is searched in PATH by default")
+	flags.StringArrayVar(&logicalBackupRestoreOptions, "pg-restore-option", nil,
+		"extra pg_restore argument, e.g. --no-owner")
+}
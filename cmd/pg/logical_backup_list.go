@@ -0,0 +1,42 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const logicalBackupListShortDescription = "Prints the pg_dump backups available in storage"
+
+var (
+	// logicalBackupListCmd represents the logical-backup-list command
+	logicalBackupListCmd = &cobra.Command{
+		Use:   "logical-backup-list",
+		Short: logicalBackupListShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandleLogicalBackupList(storage.RootFolder().GetSubFolder(postgres.LogicalBackupPath),
+				logicalBackupListDatabase, logicalBackupListPretty, logicalBackupListJSON, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	logicalBackupListDatabase string
+	logicalBackupListPretty   bool
+	logicalBackupListJSON     bool
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupListCmd)
+
+	flags := logicalBackupListCmd.Flags()
+	flags.StringVar(&logicalBackupListDatabase, "db", "", "print only the backups of the database")
+	flags.BoolVar(&logicalBackupListPretty, PrettyFlag, false, "Prints more readable output")
+	flags.BoolVar(&logicalBackupListJSON, JSONFlag, false,
+		"Prints output in JSON format, multiline and indented if combined with --pretty flag")
+}
@@ -0,0 +1,79 @@
+package pg
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const logicalBackupPushShortDescription = "Dumps a database with pg_dump and uploads the dump to storage"
+
+var (
+	// logicalBackupPushCmd represents the logical-backup-push command
+	logicalBackupPushCmd = &cobra.Command{
+		Use:   "logical-backup-push --db <name>",
+		Short: logicalBackupPushShortDescription,
+		Long: "The command streams the output of pg_dump in the custom format through the compression, " +
+			"the encryption and the stream splitting settings to the " + postgres.LogicalBackupPath +
+			" prefix of storage. The connection settings are taken from the PG* environment variables.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			signalHandler := utility.NewSignalHandler(ctx, cancel, []os.Signal{syscall.SIGINT, syscall.SIGTERM})
+			defer func() { _ = signalHandler.Close() }()
+
+			uploader, err := internal.ConfigureSplitUploader()
+			tracelog.ErrorLogger.FatalOnError(err)
+			uploader.ChangeDirectory(postgres.LogicalBackupPath + utility.BaseBackupPath)
+
+			if logicalBackupUserData == "" {
+				logicalBackupUserData = viper.GetString(conf.SentinelUserDataSetting)
+			}
+			userData, err := internal.UnmarshalSentinelUserData(logicalBackupUserData)
+			tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+
+			dumpCmd := postgres.NewLogicalDumpCommand(ctx, logicalBackupPgBinDirectory, logicalBackupDatabase,
+				logicalBackupDumpOptions)
+			pgDumpVersion, err := postgres.GetPgDumpVersion(dumpCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandleLogicalBackupPush(ctx, uploader, dumpCmd, postgres.LogicalBackupSentinelDto{
+				Database:      logicalBackupDatabase,
+				PgDumpVersion: pgDumpVersion,
+				IsPermanent:   logicalBackupPermanent,
+				UserData:      userData,
+			})
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	logicalBackupDatabase       string
+	logicalBackupPgBinDirectory string
+	logicalBackupDumpOptions    []string
+	logicalBackupPermanent      bool
+	logicalBackupUserData       string
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupPushCmd)
+
+	flags := logicalBackupPushCmd.Flags()
+	flags.StringVar(&logicalBackupDatabase, "db", "", "database to dump")
+	flags.StringVar(&logicalBackupPgBinDirectory, "pg-bin-dir", "",
+		"directory with the PostgreSQL binaries, pg_dump is searched in PATH by default")
+	flags.StringArrayVar(&logicalBackupDumpOptions, "pg-dump-option", nil,
+		"extra pg_dump argument, e.g. --exclude-table=audit_log")
+	flags.BoolVarP(&logicalBackupPermanent, permanentFlag, permanentShorthand, false, "Pushes permanent backup")
+	flags.StringVar(&logicalBackupUserData, addUserDataFlag, "",
+		"Write the provided user data to the backup sentinel.")
+	_ = logicalBackupPushCmd.MarkFlagRequired("db")
+}
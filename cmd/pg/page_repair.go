@@ -0,0 +1,73 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	pageRepairShortDescription = "Rebuilds the relation pages from the backup and the full-page images of archived WAL"
+	pageRepairRelationFlag     = "relation"
+	pageRepairBlockFlag        = "block"
+)
+
+var (
+	// pageRepairCmd represents the page-repair command
+	pageRepairCmd = &cobra.Command{
+		Use:   "page-repair --relation db_oid/relfilenode --output directory",
+		Short: pageRepairShortDescription,
+		Long: "The command restores the relation files of the default tablespace from the backup with its " +
+			"delta chain, replays the full-page images of the relation blocks from the archived WAL " +
+			"of the backup timeline up to the target LSN and writes the repaired files to the output directory. " +
+			"The files are meant to be swapped in while the cluster is stopped. The changes logged " +
+			"without full-page images can't be redone outside PostgreSQL, so if such a change follows " +
+			"the last image of a target block, the command fails without writing anything.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			dbNode, relNode, err := postgres.ParsePageRepairRelation(pageRepairRelation)
+			tracelog.ErrorLogger.FatalOnError(err)
+			target := postgres.PageRepairTarget{DBNode: dbNode, RelNode: relNode}
+			if cmd.Flags().Changed(pageRepairBlockFlag) {
+				target.Block = &pageRepairBlock
+			}
+			if pageRepairTargetLSN != "" {
+				targetLSN, err := postgres.ParseLSN(pageRepairTargetLSN)
+				tracelog.ErrorLogger.FatalOnError(err)
+				target.TargetLSN = &targetLSN
+			}
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandlePageRepair(storage.RootFolder(), pageRepairBackupName, target,
+				pageRepairOutput, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	pageRepairRelation   string
+	pageRepairBlock      uint32
+	pageRepairBackupName string
+	pageRepairTargetLSN  string
+	pageRepairOutput     string
+)
+
+func init() {
+	Cmd.AddCommand(pageRepairCmd)
+
+	pageRepairCmd.Flags().StringVar(&pageRepairRelation, pageRepairRelationFlag, "",
+		"relation to repair in the database_oid/relfilenode format")
+	pageRepairCmd.Flags().Uint32Var(&pageRepairBlock, pageRepairBlockFlag, 0,
+		"block to repair, the whole relation by default")
+	pageRepairCmd.Flags().StringVar(&pageRepairBackupName, "backup", internal.LatestString,
+		"backup to start from")
+	pageRepairCmd.Flags().StringVar(&pageRepairTargetLSN, "target-lsn", "",
+		"LSN to replay WAL up to, the end of the archived WAL by default")
+	pageRepairCmd.Flags().StringVar(&pageRepairOutput, "output", "",
+		"directory to write the repaired files to")
+	_ = pageRepairCmd.MarkFlagRequired(pageRepairRelationFlag)
+	_ = pageRepairCmd.MarkFlagRequired("output")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/cmd/common/st"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
@@ -60,5 +61,6 @@ func Execute() {
 
 func configureCommand() {
 	common.Init(Cmd, conf.PG)
+	st.SetFsckChecks(postgres.FsckChecks)
 	conf.AddTurboFlag(Cmd)
 }
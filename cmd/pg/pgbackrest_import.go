@@ -0,0 +1,49 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres/pgbackrest"
+)
+
+const pgbackrestImportShortDescription = "Converts pgbackrest backups and WAL to the wal-g format in the same storage"
+
+var (
+	pgbackrestImportCmd = &cobra.Command{
+		Use:   "import",
+		Short: pgbackrestImportShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			folder, stanza := configurePgbackrestSettings()
+			uploader, err := internal.ConfigureUploaderToFolder(folder)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			stagingDirectory := pgbackrestImportStagingDir
+			if stagingDirectory == "" {
+				stagingDirectory = os.TempDir()
+			}
+			_, err = pgbackrest.HandleImport(folder, stanza, uploader, pgbackrestImportBackup, stagingDirectory,
+				!pgbackrestImportSkipWal)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	pgbackrestImportBackup     string
+	pgbackrestImportStagingDir string
+	pgbackrestImportSkipWal    bool
+)
+
+func init() {
+	pgbackrestImportCmd.Flags().StringVar(&pgbackrestImportBackup, "backup", "",
+		"name of the pgbackrest backup to import or LATEST, all backups are imported by default")
+	pgbackrestImportCmd.Flags().StringVar(&pgbackrestImportStagingDir, "staging-dir", "",
+		"directory to restore the backups to before the upload, the system temporary directory by default")
+	pgbackrestImportCmd.Flags().BoolVar(&pgbackrestImportSkipWal, "skip-wal", false,
+		"do not copy the WAL archive")
+
+	pgbackrestCmd.AddCommand(pgbackrestImportCmd)
+}
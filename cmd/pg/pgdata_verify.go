@@ -0,0 +1,33 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const pgDataVerifyShortDescription = "Verifies page headers and checksums of a stopped cluster data directory"
+
+// pgDataVerifyCmd represents the pgdata-verify command
+var pgDataVerifyCmd = &cobra.Command{
+	Use:   "pgdata-verify data_directory",
+	Short: pgDataVerifyShortDescription,
+	Long: "The command reads every relation file of the data directory, including the tablespaces " +
+		"linked from pg_tblspc, and checks the page headers and checksums without starting PostgreSQL. " +
+		"The broken blocks are reported per relation and the command fails if any is found. " +
+		"Pages without checksums are not verified, e.g. when the cluster has data checksums disabled.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := postgres.HandlePgDataVerify(args[0], json, os.Stdout)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(pgDataVerifyCmd)
+
+	pgDataVerifyCmd.Flags().BoolVar(&json, JSONFlag, false,
+		"Prints the report in JSON format")
+}
@@ -0,0 +1,116 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	restoreDrillShortDescription = "Restores a backup into a scratch directory, recovers it and runs checks"
+	restoreDrillRandomFlag       = "random"
+)
+
+var (
+	// restoreDrillCmd represents the restore-drill command
+	restoreDrillCmd = &cobra.Command{
+		Use:   "restore-drill [backup_name | --random | --target-user-data <data>]",
+		Short: restoreDrillShortDescription,
+		Long: "The command fetches the backup into a scratch directory, writes the recovery settings to replay " +
+			"the archived WAL up to the end of the backup or to the recovery target and starts a local server " +
+			"with pg_ctl on a random port. The server accepts only the local connections through the socket " +
+			"in the scratch directory and doesn't archive WAL. After the promotion the SQL checks are run, " +
+			"the report of the drill is stored next to the backup in storage and signed with " +
+			conf.PgRestoreDrillSigningKey + " if it's set. The latest backup is drilled by default.",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			if restoreDrillConfig.RecoveryTargetTime != "" && restoreDrillConfig.RecoveryTargetLSN != "" {
+				tracelog.ErrorLogger.Fatal("only one recovery target should be specified")
+			}
+			selector, err := createRestoreDrillBackupSelector(args)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			restoreCommand, err := restoreDrillRestoreCommand()
+			tracelog.ErrorLogger.FatalOnError(err)
+			restoreDrillConfig.RestoreCommand = restoreCommand
+			restoreDrillConfig.SigningKey = viper.GetString(conf.PgRestoreDrillSigningKey)
+			if restoreDrillConfig.ScratchDirectory == "" {
+				restoreDrillConfig.ScratchDirectory = os.TempDir()
+			}
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandleRestoreDrill(storage.RootFolder(), selector, restoreDrillConfig, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	restoreDrillConfig         postgres.RestoreDrillConfig
+	restoreDrillRandom         bool
+	restoreDrillTargetUserData string
+)
+
+func createRestoreDrillBackupSelector(args []string) (internal.BackupSelector, error) {
+	if restoreDrillRandom {
+		if len(args) > 0 || restoreDrillTargetUserData != "" {
+			return nil, fmt.Errorf("--%s can't be used with the backup name or the user data", restoreDrillRandomFlag)
+		}
+		return internal.NewRandomBackupSelector(), nil
+	}
+	targetName := ""
+	if len(args) > 0 {
+		targetName = args[0]
+	} else if restoreDrillTargetUserData == "" {
+		targetName = internal.LatestString
+	}
+	return internal.NewTargetBackupSelector(restoreDrillTargetUserData, targetName, postgres.NewGenericMetaFetcher())
+}
+
+// restoreDrillRestoreCommand fetches the WAL with the same binary and configuration as the drill
+func restoreDrillRestoreCommand() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	restoreCommand := fmt.Sprintf("%q wal-fetch \"%%f\" \"%%p\"", executable)
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		restoreCommand += fmt.Sprintf(" --config %q", configFile)
+	}
+	return restoreCommand, nil
+}
+
+func init() {
+	Cmd.AddCommand(restoreDrillCmd)
+
+	flags := restoreDrillCmd.Flags()
+	flags.BoolVar(&restoreDrillRandom, restoreDrillRandomFlag, false, "drill a random backup")
+	flags.StringVar(&restoreDrillTargetUserData, "target-user-data", "", targetUserDataDescription)
+	flags.StringVar(&restoreDrillConfig.ScratchDirectory, "scratch-dir", "",
+		"directory to restore the backup to, the system temporary directory by default")
+	flags.StringVar(&restoreDrillConfig.PgBinDirectory, "pg-bin-dir", "",
+		"directory with the PostgreSQL binaries, pg_ctl is searched in PATH by default")
+	flags.StringVar(&restoreDrillConfig.RecoveryTargetTime, "target-time", "",
+		"recovery_target_time to replay WAL up to, the end of the backup by default")
+	flags.StringVar(&restoreDrillConfig.RecoveryTargetLSN, "target-lsn", "",
+		"recovery_target_lsn to replay WAL up to, the end of the backup by default")
+	flags.StringArrayVar(&restoreDrillConfig.PgOptions, "pg-option", nil,
+		"extra server setting in the name=value format, e.g. shared_preload_libraries=")
+	flags.DurationVar(&restoreDrillConfig.Timeout, "timeout", time.Hour,
+		"time to wait for the server to start and to finish the recovery")
+	flags.StringVar(&restoreDrillConfig.Database, "database", "postgres", "database to run the checks in")
+	flags.StringArrayVar(&restoreDrillConfig.Checks, "check", nil,
+		"SQL query to run after the recovery, its first value is recorded in the report")
+	flags.BoolVar(&restoreDrillConfig.Amcheck, "amcheck", false,
+		"check the btree indexes of every database with amcheck")
+	flags.BoolVar(&restoreDrillConfig.KeepScratchData, "keep", false,
+		"keep the scratch directory after the drill")
+}
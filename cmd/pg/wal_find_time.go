@@ -0,0 +1,51 @@
+package pg
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const walFindTimeShortDescription = "Finds the transactions that finished right before and after the time in WAL"
+
+var (
+	// walFindTimeCmd represents the wal-find-time command
+	walFindTimeCmd = &cobra.Command{
+		Use:   "wal-find-time timestamp",
+		Short: walFindTimeShortDescription,
+		Long: "The command searches the archived WAL of every timeline for the commit and abort records " +
+			"and reports the LSN and xid of the last transaction that finished before or at the time " +
+			"and of the first one that finished after it. The timestamp is in the RFC 3339 format, " +
+			"e.g. 2024-03-01T12:00:00Z. Use it to confirm which transaction will be the last one applied " +
+			"by a recovery to the time.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			target, err := time.Parse(time.RFC3339, args[0])
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			timelines := make([]uint32, 0, len(walFindTimeTimelines))
+			for _, timeline := range walFindTimeTimelines {
+				timelines = append(timelines, uint32(timeline))
+			}
+			err = postgres.HandleWalFindTime(storage.RootFolder(), target, timelines, json, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	walFindTimeTimelines []uint
+)
+
+func init() {
+	Cmd.AddCommand(walFindTimeCmd)
+
+	walFindTimeCmd.Flags().UintSliceVar(&walFindTimeTimelines, "timeline", nil,
+		"timelines to search, all timelines found in storage by default")
+	walFindTimeCmd.Flags().BoolVar(&json, JSONFlag, false,
+		"Prints the results in JSON format")
+}
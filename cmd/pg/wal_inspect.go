@@ -0,0 +1,81 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/walparser"
+)
+
+const walInspectShortDescription = "Prints the records of WAL segments from storage"
+
+var (
+	// walInspectCmd represents the wal-inspect command
+	walInspectCmd = &cobra.Command{
+		Use:   "wal-inspect first_segment [last_segment]",
+		Short: walInspectShortDescription,
+		Long: "The command streams the WAL segments from storage and prints their records like pg_waldump does: " +
+			"LSN, resource manager, transaction id, block references and commit timestamps. " +
+			"Neither the segments on local disk nor a PostgreSQL installation are needed.",
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := makeWalInspectFilter(cmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			lastSegment := args[0]
+			if len(args) > 1 {
+				lastSegment = args[1]
+			}
+			err = postgres.HandleWalInspect(storage.RootFolder(), args[0], lastSegment, filter, json, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	walInspectRelation string
+	walInspectXid      uint32
+	walInspectRmgrs    []string
+)
+
+func makeWalInspectFilter(cmd *cobra.Command) (postgres.WalInspectFilter, error) {
+	var filter postgres.WalInspectFilter
+	if walInspectRelation != "" {
+		relation, err := postgres.ParseRelFileNode(walInspectRelation)
+		if err != nil {
+			return filter, err
+		}
+		filter.Relation = &relation
+	}
+	if cmd.Flags().Changed("xid") {
+		filter.Xid = &walInspectXid
+	}
+	if len(walInspectRmgrs) > 0 {
+		filter.ResourceManagers = make(map[uint8]bool)
+		for _, name := range walInspectRmgrs {
+			id, ok := walparser.ResourceManagerIDByName(name)
+			if !ok {
+				return filter, fmt.Errorf("unknown resource manager %q", name)
+			}
+			filter.ResourceManagers[id] = true
+		}
+	}
+	return filter, nil
+}
+
+func init() {
+	Cmd.AddCommand(walInspectCmd)
+
+	walInspectCmd.Flags().StringVar(&walInspectRelation, "relation", "",
+		"only print records that reference the relation, in the tablespace/database/relfilenode format")
+	walInspectCmd.Flags().Uint32Var(&walInspectXid, "xid", 0,
+		"only print records of the transaction")
+	walInspectCmd.Flags().StringSliceVar(&walInspectRmgrs, "rmgr", nil,
+		"only print records of the resource managers, e.g. Heap,Transaction")
+	walInspectCmd.Flags().BoolVar(&json, JSONFlag, false,
+		"Prints a JSON object per record")
+}
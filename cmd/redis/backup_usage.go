@@ -0,0 +1,11 @@
+package redis
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+	"github.com/wal-g/wal-g/internal/databases/redis"
+)
+
+func init() {
+	// backups are marked as permanent, rdb or aof
+	cmd.AddCommand(common.NewBackupUsageCmd(redis.NewBackupUsageAttributor))
+}
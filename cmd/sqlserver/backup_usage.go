@@ -0,0 +1,10 @@
+package sqlserver
+
+import (
+	"github.com/wal-g/wal-g/cmd/common"
+)
+
+func init() {
+	// backups are attributed by the storage layout only
+	cmd.AddCommand(common.NewBackupUsageCmd(nil))
+}
@@ -84,10 +84,23 @@ func (backup *Backup) SentinelExists() (bool, error) {
 
 // TODO : unit tests
 func (backup *Backup) FetchSentinel(sentinelDto interface{}) error {
+	if fetched, err := fetchDtoFromCatalog(backup, sentinelDto, false); fetched || err != nil {
+		return err
+	}
 	return FetchDto(backup.Folder, sentinelDto, backup.getStopSentinelPath())
 }
 
 func (backup *Backup) FetchMetadata(metadataDto interface{}) error {
+	if fetched, err := fetchDtoFromCatalog(backup, metadataDto, true); fetched || err != nil {
+		return err
+	}
+	return FetchDto(backup.Folder, metadataDto, backup.getMetadataPath())
+}
+
+// FetchMetadataFromStorage fetches the metadata bypassing the backup catalog. The catalog can't check the metadata
+// against the storage without fetching it, so the metadata changed bypassing the catalog (e.g. by backup-mark without
+// the catalog enabled) is seen only here. Deciding what to delete or changing the metadata must use it.
+func (backup *Backup) FetchMetadataFromStorage(metadataDto interface{}) error {
 	return FetchDto(backup.Folder, metadataDto, backup.getMetadataPath())
 }
 
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+
+	"github.com/wal-g/wal-g/internal/catalog"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/multistorage"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// readBackupCatalog provides the catalog of the backups folder if the catalogs are enabled. Reading the catalog lists
+// the folder, so it's used in place of listing the folder by the callers that list the backups.
+// Returns nil if the catalog can't be used, so the caller must fall back to listing the folder.
+// The catalog is checked against the listing of the folder, so it has only the backups that are recorded
+// correctly, and the other ones are fetched from the storage.
+func readBackupCatalog(folder storage.Folder) *catalog.Catalog {
+	storageName, ok := backupCatalogStorage(folder)
+	if !ok {
+		return nil
+	}
+	backupCatalog, err := catalog.ReadCached(folder, storageName)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read the backup catalog, falling back to listing: %v", err)
+		return nil
+	}
+	return backupCatalog
+}
+
+// backupCatalogStorage provides the name of the storage of the folder if the catalog of the folder can be used
+func backupCatalogStorage(folder storage.Folder) (string, bool) {
+	if !viper.GetBool(conf.BackupCatalogSetting) || !strings.HasSuffix(folder.GetPath(), utility.BaseBackupPath) {
+		return "", false
+	}
+	storages := multistorage.UsedStorages(folder)
+	if len(storages) != 1 {
+		return "", false
+	}
+	return storages[0], true
+}
+
+// fetchDtoFromCatalog unmarshals the sentinel or metadata of the backup stored in the catalog. Only the catalog read
+// by listing the backups is used, since reading the catalog for a single backup costs more than fetching it.
+// Returns false if the catalog doesn't have it.
+func fetchDtoFromCatalog(backup *Backup, dto interface{}, metadata bool) (bool, error) {
+	storageName, ok := backupCatalogStorage(backup.Folder)
+	if !ok {
+		return false, nil
+	}
+	backupCatalog, ok := catalog.Cached(backup.Folder, storageName)
+	if !ok {
+		return false, nil
+	}
+	entry, ok := backupCatalog.Entry(backup.Name)
+	if !ok {
+		return false, nil
+	}
+	data := entry.Sentinel
+	if metadata {
+		data = entry.Metadata
+	}
+	if data == nil {
+		return false, nil
+	}
+	unmarshaller, err := NewDtoSerializer()
+	if err != nil {
+		return false, err
+	}
+	err = unmarshaller.Unmarshal(bytes.NewReader(data), dto)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch dto of backup %s from the backup catalog: %w", backup.Name, err)
+	}
+	return true, nil
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"math/rand"
 	"reflect"
 	"strings"
 
@@ -34,6 +35,26 @@ func (s LatestBackupSelector) Select(folder storage.Folder) (Backup, error) {
 	return GetLatestBackup(folder.GetSubFolder(utility.BaseBackupPath))
 }
 
+// RandomBackupSelector selects a random backup from storage
+type RandomBackupSelector struct {
+}
+
+func NewRandomBackupSelector() RandomBackupSelector {
+	return RandomBackupSelector{}
+}
+
+func (s RandomBackupSelector) Select(folder storage.Folder) (Backup, error) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	backupTimes, err := GetBackups(baseBackupFolder)
+	if err != nil {
+		return Backup{}, err
+	}
+	chosen := backupTimes[rand.Intn(len(backupTimes))]
+	tracelog.InfoLogger.Printf("Random backup is: '%s'\n", chosen.BackupName)
+
+	return NewBackupInStorage(baseBackupFolder, chosen.BackupName, chosen.StorageName)
+}
+
 // UserDataBackupSelector selects a backup which has the provided user data
 type UserDataBackupSelector struct {
 	userData    interface{}
@@ -95,6 +95,25 @@ func TestLatestBackupSelector_ignoreSubFolders(t *testing.T) {
 	assert.Equal(t, testLatestBackup.BackupName+".2", latestBackup.Name)
 }
 
+func TestRandomBackupSelector_emptyFolder(t *testing.T) {
+	backupSelector := internal.NewRandomBackupSelector()
+	checkEmptyFolderBehaviour(t, backupSelector)
+}
+
+func TestRandomBackupSelector(t *testing.T) {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	b1 := path.Join(utility.BaseBackupPath, testLatestBackup.BackupName+".1"+utility.SentinelSuffix)
+	b2 := path.Join(utility.BaseBackupPath, testLatestBackup.BackupName+".2"+utility.SentinelSuffix)
+	_ = folder.PutObject(b1, &bytes.Buffer{})
+	_ = folder.PutObject(b2, &bytes.Buffer{})
+
+	backupSelector := internal.NewRandomBackupSelector()
+	randomBackup, err := backupSelector.Select(folder)
+
+	assert.NoError(t, err)
+	assert.Contains(t, []string{testLatestBackup.BackupName + ".1", testLatestBackup.BackupName + ".2"}, randomBackup.Name)
+}
+
 func TestOldestNonPermanentSelector(t *testing.T) {
 	folder := testtools.MakeDefaultInMemoryStorageFolder()
 
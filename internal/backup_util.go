@@ -93,12 +93,17 @@ func GetBackupSentinelObjects(folder storage.Folder) ([]storage.Object, error) {
 }
 
 // GetBackups receives all backup descriptions from the folder.
+// If the backup catalog is enabled, the folder is listed once to check the catalog too.
 func GetBackups(folder storage.Folder) (backups []BackupTime, err error) {
-	backupObjects, _, err := folder.ListFolder()
-	if err != nil {
-		return nil, err
+	var backupObjects []storage.Object
+	if backupCatalog := readBackupCatalog(folder); backupCatalog != nil {
+		backupObjects = backupCatalog.Listing()
+	} else {
+		backupObjects, _, err = folder.ListFolder()
+		if err != nil {
+			return nil, err
+		}
 	}
-
 	backups = GetBackupTimeSlices(backupObjects)
 
 	count := len(backups)
@@ -5,8 +5,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/catalog"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/testtools"
@@ -41,6 +45,67 @@ func TestGetBackups(t *testing.T) {
 	assert.Equal(t, testStreamBackup.BackupName, backups[0].BackupName)
 }
 
+func TestGetBackups_FromCatalog(t *testing.T) {
+	viper.Set(conf.BackupCatalogSetting, true)
+	defer viper.Set(conf.BackupCatalogSetting, false)
+	folder := testtools.MakeDefaultInMemoryStorageFolder().GetSubFolder(utility.BaseBackupPath)
+	_ = folder.PutObject("base_listed"+utility.SentinelSuffix, &bytes.Buffer{})
+	// the recorded sentinel differs from the stored one, so it's seen whether the catalog is used
+	_ = folder.PutObject("base_cataloged"+utility.SentinelSuffix, bytes.NewBufferString(`{"IsPermanent":false}`))
+	require.NoError(t, catalog.Append(folder,
+		catalog.NewUploadRecord(catalog.OpSentinel, "base_cataloged", []byte(`{"IsPermanent": true}`)),
+		catalog.NewUploadRecord(catalog.OpSentinel, "base_deleted", []byte(`{}`))))
+
+	backups, err := internal.GetBackups(folder)
+	require.NoError(t, err)
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.BackupName)
+		assert.Equal(t, "default", backup.StorageName)
+	}
+	assert.ElementsMatch(t, []string{"base_listed", "base_cataloged"}, names)
+
+	backup, err := internal.NewBackup(folder, "base_cataloged")
+	require.NoError(t, err)
+	var sentinel struct{ IsPermanent bool }
+	require.NoError(t, backup.FetchSentinel(&sentinel))
+	assert.True(t, sentinel.IsPermanent)
+}
+
+func TestFetchMetadataFromStorage_IgnoresCatalog(t *testing.T) {
+	viper.Set(conf.BackupCatalogSetting, true)
+	defer viper.Set(conf.BackupCatalogSetting, false)
+	folder := testtools.MakeDefaultInMemoryStorageFolder().GetSubFolder(utility.BaseBackupPath)
+	_ = folder.PutObject("base_marked"+utility.SentinelSuffix, bytes.NewBufferString(`{}`))
+	// the permanence was changed by backup-mark without the catalog, so the recorded one is stale
+	_ = folder.PutObject("base_marked/"+utility.MetadataFileName, bytes.NewBufferString(`{"IsPermanent":true}`))
+	require.NoError(t, catalog.Append(folder,
+		catalog.NewUploadRecord(catalog.OpSentinel, "base_marked", []byte(`{}`)),
+		catalog.NewUploadRecord(catalog.OpMetadata, "base_marked", []byte(`{"IsPermanent":false}`))))
+	_, err := internal.GetBackups(folder)
+	require.NoError(t, err)
+
+	backup, err := internal.NewBackup(folder, "base_marked")
+	require.NoError(t, err)
+	var metadata struct{ IsPermanent bool }
+	require.NoError(t, backup.FetchMetadata(&metadata))
+	assert.False(t, metadata.IsPermanent)
+	require.NoError(t, backup.FetchMetadataFromStorage(&metadata))
+	assert.True(t, metadata.IsPermanent)
+}
+
+func TestGetBackups_WithoutCatalog(t *testing.T) {
+	viper.Set(conf.BackupCatalogSetting, true)
+	defer viper.Set(conf.BackupCatalogSetting, false)
+	folder := testtools.MakeDefaultInMemoryStorageFolder().GetSubFolder("seg0/" + utility.BaseBackupPath)
+	_ = folder.PutObject("base_listed"+utility.SentinelSuffix, &bytes.Buffer{})
+
+	backups, err := internal.GetBackups(folder)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(backups))
+	assert.Equal(t, "base_listed", backups[0].BackupName)
+}
+
 func TestGetBackupsAndGarbage_emptyList(t *testing.T) {
 	folder := testtools.MakeDefaultInMemoryStorageFolder()
 	_ = folder.PutObject("base_123312", &bytes.Buffer{})
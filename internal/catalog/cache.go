@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// cacheTTL is the time the catalog and the listing it's checked against are reused, so the changes made by other
+// processes are seen by the long-running ones
+const cacheTTL = time.Minute
+
+type cachedCatalog struct {
+	catalog *Catalog
+	readAt  time.Time
+}
+
+// cache keeps the catalogs that are read by the process, so the sentinels and metadata of the listed backups aren't
+// fetched one by one. It's reset when the process changes any catalog.
+var cache = struct {
+	sync.Mutex
+	catalogs map[string]cachedCatalog
+}{catalogs: map[string]cachedCatalog{}}
+
+// ReadCached is Read that caches the catalog by the storage name and the folder path
+func ReadCached(folder storage.Folder, storageName string) (*Catalog, error) {
+	key := storageName + ":" + folder.GetPath()
+	cache.Lock()
+	defer cache.Unlock()
+	if cached, ok := cache.catalogs[key]; ok && time.Since(cached.readAt) < cacheTTL {
+		return cached.catalog, nil
+	}
+	catalog, err := Read(folder)
+	if err != nil {
+		return nil, err
+	}
+	cache.catalogs[key] = cachedCatalog{catalog: catalog, readAt: time.Now()}
+	return catalog, nil
+}
+
+// Cached provides the catalog cached by ReadCached, it doesn't read the catalog if it isn't cached
+func Cached(folder storage.Folder, storageName string) (*Catalog, bool) {
+	key := storageName + ":" + folder.GetPath()
+	cache.Lock()
+	defer cache.Unlock()
+	cached, ok := cache.catalogs[key]
+	if !ok || time.Since(cached.readAt) >= cacheTTL {
+		return nil, false
+	}
+	return cached.catalog, true
+}
+
+func resetCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.catalogs = map[string]cachedCatalog{}
+}
@@ -0,0 +1,591 @@
+package catalog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// ObjectPrefix is the name prefix of the catalog objects in the backups folder. Every change is written
+	// to a new object, so the processes changing the catalog concurrently never overwrite each other's records.
+	ObjectPrefix    = "walg_backup_catalog"
+	objectExtension = ".jsonl"
+
+	// compactionThreshold is the number of catalog objects after which they are merged into one
+	compactionThreshold = 32
+
+	// MaxInlineSize is the maximum size of the sentinel or metadata that is stored in the catalog.
+	// Larger files (e.g. the sentinels of old PostgreSQL backups with the files metadata inside) are only summarized.
+	MaxInlineSize = 1 << 20
+)
+
+type Op string
+
+const (
+	OpSentinel Op = "sentinel"
+	OpMetadata Op = "metadata"
+	OpDelete   Op = "delete"
+)
+
+// Record is a change of the backups folder: the sentinel or the metadata of a backup is uploaded,
+// or the backup is deleted. The catalog objects keep the records, one JSON per line.
+type Record struct {
+	Op Op `json:"op"`
+	// Time orders the records. It's the last modified time of the catalog object the record is written to,
+	// or of the uploaded object itself if the record is made by listing the backups folder.
+	// It's set on reading and kept by the compaction.
+	Time       time.Time `json:"time,omitempty"`
+	BackupName string    `json:"backup_name"`
+	// ObjectSize is the size of the uploaded object
+	ObjectSize int64 `json:"object_size,omitempty"`
+	// Data is the content of the uploaded object, it's omitted if the object is larger than MaxInlineSize
+	Data    json.RawMessage `json:"data,omitempty"`
+	Summary *Summary        `json:"summary,omitempty"`
+}
+
+// Summary is the information about the backup which is common for all databases
+type Summary struct {
+	StartTime        time.Time `json:"start_time,omitempty"`
+	FinishTime       time.Time `json:"finish_time,omitempty"`
+	UncompressedSize int64     `json:"uncompressed_size,omitempty"`
+	CompressedSize   int64     `json:"compressed_size,omitempty"`
+	StartLSN         string    `json:"start_lsn,omitempty"`
+	FinishLSN        string    `json:"finish_lsn,omitempty"`
+	IsPermanent      *bool     `json:"is_permanent,omitempty"`
+	// DependsOn is the backup that the delta backup is made from
+	DependsOn string `json:"depends_on,omitempty"`
+}
+
+// summaryFields are the names of the sentinel and metadata fields of the different databases
+var summaryFields = struct {
+	startTime, finishTime, uncompressedSize, compressedSize, startLSN, finishLSN, isPermanent, dependsOn []string
+}{
+	startTime:        []string{"start_time", "StartTime", "StartLocalTime"},
+	finishTime:       []string{"finish_time", "FinishTime", "StopLocalTime", "FinishLocalTime"},
+	uncompressedSize: []string{"uncompressed_size", "UncompressedSize"},
+	compressedSize:   []string{"compressed_size", "CompressedSize", "DataSize"},
+	startLSN:         []string{"start_lsn", "LSN", "BinLogStart"},
+	finishLSN:        []string{"finish_lsn", "FinishLSN", "BinLogEnd"},
+	isPermanent:      []string{"is_permanent", "IsPermanent", "Permanent"},
+	dependsOn:        []string{"DeltaFrom", "IncrementFrom"},
+}
+
+// Summarize extracts the summary from the sentinel or metadata JSON. Unknown and malformed fields are skipped.
+func Summarize(data []byte) *Summary {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+	lookup := func(names []string) json.RawMessage {
+		for _, name := range names {
+			if value, ok := fields[name]; ok && string(value) != "null" {
+				return value
+			}
+		}
+		return nil
+	}
+
+	summary := &Summary{}
+	if value := lookup(summaryFields.startTime); value != nil {
+		_ = json.Unmarshal(value, &summary.StartTime)
+	}
+	if value := lookup(summaryFields.finishTime); value != nil {
+		_ = json.Unmarshal(value, &summary.FinishTime)
+	}
+	if value := lookup(summaryFields.uncompressedSize); value != nil {
+		_ = json.Unmarshal(value, &summary.UncompressedSize)
+	}
+	if value := lookup(summaryFields.compressedSize); value != nil {
+		_ = json.Unmarshal(value, &summary.CompressedSize)
+	}
+	summary.StartLSN = rawString(lookup(summaryFields.startLSN))
+	summary.FinishLSN = rawString(lookup(summaryFields.finishLSN))
+	if value := lookup(summaryFields.isPermanent); value != nil {
+		var isPermanent bool
+		if json.Unmarshal(value, &isPermanent) == nil {
+			summary.IsPermanent = &isPermanent
+		}
+	}
+	summary.DependsOn = rawString(lookup(summaryFields.dependsOn))
+	return summary
+}
+
+// rawString formats the JSON string or number as a string
+func rawString(value json.RawMessage) string {
+	if value == nil {
+		return ""
+	}
+	var str string
+	if json.Unmarshal(value, &str) == nil {
+		return str
+	}
+	var number json.Number
+	if json.Unmarshal(value, &number) == nil {
+		return number.String()
+	}
+	return ""
+}
+
+// merge overrides the summary fields with the ones that are set in other
+func (s Summary) merge(other *Summary) Summary {
+	if other == nil {
+		return s
+	}
+	if !other.StartTime.IsZero() {
+		s.StartTime = other.StartTime
+	}
+	if !other.FinishTime.IsZero() {
+		s.FinishTime = other.FinishTime
+	}
+	if other.UncompressedSize != 0 {
+		s.UncompressedSize = other.UncompressedSize
+	}
+	if other.CompressedSize != 0 {
+		s.CompressedSize = other.CompressedSize
+	}
+	if other.StartLSN != "" {
+		s.StartLSN = other.StartLSN
+	}
+	if other.FinishLSN != "" {
+		s.FinishLSN = other.FinishLSN
+	}
+	if other.IsPermanent != nil {
+		s.IsPermanent = other.IsPermanent
+	}
+	if other.DependsOn != "" {
+		s.DependsOn = other.DependsOn
+	}
+	return s
+}
+
+// Entry is the current state of a backup in the catalog
+type Entry struct {
+	BackupName string
+	// SentinelTime is the last modified time of the sentinel in the storage
+	SentinelTime time.Time
+	SentinelSize int64
+	// Sentinel and Metadata are nil if they aren't stored in the catalog
+	Sentinel json.RawMessage
+	Metadata json.RawMessage
+
+	sentinelSummary    *Summary
+	metadataSummary    *Summary
+	sentinelRecordTime time.Time
+	metadataRecordTime time.Time
+	hasSentinel        bool
+	hasMetadata        bool
+}
+
+// Summary merges the summaries of the sentinel and the metadata, the metadata is preferred
+// since it's updated when the backup is marked
+func (entry *Entry) Summary() Summary {
+	return Summary{}.merge(entry.sentinelSummary).merge(entry.metadataSummary)
+}
+
+func (entry *Entry) SentinelName() string {
+	return entry.BackupName + utility.SentinelSuffix
+}
+
+// Catalog is the state of the backups folder replayed from the records. The backups are checked against
+// the listing of the backups folder, so the backups missing in the catalog or changed bypassing it
+// are fetched from the storage by the callers.
+type Catalog struct {
+	entries map[string]*Entry
+	records int
+	// objects are the catalog objects the records are read from
+	objects []string
+	// listing is the listing of the backups folder the catalog is checked against
+	listing []storage.Object
+}
+
+func New() *Catalog {
+	return &Catalog{entries: map[string]*Entry{}}
+}
+
+// ParseRecords parses the records of a catalog object
+func ParseRecords(reader io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(reader)
+	for {
+		var record Record
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse record %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// Apply changes the state of the catalog by the record. The records must be applied in the order of their time.
+func (catalog *Catalog) Apply(record Record) {
+	catalog.records++
+	entry, ok := catalog.entries[record.BackupName]
+	if !ok {
+		entry = &Entry{BackupName: record.BackupName}
+	}
+	switch record.Op {
+	case OpSentinel:
+		entry.hasSentinel = true
+		entry.SentinelTime = record.Time
+		entry.SentinelSize = record.ObjectSize
+		entry.Sentinel = record.Data
+		entry.sentinelSummary = record.Summary
+		entry.sentinelRecordTime = record.Time
+	case OpMetadata:
+		entry.hasMetadata = true
+		entry.Metadata = record.Data
+		entry.metadataSummary = record.Summary
+		entry.metadataRecordTime = record.Time
+	case OpDelete:
+		delete(catalog.entries, record.BackupName)
+		return
+	default:
+		return
+	}
+	catalog.entries[record.BackupName] = entry
+}
+
+func (catalog *Catalog) applyInOrder(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	for _, record := range records {
+		catalog.Apply(record)
+	}
+}
+
+// crossCheck leaves only the backups whose sentinel in the listing is the one recorded: it has the same size and
+// isn't modified after the record. The sentinel time is taken from the listing. The backups without the sentinel
+// record are kept, since their metadata is uploaded before the sentinel.
+func (catalog *Catalog) crossCheck(listing []storage.Object) {
+	catalog.listing = listing
+	sentinels := map[string]storage.Object{}
+	for _, object := range listing {
+		if strings.HasSuffix(object.GetName(), utility.SentinelSuffix) {
+			sentinels[strings.TrimSuffix(object.GetName(), utility.SentinelSuffix)] = object
+		}
+	}
+	for name, entry := range catalog.entries {
+		if !entry.hasSentinel {
+			continue
+		}
+		sentinel, ok := sentinels[name]
+		if !ok || sentinel.GetSize() != entry.SentinelSize || entry.sentinelRecordTime.Before(sentinel.GetLastModified()) {
+			// the backup is deleted or changed bypassing the catalog, so its metadata isn't reliable either
+			delete(catalog.entries, name)
+			continue
+		}
+		entry.SentinelTime = sentinel.GetLastModified()
+	}
+}
+
+// Entries provides the backups that have the sentinel, ordered by the sentinel time
+func (catalog *Catalog) Entries() []*Entry {
+	entries := make([]*Entry, 0, len(catalog.entries))
+	for _, entry := range catalog.entries {
+		if entry.hasSentinel {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].SentinelTime.Equal(entries[j].SentinelTime) {
+			return entries[i].SentinelTime.Before(entries[j].SentinelTime)
+		}
+		return entries[i].BackupName < entries[j].BackupName
+	})
+	return entries
+}
+
+// Entry provides the backup if it has the sentinel
+func (catalog *Catalog) Entry(backupName string) (*Entry, bool) {
+	entry, ok := catalog.entries[backupName]
+	if !ok || !entry.hasSentinel {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Listing provides the listing of the backups folder the catalog is checked against
+func (catalog *Catalog) Listing() []storage.Object {
+	return catalog.listing
+}
+
+// Records provides the minimal records that reproduce the current state of the catalog
+func (catalog *Catalog) Records() []Record {
+	names := make([]string, 0, len(catalog.entries))
+	for name := range catalog.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var records []Record
+	for _, name := range names {
+		entry := catalog.entries[name]
+		if entry.hasSentinel {
+			records = append(records, Record{
+				Op:         OpSentinel,
+				Time:       entry.sentinelRecordTime,
+				BackupName: name,
+				ObjectSize: entry.SentinelSize,
+				Data:       entry.Sentinel,
+				Summary:    entry.sentinelSummary,
+			})
+		}
+		if entry.hasMetadata {
+			records = append(records, Record{
+				Op:         OpMetadata,
+				Time:       entry.metadataRecordTime,
+				BackupName: name,
+				Data:       entry.Metadata,
+				Summary:    entry.metadataSummary,
+			})
+		}
+	}
+	return records
+}
+
+// NewUploadRecord makes the record of the uploaded sentinel or metadata
+func NewUploadRecord(op Op, backupName string, data []byte) Record {
+	record := Record{
+		Op:         op,
+		BackupName: backupName,
+		ObjectSize: int64(len(data)),
+		Summary:    Summarize(data),
+	}
+	if len(data) <= MaxInlineSize && json.Valid(data) {
+		record.Data = compactJSON(data)
+	}
+	return record
+}
+
+func compactJSON(data []byte) json.RawMessage {
+	var buffer bytes.Buffer
+	if json.Compact(&buffer, data) != nil {
+		return nil
+	}
+	return buffer.Bytes()
+}
+
+func marshalRecords(records []Record) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("marshal record of backup %s: %w", record.BackupName, err)
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
+
+func isCatalogObject(name string) bool {
+	return strings.HasPrefix(name, ObjectPrefix) && strings.HasSuffix(name, objectExtension)
+}
+
+func newObjectName() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s_%020d_%s%s", ObjectPrefix, time.Now().UnixNano(), hex.EncodeToString(suffix), objectExtension)
+}
+
+// readAttempts is the number of times the catalog is read if its objects are merged concurrently
+const readAttempts = 3
+
+var errObjectsMerged = errors.New("backup catalog objects are merged concurrently")
+
+// Read lists the backups folder, reads the catalog objects found and checks the catalog against the listing
+func Read(folder storage.Folder) (*Catalog, error) {
+	for attempt := 1; ; attempt++ {
+		catalog, err := read(folder)
+		if errors.Is(err, errObjectsMerged) && attempt < readAttempts {
+			continue
+		}
+		return catalog, err
+	}
+}
+
+func read(folder storage.Folder) (*Catalog, error) {
+	listing, _, err := folder.ListFolder()
+	if err != nil {
+		return nil, fmt.Errorf("list backups folder: %w", err)
+	}
+	catalog := New()
+	var records []Record
+	for _, object := range listing {
+		if !isCatalogObject(object.GetName()) {
+			continue
+		}
+		objectRecords, err := readRecords(folder, object)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			// the records of the object are merged into the one that isn't listed,
+			// so the newer records would be missed
+			return nil, errObjectsMerged
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, objectRecords...)
+		catalog.objects = append(catalog.objects, object.GetName())
+	}
+	catalog.applyInOrder(records)
+	catalog.crossCheck(listing)
+	return catalog, nil
+}
+
+func readRecords(folder storage.Folder, object storage.Object) ([]Record, error) {
+	data, err := readObject(folder, object.GetName())
+	if err != nil {
+		return nil, err
+	}
+	records, err := ParseRecords(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("backup catalog object %s is corrupted: %w", object.GetName(), err)
+	}
+	for i := range records {
+		if records[i].Time.IsZero() {
+			records[i].Time = object.GetLastModified()
+		}
+	}
+	return records, nil
+}
+
+// Append writes the records to a new catalog object in the backups folder.
+// The catalog objects are merged if there are too many of them.
+func Append(folder storage.Folder, records ...Record) error {
+	content, err := marshalRecords(records)
+	if err != nil {
+		return err
+	}
+	err = folder.PutObject(newObjectName(), bytes.NewReader(content))
+	resetCache()
+	if err != nil {
+		return fmt.Errorf("upload backup catalog records: %w", err)
+	}
+
+	// the records are written already, so the catalog is only less efficient if it can't be compacted
+	err = compact(folder)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to compact the backup catalog: %v", err)
+	}
+	return nil
+}
+
+// compact merges the catalog objects if there are too many of them
+func compact(folder storage.Folder) error {
+	listing, _, err := folder.ListFolder()
+	if err != nil {
+		return fmt.Errorf("list backups folder: %w", err)
+	}
+	objectsNum := 0
+	for _, object := range listing {
+		if isCatalogObject(object.GetName()) {
+			objectsNum++
+		}
+	}
+	if objectsNum <= compactionThreshold {
+		return nil
+	}
+	catalog, err := Read(folder)
+	if err != nil {
+		return err
+	}
+	return Write(folder, catalog)
+}
+
+// Write merges the records of the catalog into a new catalog object and deletes the objects they are read from.
+// The records written concurrently are kept in their objects, since only the objects that have been read are deleted.
+func Write(folder storage.Folder, catalog *Catalog) error {
+	content, err := marshalRecords(catalog.Records())
+	if err != nil {
+		return err
+	}
+	err = folder.PutObject(newObjectName(), bytes.NewReader(content))
+	resetCache()
+	if err != nil {
+		return fmt.Errorf("upload backup catalog: %w", err)
+	}
+	if len(catalog.objects) == 0 {
+		return nil
+	}
+	err = folder.DeleteObjects(catalog.objects)
+	if err != nil {
+		return fmt.Errorf("delete merged backup catalog objects: %w", err)
+	}
+	return nil
+}
+
+// Rebuild lists the backups folder and makes the catalog of the sentinels and metadata found.
+// Writing the rebuilt catalog replaces the existing catalog objects.
+func Rebuild(folder storage.Folder) (*Catalog, error) {
+	listing, subFolders, err := folder.ListFolder()
+	if err != nil {
+		return nil, fmt.Errorf("list backups folder: %w", err)
+	}
+	catalog := New()
+	for _, object := range listing {
+		if isCatalogObject(object.GetName()) {
+			catalog.objects = append(catalog.objects, object.GetName())
+			continue
+		}
+		if !strings.HasSuffix(object.GetName(), utility.SentinelSuffix) {
+			continue
+		}
+		backupName := strings.TrimSuffix(object.GetName(), utility.SentinelSuffix)
+		data, err := readObject(folder, object.GetName())
+		if err != nil {
+			return nil, fmt.Errorf("read sentinel of backup %s: %w", backupName, err)
+		}
+		record := NewUploadRecord(OpSentinel, backupName, data)
+		record.Time = object.GetLastModified()
+		catalog.Apply(record)
+	}
+	for _, subFolder := range subFolders {
+		backupName := utility.StripPrefixName(subFolder.GetPath())
+		if _, ok := catalog.Entry(backupName); !ok {
+			continue
+		}
+		objects, _, err := subFolder.ListFolder()
+		if err != nil {
+			return nil, fmt.Errorf("list folder of backup %s: %w", backupName, err)
+		}
+		for _, object := range objects {
+			if object.GetName() != utility.MetadataFileName {
+				continue
+			}
+			data, err := readObject(subFolder, utility.MetadataFileName)
+			if err != nil {
+				return nil, fmt.Errorf("read metadata of backup %s: %w", backupName, err)
+			}
+			record := NewUploadRecord(OpMetadata, backupName, data)
+			record.Time = object.GetLastModified()
+			catalog.Apply(record)
+		}
+	}
+	catalog.crossCheck(listing)
+	return catalog, nil
+}
+
+func readObject(folder storage.Folder, name string) ([]byte, error) {
+	reader, err := folder.ReadObject(name)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "failed to close object "+name)
+	return io.ReadAll(reader)
+}
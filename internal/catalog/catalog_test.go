@@ -0,0 +1,228 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	pgSentinel = `{"LSN":16777256,"FinishLSN":33554432,"DeltaFrom":"base_000000010000000000000001",` +
+		`"UncompressedSize":100,"CompressedSize":10,"IsPermanent":false,` +
+		`"StartTime":"2024-01-01T00:00:00Z","FinishTime":"2024-01-01T01:00:00Z"}`
+	pgMetadata = `{"start_time":"2024-01-01T00:00:00Z","is_permanent":true}`
+)
+
+func putObject(t *testing.T, folder storage.Folder, name, content string) {
+	require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+}
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]byte(pgSentinel))
+	require.NotNil(t, summary)
+	assert.Equal(t, "16777256", summary.StartLSN)
+	assert.Equal(t, "33554432", summary.FinishLSN)
+	assert.Equal(t, "base_000000010000000000000001", summary.DependsOn)
+	assert.Equal(t, int64(100), summary.UncompressedSize)
+	assert.Equal(t, int64(10), summary.CompressedSize)
+	assert.Equal(t, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), summary.FinishTime)
+	require.NotNil(t, summary.IsPermanent)
+	assert.False(t, *summary.IsPermanent)
+
+	summary = Summarize([]byte(`{"BinLogStart":"mysql-bin.000001","Permanent":true,"DataSize":5}`))
+	require.NotNil(t, summary)
+	assert.Equal(t, "mysql-bin.000001", summary.StartLSN)
+	assert.Equal(t, int64(5), summary.CompressedSize)
+	assert.True(t, *summary.IsPermanent)
+
+	assert.Nil(t, Summarize([]byte("not a json")))
+}
+
+func TestCatalog_Apply(t *testing.T) {
+	catalog := New()
+	catalog.Apply(NewUploadRecord(OpMetadata, "base_2", []byte(pgMetadata)))
+	assert.Empty(t, catalog.Entries(), "backups without sentinels aren't listed")
+
+	catalog.applyInOrder([]Record{
+		withTime(NewUploadRecord(OpSentinel, "base_2", []byte(pgSentinel)), time.Unix(200, 0)),
+		withTime(NewUploadRecord(OpSentinel, "base_1", []byte(`{}`)), time.Unix(100, 0)),
+	})
+	entries := catalog.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "base_1", entries[0].BackupName)
+	assert.Equal(t, "base_2", entries[1].BackupName)
+	assert.True(t, *entries[1].Summary().IsPermanent, "metadata overrides the sentinel")
+	assert.JSONEq(t, pgMetadata, string(entries[1].Metadata))
+
+	catalog.Apply(Record{Op: OpDelete, BackupName: "base_1"})
+	_, ok := catalog.Entry("base_1")
+	assert.False(t, ok)
+	assert.Len(t, catalog.Entries(), 1)
+}
+
+func withTime(record Record, recordTime time.Time) Record {
+	record.Time = recordTime
+	return record
+}
+
+func TestCatalog_RecordsRoundTrip(t *testing.T) {
+	catalog := New()
+	catalog.applyInOrder([]Record{
+		withTime(NewUploadRecord(OpSentinel, "base_1", []byte(pgSentinel)), time.Unix(100, 0).UTC()),
+		withTime(NewUploadRecord(OpMetadata, "base_1", []byte(pgMetadata)), time.Unix(150, 0).UTC()),
+		withTime(NewUploadRecord(OpSentinel, "base_2", []byte(`{}`)), time.Unix(200, 0).UTC()),
+		withTime(Record{Op: OpDelete, BackupName: "base_2"}, time.Unix(300, 0).UTC()),
+	})
+
+	content, err := marshalRecords(catalog.Records())
+	require.NoError(t, err)
+	records, err := ParseRecords(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	parsed := New()
+	parsed.applyInOrder(records)
+	entry, ok := parsed.Entry("base_1")
+	require.True(t, ok)
+	assert.Equal(t, time.Unix(100, 0).UTC(), entry.SentinelTime)
+	assert.JSONEq(t, pgSentinel, string(entry.Sentinel))
+	assert.True(t, *entry.Summary().IsPermanent)
+}
+
+func TestParseRecords_Corrupted(t *testing.T) {
+	_, err := ParseRecords(strings.NewReader(`{"op":"sentinel","backup_name":"base_1"}` + "\n{broken"))
+	assert.Error(t, err)
+}
+
+func TestNewUploadRecord_LargeData(t *testing.T) {
+	data, err := json.Marshal(map[string]string{"StartTime": "2024-01-01T00:00:00Z", "Files": strings.Repeat("x", MaxInlineSize)})
+	require.NoError(t, err)
+	record := NewUploadRecord(OpSentinel, "base_1", data)
+	assert.Nil(t, record.Data)
+	assert.Equal(t, int64(len(data)), record.ObjectSize)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), record.Summary.StartTime)
+}
+
+func TestFolder_UpdatesCatalog(t *testing.T) {
+	root := memory.NewFolder("test/", memory.NewKVS())
+	putObject(t, root, "basebackups_005/base_1_backup_stop_sentinel.json", `{}`)
+	rebuilt, err := Rebuild(root.GetSubFolder("basebackups_005"))
+	require.NoError(t, err)
+	require.NoError(t, Write(root.GetSubFolder("basebackups_005"), rebuilt))
+
+	folder := NewFolder(root)
+	backupsFolder := folder.GetSubFolder("basebackups_005")
+	putObject(t, backupsFolder, "base_2/metadata.json", pgMetadata)
+	putObject(t, backupsFolder, "base_2/tar_partitions/part_1.tar.br", "data")
+	putObject(t, backupsFolder, "base_2_backup_stop_sentinel.json", pgSentinel)
+	putObject(t, folder, "wal_005/000000010000000000000001.br", "wal")
+
+	catalog, err := Read(root.GetSubFolder("basebackups_005"))
+	require.NoError(t, err)
+	require.Len(t, catalog.Entries(), 2)
+	entry, ok := catalog.Entry("base_2")
+	require.True(t, ok)
+	assert.JSONEq(t, pgSentinel, string(entry.Sentinel))
+	assert.JSONEq(t, pgMetadata, string(entry.Metadata))
+	assert.Equal(t, 3, catalog.records)
+	assert.Len(t, catalog.objects, 3, "every change is written to a new object")
+
+	require.NoError(t, backupsFolder.CopyObject("base_2_backup_stop_sentinel.json", "base_3_backup_stop_sentinel.json"))
+	require.NoError(t, folder.DeleteObjects([]string{"basebackups_005/base_1_backup_stop_sentinel.json"}))
+
+	catalog, err = Read(root.GetSubFolder("basebackups_005"))
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range catalog.Entries() {
+		names = append(names, entry.BackupName)
+	}
+	assert.ElementsMatch(t, []string{"base_2", "base_3"}, names)
+}
+
+func TestRead_CrossChecksListing(t *testing.T) {
+	now := time.Now()
+	root := memory.NewFolder("test/", memory.NewKVS(memory.WithCustomTime(func() time.Time { return now })))
+	folder := NewFolder(root).GetSubFolder("basebackups_005")
+	putObject(t, folder, "base_1_backup_stop_sentinel.json", `{}`)
+	putObject(t, folder, "base_2_backup_stop_sentinel.json", `{}`)
+	putObject(t, folder, "base_2/metadata.json", pgMetadata)
+	putObject(t, folder, "base_3_backup_stop_sentinel.json", `{}`)
+	putObject(t, folder, "base_3/metadata.json", pgMetadata)
+
+	// the backups are changed bypassing the catalog
+	now = now.Add(time.Minute)
+	require.NoError(t, root.DeleteObjects([]string{"basebackups_005/base_1_backup_stop_sentinel.json"}))
+	putObject(t, root, "basebackups_005/base_2_backup_stop_sentinel.json", `{"changed":true}`)
+	putObject(t, root, "basebackups_005/base_3_backup_stop_sentinel.json", `{}`)
+	putObject(t, root, "basebackups_005/base_4_backup_stop_sentinel.json", `{}`)
+
+	catalog, err := Read(root.GetSubFolder("basebackups_005"))
+	require.NoError(t, err)
+	assert.Empty(t, catalog.Entries(), "the changed backups are fetched from the storage")
+	assert.Len(t, catalog.Listing(), 3+len(catalog.objects))
+}
+
+func TestFolder_FailsIfMetadataIsNotRecorded(t *testing.T) {
+	root := memory.NewFolder("test/", memory.NewKVS())
+	folder := NewFolder(&failingPutFolder{Folder: root, failOn: ObjectPrefix})
+	backupsFolder := folder.GetSubFolder("basebackups_005")
+
+	assert.NoError(t, backupsFolder.PutObject("base_1_backup_stop_sentinel.json", strings.NewReader(`{}`)))
+	assert.Error(t, backupsFolder.PutObject("base_1/metadata.json", strings.NewReader(pgMetadata)))
+}
+
+type failingPutFolder struct {
+	storage.Folder
+	failOn string
+}
+
+func (f *failingPutFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &failingPutFolder{Folder: f.Folder.GetSubFolder(subFolderRelativePath), failOn: f.failOn}
+}
+
+func (f *failingPutFolder) PutObject(name string, content io.Reader) error {
+	return f.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (f *failingPutFolder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	if strings.HasPrefix(name, f.failOn) {
+		return errors.New("put failed")
+	}
+	return f.Folder.PutObjectWithContext(ctx, name, content)
+}
+
+func TestFolder_NestedBackupsFolder(t *testing.T) {
+	root := memory.NewFolder("test/", memory.NewKVS())
+	segmentFolder := root.GetSubFolder("segments_005/seg0/basebackups_005")
+
+	folder := NewFolder(root).GetSubFolder("segments_005/seg0")
+	putObject(t, folder, "basebackups_005/base_1_backup_stop_sentinel.json", `{}`)
+
+	catalog, err := Read(segmentFolder)
+	require.NoError(t, err)
+	_, ok := catalog.Entry("base_1")
+	assert.True(t, ok)
+}
+
+func TestAppend_Compacts(t *testing.T) {
+	root := memory.NewFolder("test/", memory.NewKVS())
+	putObject(t, root, "base_1_backup_stop_sentinel.json", `{}`)
+	for i := 0; i < compactionThreshold+1; i++ {
+		require.NoError(t, Append(root, NewUploadRecord(OpSentinel, "base_1", []byte(`{}`))))
+	}
+	catalog, err := Read(root)
+	require.NoError(t, err)
+	assert.Len(t, catalog.objects, 1)
+	assert.Equal(t, 1, catalog.records)
+	_, ok := catalog.Entry("base_1")
+	assert.True(t, ok)
+}
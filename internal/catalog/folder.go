@@ -0,0 +1,133 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// Folder keeps the catalogs of the backups folders up to date: it records the sentinels and metadata of the backups
+// uploaded, copied or deleted through it. The sentinels recorded by mistake or not recorded at all are found by
+// checking the catalog against the listing, while the metadata can't be checked without fetching it. So if the
+// metadata can't be recorded, the upload fails.
+type Folder struct {
+	storage.Folder
+	// root is the unwrapped root folder, the catalogs are changed through it
+	root storage.Folder
+}
+
+func NewFolder(root storage.Folder) *Folder {
+	return &Folder{Folder: root, root: root}
+}
+
+func (f *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &Folder{Folder: f.Folder.GetSubFolder(subFolderRelativePath), root: f.root}
+}
+
+func (f *Folder) PutObject(name string, content io.Reader) error {
+	return f.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (f *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	op, backupsFolder, backupName := f.classify(name)
+	if op == "" {
+		return f.Folder.PutObjectWithContext(ctx, name, content)
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	err = f.Folder.PutObjectWithContext(ctx, name, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return f.record(backupsFolder, op, NewUploadRecord(op, backupName, data))
+}
+
+func (f *Folder) CopyObject(srcPath string, dstPath string) error {
+	err := f.Folder.CopyObject(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	op, backupsFolder, backupName := f.classify(dstPath)
+	if op == "" {
+		return nil
+	}
+	data, err := readObject(f.Folder, dstPath)
+	if err != nil {
+		err = fmt.Errorf("read the copied %s of backup %s: %w", op, backupName, err)
+		if op == OpMetadata {
+			return fmt.Errorf("update the backup catalog in %s: %w", backupsFolder, err)
+		}
+		tracelog.WarningLogger.Printf("Failed to update the backup catalog in %s: %v", backupsFolder, err)
+		return nil
+	}
+	return f.record(backupsFolder, op, NewUploadRecord(op, backupName, data))
+}
+
+func (f *Folder) DeleteObjects(objectRelativePaths []string) error {
+	err := f.Folder.DeleteObjects(objectRelativePaths)
+	if err != nil {
+		return err
+	}
+	deleted := map[string][]Record{}
+	for _, objectPath := range objectRelativePaths {
+		op, backupsFolder, backupName := f.classify(objectPath)
+		if op == "" {
+			continue
+		}
+		deleted[backupsFolder] = append(deleted[backupsFolder], Record{
+			Op:         OpDelete,
+			BackupName: backupName,
+		})
+	}
+	for backupsFolder, records := range deleted {
+		err = f.record(backupsFolder, OpDelete, records...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// classify tells if the object is the sentinel or the metadata of a backup, and provides the path
+// of the backups folder relative to the root
+func (f *Folder) classify(name string) (op Op, backupsFolder, backupName string) {
+	fullPath := strings.TrimPrefix(f.Folder.GetPath(), f.root.GetPath()) + name
+	dir, file := path.Split(fullPath)
+	switch {
+	case strings.HasSuffix(file, utility.SentinelSuffix) && isBackupsFolder(dir):
+		return OpSentinel, dir, strings.TrimSuffix(file, utility.SentinelSuffix)
+	case file == utility.MetadataFileName && dir != "":
+		backupsFolder, backupDir := path.Split(strings.TrimSuffix(dir, "/"))
+		if isBackupsFolder(backupsFolder) {
+			return OpMetadata, backupsFolder, backupDir
+		}
+	}
+	return "", "", ""
+}
+
+func isBackupsFolder(dir string) bool {
+	return strings.HasSuffix("/"+dir, "/"+utility.BaseBackupPath)
+}
+
+// record appends the records to the catalog. Only the failure to record the metadata is returned,
+// since the sentinels and deletions are found by checking the catalog against the listing.
+func (f *Folder) record(backupsFolder string, op Op, records ...Record) error {
+	err := Append(f.root.GetSubFolder(backupsFolder), records...)
+	if err == nil {
+		return nil
+	}
+	if op == OpMetadata {
+		return fmt.Errorf("update the backup catalog in %s: %w", backupsFolder, err)
+	}
+	tracelog.WarningLogger.Printf("Failed to update the backup catalog in %s: %v", backupsFolder, err)
+	return nil
+}
@@ -74,6 +74,30 @@ const (
 	PgpEnvelopeYcEndpointSetting  = "WALG_ENVELOPE_PGP_YC_ENDPOINT"
 	PgpEnvelopeCacheExpiration    = "WALG_ENVELOPE_CACHE_EXPIRATION"
 
+	BackupCatalogSetting                = "WALG_BACKUP_CATALOG"
+	UseWalSummariesSetting              = "WALG_USE_WAL_SUMMARIES"
+	PgpEnvelopeVaultAddressSetting      = "WALG_ENVELOPE_PGP_VAULT_ADDR"
+	PgpEnvelopeVaultNamespaceSetting    = "WALG_ENVELOPE_PGP_VAULT_NAMESPACE"
+	PgpEnvelopeVaultCACertSetting       = "WALG_ENVELOPE_PGP_VAULT_CACERT"
+	PgpEnvelopeVaultTokenSetting        = "WALG_ENVELOPE_PGP_VAULT_TOKEN"
+	PgpEnvelopeVaultRoleIDSetting       = "WALG_ENVELOPE_PGP_VAULT_ROLE_ID"
+	PgpEnvelopeVaultSecretIDSetting     = "WALG_ENVELOPE_PGP_VAULT_SECRET_ID"
+	PgpEnvelopeVaultAppRoleMountSetting = "WALG_ENVELOPE_PGP_VAULT_APPROLE_MOUNT"
+	PgpEnvelopeVaultTransitMountSetting = "WALG_ENVELOPE_PGP_VAULT_TRANSIT_MOUNT"
+	PgpEnvelopeVaultTransitKeySetting   = "WALG_ENVELOPE_PGP_VAULT_TRANSIT_KEY"
+	PgpEscrowKeySetting                 = "WALG_ENVELOPE_PGP_ESCROW_KEY"
+	PgpEscrowKeyPathSetting             = "WALG_ENVELOPE_PGP_ESCROW_KEY_PATH"
+	PgpEscrowKeyPassphraseSetting       = "WALG_ENVELOPE_PGP_ESCROW_KEY_PASSPHRASE"
+	AgeRecipientsSetting                = "WALG_AGE_RECIPIENTS"
+	AgeRecipientsPathSetting            = "WALG_AGE_RECIPIENTS_PATH"
+	AgeIdentitySetting                  = "WALG_AGE_IDENTITY"
+	AgeIdentityPathSetting              = "WALG_AGE_IDENTITY_PATH"
+	AgeIdentityPassphraseSetting        = "WALG_AGE_IDENTITY_PASSPHRASE"
+	AeadKeySetting                      = "WALG_AEAD_KEY"
+	AeadKeyPathSetting                  = "WALG_AEAD_KEY_PATH"
+	AeadKeyTransformSetting             = "WALG_AEAD_KEY_TRANSFORM"
+	AeadChunkSizeSetting                = "WALG_AEAD_CHUNK_SIZE"
+
 	PgDataSetting                          = "PGDATA"
 	UserSetting                            = "USER" // TODO : do something with it
 	PgPortSetting                          = "PGPORT"
@@ -87,6 +111,8 @@ const (
 	PgSslCert                              = "PGSSLCERT"
 	PgSslRootCert                          = "PGSSLROOTCERT"
 	PgSlotName                             = "WALG_SLOTNAME"
+	PgWalReceiveHosts                      = "WALG_WAL_RECEIVE_HOSTS"
+	PgWalReceiveFailoverTimeout            = "WALG_WAL_RECEIVE_FAILOVER_TIMEOUT"
 	PgWalSize                              = "WALG_PG_WAL_SIZE"
 	TotalBgUploadedLimit                   = "TOTAL_BG_UPLOADED_LIMIT"
 	NameStreamCreateCmd                    = "WALG_STREAM_CREATE_COMMAND"
@@ -113,6 +139,10 @@ const (
 	PgFailoverStorageCacheEMAAlphaDeadMax  = "WALG_FAILOVER_STORAGES_CACHE_EMA_ALPHA_DEAD_MAX"
 	PgFailoverStorageCacheEMAAlphaDeadMin  = "WALG_FAILOVER_STORAGES_CACHE_EMA_ALPHA_DEAD_MIN"
 	PgFailoverStoragesCheckSize            = "WALG_FAILOVER_STORAGES_CHECK_SIZE"
+	PgFailoverStoragesPutQuorum            = "WALG_FAILOVER_STORAGES_PUT_QUORUM"
+	PgFailoverStoragesReadHealthiest       = "WALG_FAILOVER_STORAGES_READ_HEALTHIEST"
+	PgRestoreDrillSigningKey               = "WALG_RESTORE_DRILL_SIGNING_KEY"
+	PgFailoverStoragesCatchUpInterval      = "WALG_FAILOVER_STORAGES_CATCHUP_INTERVAL"
 	PgDaemonWALUploadTimeout               = "WALG_DAEMON_WAL_UPLOAD_TIMEOUT"
 	PgTargetStorage                        = "WALG_TARGET_STORAGE"
 
@@ -218,10 +248,27 @@ const (
 	SwiftOsTenantName = "OS_TENANT_NAME"
 	SwiftOsRegionName = "OS_REGION_NAME"
 
+	SwiftSegmentSize        = "SWIFT_SEGMENT_SIZE"
+	SwiftSegmentConcurrency = "SWIFT_SEGMENT_CONCURRENCY"
+	SwiftSegmentContainer   = "SWIFT_SEGMENT_CONTAINER"
+
 	SSHPort           = "SSH_PORT"
 	SSHPassword       = "SSH_PASSWORD"
 	SSHUsername       = "SSH_USERNAME"
 	SSHPrivateKeyPath = "SSH_PRIVATE_KEY_PATH"
+	SSHMaxConnections = "SSH_MAX_CONNECTIONS"
+	SSHMaxRetries     = "SSH_MAX_RETRIES"
+	SSHMaxPacketSize  = "SSH_MAX_PACKET_SIZE"
+	SSHMaxRequests    = "SSH_MAX_CONCURRENT_REQUESTS"
+
+	WebDAVUsername           = "WEBDAV_USERNAME"
+	WebDAVPassword           = "WEBDAV_PASSWORD"
+	WebDAVBearerToken        = "WEBDAV_BEARER_TOKEN"
+	WebDAVCACertFile         = "WEBDAV_CA_CERT_FILE"
+	WebDAVClientCertFile     = "WEBDAV_CLIENT_CERT_FILE"
+	WebDAVClientKeyFile      = "WEBDAV_CLIENT_KEY_FILE"
+	WebDAVInsecureSkipVerify = "WEBDAV_INSECURE_SKIP_VERIFY"
+	WebDAVTimeout            = "WEBDAV_TIMEOUT"
 
 	SystemdNotifySocket = "NOTIFY_SOCKET"
 )
@@ -241,6 +288,7 @@ var (
 		DeltaMaxStepsSetting:           "0",
 		CompressionMethodSetting:       "lz4",
 		UseWalDeltaSetting:             "false",
+		UseWalSummariesSetting:         "true",
 		TarSizeThresholdSetting:        "1073741823", // (1 << 30) - 1
 		TarDisableFsyncSetting:         "false",
 		TotalBgUploadedLimit:           "32",
@@ -252,6 +300,7 @@ var (
 		UseCopyComposerSetting:         "false",
 		UseDatabaseComposerSetting:     "false",
 		WithoutFilesMetadataSetting:    "false",
+		BackupCatalogSetting:           "false",
 		MaxDelayedSegmentsCount:        "0",
 		SerializerTypeSetting:          "json_default",
 		LibsodiumKeyTransform:          "none",
@@ -297,6 +346,9 @@ var (
 		PgAliveCheckInterval:        "1m",
 		PgFailoverStoragesCheckSize: "1mb",
 		PgDaemonWALUploadTimeout:    "60s",
+
+		PgWalReceiveFailoverTimeout:       "5m",
+		PgFailoverStoragesCatchUpInterval: "1m",
 	}
 
 	GPDefaultSettings = map[string]string{
@@ -367,6 +419,30 @@ var (
 		StatsdAddressSetting:          true,
 		StatsdExtraTagsSetting:        true,
 
+		BackupCatalogSetting:                true,
+		UseWalSummariesSetting:              true,
+		PgpEnvelopeVaultAddressSetting:      true,
+		PgpEnvelopeVaultNamespaceSetting:    true,
+		PgpEnvelopeVaultCACertSetting:       true,
+		PgpEnvelopeVaultTokenSetting:        true,
+		PgpEnvelopeVaultRoleIDSetting:       true,
+		PgpEnvelopeVaultSecretIDSetting:     true,
+		PgpEnvelopeVaultAppRoleMountSetting: true,
+		PgpEnvelopeVaultTransitMountSetting: true,
+		PgpEnvelopeVaultTransitKeySetting:   true,
+		PgpEscrowKeySetting:                 true,
+		PgpEscrowKeyPathSetting:             true,
+		PgpEscrowKeyPassphraseSetting:       true,
+		AgeRecipientsSetting:                true,
+		AgeRecipientsPathSetting:            true,
+		AgeIdentitySetting:                  true,
+		AgeIdentityPathSetting:              true,
+		AgeIdentityPassphraseSetting:        true,
+		AeadKeySetting:                      true,
+		AeadKeyPathSetting:                  true,
+		AeadKeyTransformSetting:             true,
+		AeadChunkSizeSetting:                true,
+
 		ProfileSamplingRatio: true,
 		ProfileMode:          true,
 		ProfilePath:          true,
@@ -379,6 +455,10 @@ var (
 		SwiftOsTenantName:   true,
 		SwiftOsRegionName:   true,
 
+		SwiftSegmentSize:        true,
+		SwiftSegmentConcurrency: true,
+		SwiftSegmentContainer:   true,
+
 		// AWS s3
 		"WALG_S3_PREFIX":              true,
 		"WALE_S3_PREFIX":              true,
@@ -435,6 +515,21 @@ var (
 		SSHPassword:       true,
 		SSHUsername:       true,
 		SSHPrivateKeyPath: true,
+		SSHMaxConnections: true,
+		SSHMaxRetries:     true,
+		SSHMaxPacketSize:  true,
+		SSHMaxRequests:    true,
+
+		// WebDAV
+		"WALG_WEBDAV_PREFIX":     true,
+		WebDAVUsername:           true,
+		WebDAVPassword:           true,
+		WebDAVBearerToken:        true,
+		WebDAVCACertFile:         true,
+		WebDAVClientCertFile:     true,
+		WebDAVClientKeyFile:      true,
+		WebDAVInsecureSkipVerify: true,
+		WebDAVTimeout:            true,
 
 		//File
 		"WALG_FILE_PREFIX": true,
@@ -462,6 +557,8 @@ var (
 		PgSslKey:                               true,
 		PgSslRootCert:                          true,
 		PgSlotName:                             true,
+		PgWalReceiveHosts:                      true,
+		PgWalReceiveFailoverTimeout:            true,
 		PgWalSize:                              true,
 		PrefetchDir:                            true,
 		PgReadyRename:                          true,
@@ -478,7 +575,11 @@ var (
 		PgFailoverStorageCacheEMAAlphaDeadMax:  true,
 		PgFailoverStorageCacheEMAAlphaDeadMin:  true,
 		PgFailoverStoragesCheckSize:            true,
+		PgFailoverStoragesPutQuorum:            true,
+		PgFailoverStoragesReadHealthiest:       true,
+		PgFailoverStoragesCatchUpInterval:      true,
 		PgDaemonWALUploadTimeout:               true,
+		PgRestoreDrillSigningKey:               true,
 	}
 
 	MongoAllowedSettings = map[string]bool{
@@ -584,6 +685,17 @@ var (
 		SQLServerConnectionString:    true,
 		SSHPassword:                  true,
 		SwiftOsPassword:              true,
+
+		AgeIdentitySetting:              true,
+		AgeIdentityPassphraseSetting:    true,
+		AeadKeySetting:                  true,
+		PgRestoreDrillSigningKey:        true,
+		PgpEscrowKeySetting:             true,
+		PgpEscrowKeyPassphraseSetting:   true,
+		PgpEnvelopeVaultTokenSetting:    true,
+		PgpEnvelopeVaultSecretIDSetting: true,
+		WebDAVPassword:                  true,
+		WebDAVBearerToken:               true,
 	}
 
 	complexSettings = map[string]bool{
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/crypto/yckms"
@@ -18,15 +19,22 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/catalog"
 	"github.com/wal-g/wal-g/internal/compression"
 	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/aead"
+	"github.com/wal-g/wal-g/internal/crypto/age"
 	"github.com/wal-g/wal-g/internal/crypto/awskms"
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
 	cachenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/cached"
+	pgpenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/pgp"
+	vaultenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/vault"
 	yckmsenvlpr "github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/yckms"
 	envopenpgp "github.com/wal-g/wal-g/internal/crypto/envelope/openpgp"
 	"github.com/wal-g/wal-g/internal/crypto/openpgp"
 	"github.com/wal-g/wal-g/internal/fsutil"
 	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/pkg/storages/chaos"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"golang.org/x/time/rate"
 )
@@ -110,7 +118,7 @@ func ConfigureStorage() (storage.HashableStorage, error) {
 			return NewLimitedFolder(prevFolder, limiters.NetworkLimiter)
 		})
 	}
-	rootWraps = append(rootWraps, ConfigureStoragePrefix)
+	rootWraps = append(rootWraps, ConfigureStoragePrefix, ConfigureBackupCatalog)
 
 	st, err := ConfigureStorageForSpecificConfig(viper.GetViper(), rootWraps...)
 	if err != nil {
@@ -128,6 +136,14 @@ func ConfigureStoragePrefix(folder storage.Folder) storage.Folder {
 	return folder
 }
 
+// ConfigureBackupCatalog makes the storage maintain the backup catalogs if they are enabled
+func ConfigureBackupCatalog(folder storage.Folder) storage.Folder {
+	if viper.GetBool(conf.BackupCatalogSetting) {
+		folder = catalog.NewFolder(folder)
+	}
+	return folder
+}
+
 // TODO: something with that
 // when provided multiple 'keys' in the config,
 // this function will always return only one concrete 'storage'.
@@ -149,7 +165,7 @@ func ConfigureStorageForSpecificConfig(
 		if err != nil {
 			return nil, fmt.Errorf("configure storage with prefix %q: %w", prefix, err)
 		}
-		return st, nil
+		return configureChaos(st, config)
 	}
 	return nil, newUnconfiguredStorageError(skippedPrefixes)
 }
@@ -185,7 +201,11 @@ func GetPgSlotName() (pgSlotName string) {
 }
 
 func ConfigureCompressor() (compression.Compressor, error) {
-	compressionMethod := viper.GetString(conf.CompressionMethodSetting)
+	return ConfigureCompressorForSpecificConfig(viper.GetViper())
+}
+
+func ConfigureCompressorForSpecificConfig(config *viper.Viper) (compression.Compressor, error) {
+	compressionMethod := config.GetString(conf.CompressionMethodSetting)
 	if _, ok := compression.Compressors[compressionMethod]; !ok {
 		return nil, newUnknownCompressionMethodError(compressionMethod)
 	}
@@ -269,11 +289,7 @@ func ConfigureCrypter() crypto.Crypter {
 }
 
 func CrypterFromConfig(configFile string) crypto.Crypter {
-	var config = viper.New()
-	conf.SetDefaultValues(config)
-	conf.ReadConfigFromFile(config, configFile)
-	conf.CheckAllowedSettings(config)
-
+	config := ConfigFromFile(configFile)
 	crypter, err := ConfigureCrypterForSpecificConfig(config)
 	if err != nil {
 		tracelog.ErrorLogger.FatalfOnError("can't configure crypter: %v", err)
@@ -294,10 +310,15 @@ func ConfigureCrypterForSpecificConfig(config *viper.Viper) (crypto.Crypter, err
 	libsodiumKey := config.IsSet(conf.LibsodiumKeySetting)
 	libsodiumKeyPath := config.IsSet(conf.LibsodiumKeySetting)
 
+	envelopeEscrowKey := config.IsSet(conf.PgpEscrowKeySetting) || config.IsSet(conf.PgpEscrowKeyPathSetting)
+
 	isPgpKey := pgpKey || pgpKeyPath || legacyGpg
-	isEnvelopePgpKey := envelopePgpKey || envelopePgpKeyPath
+	isEnvelopePgpKey := envelopePgpKey || envelopePgpKeyPath || envelopeEscrowKey
 	isLibsodium := libsodiumKey || libsodiumKeyPath
 
+	isAge := config.IsSet(conf.AgeRecipientsSetting) || config.IsSet(conf.AgeRecipientsPathSetting) ||
+		config.IsSet(conf.AgeIdentitySetting) || config.IsSet(conf.AgeIdentityPathSetting)
+
 	if isPgpKey && isEnvelopePgpKey {
 		return nil, errors.New("there is no way to configure plain gpg and envelope gpg at the same time, please choose one")
 	}
@@ -313,6 +334,10 @@ func ConfigureCrypterForSpecificConfig(config *viper.Viper) (crypto.Crypter, err
 		return yckms.YcCrypterFromKeyIDAndCredential(config.GetString(conf.YcKmsKeyIDSetting), config.GetString(conf.YcSaKeyFileSetting)), nil
 	case isLibsodium:
 		return configureLibsodiumCrypter(config)
+	case isAge:
+		return configureAgeCrypter(config), nil
+	case config.IsSet(conf.AeadKeySetting) || config.IsSet(conf.AeadKeyPathSetting):
+		return configureAeadCrypter(config)
 	default:
 		return nil, nil
 	}
@@ -320,7 +345,10 @@ func ConfigureCrypterForSpecificConfig(config *viper.Viper) (crypto.Crypter, err
 
 func configurePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 	loadPassphrase := func() (string, bool) {
-		return conf.GetSetting(conf.PgpKeyPassphraseSetting)
+		if !config.IsSet(conf.PgpKeyPassphraseSetting) {
+			return "", false
+		}
+		return config.GetString(conf.PgpKeyPassphraseSetting), true
 	}
 	// key can be either private (for download) or public (for upload)
 	if config.IsSet(conf.PgpKeySetting) {
@@ -339,34 +367,139 @@ func configurePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
 	return nil, errors.New("there is no any supported gpg crypter configuration")
 }
 
-func configureEnvelopePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
-	if !config.IsSet(conf.PgpEnvelopeYcKmsKeyIDSetting) {
-		return nil, errors.New("yandex cloud KMS key for client-side encryption and decryption must be configured")
+func configureAeadCrypter(config *viper.Viper) (crypto.Crypter, error) {
+	chunkSize := aead.DefaultChunkSize
+	if config.IsSet(conf.AeadChunkSizeSetting) {
+		chunkSize = config.GetInt(conf.AeadChunkSizeSetting)
+		if chunkSize <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %d", conf.AeadChunkSizeSetting, chunkSize)
+		}
 	}
+	return &aead.Crypter{
+		KeyInline:    config.GetString(conf.AeadKeySetting),
+		KeyPath:      config.GetString(conf.AeadKeyPathSetting),
+		KeyTransform: config.GetString(conf.AeadKeyTransformSetting),
+		ChunkSize:    chunkSize,
+	}, nil
+}
+
+func configureAgeCrypter(config *viper.Viper) crypto.Crypter {
+	// recipients are required for upload and identities are required for download
+	return age.CrypterFromConfig(age.Config{
+		Recipients:     config.GetString(conf.AgeRecipientsSetting),
+		RecipientsPath: config.GetString(conf.AgeRecipientsPathSetting),
+		Identity:       config.GetString(conf.AgeIdentitySetting),
+		IdentityPath:   config.GetString(conf.AgeIdentityPathSetting),
+		LoadPassphrase: func() (string, bool) {
+			if !config.IsSet(conf.AgeIdentityPassphraseSetting) {
+				return "", false
+			}
+			return config.GetString(conf.AgeIdentityPassphraseSetting), true
+		},
+	})
+}
 
-	yckmsEnveloper, err := yckmsenvlpr.EnveloperFromKeyIDAndCredential(
-		config.GetString(conf.PgpEnvelopeYcKmsKeyIDSetting),
-		config.GetString(conf.PgpEnvelopeYcSaKeyFileSetting),
-		config.GetString(conf.PgpEnvelopeYcEndpointSetting),
-	)
+func configureEnvelopePgpCrypter(config *viper.Viper) (crypto.Crypter, error) {
+	escrows, err := configureEnvelopeEscrows(config)
 	if err != nil {
 		return nil, err
 	}
-	expiration, err := conf.GetDurationSetting(conf.PgpEnvelopeCacheExpiration)
+	kmsEnveloper, err := configureKmsEnveloper(config)
 	if err != nil {
 		return nil, err
 	}
-	enveloper := cachenvlpr.EnveloperWithCache(yckmsEnveloper, expiration)
+	// without KMS the backups can only be decrypted with the private escrow key, e.g. when the KMS account is lost
+	var enveloper envelope.Enveloper
+	if kmsEnveloper != nil {
+		expiration, err := envelopeCacheExpiration(config)
+		if err != nil {
+			return nil, err
+		}
+		enveloper = cachenvlpr.EnveloperWithCache(kmsEnveloper, expiration)
+	} else if len(escrows) == 0 {
+		return nil, errors.New("yandex cloud KMS key, vault transit key or escrow key for client-side encryption " +
+			"and decryption must be configured")
+	}
 
 	if config.IsSet(conf.PgpEnvelopKeyPathSetting) {
-		return envopenpgp.CrypterFromKeyPath(viper.GetString(conf.PgpEnvelopKeyPathSetting), enveloper), nil
+		return envopenpgp.CrypterFromKeyPath(config.GetString(conf.PgpEnvelopKeyPathSetting), enveloper, escrows...), nil
 	}
 	if config.IsSet(conf.PgpEnvelopeKeySetting) {
-		return envopenpgp.CrypterFromKey(viper.GetString(conf.PgpEnvelopeKeySetting), enveloper), nil
+		return envopenpgp.CrypterFromKey(config.GetString(conf.PgpEnvelopeKeySetting), enveloper, escrows...), nil
+	}
+	if enveloper == nil {
+		return envopenpgp.CrypterFromEscrows(escrows...), nil
 	}
 	return nil, errors.New("there is no any supported envelope gpg crypter configuration")
 }
 
+// envelopeCacheExpiration returns 0, i.e. keys never expire, if the expiration isn't set in the config
+func envelopeCacheExpiration(config *viper.Viper) (time.Duration, error) {
+	value := config.GetString(conf.PgpEnvelopeCacheExpiration)
+	if value == "" {
+		return 0, nil
+	}
+	expiration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("duration expected for %s setting but given '%s': %w", conf.PgpEnvelopeCacheExpiration, value, err)
+	}
+	return expiration, nil
+}
+
+// configureKmsEnveloper returns nil if neither Yandex Cloud KMS nor Vault transit key is configured
+func configureKmsEnveloper(config *viper.Viper) (envelope.Enveloper, error) {
+	switch {
+	case config.IsSet(conf.PgpEnvelopeYcKmsKeyIDSetting):
+		return yckmsenvlpr.EnveloperFromKeyIDAndCredential(
+			config.GetString(conf.PgpEnvelopeYcKmsKeyIDSetting),
+			config.GetString(conf.PgpEnvelopeYcSaKeyFileSetting),
+			config.GetString(conf.PgpEnvelopeYcEndpointSetting),
+		)
+	case config.IsSet(conf.PgpEnvelopeVaultTransitKeySetting):
+		return vaultenvlpr.EnveloperFromTransitKey(
+			config.GetString(conf.PgpEnvelopeVaultTransitKeySetting),
+			config.GetString(conf.PgpEnvelopeVaultTransitMountSetting),
+			vaultenvlpr.ClientConfig{
+				Address:      config.GetString(conf.PgpEnvelopeVaultAddressSetting),
+				Namespace:    config.GetString(conf.PgpEnvelopeVaultNamespaceSetting),
+				CACertPath:   config.GetString(conf.PgpEnvelopeVaultCACertSetting),
+				Token:        config.GetString(conf.PgpEnvelopeVaultTokenSetting),
+				RoleID:       config.GetString(conf.PgpEnvelopeVaultRoleIDSetting),
+				SecretID:     config.GetString(conf.PgpEnvelopeVaultSecretIDSetting),
+				AppRoleMount: config.GetString(conf.PgpEnvelopeVaultAppRoleMountSetting),
+			},
+		)
+	default:
+		return nil, nil
+	}
+}
+
+func configureEnvelopeEscrows(config *viper.Viper) ([]envelope.EscrowEnveloper, error) {
+	loadPassphrase := func() (string, bool) {
+		if !config.IsSet(conf.PgpEscrowKeyPassphraseSetting) {
+			return "", false
+		}
+		return config.GetString(conf.PgpEscrowKeyPassphraseSetting), true
+	}
+
+	// escrow key can be either public (for upload) or private (for download when KMS is unavailable),
+	// it may contain several keys, e.g. of different key holders
+	var escrow envelope.EscrowEnveloper
+	var err error
+	switch {
+	case config.IsSet(conf.PgpEscrowKeySetting):
+		escrow, err = pgpenvlpr.EnveloperFromKey(config.GetString(conf.PgpEscrowKeySetting), loadPassphrase)
+	case config.IsSet(conf.PgpEscrowKeyPathSetting):
+		escrow, err = pgpenvlpr.EnveloperFromKeyPath(config.GetString(conf.PgpEscrowKeyPathSetting), loadPassphrase)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []envelope.EscrowEnveloper{escrow}, nil
+}
+
 // TODO : unit tests
 func GetDeltaConfig() (maxDeltas int, fromFull bool) {
 	maxDeltas = viper.GetInt(conf.DeltaMaxStepsSetting)
@@ -497,15 +630,24 @@ func ConfigureSettings(currentType string) {
 			}
 			conf.AllowedSettings["WALG_"+adapter.PrefixSettingKey()] = true
 		}
+		for _, setting := range chaos.SettingList {
+			conf.AllowedSettings[setting] = true
+		}
 	}
 }
 
-// StorageFromConfig prefers the config parameters instead of the current environment variables
-func StorageFromConfig(configFile string) (storage.Storage, error) {
+// ConfigFromFile reads the config file into a separate viper instance with the default values set
+func ConfigFromFile(configFile string) *viper.Viper {
 	var config = viper.New()
 	conf.SetDefaultValues(config)
 	conf.ReadConfigFromFile(config, configFile)
 	conf.CheckAllowedSettings(config)
+	return config
+}
+
+// StorageFromConfig prefers the config parameters instead of the current environment variables
+func StorageFromConfig(configFile string) (storage.Storage, error) {
+	config := ConfigFromFile(configFile)
 
 	folder, err := ConfigureStorageForSpecificConfig(config)
 
@@ -548,7 +690,7 @@ func ConfigureFailoverStorages() (failovers map[string]storage.HashableStorage,
 				return NewLimitedFolder(prevFolder, limiters.NetworkLimiter)
 			})
 		}
-		rootWraps = append(rootWraps, ConfigureStoragePrefix)
+		rootWraps = append(rootWraps, ConfigureStoragePrefix, ConfigureBackupCatalog)
 
 		st, err := ConfigureStorageForSpecificConfig(cfg, rootWraps...)
 		if err != nil {
@@ -148,6 +148,34 @@ func TestConfigureCompressor_FailsOnInvalidCompressorString(t *testing.T) {
 	resetToDefaults()
 }
 
+func TestConfigureCrypterForSpecificConfig_EscrowWithoutKMS(t *testing.T) {
+	escrowConfig := viper.New()
+	escrowConfig.Set(config.PgpEscrowKeyPathSetting, "crypto/envelope/openpgp/testdata/pgpTestPrivateAnotherKey")
+	crypter, err := internal.ConfigureCrypterForSpecificConfig(escrowConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, "Enveloped/escrow/Opengpg/Crypter", crypter.Name())
+
+	noKmsConfig := viper.New()
+	noKmsConfig.Set(config.PgpEnvelopeKeySetting, "key")
+	_, err = internal.ConfigureCrypterForSpecificConfig(noKmsConfig)
+	assert.Error(t, err)
+}
+
+func TestConfigureCrypterForSpecificConfig_EnvelopeSettingsFromConfig(t *testing.T) {
+	targetConfig := viper.New()
+	targetConfig.Set(config.PgpEnvelopeVaultAddressSetting, "http://127.0.0.1:8200")
+	targetConfig.Set(config.PgpEnvelopeVaultTokenSetting, "token")
+	targetConfig.Set(config.PgpEnvelopeVaultTransitKeySetting, "wal-g")
+	targetConfig.Set(config.PgpEnvelopeKeySetting, "key")
+	crypter, err := internal.ConfigureCrypterForSpecificConfig(targetConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, "Enveloped/vault/Opengpg/Crypter", crypter.Name())
+
+	targetConfig.Set(config.PgpEnvelopeCacheExpiration, "forever")
+	_, err = internal.ConfigureCrypterForSpecificConfig(targetConfig)
+	assert.ErrorContains(t, err, config.PgpEnvelopeCacheExpiration)
+}
+
 func prepareDataFolder(t *testing.T, name string) string {
 	cwd, err := filepath.Abs("./")
 	if err != nil {
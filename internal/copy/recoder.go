@@ -0,0 +1,316 @@
+package copy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	crypterNameField    = "CrypterName"
+	keyFingerprintField = "KeyFingerprint"
+
+	// filesMetadataName is the name of the PostgreSQL backup files metadata, its tar names contain
+	// the compression extension
+	filesMetadataName = "files_metadata.json"
+	tarFileSetsField  = "TarFileSets"
+
+	sentinelCompressedSizeField = "CompressedSize"
+	metadataCompressedSizeField = "compressed_size"
+)
+
+// Recoder re-encodes the copied objects, so the target storage can use other compression and encryption settings.
+// Compressed objects are decrypted with the source crypter and decompressed, then compressed with the target
+// compressor and encrypted with the target crypter. The compression extension of their names is replaced
+// accordingly, as well as in the tar names of the files metadata. The encryption info of backup sentinels
+// is rewritten to describe the target crypter, and their compressed size is recomputed by UpdateCompressedSizes
+// once the backup objects are copied.
+type Recoder struct {
+	SourceCrypter    crypto.Crypter
+	TargetCrypter    crypto.Crypter
+	TargetCompressor compression.Compressor
+
+	sentinelsMu sync.Mutex
+	sentinels   []recodedSentinel
+}
+
+type recodedSentinel struct {
+	folder storage.Folder
+	name   string
+}
+
+func NewRecoder(sourceCrypter, targetCrypter crypto.Crypter, targetCompressor compression.Compressor) *Recoder {
+	return &Recoder{
+		SourceCrypter:    sourceCrypter,
+		TargetCrypter:    targetCrypter,
+		TargetCompressor: targetCompressor,
+	}
+}
+
+// RecoderFromConfigs creates Recoder with the crypter of the source config, and the crypter and compressor
+// of the target config. If compressionMethod is not empty, it overrides the compression method of the target config.
+func RecoderFromConfigs(source, target *viper.Viper, compressionMethod string) (*Recoder, error) {
+	sourceCrypter, err := internal.ConfigureCrypterForSpecificConfig(source)
+	if err != nil {
+		return nil, fmt.Errorf("configure source crypter: %w", err)
+	}
+	targetCrypter, err := internal.ConfigureCrypterForSpecificConfig(target)
+	if err != nil {
+		return nil, fmt.Errorf("configure target crypter: %w", err)
+	}
+
+	var compressor compression.Compressor
+	if compressionMethod != "" {
+		var ok bool
+		compressor, ok = compression.Compressors[compressionMethod]
+		if !ok {
+			return nil, fmt.Errorf("unknown compression method: '%s', supported methods are: %s",
+				compressionMethod, strings.Join(compression.CompressingAlgorithms, ", "))
+		}
+	} else {
+		compressor, err = internal.ConfigureCompressorForSpecificConfig(target)
+		if err != nil {
+			return nil, fmt.Errorf("configure target compressor: %w", err)
+		}
+	}
+	tracelog.InfoLogger.Printf("Objects will be recompressed with %s and encrypted with %s",
+		compressor.FileExtension(), crypterName(targetCrypter))
+	return NewRecoder(sourceCrypter, targetCrypter, compressor), nil
+}
+
+// ConfigureCopyRecoder creates Recoder for copying between the storages of the config files. It returns nil
+// if neither the compression method nor the target config file is set, so the objects are copied byte-for-byte.
+// By default, the target compression and encryption settings are taken from the config of the target storage.
+func ConfigureCopyRecoder(fromConfigFile, toConfigFile, targetConfigFile, compressionMethod string) (*Recoder, error) {
+	if compressionMethod == "" && targetConfigFile == "" {
+		return nil, nil
+	}
+	if targetConfigFile == "" {
+		targetConfigFile = toConfigFile
+	}
+	return RecoderFromConfigs(
+		internal.ConfigFromFile(fromConfigFile),
+		internal.ConfigFromFile(targetConfigFile),
+		compressionMethod,
+	)
+}
+
+// Rename returns the name of the object in the target storage
+func (recoder *Recoder) Rename(name string) string {
+	if !isCompressed(name) {
+		return name
+	}
+	return utility.TrimFileExtension(name) + "." + recoder.TargetCompressor.FileExtension()
+}
+
+// Transform re-encodes the content of the object with the given name
+func (recoder *Recoder) Transform(name string, content io.Reader) (io.Reader, error) {
+	switch {
+	case isCompressed(name):
+		return recoder.recode(name, content)
+	case strings.HasSuffix(name, utility.SentinelSuffix):
+		return recoder.rewriteSentinel(name, content)
+	case path.Base(name) == filesMetadataName:
+		return recoder.renameTarFileSets(name, content)
+	default:
+		return content, nil
+	}
+}
+
+func (recoder *Recoder) recode(name string, content io.Reader) (io.Reader, error) {
+	var err error
+	if recoder.SourceCrypter != nil {
+		content, err = recoder.SourceCrypter.Decrypt(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt '%s'", name)
+		}
+	}
+	decompressor := compression.FindDecompressor(utility.GetFileExtension(name))
+	decompressed, err := decompressor.Decompress(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decompress '%s'", name)
+	}
+	return internal.CompressAndEncrypt(decompressed, recoder.TargetCompressor, recoder.TargetCrypter), nil
+}
+
+func (recoder *Recoder) rewriteSentinel(name string, content io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal sentinel '%s'", name)
+	}
+
+	var sourceInfo crypto.EncryptionInfo
+	err = json.Unmarshal(data, &sourceInfo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal sentinel '%s'", name)
+	}
+	err = crypto.CheckEncryptionInfo(sourceInfo, recoder.SourceCrypter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't recode objects of '%s'", name)
+	}
+
+	targetInfo := crypto.GetEncryptionInfo(recoder.TargetCrypter)
+	delete(fields, crypterNameField)
+	delete(fields, keyFingerprintField)
+	if targetInfo.CrypterName != "" {
+		fields[crypterNameField], _ = json.Marshal(targetInfo.CrypterName)
+	}
+	if targetInfo.KeyFingerprint != "" {
+		fields[keyFingerprintField], _ = json.Marshal(targetInfo.KeyFingerprint)
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (recoder *Recoder) renameTarFileSets(name string, content io.Reader) (io.Reader, error) {
+	var fields map[string]json.RawMessage
+	err := json.NewDecoder(content).Decode(&fields)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal files metadata '%s'", name)
+	}
+	if rawTarFileSets, ok := fields[tarFileSetsField]; ok {
+		var tarFileSets map[string][]string
+		err = json.Unmarshal(rawTarFileSets, &tarFileSets)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal files metadata '%s'", name)
+		}
+		renamedTarFileSets := make(map[string][]string, len(tarFileSets))
+		for tarName, files := range tarFileSets {
+			renamedTarFileSets[recoder.Rename(tarName)] = files
+		}
+		fields[tarFileSetsField], err = json.Marshal(renamedTarFileSets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// TrackSentinel remembers the backup sentinel written to the target folder,
+// so UpdateCompressedSizes recomputes its compressed size
+func (recoder *Recoder) TrackSentinel(folder storage.Folder, name string) {
+	if !strings.HasSuffix(name, utility.SentinelSuffix) {
+		return
+	}
+	recoder.sentinelsMu.Lock()
+	defer recoder.sentinelsMu.Unlock()
+	recoder.sentinels = append(recoder.sentinels, recodedSentinel{folder: folder, name: name})
+}
+
+// UpdateCompressedSizes rewrites the compressed size of the tracked backup sentinels and their metadata
+// with the size of the recoded objects, it must be called after all backup objects are copied
+func (recoder *Recoder) UpdateCompressedSizes() error {
+	recoder.sentinelsMu.Lock()
+	defer recoder.sentinelsMu.Unlock()
+	for _, sentinel := range recoder.sentinels {
+		err := updateCompressedSize(sentinel.folder, sentinel.name)
+		if err != nil {
+			return err
+		}
+	}
+	recoder.sentinels = nil
+	return nil
+}
+
+func updateCompressedSize(folder storage.Folder, sentinelName string) error {
+	backupPath := strings.TrimSuffix(sentinelName, utility.SentinelSuffix)
+	objects, err := storage.ListFolderRecursively(folder.GetSubFolder(backupPath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list objects of '%s'", backupPath)
+	}
+	var compressedSize int64
+	metadataExists := false
+	for _, object := range objects {
+		switch {
+		case isCompressed(object.GetName()):
+			compressedSize += object.GetSize()
+		case object.GetName() == utility.MetadataFileName:
+			metadataExists = true
+		}
+	}
+
+	tracelog.InfoLogger.Printf("Compressed size of recoded backup '%s' is %d", backupPath, compressedSize)
+	err = rewriteSizeField(folder, sentinelName, sentinelCompressedSizeField, compressedSize)
+	if err != nil {
+		return err
+	}
+	if metadataExists {
+		return rewriteSizeField(folder, path.Join(backupPath, utility.MetadataFileName),
+			metadataCompressedSizeField, compressedSize)
+	}
+	return nil
+}
+
+func rewriteSizeField(folder storage.Folder, name, field string, size int64) error {
+	reader, err := folder.ReadObject(name)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+	var fields map[string]json.RawMessage
+	err = json.NewDecoder(reader).Decode(&fields)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal '%s'", name)
+	}
+	if _, ok := fields[field]; !ok {
+		return nil
+	}
+	fields[field], _ = json.Marshal(size)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return folder.PutObject(name, bytes.NewReader(data))
+}
+
+// Recode makes the copying infos re-encode the objects with the recoder
+func Recode(infos []InfoProvider, recoder *Recoder) []InfoProvider {
+	for i := range infos {
+		name := infos[i].SrcObj.GetName()
+		infos[i].targetName = recoder.Rename(infos[i].targetName)
+		recoder.TrackSentinel(infos[i].To, infos[i].targetName)
+		infos[i].SourceTransformer = func(r io.Reader) (io.Reader, error) {
+			return recoder.Transform(name, r)
+		}
+	}
+	return infos
+}
+
+func isCompressed(name string) bool {
+	return compression.FindDecompressor(utility.GetFileExtension(name)) != nil
+}
+
+func crypterName(crypter crypto.Crypter) string {
+	if crypter == nil {
+		return "no crypter"
+	}
+	return crypter.Name()
+}
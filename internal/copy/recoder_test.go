@@ -0,0 +1,198 @@
+package copy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/lzma"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/crypto/aead"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func newTestCrypter(t *testing.T) crypto.Crypter {
+	key := make([]byte, aead.KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return aead.CrypterFromKey(base64.StdEncoding.EncodeToString(key), aead.KeyTransformBase64)
+}
+
+func TestRecoder_Rename(t *testing.T) {
+	recoder := NewRecoder(nil, nil, compression.Compressors[lzma.AlgorithmName])
+
+	assert.Equal(t, "basebackups_005/base_1/tar_partitions/part_1.tar.lzma",
+		recoder.Rename("basebackups_005/base_1/tar_partitions/part_1.tar.lz4"))
+	assert.Equal(t, "wal_005/000000010000000000000001.lzma", recoder.Rename("wal_005/000000010000000000000001.lz4"))
+	assert.Equal(t, "basebackups_005/base_1_backup_stop_sentinel.json",
+		recoder.Rename("basebackups_005/base_1_backup_stop_sentinel.json"))
+	assert.Equal(t, "basebackups_005/base_1/tar_partitions/part_1.tar",
+		recoder.Rename("basebackups_005/base_1/tar_partitions/part_1.tar"))
+}
+
+func TestRecoder_TransformCompressedObject(t *testing.T) {
+	sourceCrypter := newTestCrypter(t)
+	targetCrypter := newTestCrypter(t)
+	recoder := NewRecoder(sourceCrypter, targetCrypter, compression.Compressors[lzma.AlgorithmName])
+
+	data := strings.Repeat("some data ", 1000)
+	source := internal.CompressAndEncrypt(strings.NewReader(data), compression.Compressors[lz4.AlgorithmName], sourceCrypter)
+
+	recoded, err := recoder.Transform("part_1.tar.lz4", source)
+	require.NoError(t, err)
+
+	decrypted, err := targetCrypter.Decrypt(recoded)
+	require.NoError(t, err)
+	decompressed, err := lzma.Decompressor{}.Decompress(decrypted)
+	require.NoError(t, err)
+	content, err := io.ReadAll(decompressed)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(content))
+}
+
+func TestRecoder_TransformSentinel(t *testing.T) {
+	sourceCrypter := newTestCrypter(t)
+	targetCrypter := newTestCrypter(t)
+	recoder := NewRecoder(sourceCrypter, targetCrypter, compression.Compressors[lzma.AlgorithmName])
+
+	sourceInfo := crypto.GetEncryptionInfo(sourceCrypter)
+	sentinel, _ := json.Marshal(map[string]interface{}{
+		"LSN":            12345,
+		"CrypterName":    sourceInfo.CrypterName,
+		"KeyFingerprint": sourceInfo.KeyFingerprint,
+	})
+
+	rewritten, err := recoder.Transform("base_1_backup_stop_sentinel.json", bytes.NewReader(sentinel))
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.NewDecoder(rewritten).Decode(&fields))
+	targetInfo := crypto.GetEncryptionInfo(targetCrypter)
+	assert.Equal(t, targetInfo.KeyFingerprint, fields["KeyFingerprint"])
+	assert.NotEqual(t, sourceInfo.KeyFingerprint, fields["KeyFingerprint"])
+	assert.Equal(t, float64(12345), fields["LSN"])
+
+	// the sentinel of a backup encrypted with another key can't be recoded
+	_, err = NewRecoder(newTestCrypter(t), targetCrypter, compression.Compressors[lzma.AlgorithmName]).
+		Transform("base_1_backup_stop_sentinel.json", bytes.NewReader(sentinel))
+	assert.Error(t, err)
+
+	// the encryption info is removed if the target isn't encrypted
+	rewritten, err = NewRecoder(sourceCrypter, nil, compression.Compressors[lzma.AlgorithmName]).
+		Transform("base_1_backup_stop_sentinel.json", bytes.NewReader(sentinel))
+	require.NoError(t, err)
+	fields = nil
+	require.NoError(t, json.NewDecoder(rewritten).Decode(&fields))
+	assert.NotContains(t, fields, "CrypterName")
+	assert.NotContains(t, fields, "KeyFingerprint")
+}
+
+func TestRecoder_TransformFilesMetadata(t *testing.T) {
+	recoder := NewRecoder(nil, nil, compression.Compressors[lzma.AlgorithmName])
+	filesMetadata := `{"Files":{"base/1":{"MTime":"2024-01-01T00:00:00Z"}},` +
+		`"TarFileSets":{"part_1.tar.lz4":["base/1"],"pg_control.tar.lz4":["global/pg_control"]}}`
+
+	rewritten, err := recoder.Transform("base_1/files_metadata.json", strings.NewReader(filesMetadata))
+	require.NoError(t, err)
+
+	var fields struct {
+		Files       map[string]json.RawMessage
+		TarFileSets map[string][]string
+	}
+	require.NoError(t, json.NewDecoder(rewritten).Decode(&fields))
+	assert.Equal(t, map[string][]string{
+		"part_1.tar.lzma":     {"base/1"},
+		"pg_control.tar.lzma": {"global/pg_control"},
+	}, fields.TarFileSets)
+	assert.Contains(t, fields.Files, "base/1")
+}
+
+func TestRecoder_TransformOtherObject(t *testing.T) {
+	recoder := NewRecoder(newTestCrypter(t), newTestCrypter(t), compression.Compressors[lzma.AlgorithmName])
+
+	transformed, err := recoder.Transform("base_1/metadata.json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	content, _ := io.ReadAll(transformed)
+	assert.Equal(t, "{}", string(content))
+}
+
+func TestRecode(t *testing.T) {
+	viper.Set(conf.CompressionMethodSetting, lz4.AlgorithmName)
+	defer viper.Set(conf.CompressionMethodSetting, nil)
+
+	from := memory.NewFolder("from/", memory.NewKVS())
+	to := memory.NewFolder("to/", memory.NewKVS())
+	data := "wal data"
+	_ = from.PutObject("wal_005/000000010000000000000001.lz4",
+		internal.CompressAndEncrypt(strings.NewReader(data), compression.Compressors[lz4.AlgorithmName], nil))
+
+	objects, err := storage.ListFolderRecursively(from)
+	require.NoError(t, err)
+	infos := BuildCopyingInfos(from, to, objects, func(storage.Object) bool { return true },
+		NoopRenameFunc, NoopSourceTransformer)
+	infos = Recode(infos, NewRecoder(nil, nil, compression.Compressors[lzma.AlgorithmName]))
+	require.NoError(t, Infos(infos))
+
+	file, err := to.ReadObject("wal_005/000000010000000000000001.lzma")
+	require.NoError(t, err)
+	decompressed, err := lzma.Decompressor{}.Decompress(file)
+	require.NoError(t, err)
+	content, _ := io.ReadAll(decompressed)
+	assert.Equal(t, data, string(content))
+}
+
+func TestRecoder_UpdateCompressedSizes(t *testing.T) {
+	viper.Set(conf.CompressionMethodSetting, lz4.AlgorithmName)
+	defer viper.Set(conf.CompressionMethodSetting, nil)
+
+	from := memory.NewFolder("from/", memory.NewKVS())
+	to := memory.NewFolder("to/", memory.NewKVS())
+	data := strings.Repeat("some data ", 1000)
+	_ = from.PutObject("basebackups_005/base_1/tar_partitions/part_1.tar.lz4",
+		internal.CompressAndEncrypt(strings.NewReader(data), compression.Compressors[lz4.AlgorithmName], nil))
+	_ = from.PutObject("basebackups_005/base_1/metadata.json",
+		strings.NewReader(`{"compressed_size":1,"uncompressed_size":10000}`))
+	_ = from.PutObject("basebackups_005/base_1_backup_stop_sentinel.json",
+		strings.NewReader(`{"CompressedSize":1,"UncompressedSize":10000}`))
+
+	objects, err := storage.ListFolderRecursively(from)
+	require.NoError(t, err)
+	infos := BuildCopyingInfos(from, to, objects, func(storage.Object) bool { return true },
+		NoopRenameFunc, NoopSourceTransformer)
+	recoder := NewRecoder(nil, nil, compression.Compressors[lzma.AlgorithmName])
+	infos = Recode(infos, recoder)
+	require.NoError(t, Infos(infos))
+	require.NoError(t, recoder.UpdateCompressedSizes())
+
+	recoded, _, err := to.GetSubFolder("basebackups_005/base_1/tar_partitions").ListFolder()
+	require.NoError(t, err)
+	require.Len(t, recoded, 1)
+	assert.Equal(t, "part_1.tar.lzma", recoded[0].GetName())
+
+	readFields := func(name string) map[string]int64 {
+		reader, err := to.ReadObject(name)
+		require.NoError(t, err)
+		defer reader.Close()
+		var fields map[string]int64
+		require.NoError(t, json.NewDecoder(reader).Decode(&fields))
+		return fields
+	}
+	sentinel := readFields("basebackups_005/base_1_backup_stop_sentinel.json")
+	assert.Equal(t, recoded[0].GetSize(), sentinel["CompressedSize"])
+	assert.Equal(t, int64(10000), sentinel["UncompressedSize"])
+	metadata := readFields("basebackups_005/base_1/metadata.json")
+	assert.Equal(t, recoded[0].GetSize(), metadata["compressed_size"])
+}
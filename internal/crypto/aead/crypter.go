@@ -0,0 +1,132 @@
+package aead
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+const (
+	KeyTransformBase64 = "base64"
+	KeyTransformHex    = "hex"
+)
+
+// Crypter encrypts objects in authenticated chunks with AES-256-GCM. Unlike stream crypters, it detects tampering
+// as soon as the modified chunk is read, and the decrypted objects can be read from any offset.
+type Crypter struct {
+	key []byte
+
+	KeyInline    string
+	KeyPath      string
+	KeyTransform string
+	ChunkSize    int
+
+	mutex sync.RWMutex
+}
+
+func (crypter *Crypter) Name() string {
+	return "AEAD"
+}
+
+// KeyFingerprints returns the truncated SHA-256 hash of the key
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	err := crypter.setup()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(crypter.key)
+	return []string{"sha256:" + hex.EncodeToString(hash[:8])}, nil
+}
+
+// CrypterFromKey creates Crypter from the base64 or hex encoded key
+func CrypterFromKey(key string, keyTransform string) crypto.Crypter {
+	return &Crypter{KeyInline: key, KeyTransform: keyTransform, ChunkSize: DefaultChunkSize}
+}
+
+// CrypterFromKeyPath creates Crypter from the path to the base64 or hex encoded key
+func CrypterFromKeyPath(path string, keyTransform string) crypto.Crypter {
+	return &Crypter{KeyPath: path, KeyTransform: keyTransform, ChunkSize: DefaultChunkSize}
+}
+
+func (crypter *Crypter) setup() error {
+	crypter.mutex.RLock()
+	if crypter.key != nil {
+		crypter.mutex.RUnlock()
+		return nil
+	}
+	crypter.mutex.RUnlock()
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.key != nil {
+		return nil
+	}
+
+	if crypter.KeyInline == "" && crypter.KeyPath == "" {
+		return errors.New("aead Crypter: must have a key or key path")
+	}
+	keyString := crypter.KeyInline
+	if keyString == "" {
+		keyFileContents, err := os.ReadFile(crypter.KeyPath)
+		if err != nil {
+			return fmt.Errorf("aead Crypter: unable to read key from file: %v", err)
+		}
+		keyString = strings.TrimSpace(string(keyFileContents))
+	}
+
+	key, err := decodeKey(keyString, crypter.KeyTransform)
+	if err != nil {
+		return fmt.Errorf("aead Crypter: %v", err)
+	}
+	crypter.key = key
+	return nil
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	if err := crypter.setup(); err != nil {
+		return nil, err
+	}
+	if crypter.ChunkSize <= 0 || crypter.ChunkSize > maxChunkSize {
+		return nil, errors.Errorf("aead Crypter: invalid chunk size %d", crypter.ChunkSize)
+	}
+	return NewWriter(writer, crypter.key, crypter.ChunkSize), nil
+}
+
+// Decrypt creates decrypted reader from ordinary reader. The returned *Reader supports seeking if the
+// ordinary reader is an io.Seeker.
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	if err := crypter.setup(); err != nil {
+		return nil, err
+	}
+	return NewReader(reader, crypter.key), nil
+}
+
+func decodeKey(keyString, keyTransform string) ([]byte, error) {
+	var key []byte
+	var err error
+	switch keyTransform {
+	case KeyTransformBase64, "":
+		key, err = base64.StdEncoding.DecodeString(keyString)
+	case KeyTransformHex:
+		key, err = hex.DecodeString(keyString)
+	default:
+		return nil, fmt.Errorf("unknown key transform '%s' (must be %s or %s)", keyTransform, KeyTransformBase64, KeyTransformHex)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode key: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be exactly %d bytes (got %d bytes)", KeySize, len(key))
+	}
+	return key, nil
+}
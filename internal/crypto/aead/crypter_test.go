@@ -0,0 +1,225 @@
+package aead
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+const testChunkSize = 64
+
+func testKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func testData(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func encrypt(t *testing.T, key, data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writer := NewWriter(buffer, key, testChunkSize)
+	// write in uneven pieces to cross chunk boundaries
+	for len(data) > 0 {
+		n := min(len(data), 23)
+		_, err := writer.Write(data[:n])
+		require.NoError(t, err)
+		data = data[n:]
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func TestEncryptionCycle(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 3 * testChunkSize, 1000} {
+		data := testData(t, size)
+		encrypted := encrypt(t, key, data)
+		assert.Equal(t, headerSize+size+tagSize*max(1, (size+testChunkSize-1)/testChunkSize), len(encrypted))
+
+		decrypted, err := io.ReadAll(NewReader(bytes.NewReader(encrypted), key))
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, data, decrypted, "size %d", size)
+	}
+}
+
+func TestCrypterFromKey(t *testing.T) {
+	key := testKey(t)
+	keyPath := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyPath, []byte(hex.EncodeToString(key)+"\n"), 0600))
+
+	crypters := []crypto.Crypter{
+		CrypterFromKey(base64.StdEncoding.EncodeToString(key), KeyTransformBase64),
+		CrypterFromKeyPath(keyPath, KeyTransformHex),
+	}
+	data := testData(t, 3*DefaultChunkSize+5)
+	for _, crypter := range crypters {
+		buffer := new(bytes.Buffer)
+		writer, err := crypter.Encrypt(buffer)
+		require.NoError(t, err)
+		_, err = writer.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		for _, decrypter := range crypters {
+			reader, err := decrypter.Decrypt(bytes.NewReader(buffer.Bytes()))
+			require.NoError(t, err)
+			decrypted, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, data, decrypted)
+		}
+	}
+
+	fingerprints, err := crypters[0].(crypto.KeyFingerprinter).KeyFingerprints()
+	require.NoError(t, err)
+	otherFingerprints, err := crypters[1].(crypto.KeyFingerprinter).KeyFingerprints()
+	require.NoError(t, err)
+	assert.Equal(t, fingerprints, otherFingerprints)
+}
+
+func TestInvalidKey(t *testing.T) {
+	_, err := CrypterFromKey(base64.StdEncoding.EncodeToString([]byte("short")), KeyTransformBase64).Encrypt(io.Discard)
+	assert.Error(t, err)
+	_, err = CrypterFromKey("key", "rot13").Encrypt(io.Discard)
+	assert.Error(t, err)
+	_, err = CrypterFromKeyPath("/nonexistent", KeyTransformHex).Encrypt(io.Discard)
+	assert.Error(t, err)
+}
+
+func TestWrongKey(t *testing.T) {
+	encrypted := encrypt(t, testKey(t), testData(t, 100))
+	_, err := io.ReadAll(NewReader(bytes.NewReader(encrypted), testKey(t)))
+	assert.ErrorContains(t, err, "chunk 0 authentication failed")
+}
+
+func TestTamperingIsDetectedInModifiedChunk(t *testing.T) {
+	key := testKey(t)
+	data := testData(t, 4*testChunkSize)
+	encrypted := encrypt(t, key, data)
+
+	// modify the third chunk, the first two must still be readable
+	encrypted[headerSize+2*(testChunkSize+tagSize)+1] ^= 1
+	reader := NewReader(bytes.NewReader(encrypted), key)
+	decrypted := make([]byte, 2*testChunkSize)
+	_, err := io.ReadFull(reader, decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, data[:2*testChunkSize], decrypted)
+
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorContains(t, err, "chunk 2 authentication failed")
+}
+
+func TestTamperingWithHeaderIsDetected(t *testing.T) {
+	key := testKey(t)
+	encrypted := encrypt(t, key, testData(t, 100))
+
+	modified := bytes.Clone(encrypted)
+	modified[len(magic)+2] = 1 // reserved byte
+	_, err := io.ReadAll(NewReader(bytes.NewReader(modified), key))
+	assert.ErrorContains(t, err, "chunk 0 authentication failed")
+
+	modified = bytes.Clone(encrypted)
+	modified[0] = 'X'
+	_, err = io.ReadAll(NewReader(bytes.NewReader(modified), key))
+	assert.ErrorContains(t, err, "invalid header format")
+}
+
+func TestTruncationIsDetected(t *testing.T) {
+	key := testKey(t)
+	encrypted := encrypt(t, key, testData(t, 3*testChunkSize+10))
+
+	truncated := encrypted[:headerSize+2*(testChunkSize+tagSize)]
+	_, err := io.ReadAll(NewReader(bytes.NewReader(truncated), key))
+	assert.ErrorContains(t, err, "truncated")
+
+	truncated = encrypted[:len(encrypted)-1]
+	_, err = io.ReadAll(NewReader(bytes.NewReader(truncated), key))
+	assert.ErrorContains(t, err, "authentication failed")
+
+	_, err = io.ReadAll(NewReader(bytes.NewReader(encrypted[:headerSize-1]), key))
+	assert.ErrorContains(t, err, "too short")
+}
+
+func TestReorderingIsDetected(t *testing.T) {
+	key := testKey(t)
+	encrypted := encrypt(t, key, testData(t, 3*testChunkSize))
+
+	chunkSize := testChunkSize + tagSize
+	reordered := bytes.Clone(encrypted)
+	copy(reordered[headerSize:], encrypted[headerSize+chunkSize:headerSize+2*chunkSize])
+	copy(reordered[headerSize+chunkSize:], encrypted[headerSize:headerSize+chunkSize])
+	_, err := io.ReadAll(NewReader(bytes.NewReader(reordered), key))
+	assert.ErrorContains(t, err, "chunk 0 authentication failed")
+}
+
+func TestTrailingDataIsDetected(t *testing.T) {
+	key := testKey(t)
+	encrypted := encrypt(t, key, testData(t, 100))
+	_, err := io.ReadAll(NewReader(bytes.NewReader(append(encrypted, 0)), key))
+	assert.Error(t, err)
+}
+
+func TestSeek(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 10, testChunkSize, 5*testChunkSize + 7} {
+		data := testData(t, size)
+		reader := NewReader(bytes.NewReader(encrypt(t, key, data)), key)
+
+		end, err := reader.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), end)
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Empty(t, rest)
+
+		for _, offset := range []int{0, 1, testChunkSize - 1, testChunkSize, 2*testChunkSize + 3, size - 1, size / 2} {
+			if offset < 0 || offset >= size {
+				continue
+			}
+			position, err := reader.Seek(int64(offset), io.SeekStart)
+			require.NoError(t, err)
+			assert.Equal(t, int64(offset), position)
+
+			part := make([]byte, min(testChunkSize+5, size-offset))
+			_, err = io.ReadFull(reader, part)
+			require.NoError(t, err)
+			assert.Equal(t, data[offset:offset+len(part)], part, "size %d, offset %d", size, offset)
+
+			position, err = reader.Seek(-int64(len(part)), io.SeekCurrent)
+			require.NoError(t, err)
+			assert.Equal(t, int64(offset), position)
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, data[offset:], rest)
+		}
+	}
+}
+
+func TestSeekRequiresSeeker(t *testing.T) {
+	key := testKey(t)
+	reader := NewReader(io.MultiReader(bytes.NewReader(encrypt(t, key, testData(t, 10)))), key)
+	_, err := reader.Seek(0, io.SeekStart)
+	assert.Error(t, err)
+}
+
+func TestEncryptedOffset(t *testing.T) {
+	offset, inChunk := EncryptedOffset(2*testChunkSize+5, testChunkSize)
+	assert.Equal(t, int64(headerSize+2*(testChunkSize+tagSize)), offset)
+	assert.Equal(t, 5, inChunk)
+}
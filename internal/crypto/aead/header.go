@@ -0,0 +1,141 @@
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+/*
+	Encrypted object layout:
+
+	header:
+		magic "WALGAEAD"
+		format version (current version is 1)
+		cipher (1 is AES-256-GCM)
+		2 reserved bytes
+		uint32 - plaintext chunk size
+		32 bytes of random salt
+	chunks:
+		AEAD sealed chunk of chunk size plaintext bytes, only the last chunk may be shorter
+
+	Each object is encrypted with its own key, derived from the master key and the salt with HKDF-SHA256.
+	The chunk nonce consists of the chunk index and the flag of the last chunk, so the chunks can't be reordered
+	and the truncation of the object is detected. The header is the additional data of each chunk, so it's
+	authenticated together with the first chunk read.
+*/
+
+const (
+	magic                = "WALGAEAD"
+	formatVersion   byte = 1
+	cipherAES256GCM byte = 1
+
+	KeySize    = 32
+	saltSize   = 32
+	headerSize = len(magic) + 4 + 4 + saltSize
+	tagSize    = 16
+	nonceSize  = 12
+
+	DefaultChunkSize = 64 << 10
+	maxChunkSize     = 16 << 20
+
+	keyDerivationInfo = "wal-g aead v1"
+)
+
+type header struct {
+	chunkSize int
+	salt      []byte
+	raw       []byte
+}
+
+func newHeader(chunkSize int) (*header, error) {
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "aead: can't generate salt")
+	}
+
+	raw := make([]byte, 0, headerSize)
+	raw = append(raw, magic...)
+	raw = append(raw, formatVersion, cipherAES256GCM, 0, 0)
+	raw = binary.BigEndian.AppendUint32(raw, uint32(chunkSize))
+	raw = append(raw, salt...)
+	return &header{chunkSize: chunkSize, salt: salt, raw: raw}, nil
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	raw := make([]byte, headerSize)
+	_, err := io.ReadFull(r, raw)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("aead: encrypted object is too short")
+		}
+		return nil, errors.Wrap(err, "aead: can't read header")
+	}
+
+	if string(raw[:len(magic)]) != magic {
+		return nil, errors.New("aead: invalid header format")
+	}
+	if raw[len(magic)] != formatVersion {
+		return nil, errors.Errorf("aead: format version %d is not supported", raw[len(magic)])
+	}
+	if raw[len(magic)+1] != cipherAES256GCM {
+		return nil, errors.Errorf("aead: cipher %d is not supported", raw[len(magic)+1])
+	}
+	chunkSize := binary.BigEndian.Uint32(raw[len(magic)+4:])
+	if chunkSize == 0 || chunkSize > maxChunkSize {
+		return nil, errors.Errorf("aead: invalid chunk size %d", chunkSize)
+	}
+	return &header{chunkSize: int(chunkSize), salt: raw[len(magic)+8:], raw: raw}, nil
+}
+
+// newAEAD derives the object key from the master key and the header salt.
+func (h *header) newAEAD(key []byte) (cipher.AEAD, error) {
+	objectKey := make([]byte, KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, key, h.salt, []byte(keyDerivationInfo)), objectKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "aead: can't derive key")
+	}
+	block, err := aes.NewCipher(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// EncryptedOffset returns the offset of the encrypted chunk containing the plaintext offset, and the offset
+// of the plaintext within that chunk. It allows to download only the needed range of the encrypted object.
+func EncryptedOffset(plaintextOffset int64, chunkSize int) (encryptedOffset int64, offsetInChunk int) {
+	chunkIndex := plaintextOffset / int64(chunkSize)
+	return int64(headerSize) + chunkIndex*int64(chunkSize+tagSize), int(plaintextOffset % int64(chunkSize))
+}
+
+// plaintextSize calculates the size of the plaintext from the size of the encrypted object.
+func plaintextSize(encryptedSize int64, chunkSize int) (int64, error) {
+	encryptedChunkSize := int64(chunkSize + tagSize)
+	chunksSize := encryptedSize - int64(headerSize)
+	fullChunks := chunksSize / encryptedChunkSize
+	lastChunkSize := chunksSize % encryptedChunkSize
+	if lastChunkSize == 0 && fullChunks > 0 {
+		return fullChunks * int64(chunkSize), nil
+	}
+	if lastChunkSize < tagSize {
+		return 0, errors.New("aead: invalid encrypted object size")
+	}
+	return fullChunks*int64(chunkSize) + lastChunkSize - tagSize, nil
+}
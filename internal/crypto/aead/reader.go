@@ -0,0 +1,195 @@
+package aead
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Reader decrypts and authenticates the data chunk by chunk, so tampering is detected as soon as the modified chunk
+// is read. If the underlying reader is an io.Seeker, the Reader supports seeking to any plaintext offset.
+type Reader struct {
+	reader io.Reader
+	key    []byte
+
+	header *header
+	aead   cipher.AEAD
+
+	chunkIndex uint64
+	in         []byte
+	out        []byte
+	plain      []byte
+	// skip is the number of plaintext bytes to skip in the next chunk after seeking
+	skip      int
+	lastChunk bool
+	offset    int64
+	size      int64
+	err       error
+}
+
+// NewReader creates Reader from ordinary reader and key.
+func NewReader(reader io.Reader, key []byte) *Reader {
+	return &Reader{reader: reader, key: key, size: -1}
+}
+
+// setup reads the header. It's done on the first read, since the underlying reader can be a pipe.
+func (reader *Reader) setup() error {
+	if reader.header != nil {
+		return nil
+	}
+	header, err := readHeader(reader.reader)
+	if err != nil {
+		return err
+	}
+	aead, err := header.newAEAD(reader.key)
+	if err != nil {
+		return err
+	}
+	reader.header = header
+	reader.aead = aead
+	reader.in = make([]byte, header.chunkSize+aead.Overhead())
+	reader.out = make([]byte, 0, header.chunkSize)
+	return nil
+}
+
+// Read implements io.Reader
+func (reader *Reader) Read(p []byte) (n int, err error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+	if reader.err = reader.setup(); reader.err != nil {
+		return 0, reader.err
+	}
+
+	for len(reader.plain) == 0 {
+		if reader.lastChunk {
+			return 0, io.EOF
+		}
+		if reader.err = reader.readChunk(); reader.err != nil {
+			return 0, reader.err
+		}
+	}
+	n = copy(p, reader.plain)
+	reader.plain = reader.plain[n:]
+	reader.offset += int64(n)
+	return n, nil
+}
+
+func (reader *Reader) readChunk() error {
+	n, err := io.ReadFull(reader.reader, reader.in)
+	switch {
+	case err == io.EOF:
+		return errors.Errorf("aead: encrypted object is truncated before chunk %d", reader.chunkIndex)
+	case err == io.ErrUnexpectedEOF:
+		// only the last chunk can be shorter
+		reader.plain, err = reader.open(reader.in[:n], true)
+	case err != nil:
+		return errors.Wrap(err, "aead: can't read chunk")
+	default:
+		reader.plain, err = reader.open(reader.in, false)
+		if err != nil {
+			reader.plain, err = reader.open(reader.in, true)
+		}
+	}
+	if err != nil {
+		return errors.Errorf("aead: chunk %d authentication failed: the data is corrupted or the key is wrong",
+			reader.chunkIndex)
+	}
+
+	reader.chunkIndex++
+	if reader.skip > 0 {
+		reader.plain = reader.plain[min(reader.skip, len(reader.plain)):]
+		reader.skip = 0
+	}
+	if reader.lastChunk {
+		return reader.checkNoTrailingData()
+	}
+	return nil
+}
+
+func (reader *Reader) open(chunk []byte, last bool) ([]byte, error) {
+	plain, err := reader.aead.Open(reader.out[:0], chunkNonce(reader.chunkIndex, last), chunk, reader.header.raw)
+	if err == nil {
+		reader.lastChunk = last
+	}
+	return plain, err
+}
+
+func (reader *Reader) checkNoTrailingData() error {
+	n, err := reader.reader.Read(make([]byte, 1))
+	if n > 0 {
+		return errors.New("aead: unexpected data after the last chunk")
+	}
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "aead: can't read chunk")
+	}
+	return nil
+}
+
+// Seek implements io.Seeker, the offset is in the plaintext. It requires the underlying reader to be an io.Seeker
+// positioned at the beginning of the encrypted object.
+func (reader *Reader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := reader.reader.(io.Seeker)
+	if !ok {
+		return 0, errors.New("aead: underlying reader doesn't support seeking")
+	}
+	if err := reader.setup(); err != nil {
+		return 0, err
+	}
+	size, err := reader.plaintextSize(seeker)
+	if err != nil {
+		return 0, err
+	}
+
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = reader.offset + offset
+	case io.SeekEnd:
+		target = size + offset
+	default:
+		return 0, errors.Errorf("aead: invalid whence %d", whence)
+	}
+	if target < 0 {
+		return 0, errors.New("aead: negative position")
+	}
+
+	reader.err = nil
+	reader.plain = nil
+	reader.offset = target
+	if target >= size {
+		// reads at or after the end return io.EOF
+		reader.lastChunk = true
+		return target, nil
+	}
+
+	encryptedOffset, offsetInChunk := EncryptedOffset(target, reader.header.chunkSize)
+	_, err = seeker.Seek(encryptedOffset, io.SeekStart)
+	if err != nil {
+		reader.err = errors.Wrap(err, "aead: can't seek")
+		return 0, reader.err
+	}
+	reader.chunkIndex = uint64(target / int64(reader.header.chunkSize))
+	reader.skip = offsetInChunk
+	reader.lastChunk = false
+	return target, nil
+}
+
+func (reader *Reader) plaintextSize(seeker io.Seeker) (int64, error) {
+	if reader.size >= 0 {
+		return reader.size, nil
+	}
+	encryptedSize, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrap(err, "aead: can't seek")
+	}
+	size, err := plaintextSize(encryptedSize, reader.header.chunkSize)
+	if err != nil {
+		return 0, err
+	}
+	reader.size = size
+	return size, nil
+}
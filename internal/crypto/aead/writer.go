@@ -0,0 +1,111 @@
+package aead
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Writer encrypts the data written into it chunk by chunk.
+type Writer struct {
+	writer io.Writer
+	key    []byte
+
+	chunkSize  int
+	header     *header
+	aead       cipher.AEAD
+	chunkIndex uint64
+
+	// the chunk is kept in the buffer until there are more data, since the last chunk is sealed differently
+	buffer []byte
+	out    []byte
+	err    error
+	closed bool
+}
+
+// NewWriter creates Writer from ordinary writer and key.
+func NewWriter(writer io.Writer, key []byte, chunkSize int) *Writer {
+	return &Writer{
+		writer:    writer,
+		key:       key,
+		chunkSize: chunkSize,
+		buffer:    make([]byte, 0, chunkSize),
+	}
+}
+
+// setup writes the header. It's done on the first write, since the underlying writer can be a pipe.
+func (writer *Writer) setup() error {
+	if writer.header != nil {
+		return nil
+	}
+	header, err := newHeader(writer.chunkSize)
+	if err != nil {
+		return err
+	}
+	aead, err := header.newAEAD(writer.key)
+	if err != nil {
+		return err
+	}
+	_, err = writer.writer.Write(header.raw)
+	if err != nil {
+		return errors.Wrap(err, "aead: can't write header")
+	}
+	writer.header = header
+	writer.aead = aead
+	writer.out = make([]byte, 0, writer.chunkSize+aead.Overhead())
+	return nil
+}
+
+// Write implements io.Writer
+func (writer *Writer) Write(p []byte) (n int, err error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	if writer.closed {
+		return 0, errors.New("aead: write to closed writer")
+	}
+	if writer.err = writer.setup(); writer.err != nil {
+		return 0, writer.err
+	}
+
+	for len(p) > 0 {
+		if len(writer.buffer) == writer.chunkSize {
+			if writer.err = writer.writeChunk(false); writer.err != nil {
+				return n, writer.err
+			}
+		}
+		count := copy(writer.buffer[len(writer.buffer):writer.chunkSize], p)
+		writer.buffer = writer.buffer[:len(writer.buffer)+count]
+		p = p[count:]
+		n += count
+	}
+	return n, nil
+}
+
+// Close writes the last chunk. It doesn't close the underlying writer.
+func (writer *Writer) Close() error {
+	if writer.closed {
+		return writer.err
+	}
+	writer.closed = true
+	if writer.err != nil {
+		return writer.err
+	}
+	if writer.err = writer.setup(); writer.err != nil {
+		return writer.err
+	}
+	writer.err = writer.writeChunk(true)
+	return writer.err
+}
+
+func (writer *Writer) writeChunk(last bool) error {
+	writer.out = writer.aead.Seal(writer.out[:0], chunkNonce(writer.chunkIndex, last), writer.buffer, writer.header.raw)
+	_, err := writer.writer.Write(writer.out)
+	if err != nil {
+		return errors.Wrap(err, "aead: can't write chunk")
+	}
+	writer.chunkIndex++
+	writer.buffer = writer.buffer[:0]
+	return nil
+}
@@ -0,0 +1,280 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+)
+
+// Config describes where the age keys are taken from. Recipients are required to encrypt, and identities are required
+// to decrypt. If no recipients are configured, they are derived from the identities.
+type Config struct {
+	// Recipients are public keys (age1...) separated by commas, spaces or new lines.
+	Recipients string
+	// RecipientsPath is a path to a file with public keys, one per line, as in the age recipients file format.
+	RecipientsPath string
+
+	// Identity is a secret key (AGE-SECRET-KEY-1...) or several keys separated by new lines.
+	Identity string
+	// IdentityPath is a path to an identity file as generated by age-keygen. The file can be encrypted with
+	// a passphrase, as produced by `age -p`.
+	IdentityPath string
+	// LoadPassphrase provides the passphrase to decrypt the identity file.
+	LoadPassphrase func() (string, bool)
+}
+
+// Crypter is an age (https://age-encryption.org) Crypter implementation. Backup hosts need only recipients (public
+// keys) to encrypt, and restore hosts need identities (secret keys) to decrypt.
+type Crypter struct {
+	config Config
+
+	recipients []age.Recipient
+	identities []age.Identity
+
+	mutex sync.RWMutex
+}
+
+func (crypter *Crypter) Name() string {
+	return "Age"
+}
+
+// KeyFingerprints returns the recipients, which are public keys
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	recipients, err := crypter.setupRecipients()
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		if stringer, ok := recipient.(fmt.Stringer); ok {
+			fingerprints = append(fingerprints, stringer.String())
+		}
+	}
+	return fingerprints, nil
+}
+
+// DecryptionKeyFingerprints returns the recipients of the identities, which are the public keys of the secret ones
+func (crypter *Crypter) DecryptionKeyFingerprints() ([]string, error) {
+	identities, err := crypter.setupIdentities()
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		if x25519Identity, ok := identity.(*age.X25519Identity); ok {
+			fingerprints = append(fingerprints, x25519Identity.Recipient().String())
+		}
+	}
+	return fingerprints, nil
+}
+
+// CrypterFromConfig creates Crypter from recipients and identities configuration.
+func CrypterFromConfig(config Config) crypto.Crypter {
+	return &Crypter{config: config}
+}
+
+// Encrypt creates encryption writer from ordinary writer
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	recipients, err := crypter.setupRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	// We use buffered writer because encryption starts writing header immediately,
+	// which can be inappropriate for further usage with blocking writers.
+	bufferedWriter := bufio.NewWriter(writer)
+	encryptedWriter, err := age.Encrypt(bufferedWriter, recipients...)
+	if err != nil {
+		return nil, errors.Wrap(err, "age encryption error")
+	}
+
+	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
+}
+
+// Decrypt creates decrypted reader from ordinary reader
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	identities, err := crypter.setupIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	decryptedReader, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, errors.Wrap(err, "age decryption error")
+	}
+	return decryptedReader, nil
+}
+
+func (crypter *Crypter) setupRecipients() ([]age.Recipient, error) {
+	crypter.mutex.RLock()
+	if crypter.recipients != nil {
+		defer crypter.mutex.RUnlock()
+		return crypter.recipients, nil
+	}
+	crypter.mutex.RUnlock()
+
+	recipients, err := crypter.loadRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	crypter.recipients = recipients
+	return recipients, nil
+}
+
+func (crypter *Crypter) loadRecipients() ([]age.Recipient, error) {
+	var recipients []age.Recipient
+
+	for _, field := range splitKeys(crypter.config.Recipients) {
+		recipient, err := age.ParseX25519Recipient(field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "age Crypter: invalid recipient %q", field)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	if crypter.config.RecipientsPath != "" {
+		file, err := os.Open(crypter.config.RecipientsPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "age Crypter: unable to open recipients file")
+		}
+		defer file.Close()
+		fileRecipients, err := age.ParseRecipients(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "age Crypter: unable to parse recipients file %q", crypter.config.RecipientsPath)
+		}
+		recipients = append(recipients, fileRecipients...)
+	}
+
+	if len(recipients) > 0 {
+		return recipients, nil
+	}
+
+	// Without explicit recipients, encrypt to the configured identities, so a single host can both push and fetch.
+	identities, err := crypter.setupIdentities()
+	if err != nil {
+		return nil, errors.Wrap(err, "age Crypter: no recipients configured")
+	}
+	for _, identity := range identities {
+		x25519Identity, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("age Crypter: can't derive recipient from identity of type %T", identity)
+		}
+		recipients = append(recipients, x25519Identity.Recipient())
+	}
+	return recipients, nil
+}
+
+func (crypter *Crypter) setupIdentities() ([]age.Identity, error) {
+	crypter.mutex.RLock()
+	if crypter.identities != nil {
+		defer crypter.mutex.RUnlock()
+		return crypter.identities, nil
+	}
+	crypter.mutex.RUnlock()
+
+	identities, err := crypter.loadIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	crypter.identities = identities
+	return identities, nil
+}
+
+func (crypter *Crypter) loadIdentities() ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if crypter.config.Identity != "" {
+		evaluatedIdentity := strings.ReplaceAll(crypter.config.Identity, `\n`, "\n")
+		inlineIdentities, err := age.ParseIdentities(strings.NewReader(evaluatedIdentity))
+		if err != nil {
+			return nil, errors.Wrap(err, "age Crypter: unable to parse identity")
+		}
+		identities = append(identities, inlineIdentities...)
+	}
+
+	if crypter.config.IdentityPath != "" {
+		fileIdentities, err := crypter.readIdentityFile(crypter.config.IdentityPath)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	if len(identities) == 0 {
+		return nil, errors.New("age Crypter: must have an identity or identity path to decrypt")
+	}
+	return identities, nil
+}
+
+// readIdentityFile reads identities from the file, decrypting it with the passphrase if it's encrypted.
+func (crypter *Crypter) readIdentityFile(path string) ([]age.Identity, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "age Crypter: unable to read identity file")
+	}
+
+	if isEncrypted(content) {
+		content, err = crypter.decryptIdentityFile(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "age Crypter: unable to decrypt identity file %q", path)
+		}
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "age Crypter: unable to parse identity file %q", path)
+	}
+	return identities, nil
+}
+
+func (crypter *Crypter) decryptIdentityFile(content []byte) ([]byte, error) {
+	if crypter.config.LoadPassphrase == nil {
+		return nil, errors.New("identity file is encrypted, but no passphrase is configured")
+	}
+	passphrase, ok := crypter.config.LoadPassphrase()
+	if !ok {
+		return nil, errors.New("identity file is encrypted, but no passphrase is configured")
+	}
+	scryptIdentity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(content, []byte(armor.Header)) {
+		encrypted = armor.NewReader(encrypted)
+	}
+	decrypted, err := age.Decrypt(encrypted, scryptIdentity)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decrypted)
+}
+
+const encryptedFileIntro = "age-encryption.org/"
+
+func isEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedFileIntro)) || bytes.HasPrefix(content, []byte(armor.Header))
+}
+
+func splitKeys(keys string) []string {
+	return strings.FieldsFunc(keys, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	})
+}
@@ -0,0 +1,174 @@
+package age
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+const someSecret = "so very secret thingy"
+
+func encrypt(t *testing.T, crypter crypto.Crypter) []byte {
+	buf := new(bytes.Buffer)
+	encrypt, err := crypter.Encrypt(buf)
+	require.NoError(t, err)
+	_, err = encrypt.Write([]byte(someSecret))
+	require.NoError(t, err)
+	require.NoError(t, encrypt.Close())
+	return buf.Bytes()
+}
+
+func decrypt(t *testing.T, crypter crypto.Crypter, encrypted []byte) string {
+	decrypt, err := crypter.Decrypt(bytes.NewReader(encrypted))
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(decrypt)
+	require.NoError(t, err)
+	return string(decrypted)
+}
+
+func generateIdentity(t *testing.T) *age.X25519Identity {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	return identity
+}
+
+func TestEncryptionCycle(t *testing.T) {
+	identity := generateIdentity(t)
+	crypter := CrypterFromConfig(Config{Identity: identity.String()})
+
+	encrypted := encrypt(t, crypter)
+	assert.NotContains(t, string(encrypted), someSecret)
+	assert.Equal(t, someSecret, decrypt(t, crypter, encrypted))
+}
+
+func TestMultipleRecipients(t *testing.T) {
+	first, second := generateIdentity(t), generateIdentity(t)
+	pusher := CrypterFromConfig(Config{
+		Recipients: first.Recipient().String() + ", " + second.Recipient().String(),
+	})
+	encrypted := encrypt(t, pusher)
+
+	for _, identity := range []*age.X25519Identity{first, second} {
+		fetcher := CrypterFromConfig(Config{Identity: identity.String()})
+		assert.Equal(t, someSecret, decrypt(t, fetcher, encrypted))
+	}
+
+	stranger := CrypterFromConfig(Config{Identity: generateIdentity(t).String()})
+	_, err := stranger.Decrypt(bytes.NewReader(encrypted))
+	assert.Error(t, err)
+}
+
+func TestRecipientsAndIdentityFiles(t *testing.T) {
+	identity := generateIdentity(t)
+	dir := t.TempDir()
+	recipientsPath := filepath.Join(dir, "recipients.txt")
+	identityPath := filepath.Join(dir, "key.txt")
+	require.NoError(t, os.WriteFile(recipientsPath, []byte("# backup key\n"+identity.Recipient().String()+"\n"), 0600))
+	require.NoError(t, os.WriteFile(identityPath, []byte("# created: now\n"+identity.String()+"\n"), 0600))
+
+	encrypted := encrypt(t, CrypterFromConfig(Config{RecipientsPath: recipientsPath}))
+	assert.Equal(t, someSecret, decrypt(t, CrypterFromConfig(Config{IdentityPath: identityPath}), encrypted))
+}
+
+func TestPassphraseProtectedIdentityFile(t *testing.T) {
+	identity := generateIdentity(t)
+	scryptRecipient, err := age.NewScryptRecipient("correct horse")
+	require.NoError(t, err)
+	scryptRecipient.SetWorkFactor(10)
+
+	protected := new(bytes.Buffer)
+	armored := armor.NewWriter(protected)
+	writer, err := age.Encrypt(armored, scryptRecipient)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte(identity.String() + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, armored.Close())
+
+	identityPath := filepath.Join(t.TempDir(), "key.age")
+	require.NoError(t, os.WriteFile(identityPath, protected.Bytes(), 0600))
+
+	encrypted := encrypt(t, CrypterFromConfig(Config{Recipients: identity.Recipient().String()}))
+
+	t.Run("decrypt with passphrase", func(t *testing.T) {
+		crypter := CrypterFromConfig(Config{
+			IdentityPath:   identityPath,
+			LoadPassphrase: func() (string, bool) { return "correct horse", true },
+		})
+		assert.Equal(t, someSecret, decrypt(t, crypter, encrypted))
+	})
+
+	t.Run("fail with wrong passphrase", func(t *testing.T) {
+		crypter := CrypterFromConfig(Config{
+			IdentityPath:   identityPath,
+			LoadPassphrase: func() (string, bool) { return "wrong", true },
+		})
+		_, err := crypter.Decrypt(bytes.NewReader(encrypted))
+		assert.Error(t, err)
+	})
+
+	t.Run("fail without passphrase", func(t *testing.T) {
+		crypter := CrypterFromConfig(Config{IdentityPath: identityPath})
+		_, err := crypter.Decrypt(bytes.NewReader(encrypted))
+		assert.Error(t, err)
+	})
+}
+
+func TestMissingKeys(t *testing.T) {
+	crypter := CrypterFromConfig(Config{})
+
+	_, err := crypter.Encrypt(new(bytes.Buffer))
+	assert.Error(t, err)
+
+	_, err = crypter.Decrypt(new(bytes.Buffer))
+	assert.Error(t, err)
+}
+
+func TestInvalidRecipient(t *testing.T) {
+	crypter := CrypterFromConfig(Config{Recipients: "age1invalid"})
+
+	_, err := crypter.Encrypt(new(bytes.Buffer))
+	assert.Error(t, err)
+}
+
+func TestKeyFingerprintsMatchRecipients(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	pushCrypter := CrypterFromConfig(Config{Recipients: identity.Recipient().String()})
+	fetchCrypter := CrypterFromConfig(Config{Identity: identity.String()})
+
+	pushFingerprints, err := pushCrypter.(crypto.KeyFingerprinter).KeyFingerprints()
+	require.NoError(t, err)
+	fetchFingerprints, err := fetchCrypter.(crypto.KeyFingerprinter).KeyFingerprints()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{identity.Recipient().String()}, pushFingerprints)
+	assert.Equal(t, pushFingerprints, fetchFingerprints)
+}
+
+func TestCheckEncryptionInfoUsesIdentities(t *testing.T) {
+	identity := generateIdentity(t)
+	otherIdentity := generateIdentity(t)
+
+	pushCrypter := CrypterFromConfig(Config{Recipients: identity.Recipient().String()})
+	info := crypto.GetEncryptionInfo(pushCrypter)
+
+	// the restore host encrypts its own backups to another recipient, but has the identity of the backup
+	fetchCrypter := CrypterFromConfig(Config{
+		Recipients: otherIdentity.Recipient().String(),
+		Identity:   identity.String(),
+	})
+	assert.NoError(t, crypto.CheckEncryptionInfo(info, fetchCrypter))
+
+	wrongCrypter := CrypterFromConfig(Config{Recipients: identity.Recipient().String(), Identity: otherIdentity.String()})
+	assert.Error(t, crypto.CheckEncryptionInfo(info, wrongCrypter))
+}
@@ -19,6 +19,11 @@ func (crypter *Crypter) Name() string {
 	return "AWK_KMS/Crypter"
 }
 
+// KeyFingerprints returns the KMS key ID
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	return []string{crypter.SymmetricKey.GetKeyID()}, nil
+}
+
 // Encrypt creates encryption writer from ordinary writer
 func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 	if len(crypter.SymmetricKey.GetKey()) == 0 {
@@ -29,6 +29,13 @@ func (enveloper *Enveloper) Name() string {
 	return enveloper.wrapped.Name()
 }
 
+func (enveloper *Enveloper) KeyID() string {
+	if identifier, ok := enveloper.wrapped.(envelope.KeyIdentifier); ok {
+		return identifier.KeyID()
+	}
+	return ""
+}
+
 func (enveloper *Enveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
 	tracelog.DebugLogger.Println("Exctract encrypted key")
 	return enveloper.wrapped.ReadEncryptedKey(r)
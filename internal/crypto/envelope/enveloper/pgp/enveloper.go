@@ -0,0 +1,143 @@
+package pgp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+const (
+	magic              = "envelope-pgp"
+	schemeVersion byte = 1
+)
+
+// Enveloper wraps data keys with offline PGP keys, e.g. escrow keys kept in a safe. Only public keys are required
+// to wrap data keys, private keys are required to unwrap them.
+type Enveloper struct {
+	entities openpgp.EntityList
+	keyID    string
+}
+
+func (enveloper *Enveloper) Name() string {
+	return "pgp"
+}
+
+func (enveloper *Enveloper) KeyID() string {
+	return enveloper.keyID
+}
+
+// CanDecryptKey tells if the private key is present, only the public key is required to wrap data keys
+func (enveloper *Enveloper) CanDecryptKey() bool {
+	return hasPrivateKeys(enveloper.entities)
+}
+
+func (enveloper *Enveloper) EncryptKey(key []byte) (*envelope.EncryptedKey, error) {
+	buffer := new(bytes.Buffer)
+	encryptedWriter, err := openpgp.Encrypt(buffer, enveloper.entities, nil, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't encrypt key")
+	}
+	_, err = encryptedWriter.Write(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't encrypt key")
+	}
+	err = encryptedWriter.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't encrypt key")
+	}
+	return envelope.NewEncryptedKey(enveloper.keyID, buffer.Bytes()), nil
+}
+
+func (enveloper *Enveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
+	return envelope.ReadEncryptedKey(r, magic, schemeVersion)
+}
+
+func (enveloper *Enveloper) DecryptKey(encryptedKey *envelope.EncryptedKey) ([]byte, error) {
+	if !hasPrivateKeys(enveloper.entities) {
+		return nil, errors.New("envelope pgp: private key is required to decrypt key")
+	}
+	md, err := openpgp.ReadMessage(bytes.NewReader(encryptedKey.Data), enveloper.entities, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't decrypt key")
+	}
+	return io.ReadAll(md.UnverifiedBody)
+}
+
+func (enveloper *Enveloper) SerializeEncryptedKey(encryptedKey *envelope.EncryptedKey) []byte {
+	return envelope.SerializeEncryptedKey(magic, schemeVersion, encryptedKey)
+}
+
+// EnveloperFromKey creates Enveloper from armored PGP key. The key can be either public (to wrap data keys)
+// or private (to wrap and unwrap data keys).
+func EnveloperFromKey(armoredKey string, loadPassphrase func() (string, bool)) (envelope.EscrowEnveloper, error) {
+	armoredKey = strings.ReplaceAll(armoredKey, `\n`, "\n")
+	return enveloperFromReader(strings.NewReader(armoredKey), loadPassphrase)
+}
+
+// EnveloperFromKeyPath creates Enveloper from armored PGP key path.
+func EnveloperFromKeyPath(armoredKeyPath string, loadPassphrase func() (string, bool)) (envelope.EscrowEnveloper, error) {
+	content, err := os.ReadFile(armoredKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't read key file")
+	}
+	return enveloperFromReader(bytes.NewReader(content), loadPassphrase)
+}
+
+func enveloperFromReader(r io.Reader, loadPassphrase func() (string, bool)) (envelope.EscrowEnveloper, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope pgp: can't read key")
+	}
+
+	if passphrase, ok := loadPassphrase(); ok {
+		err = decryptPrivateKeys(entities, []byte(passphrase))
+		if err != nil {
+			return nil, errors.Wrap(err, "envelope pgp: can't decrypt private key")
+		}
+	}
+
+	parts := make([]string, len(entities))
+	for i, entity := range entities {
+		parts[i] = strings.ToUpper(strconv.FormatUint(entity.PrimaryKey.KeyId, 16))
+	}
+	return &Enveloper{
+		entities: entities,
+		keyID:    strings.Join(parts, ","),
+	}, nil
+}
+
+func hasPrivateKeys(entities openpgp.EntityList) bool {
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func decryptPrivateKeys(entities openpgp.EntityList, passphrase []byte) error {
+	for _, entity := range entities {
+		if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+			err := entity.PrivateKey.Decrypt(passphrase)
+			if err != nil {
+				return err
+			}
+		}
+		for _, subKey := range entity.Subkeys {
+			if subKey.PrivateKey != nil && subKey.PrivateKey.Encrypted {
+				err := subKey.PrivateKey.Decrypt(passphrase)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package pgp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+func noPassphrase() (string, bool) {
+	return "", false
+}
+
+func generateKeys(t *testing.T) (publicKey, privateKey string, keyID string) {
+	entity, err := openpgp.NewEntity("escrow", "", "escrow@example.com", nil)
+	require.NoError(t, err)
+
+	publicBuffer := new(bytes.Buffer)
+	publicWriter, err := armor.Encode(publicBuffer, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(publicWriter))
+	require.NoError(t, publicWriter.Close())
+
+	privateBuffer := new(bytes.Buffer)
+	privateWriter, err := armor.Encode(privateBuffer, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(privateWriter, nil))
+	require.NoError(t, privateWriter.Close())
+
+	keyID = strings.ToUpper(strconv.FormatUint(entity.PrimaryKey.KeyId, 16))
+	return publicBuffer.String(), privateBuffer.String(), keyID
+}
+
+func TestEncryptDecryptKey(t *testing.T) {
+	_, privateKey, keyID := generateKeys(t)
+	enveloper, err := EnveloperFromKey(privateKey, noPassphrase)
+	require.NoError(t, err)
+
+	encryptedKey, err := enveloper.EncryptKey([]byte("data key"))
+	require.NoError(t, err)
+	assert.Equal(t, keyID, encryptedKey.ID())
+
+	decryptedKey, err := enveloper.DecryptKey(encryptedKey)
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(decryptedKey))
+}
+
+func TestPublicKeyOnlyEncrypts(t *testing.T) {
+	publicKey, privateKey, _ := generateKeys(t)
+	keyPath := filepath.Join(t.TempDir(), "escrow.pub")
+	require.NoError(t, os.WriteFile(keyPath, []byte(publicKey), 0600))
+
+	publicEnveloper, err := EnveloperFromKeyPath(keyPath, noPassphrase)
+	require.NoError(t, err)
+	encryptedKey, err := publicEnveloper.EncryptKey([]byte("data key"))
+	require.NoError(t, err)
+
+	_, err = publicEnveloper.DecryptKey(encryptedKey)
+	assert.Error(t, err)
+
+	privateEnveloper, err := EnveloperFromKey(privateKey, noPassphrase)
+	require.NoError(t, err)
+	decryptedKey, err := privateEnveloper.DecryptKey(encryptedKey)
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(decryptedKey))
+}
+
+func TestSerializeDeserializeKeyHeader(t *testing.T) {
+	expected := envelope.NewEncryptedKey("example", []byte("encrypted key"))
+	encryptedKey, err := new(Enveloper).ReadEncryptedKey(bytes.NewReader(new(Enveloper).SerializeEncryptedKey(expected)))
+	require.NoError(t, err)
+
+	assert.Equal(t, expected.ID(), encryptedKey.ID())
+	assert.Equal(t, expected.Data, encryptedKey.Data)
+}
+
+func TestReadInvalidKeyHeader(t *testing.T) {
+	_, err := new(Enveloper).ReadEncryptedKey(strings.NewReader("envelope-yc-kms\x01"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	// the token is renewed a bit earlier than it expires to not fail in-flight requests
+	tokenExpirationMargin = 10 * time.Second
+)
+
+// ClientConfig describes how to reach Vault and authenticate in it. Either Token or RoleID with SecretID
+// should be set.
+type ClientConfig struct {
+	Address    string
+	Namespace  string
+	CACertPath string
+
+	Token string
+
+	RoleID       string
+	SecretID     string
+	AppRoleMount string
+}
+
+// client is a minimal client of the Vault HTTP API.
+type client struct {
+	config     ClientConfig
+	httpClient *http.Client
+
+	token          string
+	tokenExpiresAt time.Time
+	mutex          sync.Mutex
+}
+
+type errorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+type loginResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+func newClient(config ClientConfig) (*client, error) {
+	if config.Address == "" {
+		return nil, errors.New("vault address must be configured")
+	}
+	if config.Token == "" && (config.RoleID == "" || config.SecretID == "") {
+		return nil, errors.New("vault token or AppRole role ID and secret ID must be configured")
+	}
+	if config.AppRoleMount == "" {
+		config.AppRoleMount = "approle"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CACertPath != "" {
+		caCert, err := os.ReadFile(config.CACertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read vault CA certificate")
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, errors.Errorf("no certificates found in vault CA certificate file %q", config.CACertPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &client{
+		config:     config,
+		httpClient: &http.Client{Transport: transport, Timeout: requestTimeout},
+	}, nil
+}
+
+// write performs the POST request to the Vault API path, e.g. "transit/decrypt/my-key", and decodes the response.
+// If the AppRole token is rejected, it logs in again and retries the request once.
+func (c *client) write(ctx context.Context, path string, body, response any) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := c.do(ctx, path, token, body, response)
+	if status == http.StatusForbidden && c.config.Token == "" {
+		c.resetToken(token)
+		token, err = c.getToken(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = c.do(ctx, path, token, body, response)
+	}
+	return err
+}
+
+func (c *client) do(ctx context.Context, path, token string, body, response any) (int, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	url := strings.TrimSuffix(c.config.Address, "/") + "/v1/" + path
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		request.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, errors.Wrapf(err, "vault request %q", path)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "read vault response %q", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResponse errorResponse
+		_ = json.Unmarshal(responseBody, &errResponse)
+		return resp.StatusCode, fmt.Errorf("vault request %q failed with status %d: %s",
+			path, resp.StatusCode, strings.Join(errResponse.Errors, "; "))
+	}
+	err = json.Unmarshal(responseBody, response)
+	if err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "decode vault response %q", path)
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *client) getToken(ctx context.Context) (string, error) {
+	if c.config.Token != "" {
+		return c.config.Token, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" && (c.tokenExpiresAt.IsZero() || time.Now().Before(c.tokenExpiresAt)) {
+		return c.token, nil
+	}
+
+	var response loginResponse
+	_, err := c.do(ctx, "auth/"+c.config.AppRoleMount+"/login", "", map[string]string{
+		"role_id":   c.config.RoleID,
+		"secret_id": c.config.SecretID,
+	}, &response)
+	if err != nil {
+		return "", errors.Wrap(err, "vault AppRole login")
+	}
+	if response.Auth.ClientToken == "" {
+		return "", errors.New("vault AppRole login: no client token in response")
+	}
+
+	c.token = response.Auth.ClientToken
+	c.tokenExpiresAt = time.Time{}
+	if response.Auth.LeaseDuration > 0 {
+		leaseDuration := time.Duration(response.Auth.LeaseDuration) * time.Second
+		c.tokenExpiresAt = time.Now().Add(max(leaseDuration-tokenExpirationMargin, leaseDuration/2))
+	}
+	return c.token, nil
+}
+
+func (c *client) resetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
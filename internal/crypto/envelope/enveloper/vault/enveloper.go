@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+const (
+	magic              = "envelope-vault"
+	schemeVersion byte = 1
+)
+
+// Enveloper decrypts and encrypts keys with the HashiCorp Vault Transit secrets engine, so the master key never
+// leaves Vault.
+type Enveloper struct {
+	keyName string
+	mount   string
+	client  *client
+}
+
+type transitRequest struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+type transitResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+}
+
+func (enveloper *Enveloper) Name() string {
+	return "vault"
+}
+
+func (enveloper *Enveloper) KeyID() string {
+	return enveloper.keyName
+}
+
+func (enveloper *Enveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
+	return envelope.ReadEncryptedKey(r, magic, schemeVersion)
+}
+
+func (enveloper *Enveloper) DecryptKey(encryptedKey *envelope.EncryptedKey) ([]byte, error) {
+	var response transitResponse
+	err := enveloper.client.write(context.Background(), enveloper.mount+"/decrypt/"+enveloper.keyName,
+		transitRequest{Ciphertext: string(encryptedKey.Data)}, &response)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(response.Data.Plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "envelope vault: can't decode decrypted key")
+	}
+	return key, nil
+}
+
+// EncryptKey wraps the key with the Vault transit key, so Vault can be used as an escrow enveloper as well.
+func (enveloper *Enveloper) EncryptKey(key []byte) (*envelope.EncryptedKey, error) {
+	var response transitResponse
+	err := enveloper.client.write(context.Background(), enveloper.mount+"/encrypt/"+enveloper.keyName,
+		transitRequest{Plaintext: base64.StdEncoding.EncodeToString(key)}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Data.Ciphertext == "" {
+		return nil, errors.New("envelope vault: no ciphertext in response")
+	}
+	return envelope.NewEncryptedKey(enveloper.keyName, []byte(response.Data.Ciphertext)), nil
+}
+
+func (enveloper *Enveloper) SerializeEncryptedKey(encryptedKey *envelope.EncryptedKey) []byte {
+	return envelope.SerializeEncryptedKey(magic, schemeVersion, encryptedKey)
+}
+
+// EnveloperFromTransitKey creates Enveloper that uses the transit key keyName of the transit engine mounted at mount.
+func EnveloperFromTransitKey(keyName, mount string, config ClientConfig) (envelope.EscrowEnveloper, error) {
+	if keyName == "" {
+		return nil, errors.New("vault transit key name must be configured")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	vaultClient, err := newClient(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't initialize vault client")
+	}
+	return &Enveloper{
+		keyName: keyName,
+		mount:   mount,
+		client:  vaultClient,
+	}, nil
+}
@@ -0,0 +1,182 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+)
+
+const (
+	testRoleID   = "role-id"
+	testSecretID = "secret-id"
+	testKeyName  = "wal-g"
+)
+
+// fakeVault is a stand-in for the Vault dev server with AppRole auth and transit engine mounted at default paths.
+// Ciphertexts are plaintexts with the "vault:v1:" prefix, which is enough to check the enveloper.
+type fakeVault struct {
+	mutex        sync.Mutex
+	validTokens  map[string]bool
+	logins       int
+	transitCalls int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	vault := &fakeVault{validTokens: map[string]bool{"root": true}}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	return vault, server
+}
+
+func (vault *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vault.mutex.Lock()
+	defer vault.mutex.Unlock()
+
+	var request map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeResponse(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		if request["role_id"] != testRoleID || request["secret_id"] != testSecretID {
+			writeResponse(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		vault.logins++
+		token := fmt.Sprintf("token-%d", vault.logins)
+		vault.validTokens[token] = true
+		writeResponse(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": token, "lease_duration": 3600}})
+		return
+	}
+
+	if !vault.validTokens[r.Header.Get("X-Vault-Token")] {
+		writeResponse(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+	vault.transitCalls++
+	switch r.URL.Path {
+	case "/v1/transit/encrypt/" + testKeyName:
+		writeResponse(w, http.StatusOK, map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + request["plaintext"]}})
+	case "/v1/transit/decrypt/" + testKeyName:
+		if !strings.HasPrefix(request["ciphertext"], "vault:v1:") {
+			writeResponse(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid ciphertext"}})
+			return
+		}
+		plaintext := strings.TrimPrefix(request["ciphertext"], "vault:v1:")
+		writeResponse(w, http.StatusOK, map[string]any{"data": map[string]string{"plaintext": plaintext}})
+	default:
+		writeResponse(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+	}
+}
+
+func (vault *fakeVault) revokeTokens() {
+	vault.mutex.Lock()
+	defer vault.mutex.Unlock()
+	vault.validTokens = map[string]bool{}
+}
+
+func writeResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestEncryptDecryptKeyWithToken(t *testing.T) {
+	_, server := newFakeVault(t)
+	enveloper, err := EnveloperFromTransitKey(testKeyName, "", ClientConfig{Address: server.URL, Token: "root"})
+	require.NoError(t, err)
+
+	encryptedKey, err := enveloper.EncryptKey([]byte("data key"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(encryptedKey.Data, []byte("vault:v1:")))
+
+	key, err := enveloper.DecryptKey(encryptedKey)
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(key))
+}
+
+func TestAppRoleLoginIsReused(t *testing.T) {
+	vault, server := newFakeVault(t)
+	enveloper, err := EnveloperFromTransitKey(testKeyName, "transit", ClientConfig{
+		Address:  server.URL,
+		RoleID:   testRoleID,
+		SecretID: testSecretID,
+	})
+	require.NoError(t, err)
+
+	encryptedKey, err := enveloper.EncryptKey([]byte("data key"))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		key, err := enveloper.DecryptKey(encryptedKey)
+		require.NoError(t, err)
+		assert.Equal(t, "data key", string(key))
+	}
+	assert.Equal(t, 1, vault.logins)
+	assert.Equal(t, 4, vault.transitCalls)
+}
+
+func TestAppRoleLoginAfterTokenRevoked(t *testing.T) {
+	vault, server := newFakeVault(t)
+	enveloper, err := EnveloperFromTransitKey(testKeyName, "", ClientConfig{
+		Address:  server.URL,
+		RoleID:   testRoleID,
+		SecretID: testSecretID,
+	})
+	require.NoError(t, err)
+
+	encryptedKey, err := enveloper.EncryptKey([]byte("data key"))
+	require.NoError(t, err)
+
+	vault.revokeTokens()
+	key, err := enveloper.DecryptKey(encryptedKey)
+	require.NoError(t, err)
+	assert.Equal(t, "data key", string(key))
+	assert.Equal(t, 2, vault.logins)
+}
+
+func TestInvalidCredentials(t *testing.T) {
+	_, server := newFakeVault(t)
+
+	enveloper, err := EnveloperFromTransitKey(testKeyName, "", ClientConfig{Address: server.URL, Token: "invalid"})
+	require.NoError(t, err)
+	_, err = enveloper.DecryptKey(envelope.NewEncryptedKey("", []byte("vault:v1:ZGF0YQ==")))
+	assert.ErrorContains(t, err, "permission denied")
+
+	enveloper, err = EnveloperFromTransitKey(testKeyName, "", ClientConfig{
+		Address:  server.URL,
+		RoleID:   testRoleID,
+		SecretID: "invalid",
+	})
+	require.NoError(t, err)
+	_, err = enveloper.DecryptKey(envelope.NewEncryptedKey("", []byte("vault:v1:ZGF0YQ==")))
+	assert.ErrorContains(t, err, "invalid role or secret ID")
+}
+
+func TestMissingConfiguration(t *testing.T) {
+	_, err := EnveloperFromTransitKey("", "", ClientConfig{Address: "http://localhost:8200", Token: "root"})
+	assert.Error(t, err)
+	_, err = EnveloperFromTransitKey(testKeyName, "", ClientConfig{Token: "root"})
+	assert.Error(t, err)
+	_, err = EnveloperFromTransitKey(testKeyName, "", ClientConfig{Address: "http://localhost:8200", RoleID: testRoleID})
+	assert.Error(t, err)
+}
+
+func TestSerializeDeserializeKeyHeader(t *testing.T) {
+	expected := envelope.NewEncryptedKey("example", []byte("vault:v1:encrypted key"))
+	encryptedKey, err := new(Enveloper).ReadEncryptedKey(bytes.NewReader(new(Enveloper).SerializeEncryptedKey(expected)))
+	require.NoError(t, err)
+
+	assert.Equal(t, expected.ID(), encryptedKey.ID())
+	assert.Equal(t, expected.Data, encryptedKey.Data)
+}
@@ -2,7 +2,6 @@ package yckms
 
 import (
 	"context"
-	"encoding/binary"
 	"io"
 
 	"github.com/pkg/errors"
@@ -10,14 +9,12 @@ import (
 	ycsdk "github.com/yandex-cloud/go-sdk"
 	"github.com/yandex-cloud/go-sdk/iamkey"
 
-	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/crypto/envelope"
 )
 
 const (
 	magic              = "envelope-yc-kms"
 	schemeVersion byte = 1
-	sizeofInt32        = 4
 )
 
 type Enveloper struct {
@@ -29,8 +26,12 @@ func (enveloper *Enveloper) Name() string {
 	return "yckms"
 }
 
+func (enveloper *Enveloper) KeyID() string {
+	return enveloper.keyID
+}
+
 func (enveloper *Enveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
-	return readEncryptedKey(r)
+	return envelope.ReadEncryptedKey(r, magic, schemeVersion)
 }
 
 func (enveloper *Enveloper) DecryptKey(encryptedKey *envelope.EncryptedKey) ([]byte, error) {
@@ -49,79 +50,7 @@ func (enveloper *Enveloper) DecryptKey(encryptedKey *envelope.EncryptedKey) ([]b
 }
 
 func (enveloper *Enveloper) SerializeEncryptedKey(encryptedKey *envelope.EncryptedKey) []byte {
-	return serializeEncryptedKey(encryptedKey)
-}
-
-func serializeEncryptedKey(encryptedKey *envelope.EncryptedKey) []byte {
-	/*
-		magic value "envelope-yc-kms"
-		scheme version (current version is 1)
-		uint32 - keyID len
-		keyID ...
-		uint32 - encrypted key len
-		encrypted key ...
-	*/
-
-	result := append([]byte(magic), schemeVersion)
-
-	keyID := encryptedKey.ID()
-	keyIDLen := make([]byte, sizeofInt32)
-	binary.LittleEndian.PutUint32(keyIDLen, uint32(len(keyID)))
-	result = append(result, keyIDLen...)
-	result = append(result, []byte(keyID)...)
-
-	encryptedKeyLen := make([]byte, sizeofInt32)
-	binary.LittleEndian.PutUint32(encryptedKeyLen, uint32(len(encryptedKey.Data)))
-	result = append(result, encryptedKeyLen...)
-	return append(result, encryptedKey.Data...)
-}
-
-func readEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
-	magicSchemeBytes := make([]byte, len(magic)+1)
-	_, err := io.ReadFull(r, magicSchemeBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	if string(magicSchemeBytes[0:len(magic)]) != magic {
-		return nil, errors.New("envelope yc kms: invalid encrypted header format")
-	}
-
-	if schemeVersion != magicSchemeBytes[len(magic)] {
-		return nil, errors.New("envelope yc kms: scheme version is not supported")
-	}
-
-	keyIDLenBytes := make([]byte, sizeofInt32)
-	_, err = io.ReadFull(r, keyIDLenBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	keyIDLen := binary.LittleEndian.Uint32(keyIDLenBytes)
-	keyIDBytes := make([]byte, keyIDLen)
-
-	_, err = io.ReadFull(r, keyIDBytes)
-	if err != nil {
-		return nil, err
-	}
-	keyID := string(keyIDBytes)
-	tracelog.DebugLogger.Printf("Encrypted key was found: %s\n", keyID)
-
-	encryptedKeyLenBytes := make([]byte, sizeofInt32)
-	_, err = io.ReadFull(r, encryptedKeyLenBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	encryptedKeyLen := binary.LittleEndian.Uint32(encryptedKeyLenBytes)
-	encryptedKey := make([]byte, encryptedKeyLen)
-
-	_, err = io.ReadFull(r, encryptedKey)
-	if err != nil {
-		return nil, err
-	}
-
-	return envelope.NewEncryptedKey(keyID, encryptedKey), nil
+	return envelope.SerializeEncryptedKey(magic, schemeVersion, encryptedKey)
 }
 
 func getCredentials(saFilePath string) (ycsdk.Credentials, error) {
@@ -16,10 +16,10 @@ func TestSerializeDeserializeKeyHeader(t *testing.T) {
 	buffer := new(bytes.Buffer)
 
 	expected := envelope.NewEncryptedKey("example", []byte("encrypted key"))
-	serializedKey := serializeEncryptedKey(expected)
+	serializedKey := new(Enveloper).SerializeEncryptedKey(expected)
 	buffer.Write(serializedKey)
 
-	encryptedKey, err := readEncryptedKey(buffer)
+	encryptedKey, err := new(Enveloper).ReadEncryptedKey(buffer)
 	assert.NoErrorf(t, err, "YcKms envelope key deserialization error: %v", err)
 
 	assert.Equal(t, expected.ID(), encryptedKey.ID(), "YcKms deserialized envelope key len is not equal to the original one")
@@ -34,14 +34,14 @@ func TestHugeKey(t *testing.T) {
 	reader, writer := io.Pipe()
 
 	expected := envelope.NewEncryptedKey("example", []byte(strings.Repeat("awesomekey", 512)))
-	serializedKey := serializeEncryptedKey(expected)
+	serializedKey := new(Enveloper).SerializeEncryptedKey(expected)
 	go func() {
 		defer writer.Close()
 		writer.Write(serializedKey)
 	}()
 	breader := bufio.NewReaderSize(reader, 16)
 
-	encryptedKey, err := readEncryptedKey(breader)
+	encryptedKey, err := new(Enveloper).ReadEncryptedKey(breader)
 	assert.NoErrorf(t, err, "YcKms envelope key deserialization error: %v", err)
 
 	assert.Equal(t, expected.ID(), encryptedKey.ID(), "YcKms deserialized envelope key len is not equal to the original one")
@@ -0,0 +1,165 @@
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	multiKeyMagic              = "envelope-multi"
+	multiKeySchemeVersion byte = 1
+
+	maxWrappedKeys = 16
+)
+
+// EscrowEnveloper is an Enveloper that is able to encrypt data keys by itself, e.g. with a public key. The data key
+// is additionally wrapped for each escrow enveloper, so the data can be decrypted with any of them.
+type EscrowEnveloper interface {
+	Enveloper
+	EncryptKey(key []byte) (*EncryptedKey, error)
+}
+
+// KeyIdentifier is implemented by envelopers that know the ID of their key, so the wrapped keys are matched to them
+// exactly when several envelopers of the same type are configured.
+type KeyIdentifier interface {
+	KeyID() string
+}
+
+// KeyDecrypter is implemented by envelopers that may have only the public part of their key, e.g. the escrow PGP
+// keys on backup hosts, which wrap the data keys but can't unwrap them.
+type KeyDecrypter interface {
+	CanDecryptKey() bool
+}
+
+// WrappedKey is a data key encrypted by a specific enveloper.
+type WrappedKey struct {
+	Enveloper    Enveloper
+	EncryptedKey *EncryptedKey
+}
+
+// SerializeWrappedKeys serializes several wrapped keys into a single header. Each key is serialized by its enveloper.
+func SerializeWrappedKeys(keys []WrappedKey) []byte {
+	/*
+		magic value "envelope-multi"
+		scheme version (current version is 1)
+		uint32 - number of keys
+		for each key:
+			uint32 - enveloper name len
+			enveloper name ...
+			uint32 - serialized key len
+			serialized key ...
+	*/
+
+	result := append([]byte(multiKeyMagic), multiKeySchemeVersion)
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(keys)))
+	for _, key := range keys {
+		result = appendField(result, []byte(key.Enveloper.Name()))
+		result = appendField(result, key.Enveloper.SerializeEncryptedKey(key.EncryptedKey))
+	}
+	return result
+}
+
+// HasWrappedKeys checks if the reader starts with the header written by SerializeWrappedKeys.
+func HasWrappedKeys(r *bufio.Reader) bool {
+	magicBytes, err := r.Peek(len(multiKeyMagic))
+	return err == nil && string(magicBytes) == multiKeyMagic
+}
+
+// ReadWrappedKeys reads the header written by SerializeWrappedKeys. Only keys of the given envelopers are returned,
+// the keys wrapped by unknown envelopers are skipped. If several envelopers of the same type are given, e.g. with
+// different KMS keys, the key is paired with each of them, the ones with the matching key ID go first.
+func ReadWrappedKeys(r io.Reader, envelopers ...Enveloper) ([]WrappedKey, error) {
+	magicSchemeBytes := make([]byte, len(multiKeyMagic)+1)
+	_, err := io.ReadFull(r, magicSchemeBytes)
+	if err != nil {
+		return nil, err
+	}
+	if string(magicSchemeBytes[0:len(multiKeyMagic)]) != multiKeyMagic {
+		return nil, errors.New("envelope: invalid multi-key header format")
+	}
+	if multiKeySchemeVersion != magicSchemeBytes[len(multiKeyMagic)] {
+		return nil, errors.New("envelope: multi-key scheme version is not supported")
+	}
+
+	count, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxWrappedKeys {
+		return nil, fmt.Errorf("envelope: too many wrapped keys in header: %d", count)
+	}
+
+	envelopersByName := make(map[string][]Enveloper, len(envelopers))
+	for _, enveloper := range envelopers {
+		envelopersByName[enveloper.Name()] = append(envelopersByName[enveloper.Name()], enveloper)
+	}
+
+	var keys []WrappedKey
+	for i := uint32(0); i < count; i++ {
+		name, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		serializedKey, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, ok := envelopersByName[string(name)]
+		if !ok {
+			tracelog.DebugLogger.Printf("Skip key wrapped by unknown enveloper %q", name)
+			continue
+		}
+		// envelopers of the same type share the serialization format
+		encryptedKey, err := candidates[0].ReadEncryptedKey(bytes.NewReader(serializedKey))
+		if err != nil {
+			return nil, fmt.Errorf("envelope: read key wrapped by %q: %w", name, err)
+		}
+		for _, enveloper := range orderByKeyID(candidates, encryptedKey.ID()) {
+			keys = append(keys, WrappedKey{Enveloper: enveloper, EncryptedKey: encryptedKey})
+		}
+	}
+	return keys, nil
+}
+
+// orderByKeyID puts the envelopers of the key with the given ID first, keeping the order otherwise.
+func orderByKeyID(envelopers []Enveloper, keyID string) []Enveloper {
+	if len(envelopers) == 1 {
+		return envelopers
+	}
+	ordered := make([]Enveloper, 0, len(envelopers))
+	var rest []Enveloper
+	for _, enveloper := range envelopers {
+		if identifier, ok := enveloper.(KeyIdentifier); ok && identifier.KeyID() == keyID {
+			ordered = append(ordered, enveloper)
+		} else {
+			rest = append(rest, enveloper)
+		}
+	}
+	return append(ordered, rest...)
+}
+
+// DecryptWrappedKeys decrypts the data key with the first enveloper that succeeds.
+func DecryptWrappedKeys(keys []WrappedKey) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("envelope: no keys wrapped by the configured envelopers")
+	}
+
+	var errs []error
+	for _, key := range keys {
+		decryptedKey, err := key.Enveloper.DecryptKey(key.EncryptedKey)
+		if err == nil {
+			return decryptedKey, nil
+		}
+		tracelog.WarningLogger.Printf("Unable to decrypt key %s with %q enveloper: %v",
+			key.EncryptedKey.ID(), key.Enveloper.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", key.Enveloper.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
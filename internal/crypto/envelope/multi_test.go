@@ -0,0 +1,141 @@
+package envelope_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/internal/crypto/envelope/mocks"
+)
+
+func mockedEnveloper(t *testing.T, name string, decryptErr error) *mocks.Enveloper {
+	enveloper := mocks.NewEnveloper(t)
+	enveloper.EXPECT().Name().Return(name).Maybe()
+	enveloper.EXPECT().SerializeEncryptedKey(mock.Anything).RunAndReturn(func(key *envelope.EncryptedKey) []byte {
+		return key.Data
+	}).Maybe()
+	enveloper.EXPECT().ReadEncryptedKey(mock.Anything).RunAndReturn(func(r io.Reader) (*envelope.EncryptedKey, error) {
+		data, err := io.ReadAll(r)
+		return envelope.NewEncryptedKey(name, data), err
+	}).Maybe()
+	enveloper.EXPECT().DecryptKey(mock.Anything).RunAndReturn(func(key *envelope.EncryptedKey) ([]byte, error) {
+		return key.Data, decryptErr
+	}).Maybe()
+	return enveloper
+}
+
+func TestWrappedKeysCycle(t *testing.T) {
+	kms := mockedEnveloper(t, "kms", errors.New("kms is unavailable"))
+	escrow := mockedEnveloper(t, "escrow", nil)
+
+	header := envelope.SerializeWrappedKeys([]envelope.WrappedKey{
+		{Enveloper: kms, EncryptedKey: envelope.NewEncryptedKey("kms", []byte("kms key"))},
+		{Enveloper: escrow, EncryptedKey: envelope.NewEncryptedKey("escrow", []byte("escrow key"))},
+	})
+	reader := bufio.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader([]byte("data"))))
+	require.True(t, envelope.HasWrappedKeys(reader))
+
+	keys, err := envelope.ReadWrappedKeys(reader, kms, escrow)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "kms key", string(keys[0].EncryptedKey.Data))
+	assert.Equal(t, "escrow key", string(keys[1].EncryptedKey.Data))
+
+	key, err := envelope.DecryptWrappedKeys(keys)
+	require.NoError(t, err)
+	assert.Equal(t, "escrow key", string(key))
+
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(rest))
+}
+
+func TestReadWrappedKeysSkipsUnknownEnvelopers(t *testing.T) {
+	kms := mockedEnveloper(t, "kms", nil)
+	escrow := mockedEnveloper(t, "escrow", nil)
+
+	header := envelope.SerializeWrappedKeys([]envelope.WrappedKey{
+		{Enveloper: kms, EncryptedKey: envelope.NewEncryptedKey("kms", []byte("kms key"))},
+		{Enveloper: escrow, EncryptedKey: envelope.NewEncryptedKey("escrow", []byte("escrow key"))},
+	})
+
+	keys, err := envelope.ReadWrappedKeys(bytes.NewReader(header), escrow)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "escrow key", string(keys[0].EncryptedKey.Data))
+
+	keys, err = envelope.ReadWrappedKeys(bytes.NewReader(header))
+	require.NoError(t, err)
+	_, err = envelope.DecryptWrappedKeys(keys)
+	assert.Error(t, err)
+}
+
+func TestHasWrappedKeys(t *testing.T) {
+	assert.False(t, envelope.HasWrappedKeys(bufio.NewReader(bytes.NewReader([]byte("envelope-yc-kms\x01")))))
+	assert.False(t, envelope.HasWrappedKeys(bufio.NewReader(bytes.NewReader(nil))))
+}
+
+// keyEnveloper is a KMS-like enveloper that decrypts only the keys wrapped with its own key ID.
+type keyEnveloper struct {
+	keyID string
+}
+
+func (enveloper keyEnveloper) Name() string {
+	return "kms"
+}
+
+func (enveloper keyEnveloper) KeyID() string {
+	return enveloper.keyID
+}
+
+func (enveloper keyEnveloper) ReadEncryptedKey(r io.Reader) (*envelope.EncryptedKey, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	keyID, key, _ := bytes.Cut(data, []byte(":"))
+	return envelope.NewEncryptedKey(string(keyID), key), nil
+}
+
+func (enveloper keyEnveloper) DecryptKey(key *envelope.EncryptedKey) ([]byte, error) {
+	if key.ID() != enveloper.keyID {
+		return nil, errors.New("wrong key")
+	}
+	return key.Data, nil
+}
+
+func (enveloper keyEnveloper) SerializeEncryptedKey(key *envelope.EncryptedKey) []byte {
+	return append([]byte(key.ID()+":"), key.Data...)
+}
+
+func TestReadWrappedKeysOfSameTypeEnvelopers(t *testing.T) {
+	first := keyEnveloper{keyID: "first"}
+	second := keyEnveloper{keyID: "second"}
+
+	header := envelope.SerializeWrappedKeys([]envelope.WrappedKey{
+		{Enveloper: first, EncryptedKey: envelope.NewEncryptedKey("first", []byte("first key"))},
+		{Enveloper: second, EncryptedKey: envelope.NewEncryptedKey("second", []byte("second key"))},
+	})
+
+	keys, err := envelope.ReadWrappedKeys(bytes.NewReader(header), first, second)
+	require.NoError(t, err)
+	require.Len(t, keys, 4)
+	assert.Equal(t, first, keys[0].Enveloper)
+	assert.Equal(t, "first key", string(keys[0].EncryptedKey.Data))
+	assert.Equal(t, second, keys[2].Enveloper)
+	assert.Equal(t, "second key", string(keys[2].EncryptedKey.Data))
+
+	// only the second key is configured, it still finds its own key
+	keys, err = envelope.ReadWrappedKeys(bytes.NewReader(header), second)
+	require.NoError(t, err)
+	key, err := envelope.DecryptWrappedKeys(keys)
+	require.NoError(t, err)
+	assert.Equal(t, "second key", string(key))
+}
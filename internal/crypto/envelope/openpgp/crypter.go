@@ -21,6 +21,11 @@ import (
 
 const (
 	maxHeaderLenAllowed int = 4096 * 2
+	// header with the data key wrapped for several envelopers
+	maxMultiHeaderLenAllowed = maxHeaderLenAllowed * 8
+
+	// escrowOnlyEnveloperName stands for the enveloper in the name of the crypter configured without KMS
+	escrowOnlyEnveloperName = "escrow"
 )
 
 // Crypter incapsulates specific of cypher method
@@ -29,6 +34,10 @@ type Crypter struct {
 	enveloper    envelope.Enveloper
 	encryptedKey *envelope.EncryptedKey
 
+	// escrows are envelopers that the data key is additionally wrapped for, so it can be decrypted by any of them
+	escrows    []envelope.EscrowEnveloper
+	escrowKeys []envelope.WrappedKey
+
 	ArmoredKey      string
 	IsUseArmoredKey bool
 
@@ -39,13 +48,79 @@ type Crypter struct {
 }
 
 func (crypter *Crypter) Name() string {
-	parts := []string{"Enveloped", crypter.enveloper.Name(), "Opengpg", "Crypter"}
+	enveloperName := escrowOnlyEnveloperName
+	if crypter.enveloper != nil {
+		enveloperName = crypter.enveloper.Name()
+	}
+	parts := []string{"Enveloped", enveloperName, "Opengpg", "Crypter"}
 	return strings.Join(parts, "/")
 }
 
+// MatchesCrypterName tells if the data encrypted by the crypter with the name can be decrypted. With the escrow keys
+// configured, the data encrypted with any enveloper can be, since the data key is wrapped for the escrows as well.
+func (crypter *Crypter) MatchesCrypterName(name string) bool {
+	if name == crypter.Name() {
+		return true
+	}
+	return len(crypter.escrows) > 0 && strings.HasPrefix(name, "Enveloped/") && strings.HasSuffix(name, "/Opengpg/Crypter")
+}
+
+// checkEnveloper fails if no KMS enveloper is configured, so the crypter can only decrypt with the escrow keys
+func (crypter *Crypter) checkEnveloper() error {
+	if crypter.enveloper == nil {
+		return errors.New("opengpg: KMS enveloper is required to encrypt, only escrow keys are configured")
+	}
+	return nil
+}
+
+// KeyFingerprints returns the IDs of the keys the data key is wrapped with: the KMS key and the escrow keys.
+// The wrapped data key is stored in the header of each object, so it can be rotated without affecting restores.
+// No KMS request is made.
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	return crypter.wrappingKeyFingerprints(false), nil
+}
+
+// DecryptionKeyFingerprints returns the IDs of the wrapping keys that are able to unwrap the data key,
+// the escrow keys without the private part are skipped
+func (crypter *Crypter) DecryptionKeyFingerprints() ([]string, error) {
+	return crypter.wrappingKeyFingerprints(true), nil
+}
+
+func (crypter *Crypter) wrappingKeyFingerprints(decryptionOnly bool) []string {
+	var fingerprints []string
+	if crypter.enveloper != nil {
+		fingerprints = append(fingerprints, enveloperKeyFingerprints(crypter.enveloper)...)
+	}
+	for _, escrow := range crypter.escrows {
+		if decrypter, ok := escrow.(envelope.KeyDecrypter); ok && decryptionOnly && !decrypter.CanDecryptKey() {
+			continue
+		}
+		fingerprints = append(fingerprints, enveloperKeyFingerprints(escrow)...)
+	}
+	return fingerprints
+}
+
+// enveloperKeyFingerprints qualifies the key IDs of the enveloper with its name, e.g. yckms:<key ID>
+func enveloperKeyFingerprints(enveloper envelope.Enveloper) []string {
+	identifier, ok := enveloper.(envelope.KeyIdentifier)
+	if !ok || identifier.KeyID() == "" {
+		return nil
+	}
+	keyIDs := strings.Split(identifier.KeyID(), ",")
+	fingerprints := make([]string, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		fingerprints = append(fingerprints, enveloper.Name()+":"+keyID)
+	}
+	return fingerprints
+}
+
 // Encrypt creates encryption writer from ordinary writer
 func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
-	err := crypter.setupEncryptedKey()
+	err := crypter.checkEnveloper()
+	if err != nil {
+		return nil, err
+	}
+	err = crypter.setupEncryptedKey()
 	if err != nil {
 		return nil, err
 	}
@@ -62,14 +137,13 @@ func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 		return nil, errors.Wrapf(err, "can't encode gpg key id")
 	}
 
-	// need write header at first, with length less than maxHeaderLenAllowed
-	bufferedWriter := bufio.NewWriterSize(writer, maxHeaderLenAllowed)
-
-	header := crypter.enveloper.SerializeEncryptedKey(crypter.encryptedKey.WithID(keyID))
-
-	if len(header) > maxHeaderLenAllowed {
-		return nil, errors.New("opengpg: invalid size of the encrypted key")
+	header, err := crypter.serializeHeader(key, keyID)
+	if err != nil {
+		return nil, err
 	}
+
+	// need write header at first, along with the beginning of the encrypted data
+	bufferedWriter := bufio.NewWriterSize(writer, max(len(header), maxHeaderLenAllowed))
 	_, err = bufferedWriter.Write(header)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can't write encryption key to buffer")
@@ -88,15 +162,10 @@ func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	// need read header at first, with length less than maxHeaderLenAllowed
 	bufferedReader := bufio.NewReaderSize(reader, maxHeaderLenAllowed)
-	encryptedKey, err := crypter.enveloper.ReadEncryptedKey(bufferedReader)
+	key, err := crypter.readHeader(bufferedReader)
 	if err != nil {
 		return nil, err
 	}
-	var key []byte
-	key, err = crypter.enveloper.DecryptKey(encryptedKey)
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't decrypt encryption key")
-	}
 	secretKey, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
 	if err != nil {
 		return nil, errors.Wrapf(err, "can't read decrypyed gpg key")
@@ -111,6 +180,93 @@ func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
 	return md.UnverifiedBody, nil
 }
 
+// serializeHeader serializes the encrypted key. If there are escrows, the data key wrapped for them is stored as well.
+func (crypter *Crypter) serializeHeader(key []byte, keyID string) ([]byte, error) {
+	if len(crypter.escrows) == 0 {
+		header := crypter.enveloper.SerializeEncryptedKey(crypter.encryptedKey.WithID(keyID))
+		if len(header) > maxHeaderLenAllowed {
+			return nil, errors.New("opengpg: invalid size of the encrypted key")
+		}
+		return header, nil
+	}
+
+	escrowKeys, err := crypter.setupEscrowKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	keys := append([]envelope.WrappedKey{{
+		Enveloper:    crypter.enveloper,
+		EncryptedKey: crypter.encryptedKey.WithID(keyID),
+	}}, escrowKeys...)
+	header := envelope.SerializeWrappedKeys(keys)
+	if len(header) > maxMultiHeaderLenAllowed {
+		return nil, errors.New("opengpg: invalid size of the encrypted keys")
+	}
+	return header, nil
+}
+
+// readHeader reads the encrypted key and decrypts it. The data key wrapped for several envelopers is decrypted
+// by the first one that succeeds.
+func (crypter *Crypter) readHeader(reader *bufio.Reader) ([]byte, error) {
+	if !envelope.HasWrappedKeys(reader) {
+		if crypter.enveloper == nil {
+			return nil, errors.New("opengpg: the key is wrapped only by KMS, but no KMS enveloper is configured")
+		}
+		encryptedKey, err := crypter.enveloper.ReadEncryptedKey(reader)
+		if err != nil {
+			return nil, err
+		}
+		key, err := crypter.enveloper.DecryptKey(encryptedKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't decrypt encryption key")
+		}
+		return key, nil
+	}
+
+	var envelopers []envelope.Enveloper
+	if crypter.enveloper != nil {
+		envelopers = append(envelopers, crypter.enveloper)
+	}
+	for _, escrow := range crypter.escrows {
+		envelopers = append(envelopers, escrow)
+	}
+	keys, err := envelope.ReadWrappedKeys(reader, envelopers...)
+	if err != nil {
+		return nil, err
+	}
+	key, err := envelope.DecryptWrappedKeys(keys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't decrypt encryption key")
+	}
+	return key, nil
+}
+
+// setupEscrowKeys wraps the data key for the escrows once, since the data key is the same for all the objects.
+func (crypter *Crypter) setupEscrowKeys(key []byte) ([]envelope.WrappedKey, error) {
+	crypter.mutex.RLock()
+	if crypter.escrowKeys != nil {
+		defer crypter.mutex.RUnlock()
+		return crypter.escrowKeys, nil
+	}
+	crypter.mutex.RUnlock()
+
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.escrowKeys != nil {
+		return crypter.escrowKeys, nil
+	}
+	escrowKeys := make([]envelope.WrappedKey, 0, len(crypter.escrows))
+	for _, escrow := range crypter.escrows {
+		encryptedKey, err := escrow.EncryptKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't wrap encryption key for %s escrow", escrow.Name())
+		}
+		escrowKeys = append(escrowKeys, envelope.WrappedKey{Enveloper: escrow, EncryptedKey: encryptedKey})
+	}
+	crypter.escrowKeys = escrowKeys
+	return escrowKeys, nil
+}
+
 func (crypter *Crypter) setupEncryptedKey() error {
 	crypter.mutex.RLock()
 	if crypter.encryptedKey != nil {
@@ -145,23 +301,32 @@ func (crypter *Crypter) setupEncryptedKey() error {
 }
 
 // CrypterFromKey creates Crypter from encrypted armored key.
-func CrypterFromKey(armoredKey string, enveloper envelope.Enveloper) crypto.Crypter {
+// The key is additionally wrapped for escrows, if any.
+func CrypterFromKey(armoredKey string, enveloper envelope.Enveloper, escrows ...envelope.EscrowEnveloper) crypto.Crypter {
 	return &Crypter{
 		ArmoredKey:      armoredKey,
 		IsUseArmoredKey: true,
 		enveloper:       enveloper,
+		escrows:         escrows,
 	}
 }
 
 // CrypterFromKeyPath creates Crypter from encrypted armored key path.
-func CrypterFromKeyPath(armoredKeyPath string, enveloper envelope.Enveloper) crypto.Crypter {
+// The key is additionally wrapped for escrows, if any.
+func CrypterFromKeyPath(armoredKeyPath string, enveloper envelope.Enveloper, escrows ...envelope.EscrowEnveloper) crypto.Crypter {
 	return &Crypter{
 		ArmoredKeyPath:      armoredKeyPath,
 		IsUseArmoredKeyPath: true,
 		enveloper:           enveloper,
+		escrows:             escrows,
 	}
 }
 
+// CrypterFromEscrows creates Crypter that only decrypts, with the private escrow keys, when KMS isn't available.
+func CrypterFromEscrows(escrows ...envelope.EscrowEnveloper) crypto.Crypter {
+	return &Crypter{escrows: escrows}
+}
+
 func readFromString(content string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(content)
 }
@@ -1,17 +1,21 @@
 package openpgp
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"testing"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/crypto/envelope"
+	"github.com/wal-g/wal-g/internal/crypto/envelope/enveloper/pgp"
 	"github.com/wal-g/wal-g/internal/crypto/envelope/mocks"
 )
 
@@ -122,3 +126,108 @@ func TestEncodeMultiKeyID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "3BE0C94F8BDCA96B,F1A31F9064762905", keyID, "Key id is mismatch")
 }
+
+func escrowEnveloper(t *testing.T) envelope.EscrowEnveloper {
+	escrow, err := pgp.EnveloperFromKeyPath(PrivateAnotherKeyFilePath, func() (string, bool) { return "", false })
+	assert.NoError(t, err)
+	return escrow
+}
+
+func TestEncryptionCycleWithEscrow(t *testing.T) {
+	enveloper := MockedEnveloper(t)
+	EncryptionCycle(t, CrypterFromKeyPath(PrivateEncryptedKeyFilePath, enveloper, escrowEnveloper(t)))
+}
+
+func TestDecryptWithEscrowWhenEnveloperFails(t *testing.T) {
+	key, err := os.ReadFile(PrivateKeyFilePath)
+	assert.NoError(t, err)
+	enveloper := mocks.NewEnveloper(t)
+	enveloper.EXPECT().Name().Return("mocked").Maybe()
+	enveloper.EXPECT().ReadEncryptedKey(mock.Anything).Return(envelope.NewEncryptedKey(dummyKey, []byte("")), nil).Maybe()
+	enveloper.EXPECT().SerializeEncryptedKey(mock.Anything).Return([]byte("")).Maybe()
+	enveloper.EXPECT().DecryptKey(mock.Anything).Return(key, nil).Once()
+	enveloper.EXPECT().DecryptKey(mock.Anything).Return(nil, errors.New("kms is unavailable")).Once()
+
+	EncryptionCycle(t, CrypterFromKeyPath(PrivateEncryptedKeyFilePath, enveloper, escrowEnveloper(t)))
+}
+
+func TestDecryptWithoutEscrowKeepsSingleKeyHeader(t *testing.T) {
+	enveloper := MockedEnveloper(t)
+	crypter := MockArmedCrypterFromKeyPath(enveloper)
+
+	buf := new(bytes.Buffer)
+	encrypt, err := crypter.Encrypt(buf)
+	assert.NoError(t, err)
+	assert.NoError(t, encrypt.Close())
+
+	assert.False(t, envelope.HasWrappedKeys(bufio.NewReader(buf)))
+}
+
+func TestDecryptWithEscrowOnly(t *testing.T) {
+	enveloper := MockedEnveloper(t)
+	crypter := CrypterFromKeyPath(PrivateEncryptedKeyFilePath, enveloper, escrowEnveloper(t))
+	buf := new(bytes.Buffer)
+	encrypt, err := crypter.Encrypt(buf)
+	assert.NoError(t, err)
+	_, err = encrypt.Write([]byte("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, encrypt.Close())
+
+	escrowCrypter := CrypterFromEscrows(escrowEnveloper(t))
+	decrypted, err := escrowCrypter.Decrypt(buf)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(decrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(content))
+
+	_, err = escrowCrypter.Encrypt(new(bytes.Buffer))
+	assert.Error(t, err)
+	matcher := escrowCrypter.(crypto.CrypterNameMatcher)
+	assert.True(t, matcher.MatchesCrypterName(crypter.Name()))
+	assert.False(t, matcher.MatchesCrypterName("Age"))
+}
+
+func TestKeyFingerprintsOfWrappingKeys(t *testing.T) {
+	kmsEnveloper := &keyIDEnveloper{Enveloper: MockedEnveloper(t), keyID: "kms-key"}
+	publicEscrow, err := pgp.EnveloperFromKey(publicKeyOf(t, PrivateAnotherKeyFilePath), func() (string, bool) { return "", false })
+	assert.NoError(t, err)
+	pushCrypter := CrypterFromKeyPath(PrivateEncryptedKeyFilePath, kmsEnveloper, publicEscrow)
+	info := crypto.GetEncryptionInfo(pushCrypter)
+	escrowFingerprint := "pgp:" + publicEscrow.(envelope.KeyIdentifier).KeyID()
+	assert.Equal(t, "mocked:kms-key,"+escrowFingerprint, info.KeyFingerprint)
+
+	// the envelope key is rotated, the KMS key is the same
+	rotatedCrypter := CrypterFromKey("rotated", kmsEnveloper)
+	assert.NoError(t, crypto.CheckEncryptionInfo(info, rotatedCrypter))
+	// only the private escrow key is configured
+	assert.NoError(t, crypto.CheckEncryptionInfo(info, CrypterFromEscrows(escrowEnveloper(t))))
+	// the public escrow key can't decrypt
+	publicOnlyFingerprints, err := CrypterFromEscrows(publicEscrow).(crypto.DecryptionKeyFingerprinter).DecryptionKeyFingerprints()
+	assert.NoError(t, err)
+	assert.Empty(t, publicOnlyFingerprints)
+}
+
+type keyIDEnveloper struct {
+	envelope.Enveloper
+	keyID string
+}
+
+func (enveloper *keyIDEnveloper) KeyID() string {
+	return enveloper.keyID
+}
+
+func publicKeyOf(t *testing.T, privateKeyPath string) string {
+	file, err := os.Open(privateKeyPath)
+	assert.NoError(t, err)
+	defer file.Close()
+	entities, err := openpgp.ReadArmoredKeyRing(file)
+	assert.NoError(t, err)
+	buf := new(bytes.Buffer)
+	writer, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	for _, entity := range entities {
+		assert.NoError(t, entity.Serialize(writer))
+	}
+	assert.NoError(t, writer.Close())
+	return buf.String()
+}
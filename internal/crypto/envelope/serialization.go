@@ -0,0 +1,88 @@
+package envelope
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	sizeofInt32 = 4
+	maxFieldLen = 1 << 20
+)
+
+// SerializeEncryptedKey serializes the key in the format shared by the envelopers, which differ by the magic value
+func SerializeEncryptedKey(magic string, schemeVersion byte, encryptedKey *EncryptedKey) []byte {
+	/*
+		magic value
+		scheme version
+		uint32 - keyID len
+		keyID ...
+		uint32 - encrypted key len
+		encrypted key ...
+	*/
+
+	result := append([]byte(magic), schemeVersion)
+	result = appendField(result, []byte(encryptedKey.ID()))
+	return appendField(result, encryptedKey.Data)
+}
+
+// ReadEncryptedKey reads the key serialized by SerializeEncryptedKey with the same magic value and scheme version
+func ReadEncryptedKey(r io.Reader, magic string, schemeVersion byte) (*EncryptedKey, error) {
+	magicSchemeBytes := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(r, magicSchemeBytes)
+	if err != nil {
+		return nil, err
+	}
+	if string(magicSchemeBytes[0:len(magic)]) != magic {
+		return nil, fmt.Errorf("%s: invalid encrypted header format", magic)
+	}
+	if schemeVersion != magicSchemeBytes[len(magic)] {
+		return nil, fmt.Errorf("%s: scheme version is not supported", magic)
+	}
+
+	keyIDBytes, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	keyID := string(keyIDBytes)
+	tracelog.DebugLogger.Printf("Encrypted key was found: %s\n", keyID)
+
+	encryptedKey, err := readField(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptedKey(keyID, encryptedKey), nil
+}
+
+func appendField(result, field []byte) []byte {
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(field)))
+	return append(result, field...)
+}
+
+func readField(r io.Reader) ([]byte, error) {
+	fieldLen, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if fieldLen > maxFieldLen {
+		return nil, fmt.Errorf("envelope: invalid field length %d", fieldLen)
+	}
+	field := make([]byte, fieldLen)
+	_, err = io.ReadFull(r, field)
+	if err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	value := make([]byte, sizeofInt32)
+	_, err := io.ReadFull(r, value)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
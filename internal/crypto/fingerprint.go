@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+)
+
+const fingerprintSeparator = ","
+
+// KeyFingerprinter is implemented by crypters that are able to identify their keys without exposing them.
+type KeyFingerprinter interface {
+	// KeyFingerprints returns identifiers of the configured keys, e.g. PGP key IDs or public keys. The data encrypted
+	// by a crypter can be decrypted by another one if they have at least one key in common.
+	KeyFingerprints() ([]string, error)
+}
+
+// DecryptionKeyFingerprinter is implemented by crypters that decrypt with other keys than they encrypt with,
+// e.g. with the secret keys of the public ones. The fingerprints are comparable to the ones of KeyFingerprints.
+type DecryptionKeyFingerprinter interface {
+	DecryptionKeyFingerprints() ([]string, error)
+}
+
+// CrypterNameMatcher is implemented by crypters that are able to decrypt the data of crypters with other names.
+type CrypterNameMatcher interface {
+	MatchesCrypterName(name string) bool
+}
+
+// EncryptionInfo describes the crypter and keys a backup is encrypted with. It's stored in backup sentinels.
+type EncryptionInfo struct {
+	CrypterName    string `json:"CrypterName,omitempty"`
+	KeyFingerprint string `json:"KeyFingerprint,omitempty"`
+}
+
+type EncryptionMismatchError struct {
+	error
+}
+
+func NewEncryptionMismatchError(format string, args ...any) EncryptionMismatchError {
+	return EncryptionMismatchError{fmt.Errorf(format, args...)}
+}
+
+func (err EncryptionMismatchError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// GetEncryptionInfo describes the crypter to be stored in a backup sentinel. The fingerprint is left empty if the
+// crypter can't identify its keys.
+func GetEncryptionInfo(crypter Crypter) EncryptionInfo {
+	if crypter == nil {
+		return EncryptionInfo{}
+	}
+	return EncryptionInfo{
+		CrypterName:    crypter.Name(),
+		KeyFingerprint: strings.Join(getKeyFingerprints(crypter), fingerprintSeparator),
+	}
+}
+
+// CheckEncryptionInfo checks that the backup encrypted as described by info can be decrypted by the crypter.
+// Backups without encryption info, e.g. made by older versions, aren't checked.
+func CheckEncryptionInfo(info EncryptionInfo, crypter Crypter) error {
+	if info.CrypterName == "" {
+		return nil
+	}
+	if crypter == nil {
+		return NewEncryptionMismatchError("backup is encrypted with %s%s, but no crypter is configured",
+			info.CrypterName, describeKey(info.KeyFingerprint))
+	}
+	if !matchesCrypterName(info.CrypterName, crypter) {
+		return NewEncryptionMismatchError("backup is encrypted with %s%s, configured crypter is %s",
+			info.CrypterName, describeKey(info.KeyFingerprint), crypter.Name())
+	}
+	if info.KeyFingerprint == "" {
+		return nil
+	}
+
+	configured := getDecryptionKeyFingerprints(crypter)
+	if len(configured) == 0 {
+		return nil
+	}
+	for _, backupKey := range strings.Split(info.KeyFingerprint, fingerprintSeparator) {
+		for _, configuredKey := range configured {
+			if backupKey == configuredKey {
+				return nil
+			}
+		}
+	}
+	return NewEncryptionMismatchError("backup is encrypted with key %s, configured key %s",
+		info.KeyFingerprint, strings.Join(configured, fingerprintSeparator))
+}
+
+func matchesCrypterName(name string, crypter Crypter) bool {
+	if matcher, ok := crypter.(CrypterNameMatcher); ok {
+		return matcher.MatchesCrypterName(name)
+	}
+	return name == crypter.Name()
+}
+
+func getKeyFingerprints(crypter Crypter) []string {
+	fingerprinter, ok := crypter.(KeyFingerprinter)
+	if !ok {
+		return nil
+	}
+	fingerprints, err := fingerprinter.KeyFingerprints()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Unable to get %s key fingerprint: %v", crypter.Name(), err)
+		return nil
+	}
+	return fingerprints
+}
+
+func getDecryptionKeyFingerprints(crypter Crypter) []string {
+	fingerprinter, ok := crypter.(DecryptionKeyFingerprinter)
+	if !ok {
+		return getKeyFingerprints(crypter)
+	}
+	fingerprints, err := fingerprinter.DecryptionKeyFingerprints()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Unable to get %s decryption key fingerprint: %v", crypter.Name(), err)
+		return nil
+	}
+	return fingerprints
+}
+
+func describeKey(fingerprint string) string {
+	if fingerprint == "" {
+		return ""
+	}
+	return " key " + fingerprint
+}
@@ -0,0 +1,93 @@
+package crypto_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wal-g/wal-g/internal/crypto"
+)
+
+type fakeCrypter struct {
+	name         string
+	fingerprints []string
+	err          error
+}
+
+func (crypter *fakeCrypter) Name() string {
+	return crypter.name
+}
+
+func (crypter *fakeCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (crypter *fakeCrypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (crypter *fakeCrypter) KeyFingerprints() ([]string, error) {
+	return crypter.fingerprints, crypter.err
+}
+
+func TestGetEncryptionInfo(t *testing.T) {
+	assert.Equal(t, crypto.EncryptionInfo{}, crypto.GetEncryptionInfo(nil))
+
+	info := crypto.GetEncryptionInfo(&fakeCrypter{name: "Fake", fingerprints: []string{"A", "B"}})
+	assert.Equal(t, crypto.EncryptionInfo{CrypterName: "Fake", KeyFingerprint: "A,B"}, info)
+
+	info = crypto.GetEncryptionInfo(&fakeCrypter{name: "Fake", err: errors.New("no key")})
+	assert.Equal(t, crypto.EncryptionInfo{CrypterName: "Fake"}, info)
+}
+
+func TestCheckEncryptionInfo(t *testing.T) {
+	configured := &fakeCrypter{name: "Fake", fingerprints: []string{"B", "C"}}
+
+	tests := []struct {
+		name    string
+		info    crypto.EncryptionInfo
+		crypter crypto.Crypter
+		wantErr string
+	}{
+		{name: "old backup", info: crypto.EncryptionInfo{}, crypter: configured},
+		{name: "unencrypted backup", info: crypto.EncryptionInfo{}, crypter: nil},
+		{name: "common key", info: crypto.EncryptionInfo{CrypterName: "Fake", KeyFingerprint: "A,B"}, crypter: configured},
+		{name: "unknown backup key", info: crypto.EncryptionInfo{CrypterName: "Fake"}, crypter: configured},
+		{
+			name:    "unknown configured key",
+			info:    crypto.EncryptionInfo{CrypterName: "Fake", KeyFingerprint: "A"},
+			crypter: &fakeCrypter{name: "Fake", err: errors.New("no key")},
+		},
+		{
+			name:    "no crypter",
+			info:    crypto.EncryptionInfo{CrypterName: "Fake", KeyFingerprint: "A"},
+			crypter: nil,
+			wantErr: "backup is encrypted with Fake key A, but no crypter is configured",
+		},
+		{
+			name:    "another crypter",
+			info:    crypto.EncryptionInfo{CrypterName: "Other", KeyFingerprint: "A"},
+			crypter: configured,
+			wantErr: "backup is encrypted with Other key A, configured crypter is Fake",
+		},
+		{
+			name:    "another key",
+			info:    crypto.EncryptionInfo{CrypterName: "Fake", KeyFingerprint: "A"},
+			crypter: configured,
+			wantErr: "backup is encrypted with key A, configured key B,C",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := crypto.CheckEncryptionInfo(tt.info, tt.crypter)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.ErrorAs(t, err, &crypto.EncryptionMismatchError{})
+		})
+	}
+}
@@ -6,6 +6,8 @@ package libsodium
 import "C"
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -42,6 +44,16 @@ func (crypter *Crypter) Name() string {
 	return "Libsodium"
 }
 
+// KeyFingerprints returns the truncated SHA-256 hash of the key
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	err := crypter.setup()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(crypter.key)
+	return []string{"sha256:" + hex.EncodeToString(hash[:8])}, nil
+}
+
 // CrypterFromKey creates Crypter from key
 func CrypterFromKey(key string, keyTransform string) crypto.Crypter {
 	return &Crypter{KeyInline: key, KeyTransform: keyTransform}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -39,6 +40,19 @@ func (crypter *Crypter) Name() string {
 	return "Opengpg/Crypter"
 }
 
+// KeyFingerprints returns IDs of the PGP keys
+func (crypter *Crypter) KeyFingerprints() ([]string, error) {
+	err := crypter.setupPubKey()
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make([]string, 0, len(crypter.PubKey))
+	for _, entity := range crypter.PubKey {
+		fingerprints = append(fingerprints, strings.ToUpper(strconv.FormatUint(entity.PrimaryKey.KeyId, 16)))
+	}
+	return fingerprints, nil
+}
+
 // CrypterFromKey creates Crypter from armored key.
 func CrypterFromKey(armoredKey string, loadPassphrase func() (string, bool)) crypto.Crypter {
 	return &Crypter{ArmoredKey: armoredKey, IsUseArmoredKey: true, loadPassphrase: loadPassphrase}
@@ -69,3 +69,13 @@ func TestEncryptionCycleFromEnv(t *testing.T) {
 func TestEncryptionCycleFromKeyPath(t *testing.T) {
 	EncryptionCycle(t, MockArmedCrypterFromKeyPath())
 }
+
+func TestKeyFingerprints(t *testing.T) {
+	fromEnv, err := MockArmedCrypterFromEnv().(crypto.KeyFingerprinter).KeyFingerprints()
+	assert.NoError(t, err)
+	fromKeyPath, err := MockArmedCrypterFromKeyPath().(crypto.KeyFingerprinter).KeyFingerprints()
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, fromKeyPath)
+	assert.Equal(t, fromKeyPath, fromEnv)
+}
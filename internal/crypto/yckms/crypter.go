@@ -14,12 +14,18 @@ import (
 
 type YcCrypter struct {
 	symmetricKey YcSymmetricKeyInterface
+	keyID        string
 }
 
 func (crypter *YcCrypter) Name() string {
 	return "YcKMC/Crypter"
 }
 
+// KeyFingerprints returns the KMS key ID
+func (crypter *YcCrypter) KeyFingerprints() ([]string, error) {
+	return []string{crypter.keyID}, nil
+}
+
 func (crypter *YcCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
 	if crypter.symmetricKey.GetKey() == nil {
 		err := crypter.symmetricKey.CreateKey()
@@ -62,5 +68,5 @@ func YcCrypterFromKeyIDAndCredential(keyID string, saFilePath string) crypto.Cry
 	})
 	tracelog.ErrorLogger.FatalfOnError("Can't initialize yc sdk: %v", err)
 
-	return &YcCrypter{symmetricKey: YcSymmetricKeyFromKeyIDAndSdk(keyID, sdk)}
+	return &YcCrypter{symmetricKey: YcSymmetricKeyFromKeyIDAndSdk(keyID, sdk), keyID: keyID}
 }
@@ -0,0 +1,212 @@
+package greenplum
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// FsckChecks are the storage checks for Greenplum: backup files of the coordinator and segments,
+// segment backups referenced by the coordinator backups and the reachability of restore points
+func FsckChecks(minOrphanAge time.Duration) []storagetools.FsckCheck {
+	return []storagetools.FsckCheck{
+		&storagetools.BackupFilesCheck{
+			// the coordinator backups consist of sentinels only, the data is kept in the segment backups
+			DatalessSentinelFolders: []string{utility.BaseBackupPath},
+			SharedDataDirs:          []string{AoStoragePath},
+			MinOrphanAge:            minOrphanAge,
+		},
+		&SegmentBackupsCheck{},
+	}
+}
+
+// SegmentBackupsCheck finds Greenplum backups that reference missing segment backups,
+// and restore points that can't be reached from any intact backup
+type SegmentBackupsCheck struct{}
+
+func (c *SegmentBackupsCheck) Name() string {
+	return "segment_backups"
+}
+
+type fsckCoordinatorBackup struct {
+	name         string
+	sentinelPath string
+	sentinel     BackupSentinelDto
+}
+
+func (c *SegmentBackupsCheck) Check(folder storage.Folder, objects []storage.Object) ([]storagetools.FsckIssue, error) {
+	existing := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		existing[object.GetName()] = true
+	}
+
+	var issues []storagetools.FsckIssue
+	var backups []fsckCoordinatorBackup
+	var restorePoints []string
+	for _, object := range objects {
+		fileName := strings.TrimPrefix(object.GetName(), utility.BaseBackupPath)
+		if fileName == object.GetName() || strings.Contains(fileName, "/") {
+			continue
+		}
+		if strings.HasSuffix(fileName, RestorePointSuffix) {
+			restorePoints = append(restorePoints, strings.TrimSuffix(fileName, RestorePointSuffix))
+			continue
+		}
+		if !strings.HasSuffix(fileName, utility.SentinelSuffix) {
+			continue
+		}
+
+		backupName := strings.TrimSuffix(fileName, utility.SentinelSuffix)
+		backup, err := NewBackup(folder, backupName)
+		if err != nil {
+			return nil, err
+		}
+		sentinel, err := backup.GetSentinel()
+		if err != nil {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     backupName,
+				Description: fmt.Sprintf("backup sentinel can't be read: %v", err),
+			})
+			continue
+		}
+		backups = append(backups, fsckCoordinatorBackup{name: backupName, sentinelPath: object.GetName(), sentinel: sentinel})
+	}
+
+	var intactFinishTimes []time.Time
+	var references *segmentBackupReferences
+	for _, backup := range backups {
+		missing, existingSegments := checkSegmentBackups(backup.sentinel, existing)
+		if len(missing) == 0 {
+			intactFinishTimes = append(intactFinishTimes, backup.sentinel.FinishTime)
+			continue
+		}
+		if references == nil {
+			references = findSegmentBackupReferences(folder, objects, backups)
+		}
+		// the segment backups used by other backups, even as a delta base, must be kept
+		var unreferenced []string
+		for _, segmentBackupPath := range existingSegments {
+			if references.coordinators[segmentBackupPath] <= 1 && !references.deltaBases[segmentBackupPath] {
+				unreferenced = append(unreferenced, segmentBackupPath)
+			}
+		}
+		issues = append(issues, storagetools.FsckIssue{
+			Check:       c.Name(),
+			Subject:     backup.name,
+			Description: fmt.Sprintf("segment backups are missing: %s", strings.Join(missing, ", ")),
+			Garbage:     append([]string{backup.sentinelPath}, segmentBackupObjects(objects, unreferenced)...),
+		})
+	}
+
+	for _, pointName := range restorePoints {
+		point, err := FetchRestorePointMetadata(folder, pointName)
+		if err != nil {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     pointName,
+				Description: fmt.Sprintf("restore point metadata can't be read: %v", err),
+			})
+			continue
+		}
+		reachable := false
+		for _, finishTime := range intactFinishTimes {
+			if finishTime.Before(point.FinishTime) {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     pointName,
+				Description: "restore point can't be reached: there are no intact backups finished before it",
+			})
+		}
+	}
+	return issues, nil
+}
+
+// checkSegmentBackups provides the missing and the existing segment backups of the coordinator backup
+func checkSegmentBackups(sentinel BackupSentinelDto, existing map[string]bool) (missing, existingSegments []string) {
+	for _, segment := range sentinel.Segments {
+		if segment.Role != Primary || segment.BackupName == "" {
+			continue
+		}
+		segmentBackupPath := path.Join(FormatSegmentBackupPath(segment.ContentID), segment.BackupName)
+		if existing[segmentBackupPath+utility.SentinelSuffix] {
+			existingSegments = append(existingSegments, segmentBackupPath)
+			continue
+		}
+		missing = append(missing, segmentBackupPath)
+	}
+	sort.Strings(missing)
+	return missing, existingSegments
+}
+
+type segmentBackupReferences struct {
+	// coordinators is the number of coordinator backups using the segment backup
+	coordinators map[string]int
+	// deltaBases are the segment backups that are the base of segment delta backups
+	deltaBases map[string]bool
+}
+
+// findSegmentBackupReferences finds the segment backups used by the coordinator backups and by the segment delta
+// backups. Unreadable segment sentinels are assumed to reference nothing.
+func findSegmentBackupReferences(
+	folder storage.Folder,
+	objects []storage.Object,
+	backups []fsckCoordinatorBackup,
+) *segmentBackupReferences {
+	references := &segmentBackupReferences{
+		coordinators: map[string]int{},
+		deltaBases:   map[string]bool{},
+	}
+	for _, backup := range backups {
+		for _, segment := range backup.sentinel.Segments {
+			if segment.Role != Primary || segment.BackupName == "" {
+				continue
+			}
+			references.coordinators[path.Join(FormatSegmentBackupPath(segment.ContentID), segment.BackupName)]++
+		}
+	}
+
+	for _, object := range objects {
+		dir, fileName := path.Split(object.GetName())
+		if !strings.HasPrefix(dir, SegmentsFolderPath) || !strings.HasSuffix(dir, utility.BaseBackupPath) ||
+			!strings.HasSuffix(fileName, utility.SentinelSuffix) {
+			continue
+		}
+		var sentinel postgres.BackupSentinelDto
+		err := internal.FetchDto(folder, &sentinel, object.GetName())
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to read segment backup sentinel %s: %v", object.GetName(), err)
+			continue
+		}
+		if sentinel.IncrementFrom != nil {
+			references.deltaBases[path.Join(dir, *sentinel.IncrementFrom)] = true
+		}
+	}
+	return references
+}
+
+func segmentBackupObjects(objects []storage.Object, segmentBackupPaths []string) []string {
+	var segmentObjects []string
+	for _, object := range objects {
+		for _, prefix := range segmentBackupPaths {
+			if object.GetName() == prefix+utility.SentinelSuffix || strings.HasPrefix(object.GetName(), prefix+"/") {
+				segmentObjects = append(segmentObjects, object.GetName())
+			}
+		}
+	}
+	return segmentObjects
+}
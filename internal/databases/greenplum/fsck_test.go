@@ -0,0 +1,87 @@
+package greenplum_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/greenplum"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestSegmentBackupsCheck(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	put := func(name, content string) {
+		require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+	}
+	put("basebackups_005/backup_1_backup_stop_sentinel.json", `{"finish_time": "2024-01-01T00:00:00Z", "segments": [
+		{"content_id": 0, "role": "p", "backup_name": "base_000000010000000000000002"},
+		{"content_id": 1, "role": "p", "backup_name": "base_000000010000000000000003"}]}`)
+	put("basebackups_005/backup_2_backup_stop_sentinel.json", `{"finish_time": "2024-01-02T00:00:00Z", "segments": [
+		{"content_id": 0, "role": "p", "backup_name": "base_000000010000000000000004"},
+		{"content_id": 1, "role": "p", "backup_name": "base_000000010000000000000005"}]}`)
+	put("segments_005/seg0/basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json", `{}`)
+	put("segments_005/seg0/basebackups_005/base_000000010000000000000002/metadata.json", `{}`)
+	put("segments_005/seg0/basebackups_005/base_000000010000000000000004_backup_stop_sentinel.json", `{}`)
+	put("segments_005/seg1/basebackups_005/base_000000010000000000000005_backup_stop_sentinel.json", `{}`)
+	put("basebackups_005/point_1_restore_point.json", `{"name": "point_1", "finish_time": "2024-01-01T12:00:00Z"}`)
+	put("basebackups_005/point_2_restore_point.json", `{"name": "point_2", "finish_time": "2024-01-02T12:00:00Z"}`)
+
+	objects, err := storage.ListFolderRecursively(folder)
+	require.NoError(t, err)
+	issues, err := (&greenplum.SegmentBackupsCheck{}).Check(folder, objects)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+
+	assert.Equal(t, "backup_1", issues[0].Subject)
+	assert.Equal(t, "segment backups are missing: segments_005/seg1/basebackups_005/base_000000010000000000000003",
+		issues[0].Description)
+	assert.ElementsMatch(t, []string{
+		"basebackups_005/backup_1_backup_stop_sentinel.json",
+		"segments_005/seg0/basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json",
+		"segments_005/seg0/basebackups_005/base_000000010000000000000002/metadata.json",
+	}, issues[0].Garbage)
+
+	assert.Equal(t, "point_1", issues[1].Subject)
+	assert.Contains(t, issues[1].Description, "restore point can't be reached")
+
+	t.Run("keep segment backups used by other backups", func(t *testing.T) {
+		folder := memory.NewFolder("test/", memory.NewKVS())
+		put := func(name, content string) {
+			require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+		}
+		put("basebackups_005/backup_1_backup_stop_sentinel.json", `{"finish_time": "2024-01-01T00:00:00Z", "segments": [
+			{"content_id": 0, "role": "p", "backup_name": "base_000000010000000000000002"},
+			{"content_id": 1, "role": "p", "backup_name": "base_000000010000000000000003"},
+			{"content_id": 2, "role": "p", "backup_name": "base_000000010000000000000004"}]}`)
+		put("basebackups_005/backup_2_backup_stop_sentinel.json", `{"finish_time": "2024-01-02T00:00:00Z", "segments": [
+			{"content_id": 0, "role": "p", "backup_name": "base_000000010000000000000005_D_000000010000000000000002"},
+			{"content_id": 1, "role": "p", "backup_name": "base_000000010000000000000006"},
+			{"content_id": 2, "role": "p", "backup_name": "base_000000010000000000000004"}]}`)
+		put("segments_005/seg0/basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json", `{}`)
+		put("segments_005/seg0/basebackups_005/base_000000010000000000000005_D_000000010000000000000002"+
+			"_backup_stop_sentinel.json", `{"DeltaFrom": "base_000000010000000000000002"}`)
+		put("segments_005/seg1/basebackups_005/base_000000010000000000000006_backup_stop_sentinel.json", `{}`)
+		put("segments_005/seg2/basebackups_005/base_000000010000000000000004_backup_stop_sentinel.json", `{}`)
+
+		objects, err := storage.ListFolderRecursively(folder)
+		require.NoError(t, err)
+		issues, err := (&greenplum.SegmentBackupsCheck{}).Check(folder, objects)
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "backup_1", issues[0].Subject)
+		assert.Equal(t, []string{"basebackups_005/backup_1_backup_stop_sentinel.json"}, issues[0].Garbage)
+	})
+
+	t.Run("coordinator backups are sentinels only", func(t *testing.T) {
+		issues, err := greenplum.FsckChecks(time.Hour)[0].Check(folder, objects)
+		require.NoError(t, err)
+		require.Len(t, issues, 2)
+		for _, issue := range issues {
+			assert.True(t, strings.HasPrefix(issue.Subject, "segments_005/"), issue.Subject)
+		}
+	})
+}
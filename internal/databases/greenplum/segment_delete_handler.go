@@ -155,7 +155,8 @@ func GetPermanentBackupsAndWals(rootFolder storage.Folder, contentID int) (map[p
 			continue
 		}
 
-		meta, err := backup.FetchMeta()
+		// the permanence in the backup catalog can be stale
+		meta, err := backup.FetchMetaFromStorage()
 		if err != nil {
 			internal.FatalOnUnrecoverableMetadataError(backupTime, err)
 			continue
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/mongo/client"
 	"github.com/wal-g/wal-g/internal/databases/mongo/common"
 	"github.com/wal-g/wal-g/internal/databases/mongo/models"
@@ -20,6 +21,7 @@ type MongoMetaConstructor struct {
 	folder    storage.Folder
 	meta      models.BackupMeta
 	permanent bool
+	crypter   crypto.Crypter
 }
 
 func (m *MongoMetaConstructor) MetaInfo() interface{} {
@@ -33,6 +35,7 @@ func (m *MongoMetaConstructor) MetaInfo() interface{} {
 		MongoMeta:       meta.Mongo,
 		CompressedSize:  meta.CompressedSize,
 		Permanent:       meta.Permanent,
+		EncryptionInfo:  crypto.GetEncryptionInfo(m.crypter),
 	}
 	return backupSentinel
 }
@@ -40,8 +43,9 @@ func (m *MongoMetaConstructor) MetaInfo() interface{} {
 func NewBackupMongoMetaConstructor(ctx context.Context,
 	mc client.MongoDriver,
 	folder storage.Folder,
-	permanent bool) internal.MetaConstructor {
-	return &MongoMetaConstructor{ctx: ctx, client: mc, folder: folder, permanent: permanent}
+	permanent bool,
+	crypter crypto.Crypter) internal.MetaConstructor {
+	return &MongoMetaConstructor{ctx: ctx, client: mc, folder: folder, permanent: permanent, crypter: crypter}
 }
 
 func (m *MongoMetaConstructor) Init() error {
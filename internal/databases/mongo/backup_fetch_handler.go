@@ -1 +1,33 @@
 package mongo
+
+import (
+	"os/exec"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/databases/mongo/models"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// HandleBackupFetch checks that the backup can be decrypted with the configured crypter and streams it
+// to the restore command.
+func HandleBackupFetch(folder storage.Folder, backupSelector internal.BackupSelector, restoreCmd *exec.Cmd) error {
+	backup, err := backupSelector.Select(folder)
+	if err != nil {
+		return err
+	}
+
+	var sentinel models.Backup
+	err = backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return err
+	}
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
+
+	fetcher := internal.GetBackupToCommandFetcher(restoreCmd)
+	fetcher(folder, backup)
+	return nil
+}
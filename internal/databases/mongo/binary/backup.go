@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/mongo/common"
 	"github.com/wal-g/wal-g/internal/databases/mongo/models"
 	"github.com/wal-g/wal-g/utility"
@@ -111,6 +112,7 @@ func (backupService *BackupService) InitializeMongodBackupMeta(backupName string
 	backupService.Sentinel.StartLocalTime = utility.TimeNowCrossPlatformLocal()
 	backupService.Sentinel.Permanent = permanent
 	backupService.Sentinel.UserData = userData
+	backupService.Sentinel.EncryptionInfo = crypto.GetEncryptionInfo(backupService.Uploader.Crypter())
 
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/mongo/common"
 	"github.com/wal-g/wal-g/internal/databases/mongo/models"
 )
@@ -36,6 +37,10 @@ func (restoreService *RestoreService) DoRestore(args RestoreArgs) error {
 	if err != nil {
 		return err
 	}
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
 
 	if !args.SkipChecks {
 		//todo maybe delete all checks?
@@ -0,0 +1,67 @@
+package mongo
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/wal-g/internal/databases/mongo/models"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// FsckChecks are the storage checks for MongoDB: the default ones and oplog archive gaps
+func FsckChecks(minOrphanAge time.Duration) []storagetools.FsckCheck {
+	return append(storagetools.DefaultFsckChecks(minOrphanAge), &OplogGapCheck{})
+}
+
+// OplogGapCheck finds gaps between oplog archives and the gaps recorded during the archiving,
+// point-in-time recovery across them is impossible
+type OplogGapCheck struct{}
+
+func (c *OplogGapCheck) Name() string {
+	return "oplog_gap"
+}
+
+func (c *OplogGapCheck) Check(_ storage.Folder, objects []storage.Object) ([]storagetools.FsckIssue, error) {
+	var archives []models.Archive
+	for _, object := range objects {
+		if !strings.HasPrefix(object.GetName(), models.OplogArchBasePath) {
+			continue
+		}
+		arch, err := models.ArchFromFilename(path.Base(object.GetName()))
+		if err != nil {
+			continue
+		}
+		archives = append(archives, arch)
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return models.LessTS(archives[i].Start, archives[j].Start)
+	})
+
+	var issues []storagetools.FsckIssue
+	var lastEnd *models.Timestamp
+	for _, arch := range archives {
+		if arch.Type == models.ArchiveTypeGap {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     arch.Filename(),
+				Description: fmt.Sprintf("oplog between %s and %s has been lost during archiving", arch.Start, arch.End),
+			})
+		} else if lastEnd != nil && models.LessTS(*lastEnd, arch.Start) {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     arch.Filename(),
+				Description: fmt.Sprintf("oplog archives between %s and %s are missing", *lastEnd, arch.Start),
+			})
+		}
+		// the recorded gaps continue the archive chain, they are reported once
+		if lastEnd == nil || models.LessTS(*lastEnd, arch.End) {
+			end := arch.End
+			lastEnd = &end
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestOplogGapCheck(t *testing.T) {
+	objects := []storage.Object{
+		storage.NewLocalObject("oplog_005/oplog_100.1_200.1.br", time.Now(), 1),
+		storage.NewLocalObject("oplog_005/oplog_200.1_300.1.br", time.Now(), 1),
+		storage.NewLocalObject("oplog_005/oplog_150.1_250.1.br", time.Now(), 1),
+		storage.NewLocalObject("oplog_005/oplog_400.1_500.1.br", time.Now(), 1),
+		storage.NewLocalObject("oplog_005/gap_500.1_600.1.br", time.Now(), 1),
+		storage.NewLocalObject("oplog_005/oplog_600.1_700.1.br", time.Now(), 1),
+		storage.NewLocalObject("basebackups_005/stream_1_backup_stop_sentinel.json", time.Now(), 1),
+	}
+
+	issues, err := (&OplogGapCheck{}).Check(nil, objects)
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, "oplog_400.1_500.1.br", issues[0].Subject)
+	assert.Equal(t, "oplog archives between 300.1 and 400.1 are missing", issues[0].Description)
+	assert.Equal(t, "gap_500.1_600.1.br", issues[1].Subject)
+	assert.Equal(t, "oplog between 500.1 and 600.1 has been lost during archiving", issues[1].Description)
+}
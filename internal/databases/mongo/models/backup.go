@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/printlist"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -24,6 +25,8 @@ type Backup struct {
 	Permanent        bool        `json:"Permanent"`
 	UncompressedSize int64       `json:"UncompressedSize,omitempty"`
 	CompressedSize   int64       `json:"DataSize,omitempty"`
+
+	crypto.EncryptionInfo
 }
 
 func (b *Backup) Name() string {
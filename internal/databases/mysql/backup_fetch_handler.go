@@ -5,6 +5,7 @@ import (
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
@@ -19,6 +20,9 @@ func HandleBackupFetch(folder storage.Folder,
 	err = backup.FetchSentinel(&sentinel)
 	tracelog.ErrorLogger.FatalfOnError("Failed to fetch sentinel: %v", err)
 
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v", err)
+
 	// we should ba able to read & restore any backup we ever created:
 	if sentinel.Tool == WalgXtrabackupTool {
 		internal.HandleBackupFetch(folder, targetBackupSelector, GetXtrabackupFetcher(restoreCmd, prepareCmd))
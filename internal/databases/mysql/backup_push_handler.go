@@ -12,6 +12,7 @@ import (
 	"github.com/wal-g/tracelog"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/limiters"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -114,6 +115,7 @@ func HandleBackupPush(
 		IncrementFrom:     incrementFrom,
 		IncrementFullName: prevBackupInfo.fullBackupName,
 		IncrementCount:    &incrementCount,
+		EncryptionInfo:    crypto.GetEncryptionInfo(uploader.Crypter()),
 	}
 	tracelog.InfoLogger.Printf("Backup sentinel: %s", sentinel.String())
 
@@ -0,0 +1,14 @@
+package mysql
+
+import (
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// NewBackupUsageAttributor makes the attributor of the storage objects for the backup-usage report, it marks
+// permanent backups and increment chains
+func NewBackupUsageAttributor(folder storage.Folder, objects []storage.Object) (storagetools.Attributor, error) {
+	describe := storagetools.MetadataBackupDescriber(folder.GetSubFolder(utility.BaseBackupPath), NewGenericMetaFetcher())
+	return storagetools.NewBackupDetailsAttributor(objects, describe)
+}
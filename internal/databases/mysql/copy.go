@@ -11,8 +11,9 @@ import (
 	"github.com/wal-g/wal-g/utility"
 )
 
-// HandleCopyBackup copy specific backups from one storage to another
-func HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix string) {
+// HandleCopyBackup copy specific backups from one storage to another.
+// If recoder is not nil, the objects are re-encoded with it instead of copying them byte-for-byte.
+func HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix string, recoder *copy.Recoder) {
 	var from, fromError = internal.StorageFromConfig(fromConfigFile)
 	var to, toError = internal.StorageFromConfig(toConfigFile)
 	if fromError != nil || toError != nil {
@@ -20,6 +21,9 @@ func HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix string) {
 	}
 	infos, err := backupCopyingInfo(backupName, prefix, from.RootFolder(), to.RootFolder())
 	tracelog.ErrorLogger.FatalOnError(err)
+	if recoder != nil {
+		infos = copy.Recode(infos, recoder)
+	}
 
 	tracelog.DebugLogger.Printf("copying files %s\n", strings.Join(func() []string {
 		ret := make([]string, 0)
@@ -31,12 +35,15 @@ func HandleCopyBackup(fromConfigFile, toConfigFile, backupName, prefix string) {
 	}(), ","))
 
 	tracelog.ErrorLogger.FatalOnError(copy.Infos(infos))
+	if recoder != nil {
+		tracelog.ErrorLogger.FatalOnError(recoder.UpdateCompressedSizes())
+	}
 
 	tracelog.InfoLogger.Printf("Success copyed backup %s.\n", backupName)
 }
 
 // HandleCopyBackup copy  all backups from one storage to another
-func HandleCopyAll(fromConfigFile string, toConfigFile string) {
+func HandleCopyAll(fromConfigFile string, toConfigFile string, recoder *copy.Recoder) {
 	var from, fromError = internal.StorageFromConfig(fromConfigFile)
 	var to, toError = internal.StorageFromConfig(toConfigFile)
 	if fromError != nil || toError != nil {
@@ -44,8 +51,14 @@ func HandleCopyAll(fromConfigFile string, toConfigFile string) {
 	}
 	infos, err := WildcardInfo(from.RootFolder(), to.RootFolder())
 	tracelog.ErrorLogger.FatalOnError(err)
+	if recoder != nil {
+		infos = copy.Recode(infos, recoder)
+	}
 	err = copy.Infos(infos)
 	tracelog.ErrorLogger.FatalOnError(err)
+	if recoder != nil {
+		tracelog.ErrorLogger.FatalOnError(recoder.UpdateCompressedSizes())
+	}
 	tracelog.InfoLogger.Printf("Success copyed all backups\n")
 }
 
@@ -188,6 +188,17 @@ func (backup *Backup) FetchMeta() (ExtendedMetadataDto, error) {
 	return extendedMetadataDto, nil
 }
 
+// FetchMetaFromStorage is FetchMeta that bypasses the backup catalog, see internal.Backup.FetchMetadataFromStorage
+func (backup *Backup) FetchMetaFromStorage() (ExtendedMetadataDto, error) {
+	extendedMetadataDto := ExtendedMetadataDto{}
+	err := backup.FetchMetadataFromStorage(&extendedMetadataDto)
+	if err != nil {
+		return ExtendedMetadataDto{}, err
+	}
+
+	return extendedMetadataDto, nil
+}
+
 // getFilesMetadataPath returns files metadata storage path.
 func getFilesMetadataPath(backupName string) string {
 	return backupName + "/" + FilesMetadataName
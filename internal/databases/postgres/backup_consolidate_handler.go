@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	deltaBackupNameSeparator    = "_D_"
+	consolidateStagingDirPrefix = "consolidate_"
+)
+
+// consolidatedBackupName returns the name of the full backup made of the delta backup:
+// the backups start at the same WAL segment, so the name is the one of a full backup taken at that point
+func consolidatedBackupName(deltaBackupName string) string {
+	name, _, _ := strings.Cut(deltaBackupName, deltaBackupNameSeparator)
+	return name
+}
+
+// newConsolidatedSentinelDto makes the sentinel of the full backup from the sentinel of the delta backup.
+// The LSNs stay the same, the references to the delta chain are removed.
+func newConsolidatedSentinelDto(deltaSentinel BackupSentinelDto) BackupSentinelDto {
+	sentinel := deltaSentinel
+	sentinel.IncrementFromLSN = nil
+	sentinel.IncrementFrom = nil
+	sentinel.IncrementFullName = nil
+	sentinel.IncrementCount = nil
+	sentinel.IncrementFromChkpNum = nil
+	sentinel.FilesMetadataDisabled = false
+	return sentinel
+}
+
+// HandleBackupConsolidate builds a new full backup from the delta backup and its chain of increments.
+// The chain is restored into the staging directory, where the increments are applied over the base files,
+// and the result is uploaded as a full backup with the same LSNs. The database isn't involved.
+// The staging directory needs as much free space as the data directory of the cluster.
+func HandleBackupConsolidate(uploader internal.Uploader, backupName, stagingDirectory string, isPermanent bool) error {
+	rootFolder := uploader.Folder()
+	baseBackup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, rootFolder)
+	if err != nil {
+		return err
+	}
+	backup := ToPgBackup(baseBackup)
+	sentinel, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	if err != nil {
+		return err
+	}
+	if !sentinel.IsIncremental() {
+		return fmt.Errorf("backup %s is not a delta backup", backup.Name)
+	}
+	if sentinel.TablespaceSpec != nil && !sentinel.TablespaceSpec.empty() {
+		return fmt.Errorf("backup %s has tablespaces, consolidation of such backups is not supported", backup.Name)
+	}
+	meta, err := backup.FetchMetaFromStorage()
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch metadata of backup %s", backup.Name)
+	}
+
+	newBackupName := consolidatedBackupName(backup.Name)
+	exists, err := rootFolder.GetSubFolder(utility.BaseBackupPath).Exists(newBackupName + utility.SentinelSuffix)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("backup %s already exists", newBackupName)
+	}
+
+	err = os.MkdirAll(stagingDirectory, 0700)
+	if err != nil {
+		return err
+	}
+	err = checkStagingDiskSpace(stagingDirectory, sentinel.DataCatalogSize)
+	if err != nil {
+		return err
+	}
+	dataDirectory, err := os.MkdirTemp(stagingDirectory, consolidateStagingDirPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dataDirectory)
+
+	tracelog.InfoLogger.Printf("Restoring backup %s with its delta chain to %s", backup.Name, dataDirectory)
+	filesToUnwrap, err := backup.GetFilesToUnwrap("")
+	if err != nil {
+		return err
+	}
+	err = deltaFetchRecursionOld(backup, rootFolder, dataDirectory, nil, filesToUnwrap, ExtractProviderImpl{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore backup %s", backup.Name)
+	}
+
+	tracelog.InfoLogger.Printf("Uploading full backup %s", newBackupName)
+	uploader.ChangeDirectory(utility.BaseBackupPath)
+	sentinel = newConsolidatedSentinelDto(sentinel)
+	newFilesMeta, err := uploadDataDirectory(uploader, newBackupName, dataDirectory, &sentinel)
+	if err != nil {
+		return err
+	}
+	newFilesMeta.DatabasesByNames = filesMeta.DatabasesByNames
+
+	meta.IsPermanent = isPermanent
+	meta.UncompressedSize = sentinel.UncompressedSize
+	meta.CompressedSize = sentinel.CompressedSize
+	err = uploadBackupMetadata(uploader, newBackupName, sentinel, newFilesMeta, meta)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Wrote backup with name %s consolidated from %s", newBackupName, backup.Name)
+	return nil
+}
+
+// checkStagingDiskSpace makes sure the staging directory can hold the restored cluster.
+// The size of the cluster is the size of the data directory recorded in the sentinel of the delta backup.
+func checkStagingDiskSpace(stagingDirectory string, dataCatalogSize int64) error {
+	if dataCatalogSize <= 0 {
+		tracelog.WarningLogger.Printf("The size of the data directory isn't recorded in the backup, "+
+			"make sure %s has as much free space as the cluster", stagingDirectory)
+		return nil
+	}
+	freeSpace, known, err := getFreeDiskSpace(stagingDirectory)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get free space of %s", stagingDirectory)
+	}
+	if !known {
+		tracelog.WarningLogger.Printf("Unable to get free space of %s, make sure it can hold %d bytes",
+			stagingDirectory, dataCatalogSize)
+		return nil
+	}
+	if freeSpace < dataCatalogSize {
+		return fmt.Errorf("the staging directory %s has %d bytes free, but the restored cluster needs %d bytes",
+			stagingDirectory, freeSpace, dataCatalogSize)
+	}
+	return nil
+}
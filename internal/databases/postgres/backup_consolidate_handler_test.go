@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestConsolidatedBackupName(t *testing.T) {
+	assert.Equal(t, "base_000000010000000000000005",
+		consolidatedBackupName("base_000000010000000000000005_D_000000010000000000000002"))
+	assert.Equal(t, "base_000000010000000000000005", consolidatedBackupName("base_000000010000000000000005"))
+}
+
+func TestNewConsolidatedSentinelDto(t *testing.T) {
+	startLSN, finishLSN, fromLSN := LSN(0x5000028), LSN(0x5000100), LSN(0x2000028)
+	from, fullName, count := "base_000000010000000000000002", "base_000000010000000000000001", 2
+	systemIdentifier := uint64(42)
+	deltaSentinel := BackupSentinelDto{
+		BackupStartLSN:    &startLSN,
+		BackupFinishLSN:   &finishLSN,
+		IncrementFromLSN:  &fromLSN,
+		IncrementFrom:     &from,
+		IncrementFullName: &fullName,
+		IncrementCount:    &count,
+		PgVersion:         160000,
+		SystemIdentifier:  &systemIdentifier,
+		UserData:          "data",
+	}
+
+	sentinel := newConsolidatedSentinelDto(deltaSentinel)
+	assert.False(t, sentinel.IsIncremental())
+	assert.Nil(t, sentinel.IncrementFromLSN)
+	assert.Nil(t, sentinel.IncrementFullName)
+	assert.Nil(t, sentinel.IncrementCount)
+	assert.Equal(t, startLSN, *sentinel.BackupStartLSN)
+	assert.Equal(t, finishLSN, *sentinel.BackupFinishLSN)
+	assert.Equal(t, deltaSentinel.PgVersion, sentinel.PgVersion)
+	assert.Equal(t, deltaSentinel.SystemIdentifier, sentinel.SystemIdentifier)
+	assert.Equal(t, deltaSentinel.UserData, sentinel.UserData)
+	assert.True(t, deltaSentinel.IsIncremental(), "the delta sentinel is not changed")
+}
+
+func TestHandleBackupConsolidate_FullBackup(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	require.NoError(t, folder.PutObject(utility.BaseBackupPath+"base_000000010000000000000001"+utility.SentinelSuffix,
+		strings.NewReader(`{"LSN":16777256,"FilesMetadataDisabled":true}`)))
+	uploader := internal.NewRegularUploader(nil, folder)
+
+	err := HandleBackupConsolidate(uploader, "base_000000010000000000000001", t.TempDir(), false)
+	assert.ErrorContains(t, err, "is not a delta backup")
+}
+
+func TestHandleBackupConsolidate_DeltaChain(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	dataDirectory := t.TempDir()
+	relationPath := filepath.Join(dataDirectory, DefaultTablespace, "1", "16384")
+	require.NoError(t, os.MkdirAll(filepath.Dir(relationPath), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, "global"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, PgControlPath), bytes.Repeat([]byte{1}, 512), 0600))
+
+	fullName, fullLSN := "base_000000010000000000000001", LSN(0x1000028)
+	require.NoError(t, os.WriteFile(relationPath, makeTestPages(0x1000000, 1, 1, 1), 0600))
+	fullFiles := uploadTestBackup(t, folder, fullName, dataDirectory, BackupSentinelDto{BackupStartLSN: &fullLSN}, nil)
+
+	// the second page changes and a new page is appended after the full backup
+	deltaName, deltaLSN, count := "base_000000010000000000000002_D_000000010000000000000001", LSN(0x2000028), 1
+	expectedPages := makeTestPages(0x1000000, 1, 1, 1)
+	copy(expectedPages[DatabasePageSize:], makeTestPages(0x2000000, 2))
+	expectedPages = append(expectedPages, makeTestPages(0x2000000, 3)...)
+	require.NoError(t, os.WriteFile(relationPath, expectedPages, 0600))
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(relationPath, modTime, modTime))
+	deltaSentinel := BackupSentinelDto{
+		BackupStartLSN:    &deltaLSN,
+		IncrementFromLSN:  &fullLSN,
+		IncrementFrom:     &fullName,
+		IncrementFullName: &fullName,
+		IncrementCount:    &count,
+	}
+	deltaFiles := uploadTestBackup(t, folder, deltaName, dataDirectory, deltaSentinel, fullFiles)
+	require.True(t, deltaFiles["/base/1/16384"].IsIncremented, "the delta backup should store an increment")
+
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+	require.NoError(t, HandleBackupConsolidate(uploader, deltaName, t.TempDir(), false))
+
+	consolidated, err := internal.GetBackupByName("base_000000010000000000000002", utility.BaseBackupPath, folder)
+	require.NoError(t, err)
+	backup := ToPgBackup(consolidated)
+	sentinel, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+	require.NoError(t, err)
+	assert.False(t, sentinel.IsIncremental())
+	assert.Equal(t, deltaLSN, *sentinel.BackupStartLSN)
+	assert.False(t, filesMeta.Files["/base/1/16384"].IsIncremented)
+
+	restoreDirectory := t.TempDir()
+	filesToUnwrap, err := backup.GetFilesToUnwrap("")
+	require.NoError(t, err)
+	require.NoError(t, deltaFetchRecursionOld(backup, folder, restoreDirectory, nil, filesToUnwrap, ExtractProviderImpl{}))
+	restoredPages, err := os.ReadFile(filepath.Join(restoreDirectory, DefaultTablespace, "1", "16384"))
+	require.NoError(t, err)
+	assert.Equal(t, expectedPages, restoredPages)
+}
+
+func TestCheckStagingDiskSpace(t *testing.T) {
+	stagingDirectory := t.TempDir()
+	assert.NoError(t, checkStagingDiskSpace(stagingDirectory, 0))
+	assert.NoError(t, checkStagingDiskSpace(stagingDirectory, 1))
+	assert.ErrorContains(t, checkStagingDiskSpace(stagingDirectory, 1<<62), "the restored cluster needs")
+}
+
+// makeTestPages makes valid pages with the LSN, every page is filled with its byte
+func makeTestPages(lsn LSN, fills ...byte) []byte {
+	var pages []byte
+	for _, fill := range fills {
+		page := bytes.Repeat([]byte{fill}, int(DatabasePageSize))
+		binary.LittleEndian.PutUint32(page[0:], uint32(lsn>>32))
+		binary.LittleEndian.PutUint32(page[4:], uint32(lsn))
+		binary.LittleEndian.PutUint16(page[8:], 0)
+		binary.LittleEndian.PutUint16(page[10:], 0)
+		binary.LittleEndian.PutUint16(page[12:], headerSize)
+		binary.LittleEndian.PutUint16(page[14:], uint16(DatabasePageSize))
+		binary.LittleEndian.PutUint16(page[16:], uint16(DatabasePageSize))
+		binary.LittleEndian.PutUint16(page[18:], uint16(DatabasePageSize+layoutVersion))
+		pages = append(pages, page...)
+	}
+	return pages
+}
+
+// uploadTestBackup uploads the data directory as a backup, which is a delta one when the base files are passed
+func uploadTestBackup(t *testing.T, folder storage.Folder, backupName, dataDirectory string,
+	sentinel BackupSentinelDto, baseFiles internal.BackupFileList) internal.BackupFileList {
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(utility.BaseBackupPath))
+	incrementFrom := ""
+	if sentinel.IncrementFrom != nil {
+		incrementFrom = *sentinel.IncrementFrom
+	}
+	bundle := NewBundle(dataDirectory, nil, incrementFrom, sentinel.IncrementFromLSN, baseFiles, false, 0)
+	require.NoError(t, bundle.StartQueue(internal.NewStorageTarBallMaker(backupName, uploader)))
+	composerMaker, err := NewTarBallComposerMaker(RegularComposer, nil, uploader, backupName,
+		NewTarBallFilePackerOptions(false, false), false)
+	require.NoError(t, err)
+	require.NoError(t, bundle.SetupComposer(composerMaker))
+	require.NoError(t, filepath.Walk(dataDirectory, bundle.HandleWalkedFSObject))
+	tarFileSets, err := bundle.FinishTarComposer()
+	require.NoError(t, err)
+	require.NoError(t, bundle.FinishQueue())
+	require.NoError(t, bundle.UploadPgControl(uploader.Compression().FileExtension()))
+	uploader.Finish()
+	require.False(t, uploader.Failed())
+
+	sentinel.DataCatalogSize = *bundle.DataCatalogSize
+	var filesMeta FilesMetadataDto
+	filesMeta.setFiles(bundle.GetFiles())
+	filesMeta.TarFileSets = tarFileSets.Get()
+	require.NoError(t, uploadBackupMetadata(uploader, backupName, sentinel, filesMeta, ExtendedMetadataDto{}))
+	return filesMeta.Files
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -68,6 +69,10 @@ func deltaFetchRecursionOld(backup Backup, rootFolder storage.Folder, dbDataDire
 	if err != nil {
 		return err
 	}
+	err = crypto.CheckEncryptionInfo(sentinelDto.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
 	tablespaceSpec = chooseTablespaceSpecification(sentinelDto.TablespaceSpec, tablespaceSpec)
 	sentinelDto.TablespaceSpec = tablespaceSpec
 
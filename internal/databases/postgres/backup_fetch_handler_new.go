@@ -5,6 +5,7 @@ import (
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -62,6 +63,10 @@ func deltaFetchRecursionNew(cfg *FetchConfig) error {
 	if err != nil {
 		return err
 	}
+	err = crypto.CheckEncryptionInfo(sentinelDto.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
 	cfg.tablespaceSpec = chooseTablespaceSpecification(sentinelDto.TablespaceSpec, cfg.tablespaceSpec)
 	sentinelDto.TablespaceSpec = cfg.tablespaceSpec
 
@@ -159,7 +159,7 @@ func (bh *BackupHandler) createAndPushBackup(ctx context.Context) {
 	}
 
 	arguments := bh.Arguments
-	crypter := internal.ConfigureCrypter()
+	crypter := arguments.Uploader.Crypter()
 	bh.Workers.Bundle = NewBundle(bh.PgInfo.PgDataDirectory, crypter, bh.prevBackupInfo.name,
 		bh.prevBackupInfo.sentinelDto.BackupStartLSN, bh.prevBackupInfo.filesMetadataDto.Files, arguments.forceIncremental,
 		viper.GetInt64(conf.TarSizeThresholdSetting))
@@ -259,10 +259,12 @@ func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
 			tracelog.ErrorLogger.FatalOnError(newBackupFromOtherBD())
 		}
 
+		deltaMapLoaded := viper.GetBool(conf.UseWalSummariesSetting) && bh.loadWalSummaryDeltaMap()
+
 		useWalDelta, _, err := configureWalDeltaUsage()
 		tracelog.ErrorLogger.FatalOnError(err)
 
-		if useWalDelta {
+		if useWalDelta && !deltaMapLoaded {
 			err := bh.Workers.Bundle.DownloadDeltaMap(internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)), bh.CurBackupInfo.startLSN)
 			if err == nil {
 				tracelog.InfoLogger.Println("Successfully loaded delta map, delta backup will be made with provided " +
@@ -277,6 +279,33 @@ func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
 	}
 }
 
+// loadWalSummaryDeltaMap tries to build the delta map from the server WAL summaries (PostgreSQL 17+),
+// so only the changed blocks are read and no WAL is downloaded from the storage
+func (bh *BackupHandler) loadWalSummaryDeltaMap() bool {
+	enabled, err := bh.Workers.QueryRunner.IsWalSummarizationEnabled()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to check if WAL summarization is enabled: %v", err)
+		return false
+	}
+	if !enabled {
+		return false
+	}
+	prevTimeline, err := ParseTimelineFromBackupName(bh.prevBackupInfo.name)
+	if err != nil || prevTimeline != bh.Workers.Bundle.Timeline {
+		tracelog.InfoLogger.Println("WAL summaries aren't used since the timeline has changed since the previous backup")
+		return false
+	}
+	err = bh.Workers.Bundle.LoadWalSummaryDeltaMap(bh.Workers.QueryRunner, bh.CurBackupInfo.startLSN)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Error during loading delta map from WAL summaries: '%v'. "+
+			"Fallback to other delta methods\n", err)
+		return false
+	}
+	tracelog.InfoLogger.Println("Successfully loaded delta map from WAL summaries, delta backup will be made " +
+		"with provided delta map")
+	return true
+}
+
 func (bh *BackupHandler) setupDTO(tarFileSets internal.TarFileSets) (sentinelDto BackupSentinelDto,
 	filesMeta FilesMetadataDto, err error) {
 	var tablespaceSpec *TablespaceSpec
@@ -9,6 +9,7 @@ import (
 	"github.com/wal-g/wal-g/utility"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 )
 
 const MetadataDatetimeFormat = "%Y-%m-%dT%H:%M:%S.%fZ"
@@ -35,6 +36,8 @@ type BackupSentinelDto struct {
 	FilesMetadataDisabled bool    `json:"FilesMetadataDisabled,omitempty"`
 	BackupStartChkpNum    *uint32 `json:"ChkpNum"`
 	IncrementFromChkpNum  *uint32 `json:"DeltaChkpNum,omitempty"`
+
+	crypto.EncryptionInfo
 }
 
 func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSentinelDto {
@@ -63,6 +66,7 @@ func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSent
 	sentinel.CompressedSize = bh.CurBackupInfo.compressedSize
 	sentinel.DataCatalogSize = bh.CurBackupInfo.dataCatalogSize
 	sentinel.FilesMetadataDisabled = bh.Arguments.withoutFilesMetadata
+	sentinel.EncryptionInfo = crypto.GetEncryptionInfo(bh.Arguments.Uploader.Crypter())
 	return sentinel
 }
 
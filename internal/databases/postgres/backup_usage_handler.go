@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// backupUsageAttributor enriches the default attribution with the PostgreSQL specifics: it marks permanent backups
+// and delta chains, and attributes WAL that is older than all backups to garbage, since no backup can use it.
+type backupUsageAttributor struct {
+	*storagetools.BackupDetailsAttributor
+
+	hasOldestBackup  bool
+	oldestTimeline   uint32
+	oldestLogSegNo   uint64
+	oldestBackupName string
+}
+
+// NewBackupUsageAttributor makes the attributor of the storage objects for the backup-usage report
+func NewBackupUsageAttributor(folder storage.Folder, objects []storage.Object) (storagetools.Attributor, error) {
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	detailsAttributor, err := storagetools.NewBackupDetailsAttributor(objects, func(backupName string) (string, error) {
+		return describeBackupUsage(baseBackupFolder, backupName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	attributor := &backupUsageAttributor{BackupDetailsAttributor: detailsAttributor}
+	for _, backupName := range detailsAttributor.CompleteBackups() {
+		attributor.updateOldestBackup(backupName)
+	}
+	return attributor, nil
+}
+
+func describeBackupUsage(baseBackupFolder storage.Folder, backupName string) (string, error) {
+	backup, err := NewBackup(baseBackupFolder, backupName)
+	if err != nil {
+		return "", err
+	}
+	sentinel, err := backup.GetSentinel()
+	if err != nil {
+		return "", fmt.Errorf("get sentinel of backup %s: %w", backupName, err)
+	}
+
+	var details []string
+	meta, err := backup.FetchMeta()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to fetch metadata of backup %s: %v", backupName, err)
+	} else if meta.IsPermanent {
+		details = append(details, "permanent")
+	}
+	var increment internal.IncrementDetails
+	if sentinel.IsIncremental() {
+		increment = internal.IncrementDetails{
+			IncrementFrom:     *sentinel.IncrementFrom,
+			IncrementFullName: *sentinel.IncrementFullName,
+			IncrementCount:    *sentinel.IncrementCount,
+		}
+	}
+	details = append(details, storagetools.DescribeBackupType(sentinel.IsIncremental(), increment))
+	return strings.Join(details, ", "), nil
+}
+
+func (a *backupUsageAttributor) updateOldestBackup(backupName string) {
+	timeline, logSegNo, ok := TryFetchTimelineAndLogSegNo(backupName)
+	if !ok {
+		return
+	}
+	if !a.hasOldestBackup || timeline < a.oldestTimeline || timeline == a.oldestTimeline && logSegNo < a.oldestLogSegNo {
+		a.hasOldestBackup = true
+		a.oldestTimeline = timeline
+		a.oldestLogSegNo = logSegNo
+		a.oldestBackupName = backupName
+	}
+}
+
+func (a *backupUsageAttributor) Attribute(object storage.Object) storagetools.Attribution {
+	attribution := a.BackupDetailsAttributor.Attribute(object)
+	if attribution.Category == storagetools.UsageLog && a.isWalBeforeOldestBackup(object.GetName()) {
+		attribution.Category = storagetools.UsageGarbage
+		attribution.Details = "WAL before the oldest backup " + a.oldestBackupName
+	}
+	return attribution
+}
+
+func (a *backupUsageAttributor) isWalBeforeOldestBackup(name string) bool {
+	if !a.hasOldestBackup || !strings.HasPrefix(name, utility.WalPath) {
+		return false
+	}
+	timeline, logSegNo, ok := TryFetchTimelineAndLogSegNo(name)
+	if !ok {
+		return false
+	}
+	return timeline < a.oldestTimeline || timeline == a.oldestTimeline && logSegNo < a.oldestLogSegNo
+}
@@ -0,0 +1,61 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestBackupUsageAttributor(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	put := func(name, content string) {
+		require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+	}
+	put("basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json", `{"LSN": 33554432}`)
+	put("basebackups_005/base_000000010000000000000002/metadata.json", `{"is_permanent": true}`)
+	put("basebackups_005/base_000000010000000000000004_D_000000010000000000000002_backup_stop_sentinel.json",
+		`{"LSN": 67108864, "DeltaFrom": "base_000000010000000000000002",
+		"DeltaFullName": "base_000000010000000000000002", "DeltaLSN": 33554432, "DeltaCount": 1}`)
+	put("basebackups_005/base_000000010000000000000004_D_000000010000000000000002/metadata.json", `{}`)
+	put("basebackups_005/base_000000010000000000000006/tar_partitions/part_1.tar.br", "incomplete")
+	put("wal_005/000000010000000000000001.br", "old wal")
+	put("wal_005/000000010000000000000002.br", "wal")
+	put("wal_005/000000010000000000000003.br", "wal")
+
+	var output bytes.Buffer
+	newAttributor := func(objects []storage.Object) (storagetools.Attributor, error) {
+		return postgres.NewBackupUsageAttributor(folder, objects)
+	}
+	err := storagetools.HandleStorageUsage(folder, newAttributor, storagetools.DefaultStoragePrices(), false, false, true, &output)
+	require.NoError(t, err)
+
+	var items []storagetools.UsageItem
+	require.NoError(t, json.Unmarshal(output.Bytes(), &items))
+	byName := map[string]storagetools.UsageItem{}
+	for _, item := range items {
+		byName[string(item.Category)+" "+item.Name] = item
+	}
+
+	assert.Equal(t, "permanent, full", byName["backup base_000000010000000000000002"].Details)
+	assert.Equal(t, "delta #1 from base_000000010000000000000002, chain of base_000000010000000000000002",
+		byName["backup base_000000010000000000000004_D_000000010000000000000002"].Details)
+	assert.Equal(t, "incomplete backup", byName["garbage base_000000010000000000000006"].Details)
+
+	oldWal := byName["garbage wal timeline 00000001"]
+	assert.Equal(t, 1, oldWal.Objects)
+	assert.Equal(t, "000000010000000000000001.br", oldWal.First)
+	assert.Equal(t, "WAL before the oldest backup base_000000010000000000000002", oldWal.Details)
+
+	wal := byName["log wal timeline 00000001"]
+	assert.Equal(t, 2, wal.Objects)
+	assert.Equal(t, "000000010000000000000002.br", wal.First)
+	assert.Equal(t, "000000010000000000000003.br", wal.Last)
+}
@@ -422,6 +422,16 @@ func (bundle *Bundle) DownloadDeltaMap(reader internal.StorageFolderReader, back
 	return nil
 }
 
+// LoadWalSummaryDeltaMap builds the delta map from the WAL summaries of the server instead of the WAL in the storage
+func (bundle *Bundle) LoadWalSummaryDeltaMap(queryRunner *PgQueryRunner, backupStartLSN LSN) error {
+	deltaMap, err := getWalSummaryDeltaMap(queryRunner, bundle.Timeline, *bundle.IncrementFromLsn, backupStartLSN)
+	if err != nil {
+		return err
+	}
+	bundle.DeltaMap = deltaMap
+	return nil
+}
+
 func (bundle *Bundle) FinishTarComposer() (internal.TarFileSets, error) {
 	return bundle.TarBallComposer.FinishComposing()
 }
@@ -11,8 +11,9 @@ import (
 	"github.com/wal-g/wal-g/utility"
 )
 
-// HandleCopy copy specific or all backups from one storage to another
-func HandleCopy(fromConfigFile string, toConfigFile string, backupName string, withoutHistory bool) {
+// HandleCopy copy specific or all backups from one storage to another.
+// If recoder is not nil, the objects are re-encoded with it instead of copying them byte-for-byte.
+func HandleCopy(fromConfigFile string, toConfigFile string, backupName string, withoutHistory bool, recoder *copy.Recoder) {
 	var from, fromError = internal.StorageFromConfig(fromConfigFile)
 	var to, toError = internal.StorageFromConfig(toConfigFile)
 	if fromError != nil || toError != nil {
@@ -20,8 +21,14 @@ func HandleCopy(fromConfigFile string, toConfigFile string, backupName string, w
 	}
 	infos, err := getCopyingInfos(backupName, from.RootFolder(), to.RootFolder(), withoutHistory)
 	tracelog.ErrorLogger.FatalOnError(err)
+	if recoder != nil {
+		infos = copy.Recode(infos, recoder)
+	}
 	err = copy.Infos(infos)
 	tracelog.ErrorLogger.FatalOnError(err)
+	if recoder != nil {
+		tracelog.ErrorLogger.FatalOnError(recoder.UpdateCompressedSizes())
+	}
 	tracelog.InfoLogger.Println("Success copy.")
 }
 
@@ -12,10 +12,12 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/daemon"
+	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -203,6 +205,16 @@ func HandleDaemon(options DaemonOptions) {
 	defer sdNotifyTicker.Stop()
 	go SendSdNotify(sdNotifyTicker.C)
 
+	if viper.GetInt(conf.PgFailoverStoragesPutQuorum) > 0 {
+		catchUpInterval, err := conf.GetDurationSetting(conf.PgFailoverStoragesCatchUpInterval)
+		if err != nil {
+			tracelog.ErrorLogger.Fatal("Failed to get failover storages catch-up interval:", err)
+		}
+		catchUpTicker := time.NewTicker(catchUpInterval)
+		defer catchUpTicker.Stop()
+		go DrainReplicationJournal(catchUpTicker.C)
+	}
+
 	for {
 		fd, err := l.Accept()
 		if err != nil {
@@ -271,6 +283,31 @@ func failAndLogError(c net.Conn, err error) {
 	}
 }
 
+// DrainReplicationJournal catches up storages that missed WAL files uploaded with the put quorum.
+func DrainReplicationJournal(c <-chan time.Time) {
+	for {
+		<-c
+		tracelog.ErrorLogger.PrintOnError(drainReplicationJournal())
+	}
+}
+
+func drainReplicationJournal() error {
+	multiSt, err := ConfigureMultiStorage(true)
+	if err != nil {
+		return fmt.Errorf("configure multi-storage: %w", err)
+	}
+	defer utility.LoggedClose(multiSt, "close multi-storage")
+
+	replicated, err := multistorage.DrainReplicationJournal(context.Background(), multiSt.RootFolder(), "")
+	if replicated > 0 {
+		tracelog.InfoLogger.Printf("Replicated %d files pending replication to failover storages", replicated)
+	}
+	if err != nil {
+		return fmt.Errorf("drain replication journal: %w", err)
+	}
+	return nil
+}
+
 func SendSdNotify(c <-chan time.Time) {
 	for {
 		<-c
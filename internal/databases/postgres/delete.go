@@ -227,7 +227,7 @@ func getIncrementInfo(folder storage.Folder, object storage.Object, storageName
 // HandleDeleteGarbage delete outdated WAL archives and leftover backup files
 func (dh *DeleteHandler) HandleDeleteGarbage(args []string, confirm bool) error {
 	predicate := ExtractDeleteGarbagePredicate(args)
-	backupSelector := internal.NewOldestNonPermanentSelector(NewGenericMetaFetcher())
+	backupSelector := internal.NewOldestNonPermanentSelector(NewStorageGenericMetaFetcher())
 	oldestBackup, err := backupSelector.Select(dh.Folder)
 	if err != nil {
 		if _, ok := err.(internal.NoBackupsFoundError); ok {
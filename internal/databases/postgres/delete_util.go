@@ -31,7 +31,8 @@ func GetPermanentBackupsAndWals(folder storage.Folder) (map[PermanentObject]bool
 			internal.FatalOnUnrecoverableMetadataError(backupTime, err)
 			continue
 		}
-		meta, err := backup.FetchMeta()
+		// the permanence in the backup catalog can be stale
+		meta, err := backup.FetchMetaFromStorage()
 		if err != nil {
 			internal.FatalOnUnrecoverableMetadataError(backupTime, err)
 			continue
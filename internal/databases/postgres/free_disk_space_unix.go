@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package postgres
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// getFreeDiskSpace returns the number of bytes available to an unprivileged user on the filesystem of the path
+func getFreeDiskSpace(path string) (freeSpace int64, known bool, err error) {
+	var stat unix.Statfs_t
+	err = unix.Statfs(path, &stat)
+	if err != nil {
+		return 0, false, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true, nil
+}
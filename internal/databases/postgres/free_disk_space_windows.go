@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package postgres
+
+// getFreeDiskSpace isn't implemented on Windows, so the free space is reported as unknown
+func getFreeDiskSpace(path string) (freeSpace int64, known bool, err error) {
+	return 0, false, nil
+}
@@ -0,0 +1,307 @@
+package postgres
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// FsckChecks are the storage checks for PostgreSQL: the default ones, delta chains and WAL gaps
+func FsckChecks(minOrphanAge time.Duration) []storagetools.FsckCheck {
+	sentinels := &fsckSentinels{}
+	return append(storagetools.DefaultFsckChecks(minOrphanAge),
+		&DeltaChainCheck{sentinels: sentinels},
+		&WalGapCheck{sentinels: sentinels},
+	)
+}
+
+// fsckSentinels loads the sentinels of the complete backups once for all checks
+type fsckSentinels struct {
+	loaded     bool
+	sentinels  map[string]BackupSentinelDto
+	unreadable map[string]error
+}
+
+func (s *fsckSentinels) load(folder storage.Folder, objects []storage.Object) {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.sentinels = map[string]BackupSentinelDto{}
+	s.unreadable = map[string]error{}
+
+	baseBackupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	for _, object := range objects {
+		backupName, ok := sentinelBackupName(object.GetName())
+		if !ok {
+			continue
+		}
+		backup, err := NewBackup(baseBackupFolder, backupName)
+		if err != nil {
+			s.unreadable[backupName] = err
+			continue
+		}
+		sentinel, err := backup.GetSentinel()
+		if err != nil {
+			s.unreadable[backupName] = err
+			continue
+		}
+		s.sentinels[backupName] = sentinel
+	}
+}
+
+func sentinelBackupName(objectPath string) (string, bool) {
+	fileName := strings.TrimPrefix(objectPath, utility.BaseBackupPath)
+	if fileName == objectPath || strings.Contains(fileName, "/") || !strings.HasSuffix(fileName, utility.SentinelSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(fileName, utility.SentinelSuffix), true
+}
+
+// DeltaChainCheck finds delta backups that can't be restored since a backup in their chain is missing
+type DeltaChainCheck struct {
+	sentinels *fsckSentinels
+}
+
+func (c *DeltaChainCheck) Name() string {
+	return "delta_chain"
+}
+
+func (c *DeltaChainCheck) Check(folder storage.Folder, objects []storage.Object) ([]storagetools.FsckIssue, error) {
+	c.sentinels.load(folder, objects)
+
+	var issues []storagetools.FsckIssue
+	for backupName, err := range c.sentinels.unreadable {
+		issues = append(issues, storagetools.FsckIssue{
+			Check:       c.Name(),
+			Subject:     backupName,
+			Description: fmt.Sprintf("backup sentinel can't be read: %v", err),
+		})
+	}
+
+	brokenBases := map[string]string{}
+	var findBrokenBase func(backupName string) string
+	findBrokenBase = func(backupName string) string {
+		if base, ok := brokenBases[backupName]; ok {
+			return base
+		}
+		// guards against cycles in corrupted sentinels
+		brokenBases[backupName] = ""
+		sentinel := c.sentinels.sentinels[backupName]
+		if sentinel.IncrementFrom == nil {
+			return ""
+		}
+		base := *sentinel.IncrementFrom
+		if _, ok := c.sentinels.sentinels[base]; !ok {
+			brokenBases[backupName] = base
+			return base
+		}
+		brokenBases[backupName] = findBrokenBase(base)
+		return brokenBases[backupName]
+	}
+
+	for backupName := range c.sentinels.sentinels {
+		brokenBase := findBrokenBase(backupName)
+		if brokenBase == "" {
+			continue
+		}
+		issue := storagetools.FsckIssue{
+			Check:       c.Name(),
+			Subject:     backupName,
+			Description: fmt.Sprintf("delta chain is broken: backup %s is missing", brokenBase),
+		}
+		// the backup is garbage only if its missing base is known to be absent, not just unreadable
+		if _, unreadable := c.sentinels.unreadable[brokenBase]; !unreadable {
+			isPermanent, err := isPermanentBackup(folder, backupName)
+			switch {
+			case err != nil:
+				issue.Description += fmt.Sprintf("; backup metadata can't be read: %v", err)
+			case isPermanent:
+				issue.Description += "; backup is permanent"
+			default:
+				issue.Garbage = backupObjects(objects, backupName)
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func isPermanentBackup(folder storage.Folder, backupName string) (bool, error) {
+	backup, err := NewBackup(folder.GetSubFolder(utility.BaseBackupPath), backupName)
+	if err != nil {
+		return false, err
+	}
+	meta, err := backup.FetchMeta()
+	if err != nil {
+		return false, err
+	}
+	return meta.IsPermanent, nil
+}
+
+func backupObjects(objects []storage.Object, backupName string) []string {
+	var names []string
+	dataPrefix := utility.BaseBackupPath + backupName + "/"
+	sentinelName := utility.BaseBackupPath + backupName + utility.SentinelSuffix
+	for _, object := range objects {
+		if object.GetName() == sentinelName || strings.HasPrefix(object.GetName(), dataPrefix) {
+			names = append(names, object.GetName())
+		}
+	}
+	return names
+}
+
+// WalGapCheck finds gaps in WAL that break the point-in-time recovery of the stored backups.
+// WAL older than the oldest backup of the timeline isn't checked.
+type WalGapCheck struct {
+	sentinels *fsckSentinels
+}
+
+func (c *WalGapCheck) Name() string {
+	return "wal_gap"
+}
+
+type walGapBackup struct {
+	name      string
+	startSeg  uint64
+	finishSeg uint64
+}
+
+func (c *WalGapCheck) Check(folder storage.Folder, objects []storage.Object) ([]storagetools.FsckIssue, error) {
+	c.sentinels.load(folder, objects)
+
+	segmentsByTimeline := map[uint32][]uint64{}
+	for _, object := range objects {
+		if !strings.HasPrefix(object.GetName(), utility.WalPath) {
+			continue
+		}
+		fileName := path.Base(object.GetName())
+		if strings.Contains(fileName, ".history") {
+			continue
+		}
+		timeline, logSegNo, ok := TryFetchTimelineAndLogSegNo(fileName)
+		if !ok {
+			continue
+		}
+		segmentsByTimeline[timeline] = append(segmentsByTimeline[timeline], logSegNo)
+	}
+
+	backupsByTimeline := map[uint32][]walGapBackup{}
+	for backupName, sentinel := range c.sentinels.sentinels {
+		if !hasTimelineInName(backupName) || sentinel.BackupStartLSN == nil {
+			continue
+		}
+		timeline, err := ParseTimelineFromBackupName(backupName)
+		if err != nil {
+			continue
+		}
+		backup := walGapBackup{name: backupName, startSeg: GetSegmentNoFromLsn(*sentinel.BackupStartLSN)}
+		backup.finishSeg = backup.startSeg
+		if sentinel.BackupFinishLSN != nil {
+			backup.finishSeg = GetSegmentNoFromLsn(*sentinel.BackupFinishLSN)
+		}
+		backupsByTimeline[timeline] = append(backupsByTimeline[timeline], backup)
+	}
+
+	timelines := map[uint32]bool{}
+	for timeline := range segmentsByTimeline {
+		timelines[timeline] = true
+	}
+	for timeline := range backupsByTimeline {
+		timelines[timeline] = true
+	}
+
+	var issues []storagetools.FsckIssue
+	for timeline := range timelines {
+		for _, gap := range findWalGaps(segmentsByTimeline[timeline], backupsByTimeline[timeline]) {
+			issues = append(issues, storagetools.FsckIssue{
+				Check:       c.Name(),
+				Subject:     fmt.Sprintf("timeline %08X", timeline),
+				Description: describeWalGap(timeline, gap, backupsByTimeline[timeline]),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// hasTimelineInName checks that the backup name is long enough to contain the timeline,
+// since backups may be pushed with custom names
+func hasTimelineInName(backupName string) bool {
+	return strings.HasPrefix(backupName, utility.BackupNamePrefix) && len(backupName) >= len(utility.BackupNamePrefix)+8
+}
+
+type walGap struct {
+	from, to uint64
+}
+
+// findWalGaps finds the missing segments from the oldest backup start up to the newest segment or backup finish.
+// If the timeline has no backups, the segments are checked from the oldest one.
+func findWalGaps(segments []uint64, backups []walGapBackup) []walGap {
+	if len(segments) == 0 && len(backups) == 0 {
+		return nil
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	var lower, upper uint64
+	if len(backups) > 0 {
+		lower, upper = backups[0].startSeg, backups[0].finishSeg
+		for _, backup := range backups {
+			lower = min(lower, backup.startSeg)
+			upper = max(upper, backup.finishSeg)
+		}
+	} else {
+		lower = segments[0]
+	}
+	if len(segments) > 0 {
+		upper = max(upper, segments[len(segments)-1])
+	}
+
+	var gaps []walGap
+	next := lower
+	for _, segment := range segments {
+		if segment < next {
+			continue
+		}
+		if segment > next {
+			gaps = append(gaps, walGap{from: next, to: segment - 1})
+		}
+		next = segment + 1
+	}
+	if next <= upper {
+		gaps = append(gaps, walGap{from: next, to: upper})
+	}
+	return gaps
+}
+
+func describeWalGap(timeline uint32, gap walGap, backups []walGapBackup) string {
+	var unrestorable, limited []string
+	for _, backup := range backups {
+		switch {
+		case backup.startSeg <= gap.to && backup.finishSeg >= gap.from:
+			unrestorable = append(unrestorable, backup.name)
+		case backup.finishSeg < gap.from:
+			limited = append(limited, backup.name)
+		}
+	}
+	sort.Strings(unrestorable)
+	sort.Strings(limited)
+
+	description := fmt.Sprintf("WAL segments %s - %s are missing",
+		formatWALFileName(timeline, gap.from), formatWALFileName(timeline, gap.to))
+	if len(unrestorable) > 0 {
+		description += fmt.Sprintf("; backups can't be restored: %s", strings.Join(unrestorable, ", "))
+	}
+	if len(limited) > 0 {
+		description += fmt.Sprintf("; point-in-time recovery of backups ends before the gap: %s", strings.Join(limited, ", "))
+	}
+	if len(unrestorable) == 0 && len(limited) == 0 {
+		description += "; point-in-time recovery across the gap is impossible"
+	}
+	return description
+}
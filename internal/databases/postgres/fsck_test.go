@@ -0,0 +1,75 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestFsckChecks(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	put := func(name, content string) {
+		require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+	}
+	put("basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json",
+		`{"LSN": 33554432, "FinishLSN": 33554560}`)
+	put("basebackups_005/base_000000010000000000000002/metadata.json", `{}`)
+	put("basebackups_005/base_000000010000000000000004_D_000000010000000000000002_backup_stop_sentinel.json",
+		`{"LSN": 67108864, "FinishLSN": 67108992, "DeltaFrom": "base_000000010000000000000002",
+		"DeltaFullName": "base_000000010000000000000002", "DeltaLSN": 33554432, "DeltaCount": 1}`)
+	put("basebackups_005/base_000000010000000000000004_D_000000010000000000000002/metadata.json", `{}`)
+	put("basebackups_005/base_000000010000000000000006_D_000000010000000000000005_backup_stop_sentinel.json",
+		`{"LSN": 100663296, "FinishLSN": 100663424, "DeltaFrom": "base_000000010000000000000005",
+		"DeltaFullName": "base_000000010000000000000005", "DeltaLSN": 83886080, "DeltaCount": 1}`)
+	put("basebackups_005/base_000000010000000000000006_D_000000010000000000000005/metadata.json", `{}`)
+	put("basebackups_005/base_000000010000000000000008_D_000000010000000000000007_backup_stop_sentinel.json",
+		`{"LSN": 134217728, "FinishLSN": 134217856, "DeltaFrom": "base_000000010000000000000007",
+		"DeltaFullName": "base_000000010000000000000007", "DeltaLSN": 117440512, "DeltaCount": 1}`)
+	put("basebackups_005/base_000000010000000000000008_D_000000010000000000000007/metadata.json",
+		`{"is_permanent": true}`)
+	// the custom backup name doesn't contain the timeline
+	put("basebackups_005/short_backup_stop_sentinel.json", `{"LSN": 33554432, "FinishLSN": 33554560}`)
+	put("basebackups_005/short/metadata.json", `{}`)
+	for _, segment := range []string{"1", "2", "3", "5", "6", "7", "8"} {
+		put("wal_005/00000001000000000000000"+segment+".br", "wal")
+	}
+	put("wal_005/00000002.history.br", "history")
+
+	var output bytes.Buffer
+	err := storagetools.HandleFsck(folder, postgres.FsckChecks(time.Hour), false, false, true, &output)
+	require.Error(t, err)
+
+	var issues []storagetools.FsckIssue
+	require.NoError(t, json.Unmarshal(output.Bytes(), &issues))
+	require.Len(t, issues, 3)
+
+	assert.Equal(t, "delta_chain", issues[0].Check)
+	assert.Equal(t, "base_000000010000000000000006_D_000000010000000000000005", issues[0].Subject)
+	assert.Equal(t, "delta chain is broken: backup base_000000010000000000000005 is missing", issues[0].Description)
+	assert.ElementsMatch(t, []string{
+		"basebackups_005/base_000000010000000000000006_D_000000010000000000000005_backup_stop_sentinel.json",
+		"basebackups_005/base_000000010000000000000006_D_000000010000000000000005/metadata.json",
+	}, issues[0].Garbage)
+
+	// permanent backups aren't deleted by the repair
+	assert.Equal(t, "delta_chain", issues[1].Check)
+	assert.Equal(t, "base_000000010000000000000008_D_000000010000000000000007", issues[1].Subject)
+	assert.Equal(t, "delta chain is broken: backup base_000000010000000000000007 is missing; backup is permanent",
+		issues[1].Description)
+	assert.Empty(t, issues[1].Garbage)
+
+	assert.Equal(t, "wal_gap", issues[2].Check)
+	assert.Equal(t, "timeline 00000001", issues[2].Subject)
+	assert.Equal(t, "WAL segments 000000010000000000000004 - 000000010000000000000004 are missing; "+
+		"backups can't be restored: base_000000010000000000000004_D_000000010000000000000002; "+
+		"point-in-time recovery of backups ends before the gap: base_000000010000000000000002", issues[2].Description)
+	assert.Empty(t, issues[2].Garbage)
+}
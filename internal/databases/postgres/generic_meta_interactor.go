@@ -13,23 +13,37 @@ type GenericMetaInteractor struct {
 
 func NewGenericMetaInteractor() GenericMetaInteractor {
 	return GenericMetaInteractor{
-		GenericMetaFetcher: NewGenericMetaFetcher(),
+		// the metadata is modified by the interactor, so it's fetched bypassing the backup catalog
+		GenericMetaFetcher: NewStorageGenericMetaFetcher(),
 		GenericMetaSetter:  NewGenericMetaSetter(),
 	}
 }
 
-type GenericMetaFetcher struct{}
+type GenericMetaFetcher struct {
+	// fromStorage makes the metadata be fetched bypassing the backup catalog
+	fromStorage bool
+}
 
 func NewGenericMetaFetcher() GenericMetaFetcher {
 	return GenericMetaFetcher{}
 }
 
+// NewStorageGenericMetaFetcher makes the fetcher that bypasses the backup catalog. It's used to decide what to delete,
+// since the permanence in the catalog can be stale.
+func NewStorageGenericMetaFetcher() GenericMetaFetcher {
+	return GenericMetaFetcher{fromStorage: true}
+}
+
 func (mf GenericMetaFetcher) Fetch(backupName string, backupFolder storage.Folder) (internal.GenericMetadata, error) {
 	backup, err := NewBackup(backupFolder, backupName)
 	if err != nil {
 		return internal.GenericMetadata{}, err
 	}
-	meta, err := backup.FetchMeta()
+	fetchMeta := backup.FetchMeta
+	if mf.fromStorage {
+		fetchMeta = backup.FetchMetaFromStorage
+	}
+	meta, err := fetchMeta()
 	if err != nil {
 		return internal.GenericMetadata{}, err
 	}
@@ -76,7 +90,7 @@ func modifyBackupMetadata(backupName string, backupFolder storage.Folder, modifi
 		return errors.Wrap(err, "failed to modify metadata")
 	}
 	var meta ExtendedMetadataDto
-	err = backup.FetchMetadata(&meta)
+	err = backup.FetchMetadataFromStorage(&meta)
 	if err != nil {
 		return errors.Wrap(err, "failed to fetch the existing backup metadata for modifying")
 	}
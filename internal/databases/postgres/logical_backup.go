@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// LogicalBackupPath is the storage prefix of the pg_dump backups,
+// its layout repeats the storage root so the generic backup helpers work with it
+const LogicalBackupPath = "logical_backups/"
+
+// LogicalBackupFormat is the pg_dump output format stored in storage
+const LogicalBackupFormat = "custom"
+
+// LogicalBackupSentinelDto describes the pg_dump backup of a single database
+type LogicalBackupSentinelDto struct {
+	Database         string      `json:"Database"`
+	Format           string      `json:"Format"`
+	PgDumpVersion    string      `json:"PgDumpVersion,omitempty"`
+	StartLocalTime   time.Time   `json:"StartLocalTime"`
+	FinishLocalTime  time.Time   `json:"FinishLocalTime"`
+	UncompressedSize int64       `json:"UncompressedSize,omitempty"`
+	CompressedSize   int64       `json:"CompressedSize,omitempty"`
+	Hostname         string      `json:"Hostname,omitempty"`
+	IsPermanent      bool        `json:"IsPermanent"`
+	UserData         interface{} `json:"UserData,omitempty"`
+
+	crypto.EncryptionInfo
+}
+
+// NewLogicalDumpCommand builds the pg_dump command writing the database in the custom format to stdout,
+// the connection settings are taken from the PG* environment variables
+func NewLogicalDumpCommand(ctx context.Context, pgBinDirectory, database string, extraArgs []string) *exec.Cmd {
+	args := append([]string{"--format=" + LogicalBackupFormat, "--dbname=" + database}, extraArgs...)
+	return exec.CommandContext(ctx, pgBinaryPath(pgBinDirectory, "pg_dump"), args...)
+}
+
+// NewLogicalRestoreCommand builds the pg_restore command reading the custom format dump from stdin
+func NewLogicalRestoreCommand(ctx context.Context, pgBinDirectory, database string, extraArgs []string) *exec.Cmd {
+	args := append([]string{"--dbname=" + database}, extraArgs...)
+	return exec.CommandContext(ctx, pgBinaryPath(pgBinDirectory, "pg_restore"), args...)
+}
+
+func pgBinaryPath(pgBinDirectory, name string) string {
+	if pgBinDirectory == "" {
+		return name
+	}
+	return pgBinDirectory + string(os.PathSeparator) + name
+}
+
+// GetPgDumpVersion returns the version line printed by the pg_dump binary next to the dump command
+func GetPgDumpVersion(dumpCmd *exec.Cmd) (string, error) {
+	output, err := exec.Command(dumpCmd.Path, "--version").Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the version of %s", dumpCmd.Path)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// newLogicalBackupName makes the name of the stream backups unique with a random suffix,
+// since the dumps of several databases may be pushed within the same second
+func newLogicalBackupName() (string, error) {
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+	return internal.StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat) + "_" +
+		hex.EncodeToString(suffix), nil
+}
+
+// checkLogicalBackupNameIsFree fails if the backup with the name is already in storage, so it's never overwritten
+func checkLogicalBackupNameIsFree(uploader internal.Uploader, backupName string) error {
+	exists, err := uploader.Folder().Exists(internal.SentinelNameFromBackup(backupName))
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if backup %s exists", backupName)
+	}
+	if exists {
+		return fmt.Errorf("backup %s already exists", backupName)
+	}
+	return nil
+}
+
+// HandleLogicalBackupPush streams the dump command output through the uploader
+// and uploads the logical backup sentinel
+func HandleLogicalBackupPush(ctx context.Context, uploader internal.Uploader, dumpCmd *exec.Cmd,
+	sentinel LogicalBackupSentinelDto) error {
+	backupName, err := newLogicalBackupName()
+	if err != nil {
+		return err
+	}
+	err = checkLogicalBackupNameIsFree(uploader, backupName)
+	if err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to obtain the OS hostname: %v", err)
+	}
+	sentinel.Hostname = hostname
+	sentinel.Format = LogicalBackupFormat
+	sentinel.StartLocalTime = utility.TimeNowCrossPlatformLocal()
+
+	stdout, stderr, err := utility.StartCommandWithStdoutStderr(dumpCmd)
+	if err != nil {
+		return errors.Wrap(err, "failed to start the dump command")
+	}
+	tracelog.InfoLogger.Printf("Dumping database %s", sentinel.Database)
+	err = uploader.PushNamedStream(ctx, limiters.NewDiskLimitReader(stdout), backupName)
+	cmdErr := dumpCmd.Wait()
+	if cmdErr != nil {
+		tracelog.ErrorLogger.Printf("Dump command output:\n%s", stderr.String())
+		return errors.Wrap(cmdErr, "dump command failed")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to push the dump")
+	}
+	sentinel.FinishLocalTime = utility.TimeNowCrossPlatformLocal()
+
+	sentinel.CompressedSize, err = uploader.UploadedDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc uploaded data size: %v", err)
+	}
+	sentinel.UncompressedSize, err = uploader.RawDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc raw data size: %v", err)
+	}
+	sentinel.EncryptionInfo = crypto.GetEncryptionInfo(uploader.Crypter())
+
+	err = checkLogicalBackupNameIsFree(uploader, backupName)
+	if err != nil {
+		return err
+	}
+	err = internal.UploadSentinel(uploader, &sentinel, backupName)
+	if err != nil {
+		return errors.Wrap(err, "failed to upload the sentinel")
+	}
+	tracelog.InfoLogger.Printf("Logical backup %s of database %s is uploaded", backupName, sentinel.Database)
+	return nil
+}
+
+// FetchLogicalBackup writes the selected dump to the output and closes it
+func FetchLogicalBackup(folder storage.Folder, selector internal.BackupSelector,
+	output io.WriteCloser) (LogicalBackupSentinelDto, error) {
+	// the split stream fetcher leaves the output open, the single stream one closes it
+	output = &utility.CloseOnce{WriteCloser: output}
+	defer utility.LoggedClose(output, "")
+
+	backup, err := selector.Select(folder)
+	if err != nil {
+		return LogicalBackupSentinelDto{}, err
+	}
+	var sentinel LogicalBackupSentinelDto
+	err = backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return LogicalBackupSentinelDto{}, err
+	}
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return LogicalBackupSentinelDto{}, err
+	}
+	fetcher, err := internal.GetBackupStreamFetcher(backup)
+	if err != nil {
+		return LogicalBackupSentinelDto{}, err
+	}
+	tracelog.InfoLogger.Printf("Fetching logical backup %s of database %s", backup.Name, sentinel.Database)
+	return sentinel, fetcher(backup, output)
+}
+
+// HandleLogicalBackupFetch streams the selected dump to the stdin of the restore command
+func HandleLogicalBackupFetch(folder storage.Folder, selector internal.BackupSelector, restoreCmd *exec.Cmd) error {
+	stdin, err := restoreCmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &bytes.Buffer{}
+	restoreCmd.Stderr = stderr
+	err = restoreCmd.Start()
+	if err != nil {
+		return errors.Wrap(err, "failed to start the restore command")
+	}
+	_, err = FetchLogicalBackup(folder, selector, stdin)
+	cmdErr := restoreCmd.Wait()
+	if err != nil || cmdErr != nil {
+		tracelog.ErrorLogger.Printf("Restore command output:\n%s", stderr.String())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch the logical backup")
+	}
+	if cmdErr != nil {
+		return errors.Wrap(cmdErr, "restore command failed")
+	}
+	return nil
+}
+
+// LogicalBackupMetaFetcher reads the generic metadata from the logical backup sentinels
+type LogicalBackupMetaFetcher struct{}
+
+func NewLogicalBackupMetaFetcher() LogicalBackupMetaFetcher {
+	return LogicalBackupMetaFetcher{}
+}
+
+func (mf LogicalBackupMetaFetcher) Fetch(backupName string, backupFolder storage.Folder) (internal.GenericMetadata, error) {
+	sentinel, err := fetchLogicalBackupSentinel(backupFolder, backupName)
+	if err != nil {
+		return internal.GenericMetadata{}, err
+	}
+	return internal.GenericMetadata{
+		BackupName:       backupName,
+		UncompressedSize: sentinel.UncompressedSize,
+		CompressedSize:   sentinel.CompressedSize,
+		Hostname:         sentinel.Hostname,
+		StartTime:        sentinel.StartLocalTime,
+		FinishTime:       sentinel.FinishLocalTime,
+		IsPermanent:      sentinel.IsPermanent,
+		IncrementDetails: &internal.NopIncrementDetailsFetcher{},
+		UserData:         sentinel.UserData,
+	}, nil
+}
+
+func fetchLogicalBackupSentinel(backupFolder storage.Folder, backupName string) (LogicalBackupSentinelDto, error) {
+	backup, err := internal.NewBackup(backupFolder, backupName)
+	if err != nil {
+		return LogicalBackupSentinelDto{}, err
+	}
+	var sentinel LogicalBackupSentinelDto
+	err = backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return LogicalBackupSentinelDto{}, fmt.Errorf("failed to fetch the sentinel of %s: %w", backupName, err)
+	}
+	return sentinel, nil
+}
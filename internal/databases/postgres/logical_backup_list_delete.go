@@ -0,0 +1,258 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/printlist"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// LogicalBackupDetail is the entry of the logical backup list
+type LogicalBackupDetail struct {
+	BackupName       string      `json:"backup_name"`
+	Database         string      `json:"database"`
+	PgDumpVersion    string      `json:"pg_dump_version,omitempty"`
+	StartLocalTime   time.Time   `json:"start_local_time"`
+	FinishLocalTime  time.Time   `json:"finish_local_time"`
+	UncompressedSize int64       `json:"uncompressed_size"`
+	CompressedSize   int64       `json:"compressed_size"`
+	Hostname         string      `json:"hostname,omitempty"`
+	IsPermanent      bool        `json:"is_permanent"`
+	UserData         interface{} `json:"user_data,omitempty"`
+}
+
+func (bd *LogicalBackupDetail) PrintableFields() []printlist.TableField {
+	prettyStartTime := internal.PrettyFormatTime(bd.StartLocalTime)
+	prettyFinishTime := internal.PrettyFormatTime(bd.FinishLocalTime)
+	return []printlist.TableField{
+		{
+			Name:       "name",
+			PrettyName: "Name",
+			Value:      bd.BackupName,
+		},
+		{
+			Name:       "database",
+			PrettyName: "Database",
+			Value:      bd.Database,
+		},
+		{
+			Name:        "start_time",
+			PrettyName:  "Start time",
+			Value:       internal.FormatTime(bd.StartLocalTime),
+			PrettyValue: &prettyStartTime,
+		},
+		{
+			Name:        "finish_time",
+			PrettyName:  "Finish time",
+			Value:       internal.FormatTime(bd.FinishLocalTime),
+			PrettyValue: &prettyFinishTime,
+		},
+		{
+			Name:       "pg_dump_version",
+			PrettyName: "pg_dump version",
+			Value:      bd.PgDumpVersion,
+		},
+		{
+			Name:       "uncompressed_size",
+			PrettyName: "Uncompressed size",
+			Value:      strconv.FormatInt(bd.UncompressedSize, 10),
+		},
+		{
+			Name:       "compressed_size",
+			PrettyName: "Compressed size",
+			Value:      strconv.FormatInt(bd.CompressedSize, 10),
+		},
+		{
+			Name:       "is_permanent",
+			PrettyName: "Permanent",
+			Value:      fmt.Sprintf("%v", bd.IsPermanent),
+		},
+	}
+}
+
+// GetLogicalBackupDetails loads the sentinels of the logical backups in the order of their creation,
+// database filters the backups of a single database if it's not empty
+func GetLogicalBackupDetails(folder storage.Folder, database string) ([]LogicalBackupDetail, error) {
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	backupTimes, err := internal.GetBackups(backupFolder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		return []LogicalBackupDetail{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	internal.SortBackupTimeSlices(backupTimes)
+
+	details := make([]LogicalBackupDetail, 0, len(backupTimes))
+	for _, backupTime := range backupTimes {
+		sentinel, err := fetchLogicalBackupSentinel(backupFolder, backupTime.BackupName)
+		if err != nil {
+			return nil, err
+		}
+		if database != "" && sentinel.Database != database {
+			continue
+		}
+		details = append(details, LogicalBackupDetail{
+			BackupName:       backupTime.BackupName,
+			Database:         sentinel.Database,
+			PgDumpVersion:    sentinel.PgDumpVersion,
+			StartLocalTime:   sentinel.StartLocalTime,
+			FinishLocalTime:  sentinel.FinishLocalTime,
+			UncompressedSize: sentinel.UncompressedSize,
+			CompressedSize:   sentinel.CompressedSize,
+			Hostname:         sentinel.Hostname,
+			IsPermanent:      sentinel.IsPermanent,
+			UserData:         sentinel.UserData,
+		})
+	}
+	return details, nil
+}
+
+// HandleLogicalBackupList prints the logical backups stored in the folder
+func HandleLogicalBackupList(folder storage.Folder, database string, pretty, json bool, output io.Writer) error {
+	details, err := GetLogicalBackupDetails(folder, database)
+	if err != nil {
+		return err
+	}
+	printableEntities := make([]printlist.Entity, len(details))
+	for i := range details {
+		printableEntities[i] = &details[i]
+	}
+	return printlist.List(printableEntities, output, pretty, json)
+}
+
+// NewLogicalDeleteHandler creates the delete handler for the logical backups of the database,
+// of all databases if it's empty, and returns the names of their permanent backups.
+// The handler deletes only these logical backups, the WAL and the physical backups are never touched by it.
+func NewLogicalDeleteHandler(folder storage.Folder, database string) (*internal.DeleteHandler, []string, error) {
+	details, err := GetLogicalBackupDetails(folder, database)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newLogicalDeleteHandler(folder, details, database != "")
+}
+
+func newLogicalDeleteHandler(folder storage.Folder, details []LogicalBackupDetail,
+	filterObjects bool) (*internal.DeleteHandler, []string, error) {
+	backupObjects, err := internal.FindBackupObjects(folder)
+	if err != nil {
+		return nil, nil, err
+	}
+	backupNames := make(map[string]bool, len(details))
+	permanentBackups := make(map[string]bool)
+	permanentBackupNames := make([]string, 0)
+	for _, detail := range details {
+		backupNames[detail.BackupName] = true
+		if detail.IsPermanent {
+			permanentBackups[detail.BackupName] = true
+			permanentBackupNames = append(permanentBackupNames, detail.BackupName)
+		}
+	}
+	handlerObjects := make([]internal.BackupObject, 0, len(details))
+	for _, object := range backupObjects {
+		if backupNames[object.GetBackupName()] {
+			handlerObjects = append(handlerObjects, object)
+		}
+	}
+
+	options := []internal.DeleteHandlerOption{
+		internal.IsPermanentFunc(func(object storage.Object) bool {
+			// the names differ in length, the later ones have a random suffix
+			return strings.HasPrefix(object.GetName(), utility.BaseBackupPath) && permanentBackups[logicalBackupNameOf(object)]
+		}),
+	}
+	if filterObjects {
+		options = append(options, internal.ObjectFilter(func(object storage.Object) bool {
+			return backupNames[logicalBackupNameOf(object)]
+		}))
+	}
+	return internal.NewDeleteHandler(folder, handlerObjects, logicalBackupLess, options...),
+		permanentBackupNames, nil
+}
+
+// logicalBackupNameOf returns the name of the backup the object of the logical backups folder belongs to
+func logicalBackupNameOf(object storage.Object) string {
+	return utility.StripLeftmostBackupName(strings.TrimPrefix(object.GetName(), utility.BaseBackupPath))
+}
+
+// HandleLogicalDeleteRetention applies the retention found by findTarget, e.g. DeleteHandler.FindTargetRetain,
+// to the logical backups of every database separately, so that retain keeps the number of backups of each database.
+// The database limits the retention to one database, as does the backup the retention is relative to, if any.
+func HandleLogicalDeleteRetention(folder storage.Folder, database, relativeToBackup string, confirmed bool,
+	findTarget func(handler *internal.DeleteHandler) (internal.BackupObject, error)) error {
+	details, err := GetLogicalBackupDetails(folder, database)
+	if err != nil {
+		return err
+	}
+	if relativeToBackup != "" {
+		database, err = findLogicalBackupDatabase(details, relativeToBackup)
+		if err != nil {
+			return err
+		}
+	}
+
+	detailsByDatabase := make(map[string][]LogicalBackupDetail)
+	databases := make([]string, 0)
+	for _, detail := range details {
+		if database != "" && detail.Database != database {
+			continue
+		}
+		if _, ok := detailsByDatabase[detail.Database]; !ok {
+			databases = append(databases, detail.Database)
+		}
+		detailsByDatabase[detail.Database] = append(detailsByDatabase[detail.Database], detail)
+	}
+	sort.Strings(databases)
+
+	for _, name := range databases {
+		handler, _, err := newLogicalDeleteHandler(folder, detailsByDatabase[name], true)
+		if err != nil {
+			return err
+		}
+		target, err := findTarget(handler)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply the retention to the backups of database %s", name)
+		}
+		if target == nil {
+			tracelog.InfoLogger.Printf("No backup of database %s found for deletion", name)
+			continue
+		}
+		tracelog.InfoLogger.Printf("Deleting the backups of database %s before %s", name, target.GetBackupName())
+		err = handler.DeleteBeforeTarget(target, confirmed)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findLogicalBackupDatabase(details []LogicalBackupDetail, backupName string) (string, error) {
+	for _, detail := range details {
+		if detail.BackupName == backupName {
+			return detail.Database, nil
+		}
+	}
+	return "", fmt.Errorf("logical backup %s is not found", backupName)
+}
+
+// logicalBackupLess orders the objects by the time in the stream backup name
+func logicalBackupLess(object1, object2 storage.Object) bool {
+	time1, ok := utility.TryFetchTimeRFC3999(object1.GetName())
+	if !ok {
+		time1 = object1.GetLastModified().Format(utility.BackupTimeFormat)
+	}
+	time2, ok := utility.TryFetchTimeRFC3999(object2.GetName())
+	if !ok {
+		time2 = object2.GetLastModified().Format(utility.BackupTimeFormat)
+	}
+	return time1 < time2
+}
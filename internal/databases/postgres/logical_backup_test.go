@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type logicalTestWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (writer *logicalTestWriteCloser) Close() error {
+	writer.closed = true
+	return nil
+}
+
+func putLogicalTestSentinel(t *testing.T, folder storage.Folder, backupName, sentinel string) {
+	require.NoError(t, folder.PutObject(
+		LogicalBackupPath+utility.BaseBackupPath+backupName+utility.SentinelSuffix, strings.NewReader(sentinel)))
+}
+
+func TestHandleLogicalBackupPushAndFetch(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	dump := strings.Repeat("PGDMP logical dump ", 100)
+	uploader := internal.NewSplitStreamUploader(internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(LogicalBackupPath+utility.BaseBackupPath)), 2, 7, 0)
+
+	err := HandleLogicalBackupPush(context.Background(), uploader, exec.Command("printf", "%s", dump),
+		LogicalBackupSentinelDto{Database: "shop", PgDumpVersion: "pg_dump (PostgreSQL) 16.2", IsPermanent: true})
+	require.NoError(t, err)
+
+	details, err := GetLogicalBackupDetails(folder.GetSubFolder(LogicalBackupPath), "")
+	require.NoError(t, err)
+	require.Len(t, details, 1)
+	assert.Equal(t, "shop", details[0].Database)
+	assert.Equal(t, "pg_dump (PostgreSQL) 16.2", details[0].PgDumpVersion)
+	assert.Equal(t, int64(len(dump)), details[0].UncompressedSize)
+	assert.True(t, details[0].IsPermanent)
+
+	output := &logicalTestWriteCloser{}
+	sentinel, err := FetchLogicalBackup(folder.GetSubFolder(LogicalBackupPath), internal.NewLatestBackupSelector(), output)
+	require.NoError(t, err)
+	assert.Equal(t, "shop", sentinel.Database)
+	assert.Equal(t, LogicalBackupFormat, sentinel.Format)
+	assert.Equal(t, dump, output.String())
+	assert.True(t, output.closed)
+}
+
+func TestHandleLogicalBackupPush_DumpFailure(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(LogicalBackupPath+utility.BaseBackupPath))
+
+	err := HandleLogicalBackupPush(context.Background(), uploader, exec.Command("sh", "-c", "printf partial; exit 1"),
+		LogicalBackupSentinelDto{Database: "shop"})
+	assert.Error(t, err)
+	sentinels, err := internal.GetBackupSentinelObjects(folder.GetSubFolder(LogicalBackupPath))
+	require.NoError(t, err)
+	assert.Empty(t, sentinels)
+}
+
+func TestHandleLogicalBackupPush_SameSecond(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(LogicalBackupPath+utility.BaseBackupPath))
+
+	for _, database := range []string{"shop", "billing"} {
+		require.NoError(t, HandleLogicalBackupPush(context.Background(), uploader,
+			exec.Command("printf", "%s", database), LogicalBackupSentinelDto{Database: database}))
+	}
+	details, err := GetLogicalBackupDetails(folder.GetSubFolder(LogicalBackupPath), "")
+	require.NoError(t, err)
+	require.Len(t, details, 2)
+	assert.NotEqual(t, details[0].BackupName, details[1].BackupName)
+	assert.ElementsMatch(t, []string{"shop", "billing"}, []string{details[0].Database, details[1].Database})
+}
+
+func TestCheckLogicalBackupNameIsFree(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(LogicalBackupPath+utility.BaseBackupPath))
+	assert.NoError(t, checkLogicalBackupNameIsFree(uploader, "stream_20240301T120000Z"))
+
+	putLogicalTestSentinel(t, folder, "stream_20240301T120000Z", `{"Database":"shop"}`)
+	assert.ErrorContains(t, checkLogicalBackupNameIsFree(uploader, "stream_20240301T120000Z"), "already exists")
+}
+
+func TestHandleLogicalBackupPush_Canceled(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName],
+		folder.GetSubFolder(LogicalBackupPath+utility.BaseBackupPath))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := HandleLogicalBackupPush(ctx, uploader, exec.CommandContext(ctx, "printf", "dump"),
+		LogicalBackupSentinelDto{Database: "shop"})
+	assert.Error(t, err)
+	sentinels, err := internal.GetBackupSentinelObjects(folder.GetSubFolder(LogicalBackupPath))
+	require.NoError(t, err)
+	assert.Empty(t, sentinels)
+}
+
+func TestGetLogicalBackupDetails_Database(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putLogicalTestSentinel(t, folder, "stream_20240301T120000Z", `{"Database":"shop"}`)
+	putLogicalTestSentinel(t, folder, "stream_20240302T120000Z", `{"Database":"billing"}`)
+
+	details, err := GetLogicalBackupDetails(folder.GetSubFolder(LogicalBackupPath), "billing")
+	require.NoError(t, err)
+	require.Len(t, details, 1)
+	assert.Equal(t, "stream_20240302T120000Z", details[0].BackupName)
+
+	details, err = GetLogicalBackupDetails(memory.NewFolder("", memory.NewKVS()), "")
+	require.NoError(t, err)
+	assert.Empty(t, details)
+}
+
+func getLogicalTestBackupNames(t *testing.T, folder storage.Folder) []string {
+	details, err := GetLogicalBackupDetails(folder.GetSubFolder(LogicalBackupPath), "")
+	require.NoError(t, err)
+	names := make([]string, 0, len(details))
+	for _, detail := range details {
+		names = append(names, detail.BackupName)
+	}
+	return names
+}
+
+func retainLogicalTestBackups(count int) func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+	return func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+		return handler.FindTargetRetain(count, internal.NoDeleteModifier)
+	}
+}
+
+func newLogicalRetentionTestFolder(t *testing.T) *memory.Folder {
+	folder := memory.NewFolder("", memory.NewKVS())
+	putLogicalTestSentinel(t, folder, "stream_20240301T120000Z", `{"Database":"shop","IsPermanent":true}`)
+	putLogicalTestSentinel(t, folder, "stream_20240302T120000Z", `{"Database":"shop"}`)
+	putLogicalTestSentinel(t, folder, "stream_20240302T130000Z", `{"Database":"billing"}`)
+	putLogicalTestSentinel(t, folder, "stream_20240303T120000Z", `{"Database":"shop"}`)
+	require.NoError(t, folder.PutObject(utility.BaseBackupPath+"base_000000010000000000000002"+utility.SentinelSuffix,
+		strings.NewReader("{}")))
+	return folder
+}
+
+func TestHandleLogicalDeleteRetention_PerDatabase(t *testing.T) {
+	folder := newLogicalRetentionTestFolder(t)
+
+	err := HandleLogicalDeleteRetention(folder.GetSubFolder(LogicalBackupPath), "", "", true,
+		retainLogicalTestBackups(1))
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"stream_20240301T120000Z", "stream_20240302T130000Z", "stream_20240303T120000Z"},
+		getLogicalTestBackupNames(t, folder), "the only backup of billing is kept")
+	_, err = folder.ReadObject(utility.BaseBackupPath + "base_000000010000000000000002" + utility.SentinelSuffix)
+	assert.NoError(t, err, "the physical backups are not touched")
+}
+
+func TestHandleLogicalDeleteRetention_PermanentWithSuffix(t *testing.T) {
+	folder := newLogicalRetentionTestFolder(t)
+	putLogicalTestSentinel(t, folder, "stream_20240301T110000Z_0a1b2c3d", `{"Database":"shop","IsPermanent":true}`)
+
+	err := HandleLogicalDeleteRetention(folder.GetSubFolder(LogicalBackupPath), "shop", "", true,
+		retainLogicalTestBackups(1))
+	require.NoError(t, err)
+	assert.Contains(t, getLogicalTestBackupNames(t, folder), "stream_20240301T110000Z_0a1b2c3d")
+}
+
+func TestHandleLogicalDeleteRetention_Database(t *testing.T) {
+	folder := newLogicalRetentionTestFolder(t)
+	putLogicalTestSentinel(t, folder, "stream_20240304T130000Z", `{"Database":"billing"}`)
+
+	err := HandleLogicalDeleteRetention(folder.GetSubFolder(LogicalBackupPath), "billing", "", true,
+		retainLogicalTestBackups(1))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"stream_20240301T120000Z", "stream_20240302T120000Z",
+		"stream_20240303T120000Z", "stream_20240304T130000Z"}, getLogicalTestBackupNames(t, folder))
+
+	// the retention relative to a backup is applied to its database only
+	err = HandleLogicalDeleteRetention(folder.GetSubFolder(LogicalBackupPath), "", "stream_20240303T120000Z", true,
+		func(handler *internal.DeleteHandler) (internal.BackupObject, error) {
+			return handler.FindTargetBefore("stream_20240303T120000Z", internal.NoDeleteModifier)
+		})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"stream_20240301T120000Z", "stream_20240303T120000Z", "stream_20240304T130000Z"},
+		getLogicalTestBackupNames(t, folder))
+}
+
+func TestNewLogicalDeleteHandler_Database(t *testing.T) {
+	folder := newLogicalRetentionTestFolder(t)
+
+	deleteHandler, permanentBackups, err := NewLogicalDeleteHandler(folder.GetSubFolder(LogicalBackupPath), "billing")
+	require.NoError(t, err)
+	assert.Empty(t, permanentBackups)
+	deleteHandler.DeleteEverything(true)
+	assert.ElementsMatch(t, []string{"stream_20240301T120000Z", "stream_20240302T120000Z", "stream_20240303T120000Z"},
+		getLogicalTestBackupNames(t, folder))
+
+	_, permanentBackups, err = NewLogicalDeleteHandler(folder.GetSubFolder(LogicalBackupPath), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stream_20240301T120000Z"}, permanentBackups)
+}
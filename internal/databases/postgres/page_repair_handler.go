@@ -0,0 +1,453 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	pageRepairStagingDirPrefix = "page_repair_"
+	pageRepairMainFork         = 0
+	pdUpperOffset              = 14
+)
+
+// PageRepairTarget is the relation of the default tablespace to repair, the whole main fork or one block of it.
+// Without the target LSN the WAL is replayed up to the end of the archive.
+type PageRepairTarget struct {
+	DBNode    walparser.Oid
+	RelNode   walparser.Oid
+	Block     *uint32
+	TargetLSN *LSN
+}
+
+// ParsePageRepairRelation parses the relation in the database/relfilenode format
+func ParsePageRepairRelation(relation string) (dbNode, relNode walparser.Oid, err error) {
+	parts := strings.Split(relation, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("relation %q is not in the database/relfilenode format", relation)
+	}
+	var oids [2]walparser.Oid
+	for i, part := range parts {
+		oid, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "invalid oid in relation %q", relation)
+		}
+		oids[i] = walparser.Oid(oid)
+	}
+	return oids[0], oids[1], nil
+}
+
+func (target PageRepairTarget) relFileNode() walparser.RelFileNode {
+	return walparser.RelFileNode{SpcNode: DefaultSpcNode, DBNode: target.DBNode, RelNode: target.RelNode}
+}
+
+// relationPath is the path of the relation segment file relative to the data directory
+func (target PageRepairTarget) relationPath(segmentNo uint32) string {
+	name := strconv.FormatUint(uint64(target.RelNode), 10)
+	if segmentNo > 0 {
+		name += "." + strconv.FormatUint(uint64(segmentNo), 10)
+	}
+	return filepath.Join(DefaultTablespace, strconv.FormatUint(uint64(target.DBNode), 10), name)
+}
+
+// PageRepairStaleBlock is the block changed by WAL without a full-page image after its last restored version.
+// SafeLSN is the newest target LSN the block is repaired exactly at: the record before its first such change,
+// nil if there is no such LSN after the backup is consistent.
+type PageRepairStaleBlock struct {
+	Block   uint32
+	LSN     LSN
+	SafeLSN *LSN
+}
+
+// pageRepairer replays the full-page images of the relation blocks from WAL
+type pageRepairer struct {
+	target   PageRepairTarget
+	relation walparser.RelFileNode
+	pages    map[uint32]*PgDatabasePage
+	stale    map[uint32]LSN
+	// safe keeps the newest safe target LSN of the stale blocks, see PageRepairStaleBlock
+	safe map[uint32]LSN
+	// consistentLSN is the LSN the restored backup is consistent from, no earlier target is safe
+	consistentLSN LSN
+	lastLSN       LSN
+	reachedLSN    LSN
+}
+
+func newPageRepairer(target PageRepairTarget) *pageRepairer {
+	return &pageRepairer{
+		target:   target,
+		relation: target.relFileNode(),
+		pages:    make(map[uint32]*PgDatabasePage),
+		stale:    make(map[uint32]LSN),
+		safe:     make(map[uint32]LSN),
+	}
+}
+
+// applyRecord restores the pages from the full-page images of the record
+// and marks the blocks changed without images as stale
+func (repairer *pageRepairer) applyRecord(located walparser.LocatedXLogRecord) {
+	previousLSN := repairer.lastLSN
+	repairer.lastLSN = LSN(located.LSN)
+	repairer.reachedLSN = LSN(located.EndLSN)
+	for i := range located.Record.Blocks {
+		block := &located.Record.Blocks[i]
+		location := block.Header.BlockLocation
+		if location.RelationFileNode != repairer.relation || block.Header.ForkNum() != pageRepairMainFork {
+			continue
+		}
+		if repairer.target.Block != nil && location.BlockNo != *repairer.target.Block {
+			continue
+		}
+		if !block.Header.HasImage() && block.Header.WillInit() {
+			// the record initializes the page without reading it, so the changes missed before don't matter
+			repairer.clearStale(location.BlockNo)
+			continue
+		}
+		if !block.Header.HasImage() || !block.Header.ImageHeader.ApplyImage() {
+			repairer.markStale(location.BlockNo, LSN(located.LSN), previousLSN)
+			continue
+		}
+		image, err := block.PageImage()
+		if err != nil {
+			tracelog.WarningLogger.Printf("Can't restore block %d from the record at %s: %v",
+				location.BlockNo, LSN(located.LSN), err)
+			repairer.markStale(location.BlockNo, LSN(located.LSN), previousLSN)
+			continue
+		}
+		page := new(PgDatabasePage)
+		copy(page[:], image)
+		setRestoredPageLSN(page, location.BlockNo, LSN(located.EndLSN))
+		repairer.pages[location.BlockNo] = page
+		repairer.clearStale(location.BlockNo)
+	}
+}
+
+// markStale marks the block changed at the LSN, the previous record is its newest safe target
+// unless the block is stale already
+func (repairer *pageRepairer) markStale(blockNo uint32, lsn, previousLSN LSN) {
+	if _, ok := repairer.stale[blockNo]; !ok && previousLSN != 0 {
+		repairer.safe[blockNo] = previousLSN
+	}
+	repairer.stale[blockNo] = lsn
+}
+
+func (repairer *pageRepairer) clearStale(blockNo uint32) {
+	delete(repairer.stale, blockNo)
+	delete(repairer.safe, blockNo)
+}
+
+// setRestoredPageLSN stamps the page the way the WAL redo does after restoring the image and sets the checksum
+func setRestoredPageLSN(page *PgDatabasePage, blockNo uint32, lsn LSN) {
+	if binary.LittleEndian.Uint16(page[pdUpperOffset:]) == 0 {
+		// new pages have neither LSN nor checksum
+		return
+	}
+	binary.LittleEndian.PutUint32(page[0:], uint32(lsn>>32))
+	binary.LittleEndian.PutUint32(page[4:], uint32(lsn))
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], pgChecksumPage(blockNo, page))
+}
+
+// replayWal applies the WAL records of the timeline from the start LSN up to the target LSN inclusive.
+// Without the target the records are read until the first missing segment.
+func (repairer *pageRepairer) replayWal(folderReader internal.StorageFolderReader, timeline uint32,
+	startLSN LSN) error {
+	targetLSN := repairer.target.TargetLSN
+	repairer.reachedLSN = startLSN
+	recordReader := walparser.NewWalRecordReader()
+	for segmentNo := NewWalSegmentNo(startLSN); ; segmentNo = segmentNo.Next() {
+		if targetLSN != nil && segmentNo.firstLsn() > *targetLSN {
+			return nil
+		}
+		filename := segmentNo.GetFilename(timeline)
+		reader, err := internal.DownloadAndDecompressStorageFile(folderReader, filename)
+		if _, ok := err.(internal.ArchiveNonExistenceError); ok && targetLSN == nil {
+			tracelog.InfoLogger.Printf("WAL segment %s is not found, stopping the replay", filename)
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to download WAL segment %s", filename)
+		}
+		tracelog.DebugLogger.Printf("Replaying WAL segment %s", filename)
+		err = recordReader.ReadRecords(reader, func(located walparser.LocatedXLogRecord) error {
+			if LSN(located.LSN) < startLSN || located.Record.IsZero() {
+				return nil
+			}
+			if targetLSN != nil && LSN(located.LSN) > *targetLSN {
+				return errStopReadingWal
+			}
+			repairer.applyRecord(located)
+			return nil
+		})
+		utility.LoggedClose(reader, "")
+		if err == errStopReadingWal {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read WAL segment %s", filename)
+		}
+	}
+}
+
+// readBackupPage reads the block from the relation restored from the backup, nil if there is no such block
+func (repairer *pageRepairer) readBackupPage(dataDirectory string, blockNo uint32) (*PgDatabasePage, error) {
+	file, err := os.Open(filepath.Join(dataDirectory, repairer.target.relationPath(blockNo/uint32(BlocksInRelFile))))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(file, "")
+	page := new(PgDatabasePage)
+	_, err = file.ReadAt(page[:], int64(blockNo%uint32(BlocksInRelFile))*DatabasePageSize)
+	if err == io.EOF {
+		return nil, nil
+	}
+	return page, err
+}
+
+// writeBlock writes the repaired block as a single page file and returns its path
+func (repairer *pageRepairer) writeBlock(dataDirectory, outputDirectory string) (string, error) {
+	blockNo := *repairer.target.Block
+	page, ok := repairer.pages[blockNo]
+	if !ok {
+		var err error
+		page, err = repairer.readBackupPage(dataDirectory, blockNo)
+		if err != nil {
+			return "", err
+		}
+		if page == nil {
+			return "", fmt.Errorf("block %d is found neither in the backup nor in the full-page images of WAL", blockNo)
+		}
+	}
+	outputPath := filepath.Join(outputDirectory, fmt.Sprintf("%d_%d.page", repairer.target.RelNode, blockNo))
+	return outputPath, os.WriteFile(outputPath, page[:], 0600)
+}
+
+// writeRelation writes the relation files restored from the backup with the repaired pages written over them
+func (repairer *pageRepairer) writeRelation(dataDirectory, outputDirectory string) ([]string, error) {
+	segments := make(map[uint32]bool)
+	for blockNo := range repairer.pages {
+		segments[blockNo/uint32(BlocksInRelFile)] = true
+	}
+	for segmentNo := uint32(0); ; segmentNo++ {
+		_, err := os.Stat(filepath.Join(dataDirectory, repairer.target.relationPath(segmentNo)))
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments[segmentNo] = true
+	}
+
+	var outputPaths []string
+	for segmentNo := range segments {
+		outputPath, err := repairer.writeRelationSegment(dataDirectory, outputDirectory, segmentNo)
+		if err != nil {
+			return nil, err
+		}
+		outputPaths = append(outputPaths, outputPath)
+	}
+	sort.Strings(outputPaths)
+	return outputPaths, nil
+}
+
+func (repairer *pageRepairer) writeRelationSegment(dataDirectory, outputDirectory string,
+	segmentNo uint32) (string, error) {
+	relationPath := repairer.target.relationPath(segmentNo)
+	outputPath := filepath.Join(outputDirectory, filepath.Base(relationPath))
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer utility.LoggedClose(output, "")
+
+	input, err := os.Open(filepath.Join(dataDirectory, relationPath))
+	if err == nil {
+		_, err = io.Copy(output, input)
+		utility.LoggedClose(input, "")
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for blockNo, page := range repairer.pages {
+		if blockNo/uint32(BlocksInRelFile) != segmentNo {
+			continue
+		}
+		_, err = output.WriteAt(page[:], int64(blockNo%uint32(BlocksInRelFile))*DatabasePageSize)
+		if err != nil {
+			return "", err
+		}
+	}
+	return outputPath, nil
+}
+
+func (repairer *pageRepairer) staleBlocks() []PageRepairStaleBlock {
+	blocks := make([]PageRepairStaleBlock, 0, len(repairer.stale))
+	for blockNo, lsn := range repairer.stale {
+		block := PageRepairStaleBlock{Block: blockNo, LSN: lsn}
+		if safeLSN, ok := repairer.safe[blockNo]; ok && safeLSN >= repairer.consistentLSN {
+			block.SafeLSN = &safeLSN
+		}
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Block < blocks[j].Block })
+	return blocks
+}
+
+// checkStaleBlocks fails if any block was changed after its last restored version: writing the outdated page
+// would silently lose committed changes. The error tells the newest target LSN each block can be repaired at,
+// repair the blocks one by one with --block if their safe LSNs differ.
+func (repairer *pageRepairer) checkStaleBlocks() error {
+	staleBlocks := repairer.staleBlocks()
+	if len(staleBlocks) == 0 {
+		return nil
+	}
+	descriptions := make([]string, 0, len(staleBlocks))
+	for _, block := range staleBlocks {
+		if block.SafeLSN == nil {
+			descriptions = append(descriptions, fmt.Sprintf("block %d at %s (no safe target LSN)", block.Block, block.LSN))
+			continue
+		}
+		descriptions = append(descriptions,
+			fmt.Sprintf("block %d at %s (safe up to --target-lsn %s)", block.Block, block.LSN, *block.SafeLSN))
+	}
+	return fmt.Errorf("the blocks are changed in WAL without full-page images after their last restored version, "+
+		"so the repaired pages would lose these changes and nothing is written: %s", strings.Join(descriptions, ", "))
+}
+
+// HandlePageRepair restores the relation files from the backup with its delta chain, replays the full-page images
+// of the relation blocks from the archived WAL of the backup timeline and writes the repaired files
+// to the output directory, so that they can be swapped in while the cluster is stopped.
+// The blocks changed in WAL without full-page images can't be redone outside PostgreSQL,
+// so if any of the target blocks is changed after its last image, the command fails without writing anything
+// and reports the newest target LSN each of them can be repaired at.
+func HandlePageRepair(rootFolder storage.Folder, backupName string, target PageRepairTarget,
+	outputDirectory string, output io.Writer) error {
+	baseBackup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, rootFolder)
+	if err != nil {
+		return err
+	}
+	backup := ToPgBackup(baseBackup)
+	sentinel, err := backup.GetSentinel()
+	if err != nil {
+		return err
+	}
+	if sentinel.BackupStartLSN == nil {
+		return fmt.Errorf("backup %s has no start LSN", backup.Name)
+	}
+	timeline, err := ParseTimelineFromBackupName(backup.Name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(outputDirectory, 0700)
+	if err != nil {
+		return err
+	}
+	dataDirectory, err := os.MkdirTemp(outputDirectory, pageRepairStagingDirPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dataDirectory)
+
+	err = restoreRelationFiles(backup, rootFolder, target, dataDirectory)
+	if err != nil {
+		return err
+	}
+
+	repairer := newPageRepairer(target)
+	if sentinel.BackupFinishLSN != nil {
+		repairer.consistentLSN = *sentinel.BackupFinishLSN
+	}
+	tracelog.InfoLogger.Printf("Replaying WAL of timeline %d from LSN %s", timeline, *sentinel.BackupStartLSN)
+	err = repairer.replayWal(internal.NewFolderReader(rootFolder.GetSubFolder(utility.WalPath)),
+		timeline, *sentinel.BackupStartLSN)
+	if err != nil {
+		return err
+	}
+	err = repairer.checkStaleBlocks()
+	if err != nil {
+		return err
+	}
+
+	var outputPaths []string
+	if target.Block != nil {
+		var outputPath string
+		outputPath, err = repairer.writeBlock(dataDirectory, outputDirectory)
+		outputPaths = []string{outputPath}
+	} else {
+		outputPaths, err = repairer.writeRelation(dataDirectory, outputDirectory)
+	}
+	if err != nil {
+		return err
+	}
+	return printPageRepairSummary(output, backup.Name, repairer, outputPaths)
+}
+
+// restoreRelationFiles restores the main fork files of the relation from the backup
+func restoreRelationFiles(backup Backup, rootFolder storage.Folder, target PageRepairTarget,
+	dataDirectory string) error {
+	relationPath := target.relationPath(0)
+	filesToUnwrap, err := backup.GetFilesToUnwrap(relationPath)
+	if err != nil {
+		return err
+	}
+	if filesToUnwrap == nil {
+		tracelog.WarningLogger.Printf("Backup %s has no files metadata, the whole backup is restored", backup.Name)
+	} else {
+		segmentFiles, err := backup.GetFilesToUnwrap(relationPath + ".*")
+		if err != nil {
+			return err
+		}
+		if len(filesToUnwrap)+len(segmentFiles) == 0 {
+			tracelog.WarningLogger.Printf("Relation %s is not found in backup %s, starting from empty pages",
+				relationPath, backup.Name)
+			return nil
+		}
+		for file := range segmentFiles {
+			filesToUnwrap[file] = true
+		}
+	}
+	tracelog.InfoLogger.Printf("Restoring relation %s from backup %s", relationPath, backup.Name)
+	err = deltaFetchRecursionOld(backup, rootFolder, dataDirectory, nil, filesToUnwrap, ExtractProviderImpl{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore relation %s from backup %s", relationPath, backup.Name)
+	}
+	return nil
+}
+
+func printPageRepairSummary(output io.Writer, backupName string, repairer *pageRepairer, outputPaths []string) error {
+	restored := make([]uint32, 0, len(repairer.pages))
+	for blockNo := range repairer.pages {
+		restored = append(restored, blockNo)
+	}
+	sort.Slice(restored, func(i, j int) bool { return restored[i] < restored[j] })
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Backup: %s\nWAL replayed up to: %s\n", backupName, repairer.reachedLSN)
+	fmt.Fprintf(&builder, "Blocks restored from full-page images: %d %v\n", len(restored), restored)
+	for _, outputPath := range outputPaths {
+		fmt.Fprintf(&builder, "Written: %s\n", outputPath)
+	}
+	if block := repairer.target.Block; block != nil {
+		fmt.Fprintf(&builder, "Swap in with the cluster stopped: dd if=%s of=$PGDATA/%s bs=%d seek=%d conv=notrunc\n",
+			outputPaths[0], repairer.target.relationPath(*block/uint32(BlocksInRelFile)), DatabasePageSize,
+			*block%uint32(BlocksInRelFile))
+	}
+	_, err := io.WriteString(output, builder.String())
+	return err
+}
@@ -0,0 +1,275 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	pageRepairTestImageHead = 40
+	pageRepairTestImageTail = 60
+)
+
+var pageRepairTestRelation = walparser.RelFileNode{SpcNode: DefaultSpcNode, DBNode: 5, RelNode: 16384}
+
+// pageRepairTestRecord is the heap record referencing one block with a full-page image or with data only
+type pageRepairTestRecord struct {
+	relation walparser.RelFileNode
+	blockNo  uint32
+	image    bool
+	willInit bool
+	fill     byte
+}
+
+// pageRepairTestImage makes the image of the page filled with the byte, the hole is in the middle of the page
+func pageRepairTestImage(fill byte) []byte {
+	image := bytes.Repeat([]byte{fill}, pageRepairTestImageHead+pageRepairTestImageTail)
+	binary.LittleEndian.PutUint16(image[pdUpperOffset:], uint16(walparser.BlockSize)-pageRepairTestImageTail)
+	return image
+}
+
+func (record pageRepairTestRecord) bytes(prevLSN uint64) []byte {
+	blockHeader := new(bytes.Buffer)
+	blockData := []byte{record.fill, record.fill, record.fill, record.fill}
+	forkFlags, dataLength := walparser.BkpBlockHasData, uint16(len(blockData))
+	if record.image {
+		blockData = pageRepairTestImage(record.fill)
+		forkFlags, dataLength = walparser.BkpBlockHasImage, 0
+	}
+	if record.willInit {
+		forkFlags |= walparser.BkpBlockWillInit
+	}
+	_ = binary.Write(blockHeader, binary.LittleEndian, struct {
+		BlockID    uint8
+		ForkFlags  uint8
+		DataLength uint16
+	}{ForkFlags: forkFlags, DataLength: dataLength})
+	if record.image {
+		_ = binary.Write(blockHeader, binary.LittleEndian, struct {
+			ImageLength uint16
+			HoleOffset  uint16
+			Info        uint8
+		}{uint16(len(blockData)), pageRepairTestImageHead, walparser.BkpImageHasHole | walparser.BkpImageApply})
+	}
+	_ = binary.Write(blockHeader, binary.LittleEndian, record.relation)
+	_ = binary.Write(blockHeader, binary.LittleEndian, record.blockNo)
+
+	result := new(bytes.Buffer)
+	_ = binary.Write(result, binary.LittleEndian, struct {
+		TotalRecordLength uint32
+		XactID            uint32
+		PrevRecordPtr     uint64
+		Info              uint8
+		ResourceManagerID uint8
+		Padding           uint16
+		Crc32Hash         uint32
+	}{
+		TotalRecordLength: uint32(walparser.XLogRecordHeaderSize + blockHeader.Len() + len(blockData)),
+		XactID:            100,
+		PrevRecordPtr:     prevLSN,
+		ResourceManagerID: walparser.RmHeapID,
+	})
+	result.Write(blockHeader.Bytes())
+	result.Write(blockData)
+	return result.Bytes()
+}
+
+// makePageRepairSegment builds a WAL segment made of one page with the records and returns their LSNs
+func makePageRepairSegment(pageAddress LSN, records []pageRepairTestRecord) ([]byte, []LSN) {
+	page := new(bytes.Buffer)
+	_ = binary.Write(page, binary.LittleEndian, struct {
+		Magic            uint16
+		Info             uint16
+		TimeLineID       uint32
+		PageAddress      uint64
+		RemainingDataLen uint32
+		Padding          uint32
+	}{Magic: 0xD10D, TimeLineID: 1, PageAddress: uint64(pageAddress)})
+	lsns := make([]LSN, 0, len(records))
+	prevLSN := uint64(0)
+	for _, record := range records {
+		lsn := uint64(pageAddress) + uint64(page.Len())
+		page.Write(record.bytes(prevLSN))
+		page.Write(make([]byte, (walparser.XLogRecordAlignment-page.Len()%walparser.XLogRecordAlignment)%
+			walparser.XLogRecordAlignment))
+		lsns = append(lsns, LSN(lsn))
+		prevLSN = lsn
+	}
+	segment := make([]byte, walparser.WalPageSize)
+	copy(segment, page.Bytes())
+	return segment, lsns
+}
+
+func newPageRepairTestFolder(t *testing.T) (*memory.Folder, []LSN) {
+	otherRelation := pageRepairTestRelation
+	otherRelation.RelNode++
+	segment, lsns := makePageRepairSegment(WalSegmentNo(2).firstLsn(), []pageRepairTestRecord{
+		{relation: pageRepairTestRelation, blockNo: 3, image: true, fill: 1},
+		{relation: pageRepairTestRelation, blockNo: 4, fill: 2},
+		{relation: otherRelation, blockNo: 3, image: true, fill: 3},
+		{relation: pageRepairTestRelation, blockNo: 4, image: true, fill: 4},
+		{relation: pageRepairTestRelation, blockNo: 3, fill: 5},
+	})
+	folder := memory.NewFolder("", memory.NewKVS())
+	require.NoError(t, folder.PutObject(utility.WalPath+WalSegmentNo(2).GetFilename(1), bytes.NewReader(segment)))
+	return folder, lsns
+}
+
+func replayPageRepairTestWal(t *testing.T, folder *memory.Folder, target PageRepairTarget) *pageRepairer {
+	repairer := newPageRepairer(target)
+	require.NoError(t, repairer.replayWal(internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)),
+		1, WalSegmentNo(2).firstLsn()))
+	return repairer
+}
+
+func TestParsePageRepairRelation(t *testing.T) {
+	dbNode, relNode, err := ParsePageRepairRelation("5/16384")
+	require.NoError(t, err)
+	assert.Equal(t, walparser.Oid(5), dbNode)
+	assert.Equal(t, walparser.Oid(16384), relNode)
+
+	_, _, err = ParsePageRepairRelation("1663/5/16384")
+	assert.Error(t, err)
+	_, _, err = ParsePageRepairRelation("5/rel")
+	assert.Error(t, err)
+}
+
+func TestPageRepairer_ReplayWal(t *testing.T) {
+	folder, lsns := newPageRepairTestFolder(t)
+	repairer := replayPageRepairTestWal(t, folder, PageRepairTarget{DBNode: 5, RelNode: 16384})
+
+	require.Len(t, repairer.pages, 2)
+	page := repairer.pages[4]
+	assert.Equal(t, bytes.Repeat([]byte{4}, 8), page[16:24])
+	assert.Equal(t, make([]byte, int(walparser.BlockSize)-pageRepairTestImageHead-pageRepairTestImageTail),
+		page[pageRepairTestImageHead:int(walparser.BlockSize)-pageRepairTestImageTail])
+	pageLSN := LSN(binary.LittleEndian.Uint32(page[0:]))<<32 | LSN(binary.LittleEndian.Uint32(page[4:]))
+	assert.Greater(t, pageLSN, lsns[3], "the page LSN is the end of the record")
+	assert.Less(t, pageLSN, lsns[4])
+	checksum := binary.LittleEndian.Uint16(page[PdChecksumOffset:])
+	assert.Equal(t, pgChecksumPage(4, page), checksum)
+
+	assert.Equal(t, []PageRepairStaleBlock{{Block: 3, LSN: lsns[4], SafeLSN: &lsns[3]}}, repairer.staleBlocks())
+}
+
+func TestPageRepairer_ReplayWal_TargetLSN(t *testing.T) {
+	folder, lsns := newPageRepairTestFolder(t)
+	block := uint32(4)
+	repairer := replayPageRepairTestWal(t, folder,
+		PageRepairTarget{DBNode: 5, RelNode: 16384, Block: &block, TargetLSN: &lsns[2]})
+
+	assert.Empty(t, repairer.pages)
+	assert.Equal(t, []PageRepairStaleBlock{{Block: 4, LSN: lsns[1], SafeLSN: &lsns[0]}}, repairer.staleBlocks())
+}
+
+func TestPageRepairer_ReplayWal_SafeLSN(t *testing.T) {
+	segment, lsns := makePageRepairSegment(WalSegmentNo(2).firstLsn(), []pageRepairTestRecord{
+		{relation: pageRepairTestRelation, blockNo: 1, fill: 1},
+		{relation: pageRepairTestRelation, blockNo: 2, image: true, fill: 2},
+		{relation: pageRepairTestRelation, blockNo: 2, fill: 3},
+		{relation: pageRepairTestRelation, blockNo: 2, fill: 4},
+		{relation: pageRepairTestRelation, blockNo: 3, image: true, fill: 5},
+		{relation: pageRepairTestRelation, blockNo: 3, fill: 6},
+		{relation: pageRepairTestRelation, blockNo: 3, willInit: true, fill: 7},
+	})
+	folder := memory.NewFolder("", memory.NewKVS())
+	require.NoError(t, folder.PutObject(utility.WalPath+WalSegmentNo(2).GetFilename(1), bytes.NewReader(segment)))
+	repairer := replayPageRepairTestWal(t, folder, PageRepairTarget{DBNode: 5, RelNode: 16384})
+
+	// the first change of block 1 is the first replayed record, the safe LSN of block 2 is before its first change,
+	// and the change of block 3 is superseded by the record initializing the page
+	assert.Equal(t, []PageRepairStaleBlock{
+		{Block: 1, LSN: lsns[0]},
+		{Block: 2, LSN: lsns[3], SafeLSN: &lsns[1]},
+	}, repairer.staleBlocks())
+	assert.ErrorContains(t, repairer.checkStaleBlocks(), fmt.Sprintf("block 1 at %s (no safe target LSN), "+
+		"block 2 at %s (safe up to --target-lsn %s)", lsns[0], lsns[3], lsns[1]))
+
+	// the targets before the backup is consistent aren't safe
+	repairer.consistentLSN = lsns[2]
+	assert.Equal(t, []PageRepairStaleBlock{{Block: 1, LSN: lsns[0]}, {Block: 2, LSN: lsns[3]}},
+		repairer.staleBlocks())
+}
+
+func TestPageRepairer_CheckStaleBlocks(t *testing.T) {
+	folder, lsns := newPageRepairTestFolder(t)
+	repairer := replayPageRepairTestWal(t, folder, PageRepairTarget{DBNode: 5, RelNode: 16384})
+	assert.ErrorContains(t, repairer.checkStaleBlocks(), fmt.Sprintf("block 3 at %s", lsns[4]))
+
+	block := uint32(4)
+	repairer = replayPageRepairTestWal(t, folder, PageRepairTarget{DBNode: 5, RelNode: 16384, Block: &block})
+	assert.NoError(t, repairer.checkStaleBlocks())
+}
+
+func TestPageRepairer_ReplayWal_MissingSegmentBeforeTarget(t *testing.T) {
+	folder, _ := newPageRepairTestFolder(t)
+	targetLSN := WalSegmentNo(3).firstLsn()
+	repairer := newPageRepairer(PageRepairTarget{DBNode: 5, RelNode: 16384, TargetLSN: &targetLSN})
+	err := repairer.replayWal(internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)),
+		1, WalSegmentNo(2).firstLsn())
+	assert.Error(t, err)
+}
+
+func TestPageRepairer_WriteRelation(t *testing.T) {
+	dataDirectory, outputDirectory := t.TempDir(), t.TempDir()
+	target := PageRepairTarget{DBNode: 5, RelNode: 16384}
+	relationPath := filepath.Join(dataDirectory, target.relationPath(0))
+	require.NoError(t, os.MkdirAll(filepath.Dir(relationPath), 0700))
+	require.NoError(t, os.WriteFile(relationPath, bytes.Repeat([]byte{7}, 2*int(DatabasePageSize)), 0600))
+
+	repairer := newPageRepairer(target)
+	page := new(PgDatabasePage)
+	page[0] = 9
+	repairer.pages[0] = page
+	repairer.pages[3] = page
+	repairer.pages[uint32(BlocksInRelFile)+1] = page
+
+	outputPaths, err := repairer.writeRelation(dataDirectory, outputDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(outputDirectory, "16384"), filepath.Join(outputDirectory, "16384.1")},
+		outputPaths)
+
+	relation, err := os.ReadFile(outputPaths[0])
+	require.NoError(t, err)
+	require.Equal(t, 4*int(DatabasePageSize), len(relation))
+	assert.Equal(t, page[:], relation[:DatabasePageSize])
+	assert.Equal(t, bytes.Repeat([]byte{7}, int(DatabasePageSize)), relation[DatabasePageSize:2*DatabasePageSize])
+	assert.Equal(t, page[:], relation[3*DatabasePageSize:])
+
+	relationSegment, err := os.ReadFile(outputPaths[1])
+	require.NoError(t, err)
+	assert.Equal(t, 2*int(DatabasePageSize), len(relationSegment))
+}
+
+func TestPageRepairer_WriteBlock(t *testing.T) {
+	dataDirectory, outputDirectory := t.TempDir(), t.TempDir()
+	block := uint32(1)
+	target := PageRepairTarget{DBNode: 5, RelNode: 16384, Block: &block}
+	relationPath := filepath.Join(dataDirectory, target.relationPath(0))
+	require.NoError(t, os.MkdirAll(filepath.Dir(relationPath), 0700))
+	require.NoError(t, os.WriteFile(relationPath, bytes.Repeat([]byte{7}, 2*int(DatabasePageSize)), 0600))
+
+	// the block without full-page images is taken from the backup
+	repairer := newPageRepairer(target)
+	outputPath, err := repairer.writeBlock(dataDirectory, outputDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(outputDirectory, "16384_1.page"), outputPath)
+	page, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{7}, int(DatabasePageSize)), page)
+
+	block = 2
+	_, err = repairer.writeBlock(dataDirectory, outputDirectory)
+	assert.Error(t, err)
+}
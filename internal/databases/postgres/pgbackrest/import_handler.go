@@ -0,0 +1,433 @@
+package pgbackrest
+
+import (
+	"compress/bzip2"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/gzip"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/zstd"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	importStagingDirPrefix = "pgbackrest_import_"
+	bzip2FileExtension     = "bz2"
+)
+
+// repoCompressionExtensions are the extensions of the files compressed by pgBackRest
+var repoCompressionExtensions = map[string]bool{
+	gzip.FileExtension: true,
+	lz4.FileExtension:  true,
+	zstd.FileExtension: true,
+	bzip2FileExtension: true,
+}
+
+var walSegmentNameRegexp = regexp.MustCompile("^[0-9A-F]{24}")
+
+// ImportResult lists what the import has written to the wal-g storage
+type ImportResult struct {
+	ImportedBackups map[string]string
+	SkippedBackups  []string
+	ImportedWals    int
+	SkippedWals     int
+}
+
+// HandleImport converts the backups of the pgBackRest repository in the folder to the native wal-g backups and
+// copies the WAL archive, the uploader points to the root of the wal-g storage. Every backup is restored into
+// the staging directory with its references and uploaded as a full backup named after its start WAL segment.
+// The backups and WAL already present in the wal-g storage are skipped, so the import can be repeated.
+func HandleImport(folder storage.Folder, stanza string, uploader internal.Uploader, backupName string,
+	stagingDirectory string, importWal bool) (ImportResult, error) {
+	result := ImportResult{ImportedBackups: make(map[string]string)}
+	err := CheckRepositoryNotEncrypted(folder, stanza)
+	if err != nil {
+		return result, err
+	}
+	backupNames, err := getImportBackupNames(folder, stanza, backupName)
+	if err != nil {
+		return result, err
+	}
+	manifests, err := loadImportManifests(folder, stanza, backupNames)
+	if err != nil {
+		return result, err
+	}
+
+	backupUploader := uploader.Clone()
+	backupUploader.ChangeDirectory(utility.BaseBackupPath)
+	for _, name := range backupNames {
+		walgBackupName, imported, err := importBackup(folder, stanza, name, manifests[name], backupUploader,
+			stagingDirectory)
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to import backup %s", name)
+		}
+		if imported {
+			result.ImportedBackups[name] = walgBackupName
+		} else {
+			result.SkippedBackups = append(result.SkippedBackups, name)
+		}
+	}
+
+	if importWal {
+		walUploader := uploader.Clone()
+		walUploader.DisableSizeTracking()
+		walUploader.ChangeDirectory(utility.WalPath)
+		result.ImportedWals, result.SkippedWals, err = importWalArchive(folder, stanza, walUploader)
+		if err != nil {
+			return result, err
+		}
+	}
+	tracelog.InfoLogger.Printf("Imported %d backups and %d WAL files, skipped %d existing backups and %d WAL files",
+		len(result.ImportedBackups), result.ImportedWals, len(result.SkippedBackups), result.SkippedWals)
+	return result, nil
+}
+
+// getImportBackupNames returns the backups to import in the order of their creation
+func getImportBackupNames(folder storage.Folder, stanza string, backupName string) ([]string, error) {
+	backupTimes, err := GetBackupList(folder, stanza)
+	if err != nil {
+		return nil, err
+	}
+	internal.SortBackupTimeSlices(backupTimes)
+	if backupName == internal.LatestString && len(backupTimes) > 0 {
+		backupName = backupTimes[len(backupTimes)-1].BackupName
+	}
+	names := make([]string, 0, len(backupTimes))
+	for _, backupTime := range backupTimes {
+		if backupName == "" || backupTime.BackupName == backupName {
+			names = append(names, backupTime.BackupName)
+		}
+	}
+	if backupName != "" && len(names) == 0 {
+		return nil, fmt.Errorf("backup %s is not found in stanza %s", backupName, stanza)
+	}
+	return names, nil
+}
+
+// loadImportManifests loads the manifests of the backups to import and checks that all of them can be imported,
+// so the import fails before any backup is written
+func loadImportManifests(folder storage.Folder, stanza string,
+	backupNames []string) (map[string]*ManifestSettings, error) {
+	manifests := make(map[string]*ManifestSettings, len(backupNames))
+	for _, name := range backupNames {
+		manifest, err := LoadManifest(folder, stanza, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load manifest of backup %s", name)
+		}
+		err = checkManifestFiles(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "backup %s can't be imported", name)
+		}
+		manifests[name] = manifest
+	}
+	return manifests, nil
+}
+
+// checkManifestFiles fails on the files stored in the ways the import doesn't support
+func checkManifestFiles(manifest *ManifestSettings) error {
+	for _, file := range manifest.FileSection.files {
+		if !strings.HasPrefix(file.Name, BackupDataDirectory+"/") {
+			return fmt.Errorf("file %s is outside of %s, backups with tablespaces are not supported",
+				file.Name, BackupDataDirectory)
+		}
+		if file.BundleID != nil {
+			return fmt.Errorf("file %s is bundled, repositories with repo-bundle are not supported", file.Name)
+		}
+		if file.IsBlockIncremental() {
+			return fmt.Errorf("file %s is block incremental, repositories with repo-block are not supported",
+				file.Name)
+		}
+	}
+	return nil
+}
+
+func importBackup(folder storage.Folder, stanza, backupName string, manifest *ManifestSettings,
+	uploader internal.Uploader, stagingDirectory string) (string, bool, error) {
+	details, err := GetBackupDetails(folder, stanza, backupName)
+	if err != nil {
+		return "", false, err
+	}
+	walgBackupName := utility.BackupNamePrefix + manifest.BackupSection.BackupArchiveStart
+	exists, err := uploader.Folder().Exists(walgBackupName + utility.SentinelSuffix)
+	if err != nil {
+		return "", false, err
+	}
+	if exists {
+		tracelog.InfoLogger.Printf("Backup %s is already imported as %s, skipping", backupName, walgBackupName)
+		return walgBackupName, false, nil
+	}
+	pgVersion, err := parsePgVersion(details.PgVersion)
+	if err != nil {
+		return "", false, err
+	}
+
+	err = os.MkdirAll(stagingDirectory, 0700)
+	if err != nil {
+		return "", false, err
+	}
+	dataDirectory, err := os.MkdirTemp(stagingDirectory, importStagingDirPrefix)
+	if err != nil {
+		return "", false, err
+	}
+	defer os.RemoveAll(dataDirectory)
+
+	tracelog.InfoLogger.Printf("Restoring %s backup %s to %s", details.Type, backupName, dataDirectory)
+	err = restoreManifestFiles(folder, stanza, backupName, manifest, details, dataDirectory)
+	if err != nil {
+		return "", false, err
+	}
+
+	tracelog.InfoLogger.Printf("Uploading backup %s as %s", backupName, walgBackupName)
+	sentinel := postgres.BackupSentinelDto{
+		BackupStartLSN:   &details.StartLsn,
+		BackupFinishLSN:  &details.FinishLsn,
+		PgVersion:        pgVersion,
+		SystemIdentifier: &details.SystemIdentifier,
+	}
+	meta := postgres.ExtendedMetadataDto{
+		StartTime:        details.StartTime.UTC(),
+		FinishTime:       details.FinishTime.UTC(),
+		DatetimeFormat:   postgres.MetadataDatetimeFormat,
+		DataDir:          manifest.BackupTargetSection.PgdataPath,
+		PgVersion:        pgVersion,
+		StartLsn:         details.StartLsn,
+		FinishLsn:        details.FinishLsn,
+		SystemIdentifier: &details.SystemIdentifier,
+	}
+	err = postgres.UploadRestoredBackup(uploader, walgBackupName, dataDirectory, sentinel, meta)
+	if err != nil {
+		return "", false, err
+	}
+	return walgBackupName, true, nil
+}
+
+// restoreManifestFiles writes the files of the manifest to the data directory, taking the files of
+// the diff and incr backups from the backups they reference
+func restoreManifestFiles(folder storage.Folder, stanza, backupName string, manifest *ManifestSettings,
+	details *BackupDetails, dataDirectory string) error {
+	err := createDirectories(details, dataDirectory)
+	if err != nil {
+		return err
+	}
+
+	backupsFolder := folder.GetSubFolder(BackupFolderName).GetSubFolder(stanza)
+	repoFiles := make(map[string]map[string]string)
+	errGroup := new(errgroup.Group)
+	errGroup.SetLimit(max(viper.GetInt(conf.DownloadConcurrencySetting), 1))
+	for _, file := range manifest.FileSection.files {
+		relativePath := strings.TrimPrefix(file.Name, BackupDataDirectory+"/")
+		localPath := filepath.Join(dataDirectory, filepath.FromSlash(relativePath))
+		fileMode := int64(details.DefaultFileMode)
+		if file.Mode != "" {
+			fileMode, err = strconv.ParseInt(file.Mode, 8, 0)
+			if err != nil {
+				return err
+			}
+		}
+		if file.Size == 0 {
+			err = writeRepoFile(localPath, os.FileMode(fileMode), nil, "")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		sourceBackup := backupName
+		if file.Reference != "" {
+			sourceBackup = file.Reference
+		}
+		if _, ok := repoFiles[sourceBackup]; !ok {
+			repoFiles[sourceBackup], err = listRepoFiles(backupsFolder.GetSubFolder(sourceBackup))
+			if err != nil {
+				return err
+			}
+		}
+		objectPath, ok := repoFiles[sourceBackup][file.Name]
+		if !ok {
+			return fmt.Errorf("file %s of backup %s is not found in the repository", file.Name, sourceBackup)
+		}
+		sourceFolder := backupsFolder.GetSubFolder(sourceBackup)
+		checksum := file.Checksum
+		errGroup.Go(func() error {
+			reader, err := openRepoFile(sourceFolder, objectPath)
+			if err != nil {
+				return err
+			}
+			defer utility.LoggedClose(reader, "")
+			return writeRepoFile(localPath, os.FileMode(fileMode), reader, checksum)
+		})
+	}
+	return errGroup.Wait()
+}
+
+// listRepoFiles maps the manifest names of the files of the backup to the paths of the repository objects
+func listRepoFiles(backupFolder storage.Folder) (map[string]string, error) {
+	objects, err := storage.ListFolderRecursivelyWithPrefix(backupFolder, BackupDataDirectory)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(objects))
+	for _, object := range objects {
+		files[trimRepoCompressionExtension(object.GetName())] = object.GetName()
+	}
+	return files, nil
+}
+
+func trimRepoCompressionExtension(name string) string {
+	extension := strings.TrimPrefix(path.Ext(name), ".")
+	if repoCompressionExtensions[extension] {
+		return strings.TrimSuffix(name, "."+extension)
+	}
+	return name
+}
+
+// openRepoFile opens the repository object decompressed according to its extension
+func openRepoFile(folder storage.Folder, objectPath string) (io.ReadCloser, error) {
+	reader, err := folder.ReadObject(objectPath)
+	if err != nil {
+		return nil, err
+	}
+	extension := strings.TrimPrefix(path.Ext(objectPath), ".")
+	if !repoCompressionExtensions[extension] {
+		return reader, nil
+	}
+	if extension == bzip2FileExtension {
+		return ioReadCloser{Reader: bzip2.NewReader(reader), Closer: reader}, nil
+	}
+	decompressed, err := compression.FindDecompressor(extension).Decompress(reader)
+	if err != nil {
+		utility.LoggedClose(reader, "")
+		return nil, errors.Wrapf(err, "failed to decompress %s", objectPath)
+	}
+	return ioReadCloser{Reader: decompressed, Closer: reader}, nil
+}
+
+type ioReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// writeRepoFile writes the content of the reader to the local file,
+// the SHA-1 of the content is compared to the checksum of the manifest if it is set
+func writeRepoFile(localPath string, mode os.FileMode, reader io.Reader, checksum string) error {
+	err := os.MkdirAll(filepath.Dir(localPath), 0700)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		hash := sha1.New()
+		_, err = io.Copy(file, io.TeeReader(reader, hash))
+		if err != nil {
+			utility.LoggedClose(file, "")
+			return errors.Wrapf(err, "failed to write %s", localPath)
+		}
+		actualChecksum := hex.EncodeToString(hash.Sum(nil))
+		if checksum != "" && actualChecksum != checksum {
+			utility.LoggedClose(file, "")
+			return fmt.Errorf("checksum mismatch of %s: expected %s, got %s", localPath, checksum, actualChecksum)
+		}
+	}
+	return file.Close()
+}
+
+// importWalArchive copies the WAL segments, the history and the backup history files of the current archive
+func importWalArchive(folder storage.Folder, stanza string, uploader internal.Uploader) (int, int, error) {
+	archiveName, err := GetArchiveName(folder, stanza)
+	if err != nil {
+		return 0, 0, err
+	}
+	archiveFolder := folder.GetSubFolder(WalArchivePath).GetSubFolder(stanza).GetSubFolder(*archiveName)
+	objects, err := storage.ListFolderRecursively(archiveFolder)
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].GetName() < objects[j].GetName()
+	})
+
+	imported, skipped := 0, 0
+	for _, object := range objects {
+		walFileName, ok := getWalFileName(object.GetName())
+		if !ok {
+			continue
+		}
+		dstPath := walFileName + "." + uploader.Compression().FileExtension()
+		exists, err := uploader.Folder().Exists(dstPath)
+		if err != nil {
+			return imported, skipped, err
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		err = importWalFile(archiveFolder, object.GetName(), uploader, dstPath)
+		if err != nil {
+			return imported, skipped, errors.Wrapf(err, "failed to import WAL file %s", object.GetName())
+		}
+		imported++
+	}
+	return imported, skipped, nil
+}
+
+// getWalFileName returns the PostgreSQL name of the archived file, e.g. 000000010000000000000003
+// for 0000000100000000/000000010000000000000003-<sha1>.gz
+func getWalFileName(objectName string) (string, bool) {
+	name := trimRepoCompressionExtension(path.Base(objectName))
+	if !walSegmentNameRegexp.MatchString(name) && !strings.HasSuffix(name, ".history") {
+		return "", false
+	}
+	name, _, _ = strings.Cut(name, "-")
+	return name, true
+}
+
+func importWalFile(archiveFolder storage.Folder, objectPath string, uploader internal.Uploader, dstPath string) error {
+	reader, err := openRepoFile(archiveFolder, objectPath)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+	compressed := internal.CompressAndEncrypt(reader, uploader.Compression(), uploader.Crypter())
+	return uploader.Upload(context.Background(), dstPath, compressed)
+}
+
+// parsePgVersion converts the pgBackRest version, e.g. 9.6 or 16, to the server_version_num format
+func parsePgVersion(version string) (int, error) {
+	major, minor, _ := strings.Cut(version, ".")
+	majorNum, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected PostgreSQL version %q", version)
+	}
+	minorNum := 0
+	if minor != "" {
+		minorNum, err = strconv.Atoi(minor)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected PostgreSQL version %q", version)
+		}
+	}
+	return majorNum*10000 + minorNum*100, nil
+}
@@ -0,0 +1,234 @@
+package pgbackrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/gzip"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	importTestStanza = "main"
+
+	importTestBackupInfo = `[backup:current]
+20240301-120000F={"backrest-format":5,"backup-archive-start":"000000010000000000000002","backup-archive-stop":"000000010000000000000002","backup-timestamp-start":1709294400,"backup-timestamp-stop":1709294460,"backup-type":"full"}
+20240301-120000F_20240302-120000I={"backrest-format":5,"backup-archive-start":"000000010000000000000004","backup-archive-stop":"000000010000000000000004","backup-prior":"20240301-120000F","backup-timestamp-start":1709380800,"backup-timestamp-stop":1709380860,"backup-type":"incr"}
+`
+
+	importTestManifest = `[backup]
+backup-archive-start="%START%"
+backup-label="%LABEL%"
+backup-lsn-start="%LSN%"
+backup-lsn-stop="%LSN%"
+backup-timestamp-start=1709294400
+backup-timestamp-stop=1709294460
+backup-type="%TYPE%"
+
+[backup:db]
+db-id=1
+db-system-id=7340000000000000001
+db-version="16"
+
+[backup:target]
+pg_data={"path":"/var/lib/postgresql/16/main","type":"path"}
+
+[target:file]
+pg_data/PG_VERSION={"checksum":"3596ea087bfdaf52380eae441077572ed289d657","size":3%REFERENCE%}
+pg_data/base/1/1259={"checksum":"445bee61312cfd1c972caff16385f0e83eb3c6ef","size":9%REFERENCE%}
+pg_data/global/pg_control={"size":7,"mode":"0600"}
+pg_data/postgresql.auto.conf={"size":0}
+
+[target:file:default]
+group="postgres"
+mode="0640"
+user="postgres"
+
+[target:path]
+pg_data={}
+pg_data/base={}
+pg_data/base/1={}
+pg_data/global={}
+
+[target:path:default]
+group="postgres"
+mode="0750"
+user="postgres"
+`
+)
+
+func putImportTestFile(t *testing.T, folder storage.Folder, path string, content string, gzipped bool) {
+	if !gzipped {
+		require.NoError(t, folder.PutObject(path, strings.NewReader(content)))
+		return
+	}
+	var compressed bytes.Buffer
+	writer := gzip.Compressor{}.NewWriter(&compressed)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, folder.PutObject(path+"."+gzip.FileExtension, &compressed))
+}
+
+func putImportTestManifest(t *testing.T, folder storage.Folder, label, backupType, start, lsn, reference string) {
+	manifest := strings.NewReplacer("%LABEL%", label, "%TYPE%", backupType, "%START%", start, "%LSN%", lsn,
+		"%REFERENCE%", reference).Replace(importTestManifest)
+	putImportTestFile(t, folder, BackupPath+"/"+importTestStanza+"/"+label+"/"+BackupManifestIni, manifest, false)
+}
+
+func newImportTestRepository(t *testing.T) storage.Folder {
+	internal.ConfigureSettings(conf.PG)
+	conf.InitConfig()
+	conf.Configure()
+
+	folder := memory.NewFolder("", memory.NewKVS())
+	backupFolder := BackupPath + "/" + importTestStanza + "/"
+	putImportTestFile(t, folder, backupFolder+BackupInfoIni, importTestBackupInfo, false)
+
+	fullName := "20240301-120000F"
+	putImportTestManifest(t, folder, fullName, "full", "000000010000000000000002", "0/2000028", "")
+	putImportTestFile(t, folder, backupFolder+fullName+"/pg_data/PG_VERSION", "16\n", true)
+	putImportTestFile(t, folder, backupFolder+fullName+"/pg_data/base/1/1259", "pg_class\n", true)
+	putImportTestFile(t, folder, backupFolder+fullName+"/pg_data/global/pg_control", "control", false)
+
+	incrName := "20240301-120000F_20240302-120000I"
+	putImportTestManifest(t, folder, incrName, "incr", "000000010000000000000004", "0/4000028",
+		`,"reference":"20240301-120000F"`)
+	putImportTestFile(t, folder, backupFolder+incrName+"/pg_data/global/pg_control", "contro2", true)
+
+	archiveFolder := WalArchivePath + "/" + importTestStanza + "/"
+	putImportTestFile(t, folder, archiveFolder+ArchiveInfo, "[db]\ndb-id=1\ndb-version=\"16\"\n", false)
+	putImportTestFile(t, folder, archiveFolder+"16-1/0000000100000000/000000010000000000000002-"+
+		"0123456789abcdef0123456789abcdef01234567", "segment 2", true)
+	putImportTestFile(t, folder, archiveFolder+"16-1/0000000100000000/000000010000000000000003-"+
+		"0123456789abcdef0123456789abcdef01234567", "segment 3", false)
+	putImportTestFile(t, folder, archiveFolder+"16-1/00000002.history", "1\t0/5000000\tno recovery target\n", false)
+	return folder
+}
+
+func readImportTestWal(t *testing.T, folder storage.Folder, name string) string {
+	reader, err := folder.ReadObject(utility.WalPath + name + "." + lz4.FileExtension)
+	require.NoError(t, err)
+	decompressed, err := compression.FindDecompressor(lz4.FileExtension).Decompress(reader)
+	require.NoError(t, err)
+	content, err := io.ReadAll(decompressed)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestHandleImport(t *testing.T) {
+	folder := newImportTestRepository(t)
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+
+	result, err := HandleImport(folder, importTestStanza, uploader, "", t.TempDir(), true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"20240301-120000F":                  "base_000000010000000000000002",
+		"20240301-120000F_20240302-120000I": "base_000000010000000000000004",
+	}, result.ImportedBackups)
+	assert.Equal(t, 3, result.ImportedWals)
+
+	sentinelReader, err := folder.ReadObject(utility.BaseBackupPath + "base_000000010000000000000004" +
+		utility.SentinelSuffix)
+	require.NoError(t, err)
+	var sentinel postgres.BackupSentinelDto
+	require.NoError(t, json.NewDecoder(sentinelReader).Decode(&sentinel))
+	require.NotNil(t, sentinel.BackupStartLSN)
+	assert.Equal(t, postgres.LSN(0x4000028), *sentinel.BackupStartLSN)
+	assert.Equal(t, 160000, sentinel.PgVersion)
+	require.NotNil(t, sentinel.SystemIdentifier)
+	assert.Equal(t, uint64(7340000000000000001), *sentinel.SystemIdentifier)
+	assert.Nil(t, sentinel.IncrementFrom, "the imported backups are full")
+
+	assert.Equal(t, "segment 2", readImportTestWal(t, folder, "000000010000000000000002"))
+	assert.Equal(t, "segment 3", readImportTestWal(t, folder, "000000010000000000000003"))
+	assert.Equal(t, "1\t0/5000000\tno recovery target\n", readImportTestWal(t, folder, "00000002.history"))
+
+	result, err = HandleImport(folder, importTestStanza, uploader, "", t.TempDir(), true)
+	require.NoError(t, err)
+	assert.Empty(t, result.ImportedBackups)
+	assert.Len(t, result.SkippedBackups, 2)
+	assert.Equal(t, 3, result.SkippedWals)
+}
+
+func TestHandleImport_Backup(t *testing.T) {
+	folder := newImportTestRepository(t)
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+
+	result, err := HandleImport(folder, importTestStanza, uploader, internal.LatestString, t.TempDir(), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"20240301-120000F_20240302-120000I": "base_000000010000000000000004",
+	}, result.ImportedBackups)
+	assert.Zero(t, result.ImportedWals)
+
+	_, err = HandleImport(folder, importTestStanza, uploader, "20240101-120000F", t.TempDir(), false)
+	assert.Error(t, err)
+}
+
+func TestHandleImport_ChecksumMismatch(t *testing.T) {
+	folder := newImportTestRepository(t)
+	putImportTestFile(t, folder, BackupPath+"/"+importTestStanza+"/20240301-120000F/pg_data/base/1/1259",
+		"pg_clasz\n", true)
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+
+	_, err := HandleImport(folder, importTestStanza, uploader, "20240301-120000F", t.TempDir(), false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+	exists, err := folder.Exists(utility.BaseBackupPath + "base_000000010000000000000002" + utility.SentinelSuffix)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestHandleImport_BlockIncremental(t *testing.T) {
+	folder := newImportTestRepository(t)
+	putImportTestManifest(t, folder, "20240301-120000F_20240302-120000I", "incr", "000000010000000000000004",
+		"0/4000028", `,"reference":"20240301-120000F","bi":1,"bim":16`)
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+
+	result, err := HandleImport(folder, importTestStanza, uploader, "", t.TempDir(), false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "repo-block")
+	assert.Empty(t, result.ImportedBackups, "no backup is imported when any of them can't be")
+}
+
+func TestHandleImport_Encrypted(t *testing.T) {
+	backupInfoPath := BackupPath + "/" + importTestStanza + "/" + BackupInfoIni
+	for name, backupInfo := range map[string]string{
+		"cipher-pass": importTestBackupInfo + "\n[cipher]\ncipher-pass=\"subpass\"\n",
+		"encrypted":   "Salted__\x01\x02\x03\x04\x05\x06\x07\x08",
+	} {
+		t.Run(name, func(t *testing.T) {
+			folder := newImportTestRepository(t)
+			putImportTestFile(t, folder, backupInfoPath, backupInfo, false)
+			uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+
+			_, err := HandleImport(folder, importTestStanza, uploader, "", t.TempDir(), true)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "repo-cipher-type")
+		})
+	}
+}
+
+func TestParsePgVersion(t *testing.T) {
+	version, err := parsePgVersion("16")
+	require.NoError(t, err)
+	assert.Equal(t, 160000, version)
+	version, err = parsePgVersion("9.6")
+	require.NoError(t, err)
+	assert.Equal(t, 90600, version)
+	_, err = parsePgVersion("sixteen")
+	assert.Error(t, err)
+}
@@ -1,14 +1,21 @@
 package pgbackrest
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"gopkg.in/ini.v1"
 )
 
 const (
+	// encryptedFileMagic starts the files encrypted by pgBackRest with repo-cipher-type, the OpenSSL format
+	encryptedFileMagic = "Salted__"
+	cipherSection      = "cipher"
+	cipherPassKey      = "cipher-pass"
+
 	BackupPath        = "backup"
 	BackupInfoIni     = "backup.info"
 	BackupManifestIni = "backup.manifest"
@@ -78,12 +85,36 @@ type PathSection struct {
 	directoryPaths []string
 }
 
+type FileSection struct {
+	files []ManifestFile
+}
+
+// ManifestFile is the file of the backup, the file is stored in the referenced backup if the reference is set.
+// The checksum is the SHA-1 of the file content, the block incremental sizes are set for the files
+// stored as block maps with repo-block.
+type ManifestFile struct {
+	Name                    string
+	Reference               string `json:"reference"`
+	Size                    int64  `json:"size"`
+	Mode                    string `json:"mode"`
+	Checksum                string `json:"checksum"`
+	BundleID                *int64 `json:"bni"`
+	BlockIncrementalSize    int64  `json:"bi"`
+	BlockIncrementalMapSize int64  `json:"bim"`
+}
+
+// IsBlockIncremental tells if the file is stored as a block map rather than as its content
+func (file ManifestFile) IsBlockIncremental() bool {
+	return file.BlockIncrementalSize > 0 || file.BlockIncrementalMapSize > 0
+}
+
 type ManifestSettings struct {
 	BackrestSection       BackrestSection       `ini:"backrest"`
 	BackupSection         BackupSection         `ini:"backup"`
 	BackupTargetSection   BackupTargetSection   `ini:"backup:target"`
 	BackupDatabaseSection BackupDatabaseSection `ini:"backup:db"`
 	PathSection           PathSection
+	FileSection           FileSection
 	DefaultFileSection    DefaultFileSection `ini:"target:file:default"`
 	DefaultPathSection    DefaultPathSection `ini:"target:path:default"`
 }
@@ -172,6 +203,33 @@ func LoadBackupsSettings(folder storage.Folder, stanza string) ([]BackupSettings
 	return backupsSettings, nil
 }
 
+// CheckRepositoryNotEncrypted fails if the repository of the stanza is encrypted with repo-cipher-type:
+// the info files are encrypted then, or keep the cipher-pass of the backup files
+func CheckRepositoryNotEncrypted(folder storage.Folder, stanza string) error {
+	reader, err := folder.GetSubFolder(BackupPath).GetSubFolder(stanza).ReadObject(BackupInfoIni)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	encrypted := bytes.HasPrefix(content, []byte(encryptedFileMagic))
+	if !encrypted {
+		cfg, err := ini.Load(content)
+		if err != nil {
+			return err
+		}
+		encrypted = cfg.Section(cipherSection).HasKey(cipherPassKey)
+	}
+	if encrypted {
+		return fmt.Errorf("the repository of stanza %s is encrypted with repo-cipher-type, "+
+			"encrypted repositories are not supported", stanza)
+	}
+	return nil
+}
+
 func LoadManifest(folder storage.Folder, stanza string, backupName string) (*ManifestSettings, error) {
 	backupFolder := folder.GetSubFolder(BackupPath).GetSubFolder(stanza).GetSubFolder(backupName)
 	ioReader, err := backupFolder.ReadObject(BackupManifestIni)
@@ -187,5 +245,19 @@ func LoadManifest(folder storage.Folder, stanza string, backupName string) (*Man
 		return nil, err
 	}
 	settings.PathSection.directoryPaths = cfg.Section("target:path").KeyStrings()
+	for _, key := range cfg.Section("target:file").Keys() {
+		file := ManifestFile{Name: key.Name()}
+		if err := json.Unmarshal([]byte(key.Value()), &file); err != nil {
+			return nil, err
+		}
+		settings.FileSection.files = append(settings.FileSection.files, file)
+	}
+	if pgDataKey := cfg.Section("backup:target").Key(BackupDataDirectory); pgDataKey.String() != "" {
+		var pgData PgData
+		if err := json.Unmarshal([]byte(pgDataKey.String()), &pgData); err != nil {
+			return nil, err
+		}
+		settings.BackupTargetSection.PgdataPath = pgData.Path
+	}
 	return &settings, nil
 }
@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	postmasterPidFileName     = "postmaster.pid"
+	backupLabelStartWalPrefix = "START WAL LOCATION: "
+)
+
+// PgDataVerifyResult is the report of the page verification of the data directory
+type PgDataVerifyResult struct {
+	DataDirectory    string                  `json:"data_directory"`
+	CheckedFiles     int                     `json:"checked_files"`
+	CheckedBlocks    int64                   `json:"checked_blocks"`
+	SkippedBlocks    int64                   `json:"skipped_blocks,omitempty"`
+	CorruptRelations []PgDataCorruptRelation `json:"corrupt_relations"`
+}
+
+// PgDataCorruptRelation holds the absolute numbers of the broken blocks of the relation main file
+// and of its segments
+type PgDataCorruptRelation struct {
+	Path                string   `json:"path"`
+	CorruptBlocks       []uint32 `json:"corrupt_blocks,omitempty"`
+	InvalidHeaderBlocks []uint32 `json:"invalid_header_blocks,omitempty"`
+}
+
+// IsCorrupt tells if any broken block is found
+func (result *PgDataVerifyResult) IsCorrupt() bool {
+	return len(result.CorruptRelations) > 0
+}
+
+// pgDataVerifier checks the pages of the paged files, the relations are identified by the path of the main file.
+// The pages changed since the backup start LSN may be torn and their checksums are left to the WAL replay,
+// as pg_basebackup does. Their headers are still checked, the header is written within one sector.
+type pgDataVerifier struct {
+	result         PgDataVerifyResult
+	relations      map[string]*PgDataCorruptRelation
+	backupStartLSN *LSN
+}
+
+func newPgDataVerifier(dataDirectory string, backupStartLSN *LSN) *pgDataVerifier {
+	return &pgDataVerifier{
+		result:         PgDataVerifyResult{DataDirectory: dataDirectory, CorruptRelations: []PgDataCorruptRelation{}},
+		relations:      make(map[string]*PgDataCorruptRelation),
+		backupStartLSN: backupStartLSN,
+	}
+}
+
+// verifyDirectory walks the directory, relativePath is the path of the directory in the data directory
+func (verifier *pgDataVerifier) verifyDirectory(directory, relativePath string) error {
+	return filepath.Walk(directory, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativeFilePath, err := filepath.Rel(directory, filePath)
+		if err != nil {
+			return err
+		}
+		relativeFilePath = filepath.Join(relativePath, relativeFilePath)
+		if ignoredFileNames[info.Name()] || !isPagedFile(info, relativeFilePath) {
+			return nil
+		}
+		return verifier.verifyFile(filePath, relativeFilePath)
+	})
+}
+
+func (verifier *pgDataVerifier) verifyFile(filePath, relativeFilePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(file, "")
+	segmentNo, err := GetRelFileIDFrom(relativeFilePath)
+	if err != nil {
+		return err
+	}
+	segmentBlockOffset := uint32(segmentNo * BlocksInRelFile)
+	relationPath := path.Join(path.Dir(filepath.ToSlash(relativeFilePath)),
+		pagedFilenameRegexp.FindStringSubmatch(path.Base(relativeFilePath))[1])
+
+	verifier.result.CheckedFiles++
+	page := PgDatabasePage{}
+	for blockNo := uint32(0); ; blockNo++ {
+		_, err = io.ReadFull(file, page[:])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relativeFilePath)
+		}
+		verifier.result.CheckedBlocks++
+		pageHeader, err := parsePostgresPageHeader(bytes.NewReader(page[:]))
+		if err != nil {
+			return err
+		}
+		// the header is checked before trusting its LSN, as PageIsVerifiedExtended does: a broken header
+		// can't tell the page was changed since the backup start
+		if !pageHeader.isNew() && !pageHeader.isValid() {
+			verifier.relation(relationPath).InvalidHeaderBlocks = append(
+				verifier.relation(relationPath).InvalidHeaderBlocks, segmentBlockOffset+blockNo)
+			continue
+		}
+		if verifier.backupStartLSN != nil && !pageHeader.isNew() && pageHeader.lsn() >= *verifier.backupStartLSN {
+			verifier.result.SkippedBlocks++
+			continue
+		}
+		corrupted, err := isPageCorrupted(relativeFilePath, blockNo, &page)
+		if err != nil {
+			return err
+		}
+		if corrupted {
+			verifier.relation(relationPath).CorruptBlocks = append(
+				verifier.relation(relationPath).CorruptBlocks, segmentBlockOffset+blockNo)
+		}
+	}
+}
+
+func (verifier *pgDataVerifier) relation(relationPath string) *PgDataCorruptRelation {
+	relation, ok := verifier.relations[relationPath]
+	if !ok {
+		relation = &PgDataCorruptRelation{Path: relationPath}
+		verifier.relations[relationPath] = relation
+	}
+	return relation
+}
+
+func (verifier *pgDataVerifier) finish() PgDataVerifyResult {
+	for _, relation := range verifier.relations {
+		sort.Slice(relation.CorruptBlocks, func(i, j int) bool {
+			return relation.CorruptBlocks[i] < relation.CorruptBlocks[j]
+		})
+		sort.Slice(relation.InvalidHeaderBlocks, func(i, j int) bool {
+			return relation.InvalidHeaderBlocks[i] < relation.InvalidHeaderBlocks[j]
+		})
+		verifier.result.CorruptRelations = append(verifier.result.CorruptRelations, *relation)
+	}
+	sort.Slice(verifier.result.CorruptRelations, func(i, j int) bool {
+		return verifier.result.CorruptRelations[i].Path < verifier.result.CorruptRelations[j].Path
+	})
+	return verifier.result
+}
+
+// VerifyPgData checks the page headers and checksums of the relation files of the stopped cluster data directory,
+// including the tablespaces linked from pg_tblspc. If the data directory is restored from a backup and isn't
+// recovered yet, i.e. it has backup_label, the pages changed since the backup start are skipped.
+func VerifyPgData(dataDirectory string) (PgDataVerifyResult, error) {
+	_, err := os.Stat(filepath.Join(dataDirectory, postmasterPidFileName))
+	if err == nil {
+		return PgDataVerifyResult{}, fmt.Errorf("%s is found in %s, the cluster must be stopped",
+			postmasterPidFileName, dataDirectory)
+	}
+	if !os.IsNotExist(err) {
+		return PgDataVerifyResult{}, err
+	}
+
+	backupStartLSN, err := readBackupLabelStartLSN(dataDirectory)
+	if err != nil {
+		return PgDataVerifyResult{}, err
+	}
+	if backupStartLSN != nil {
+		tracelog.InfoLogger.Printf("%s is found, the pages changed since the backup start LSN %s are not verified",
+			BackupLabelFilename, *backupStartLSN)
+	}
+	verifier := newPgDataVerifier(dataDirectory, backupStartLSN)
+	err = verifier.verifyDirectory(dataDirectory, "")
+	if err != nil {
+		return PgDataVerifyResult{}, err
+	}
+	tablespaces, err := os.ReadDir(filepath.Join(dataDirectory, NonDefaultTablespace))
+	if err != nil && !os.IsNotExist(err) {
+		return PgDataVerifyResult{}, err
+	}
+	for _, tablespace := range tablespaces {
+		if tablespace.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		linkPath := filepath.Join(NonDefaultTablespace, tablespace.Name())
+		location, err := filepath.EvalSymlinks(filepath.Join(dataDirectory, linkPath))
+		if err != nil {
+			return PgDataVerifyResult{}, errors.Wrapf(err, "failed to resolve tablespace link %s", linkPath)
+		}
+		err = verifier.verifyDirectory(location, linkPath)
+		if err != nil {
+			return PgDataVerifyResult{}, err
+		}
+	}
+	return verifier.finish(), nil
+}
+
+// readBackupLabelStartLSN reads the backup start LSN from backup_label, nil if there is no such file
+func readBackupLabelStartLSN(dataDirectory string) (*LSN, error) {
+	label, err := os.ReadFile(filepath.Join(dataDirectory, BackupLabelFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(label), "\n") {
+		location, found := strings.CutPrefix(line, backupLabelStartWalPrefix)
+		if !found {
+			continue
+		}
+		lsnText, _, _ := strings.Cut(location, " ")
+		lsn, err := ParseLSN(lsnText)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the start LSN in %s", BackupLabelFilename)
+		}
+		return &lsn, nil
+	}
+	return nil, fmt.Errorf("no start LSN is found in %s", BackupLabelFilename)
+}
+
+// HandlePgDataVerify verifies the data directory, prints the report and fails if broken blocks are found
+func HandlePgDataVerify(dataDirectory string, jsonOutput bool, output io.Writer) error {
+	tracelog.InfoLogger.Printf("Verifying pages of %s", dataDirectory)
+	result, err := VerifyPgData(dataDirectory)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		err = json.NewEncoder(output).Encode(result)
+	} else {
+		err = printPgDataVerifyResult(result, output)
+	}
+	if err != nil {
+		return err
+	}
+	if result.IsCorrupt() {
+		return fmt.Errorf("broken blocks are found in %d relations of %s",
+			len(result.CorruptRelations), dataDirectory)
+	}
+	return nil
+}
+
+func printPgDataVerifyResult(result PgDataVerifyResult, output io.Writer) error {
+	_, err := fmt.Fprintf(output, "Checked %d blocks in %d files of %s\n",
+		result.CheckedBlocks, result.CheckedFiles, result.DataDirectory)
+	if err != nil {
+		return err
+	}
+	if result.SkippedBlocks > 0 {
+		_, err = fmt.Fprintf(output, "Skipped %d blocks changed since the backup start, WAL replay restores them\n",
+			result.SkippedBlocks)
+		if err != nil {
+			return err
+		}
+	}
+	for _, relation := range result.CorruptRelations {
+		_, err = fmt.Fprintf(output, "%s: checksum mismatch in blocks %v, invalid header in blocks %v\n",
+			relation.Path, relation.CorruptBlocks, relation.InvalidHeaderBlocks)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
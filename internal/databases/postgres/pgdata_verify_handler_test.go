@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeVerifyTestPage makes the valid page with the checksum of the absolute block number
+func makeVerifyTestPage(blockNo uint32) []byte {
+	page := new(PgDatabasePage)
+	binary.LittleEndian.PutUint32(page[4:], 0x1000028)
+	binary.LittleEndian.PutUint16(page[12:], headerSize)
+	binary.LittleEndian.PutUint16(page[pdUpperOffset:], uint16(DatabasePageSize)-64)
+	binary.LittleEndian.PutUint16(page[16:], uint16(DatabasePageSize))
+	binary.LittleEndian.PutUint16(page[18:], uint16(DatabasePageSize+layoutVersion))
+	for i := int(DatabasePageSize) - 64; i < int(DatabasePageSize); i++ {
+		page[i] = byte(blockNo) + byte(i)
+	}
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], pgChecksumPage(blockNo, page))
+	return page[:]
+}
+
+func writeVerifyTestFile(t *testing.T, filePath string, pages ...[]byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0700))
+	require.NoError(t, os.WriteFile(filePath, bytes.Join(pages, nil), 0600))
+}
+
+func newVerifyTestDataDirectory(t *testing.T) string {
+	dataDirectory := t.TempDir()
+	corruptPage := makeVerifyTestPage(1)
+	corruptPage[DatabasePageSize-1]++
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, "base", "5", "16384"),
+		makeVerifyTestPage(0), corruptPage, make([]byte, DatabasePageSize))
+
+	invalidHeaderPage := makeVerifyTestPage(uint32(BlocksInRelFile) + 1)
+	binary.LittleEndian.PutUint16(invalidHeaderPage[12:], uint16(DatabasePageSize))
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, "base", "5", "16384.1"),
+		makeVerifyTestPage(uint32(BlocksInRelFile)), invalidHeaderPage)
+
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, "base", "5", "16385"), makeVerifyTestPage(0))
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, "global", "pg_control"), []byte("not paged"))
+	return dataDirectory
+}
+
+func TestVerifyPgData(t *testing.T) {
+	dataDirectory := newVerifyTestDataDirectory(t)
+
+	result, err := VerifyPgData(dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.CheckedFiles)
+	assert.Equal(t, int64(6), result.CheckedBlocks)
+	assert.Equal(t, []PgDataCorruptRelation{
+		{Path: "base/5/16384", CorruptBlocks: []uint32{1}, InvalidHeaderBlocks: []uint32{uint32(BlocksInRelFile) + 1}},
+	}, result.CorruptRelations)
+}
+
+func TestVerifyPgData_Tablespace(t *testing.T) {
+	dataDirectory, tablespaceDirectory := t.TempDir(), t.TempDir()
+	corruptPage := makeVerifyTestPage(0)
+	corruptPage[DatabasePageSize-1]++
+	writeVerifyTestFile(t, filepath.Join(tablespaceDirectory, "PG_16_202307071", "5", "16390"), corruptPage)
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, NonDefaultTablespace), 0700))
+	require.NoError(t, os.Symlink(tablespaceDirectory, filepath.Join(dataDirectory, NonDefaultTablespace, "16389")))
+
+	result, err := VerifyPgData(dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, []PgDataCorruptRelation{
+		{Path: "pg_tblspc/16389/PG_16_202307071/5/16390", CorruptBlocks: []uint32{0}},
+	}, result.CorruptRelations)
+}
+
+func TestVerifyPgData_BackupLabel(t *testing.T) {
+	dataDirectory := newVerifyTestDataDirectory(t)
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, BackupLabelFilename),
+		[]byte("START WAL LOCATION: 0/2000028 (file 000000010000000000000002)\nCHECKPOINT LOCATION: 0/2000060\n"))
+	result, err := VerifyPgData(dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.SkippedBlocks)
+	assert.True(t, result.IsCorrupt())
+
+	// the pages written during the online backup may be torn, the WAL replay fixes them,
+	// but the page with the broken header is reported whatever LSN it has
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, BackupLabelFilename),
+		[]byte("START WAL LOCATION: 0/1000028 (file 000000010000000000000001)\n"))
+	result, err = VerifyPgData(dataDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.CheckedBlocks)
+	assert.Equal(t, int64(4), result.SkippedBlocks)
+	assert.Equal(t, []PgDataCorruptRelation{
+		{Path: "base/5/16384", InvalidHeaderBlocks: []uint32{uint32(BlocksInRelFile) + 1}},
+	}, result.CorruptRelations)
+
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, BackupLabelFilename), []byte("LABEL: test\n"))
+	_, err = VerifyPgData(dataDirectory)
+	assert.Error(t, err)
+}
+
+func TestVerifyPgData_RunningCluster(t *testing.T) {
+	dataDirectory := t.TempDir()
+	writeVerifyTestFile(t, filepath.Join(dataDirectory, postmasterPidFileName), []byte("42"))
+
+	_, err := VerifyPgData(dataDirectory)
+	assert.Error(t, err)
+}
+
+func TestHandlePgDataVerify(t *testing.T) {
+	dataDirectory := newVerifyTestDataDirectory(t)
+	var output bytes.Buffer
+	err := HandlePgDataVerify(dataDirectory, true, &output)
+	assert.Error(t, err)
+	var result PgDataVerifyResult
+	require.NoError(t, json.Unmarshal(output.Bytes(), &result))
+	assert.Len(t, result.CorruptRelations, 1)
+
+	require.NoError(t, os.Remove(filepath.Join(dataDirectory, "base", "5", "16384")))
+	require.NoError(t, os.Remove(filepath.Join(dataDirectory, "base", "5", "16384.1")))
+	output.Reset()
+	assert.NoError(t, HandlePgDataVerify(dataDirectory, false, &output))
+	assert.Contains(t, output.String(), "Checked 1 blocks in 1 files")
+}
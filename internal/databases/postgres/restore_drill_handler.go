@@ -0,0 +1,565 @@
+package postgres
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	restoreDrillScratchDirPrefix  = "restore_drill_"
+	restoreDrillReportPrefix      = "restore_drill_"
+	restoreDrillSignatureHmac     = "hmac-sha256"
+	restoreDrillPollInterval      = time.Second
+	restoreDrillLogTailSize       = 4096
+	restoreDrillPgVersionSignal   = 120000
+	restoreDrillPgVersionWalNames = 100000
+	recoverySignalFileName        = "recovery.signal"
+
+	restoreDrillAmcheckQuery = `SELECT count(bt_index_check(c.oid))
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indexrelid
+JOIN pg_am am ON am.oid = c.relam
+WHERE am.amname = 'btree' AND c.relpersistence <> 't' AND i.indisready AND i.indisvalid`
+)
+
+// RestoreDrillConfig describes how the backup is restored and checked
+type RestoreDrillConfig struct {
+	// ScratchDirectory holds the data directory of the drill, the system temporary directory by default
+	ScratchDirectory string
+	// PgBinDirectory holds pg_ctl, it's searched in PATH by default
+	PgBinDirectory string
+	// RestoreCommand fetches the WAL segment %f to the path %p
+	RestoreCommand string
+	// RecoveryTargetTime and RecoveryTargetLSN set the recovery target,
+	// the recovery stops as soon as the backup becomes consistent if they are empty
+	RecoveryTargetTime string
+	RecoveryTargetLSN  string
+	// PgOptions are the extra server settings in the name=value format
+	PgOptions []string
+	Timeout   time.Duration
+	// Database is the database to run the checks in
+	Database string
+	Checks   []string
+	Amcheck  bool
+	// SigningKey signs the report, the report isn't signed if it's empty
+	SigningKey      string
+	KeepScratchData bool
+}
+
+// RestoreDrillCheckResult is the result of the SQL check, the first column of the first row
+type RestoreDrillCheckResult struct {
+	Database string  `json:"database"`
+	Query    string  `json:"query"`
+	Result   string  `json:"result,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+// RestoreDrillReport is the report of the drill stored next to the backup
+type RestoreDrillReport struct {
+	BackupName     string                    `json:"backup_name"`
+	StartTime      time.Time                 `json:"start_time"`
+	FinishTime     time.Time                 `json:"finish_time"`
+	RecoveryTarget string                    `json:"recovery_target"`
+	ReplayedLSN    string                    `json:"replayed_lsn,omitempty"`
+	Checks         []RestoreDrillCheckResult `json:"checks"`
+	Success        bool                      `json:"success"`
+	Error          string                    `json:"error,omitempty"`
+}
+
+// RestoreDrillReportObject is the stored report with the signature of its exact bytes
+type RestoreDrillReportObject struct {
+	Report             json.RawMessage `json:"report"`
+	SignatureAlgorithm string          `json:"signature_algorithm,omitempty"`
+	Signature          string          `json:"signature,omitempty"`
+}
+
+func signRestoreDrillReport(report []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(report)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newRestoreDrillReportObject(report RestoreDrillReport, signingKey string) ([]byte, error) {
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	object := RestoreDrillReportObject{Report: reportBytes}
+	if signingKey != "" {
+		object.SignatureAlgorithm = restoreDrillSignatureHmac
+		object.Signature = signRestoreDrillReport(reportBytes, signingKey)
+	}
+	return json.Marshal(object)
+}
+
+// VerifyRestoreDrillReport checks the signature of the stored report and returns the report
+func VerifyRestoreDrillReport(objectBytes []byte, signingKey string) (RestoreDrillReport, error) {
+	var object RestoreDrillReportObject
+	err := json.Unmarshal(objectBytes, &object)
+	if err != nil {
+		return RestoreDrillReport{}, err
+	}
+	if object.SignatureAlgorithm != restoreDrillSignatureHmac {
+		return RestoreDrillReport{}, fmt.Errorf("the report is not signed with %s", restoreDrillSignatureHmac)
+	}
+	signature, err := hex.DecodeString(object.Signature)
+	if err != nil {
+		return RestoreDrillReport{}, errors.Wrap(err, "invalid report signature")
+	}
+	expected, _ := hex.DecodeString(signRestoreDrillReport(object.Report, signingKey))
+	if !hmac.Equal(signature, expected) {
+		return RestoreDrillReport{}, errors.New("report signature mismatch")
+	}
+	var report RestoreDrillReport
+	err = json.Unmarshal(object.Report, &report)
+	return report, err
+}
+
+// restoreDrillReportPath is the path of the report in the backup folder
+func restoreDrillReportPath(backupName string, startTime time.Time) string {
+	return storage.JoinPath(backupName, restoreDrillReportPrefix+startTime.Format("20060102T150405Z")+".json")
+}
+
+func quotePgSetting(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// recoveryTarget returns the recovery target settings and their description
+func (config *RestoreDrillConfig) recoveryTarget() ([][2]string, string) {
+	switch {
+	case config.RecoveryTargetLSN != "":
+		return [][2]string{{"recovery_target_lsn", config.RecoveryTargetLSN}}, "lsn " + config.RecoveryTargetLSN
+	case config.RecoveryTargetTime != "":
+		return [][2]string{{"recovery_target_time", config.RecoveryTargetTime}}, "time " + config.RecoveryTargetTime
+	default:
+		return [][2]string{{"recovery_target", "immediate"}}, "immediate"
+	}
+}
+
+// writeRestoreDrillRecoveryConfig makes the restored data directory recover from the archive and promote
+// at the target. PostgreSQL 12 and later read the settings from postgresql.auto.conf and recovery.signal,
+// the older versions from recovery.conf.
+func writeRestoreDrillRecoveryConfig(dataDirectory string, pgVersion int, config *RestoreDrillConfig) error {
+	settings, _ := config.recoveryTarget()
+	settings = append([][2]string{{"restore_command", config.RestoreCommand}}, settings...)
+	settings = append(settings, [2]string{"recovery_target_action", "promote"})
+
+	var builder strings.Builder
+	builder.WriteString("# added by wal-g restore-drill\n")
+	for _, setting := range settings {
+		fmt.Fprintf(&builder, "%s = %s\n", setting[0], quotePgSetting(setting[1]))
+	}
+
+	configPath := filepath.Join(dataDirectory, "recovery.conf")
+	if pgVersion >= restoreDrillPgVersionSignal || pgVersion == 0 {
+		configPath = filepath.Join(dataDirectory, "postgresql.auto.conf")
+		err := os.WriteFile(filepath.Join(dataDirectory, recoverySignalFileName), nil, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(configPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(builder.String())
+	if err != nil {
+		utility.LoggedClose(file, "")
+		return err
+	}
+	return file.Close()
+}
+
+// writeRestoreDrillServerConfig lets only the local connections through the private socket of the drill
+// and makes sure the main configuration file exists, it may be kept outside the data directory
+func writeRestoreDrillServerConfig(dataDirectory string) error {
+	err := os.WriteFile(filepath.Join(dataDirectory, "pg_hba.conf"), []byte("local all all trust\n"), 0600)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dataDirectory, "postgresql.conf"), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// restoreDrillServerOptions are the options of the drill server, the archiving is off
+// so that the drill doesn't write to the storage
+func restoreDrillServerOptions(dataDirectory, socketDirectory string, port int, pgOptions []string) string {
+	options := []string{
+		fmt.Sprintf("-p %d", port),
+		"-c listen_addresses=''",
+		"-c unix_socket_directories=" + quotePgSetting(socketDirectory),
+		"-c hba_file=" + quotePgSetting(filepath.Join(dataDirectory, "pg_hba.conf")),
+		"-c archive_mode=off",
+		"-c ssl=off",
+	}
+	for _, option := range pgOptions {
+		name, value, _ := strings.Cut(option, "=")
+		options = append(options, fmt.Sprintf("-c %s=%s", name, quotePgSetting(value)))
+	}
+	return strings.Join(options, " ")
+}
+
+func findFreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer utility.LoggedClose(listener, "")
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// restoreDrill is the drill of one backup in the scratch directory
+type restoreDrill struct {
+	config        *RestoreDrillConfig
+	scratch       string
+	dataDirectory string
+	port          int
+	serverStarted bool
+	report        RestoreDrillReport
+}
+
+func (drill *restoreDrill) pgCtl(args ...string) *exec.Cmd {
+	pgCtl := "pg_ctl"
+	if drill.config.PgBinDirectory != "" {
+		pgCtl = filepath.Join(drill.config.PgBinDirectory, pgCtl)
+	}
+	cmd := exec.Command(pgCtl, append([]string{"-D", drill.dataDirectory}, args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func (drill *restoreDrill) logPath() string {
+	return filepath.Join(drill.scratch, "postgresql.log")
+}
+
+func (drill *restoreDrill) logServerLogTail() {
+	data, err := os.ReadFile(drill.logPath())
+	if err != nil {
+		return
+	}
+	if len(data) > restoreDrillLogTailSize {
+		data = data[len(data)-restoreDrillLogTailSize:]
+	}
+	tracelog.ErrorLogger.Printf("Server log tail:\n%s", data)
+}
+
+func (drill *restoreDrill) restore(rootFolder storage.Folder, backup Backup, sentinel BackupSentinelDto) error {
+	var spec *TablespaceSpec
+	if sentinel.TablespaceSpec != nil && !sentinel.TablespaceSpec.empty() {
+		// the tablespaces are restored into the scratch directory instead of their original locations
+		relocated := NewTablespaceSpec(drill.dataDirectory)
+		for _, name := range sentinel.TablespaceSpec.TablespaceNames() {
+			relocated.addTablespace(name, filepath.Join(drill.scratch, "tablespaces", name))
+		}
+		spec = &relocated
+	}
+	filesToUnwrap, err := backup.GetFilesToUnwrap("")
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Restoring backup %s to %s", backup.Name, drill.dataDirectory)
+	err = deltaFetchRecursionOld(backup, rootFolder, drill.dataDirectory, spec, filesToUnwrap, ExtractProviderImpl{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore backup %s", backup.Name)
+	}
+	err = writeRestoreDrillServerConfig(drill.dataDirectory)
+	if err != nil {
+		return err
+	}
+	return writeRestoreDrillRecoveryConfig(drill.dataDirectory, sentinel.PgVersion, drill.config)
+}
+
+func (drill *restoreDrill) start() error {
+	var err error
+	drill.port, err = findFreePort()
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Starting the server on port %d, log is written to %s", drill.port, drill.logPath())
+	cmd := drill.pgCtl("-l", drill.logPath(), "-w", "-t", fmt.Sprintf("%d", int(drill.config.Timeout.Seconds())),
+		"-o", restoreDrillServerOptions(drill.dataDirectory, drill.scratch, drill.port, drill.config.PgOptions),
+		"start")
+	err = cmd.Run()
+	// the server may be running even if pg_ctl failed to wait for it
+	drill.serverStarted = true
+	if err != nil {
+		drill.logServerLogTail()
+		return errors.Wrap(err, "failed to start the server")
+	}
+	return nil
+}
+
+func (drill *restoreDrill) stop() {
+	err := drill.pgCtl("-m", "fast", "-w", "stop").Run()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to stop the server: %v", err)
+	}
+}
+
+func (drill *restoreDrill) connect(database string) (*pgx.Conn, error) {
+	config, err := pgx.ParseEnvLibpq()
+	if err != nil {
+		return nil, err
+	}
+	config.Host = drill.scratch
+	config.Port = uint16(drill.port)
+	config.Database = database
+	return pgx.Connect(config)
+}
+
+// waitForPromotion waits until the recovery reaches the target and the server is promoted
+func (drill *restoreDrill) waitForPromotion(pgVersion int) error {
+	deadline := time.Now().Add(drill.config.Timeout)
+	for {
+		inRecovery, replayedLSN, err := drill.recoveryState(pgVersion)
+		if err == nil && !inRecovery {
+			drill.report.ReplayedLSN = replayedLSN
+			return nil
+		}
+		if time.Now().After(deadline) {
+			drill.logServerLogTail()
+			if err != nil {
+				return errors.Wrap(err, "the server is not available")
+			}
+			return errors.New("the recovery has not finished in time")
+		}
+		time.Sleep(restoreDrillPollInterval)
+	}
+}
+
+func (drill *restoreDrill) recoveryState(pgVersion int) (bool, string, error) {
+	conn, err := drill.connect(drill.config.Database)
+	if err != nil {
+		return false, "", err
+	}
+	defer utility.LoggedClose(conn, "")
+	replayLSNFunction := "pg_last_wal_replay_lsn()"
+	if pgVersion != 0 && pgVersion < restoreDrillPgVersionWalNames {
+		replayLSNFunction = "pg_last_xlog_replay_location()"
+	}
+	var inRecovery bool
+	var replayedLSN *string
+	err = conn.QueryRow("SELECT pg_is_in_recovery(), "+replayLSNFunction+"::text").Scan(&inRecovery, &replayedLSN)
+	if err != nil || replayedLSN == nil {
+		return inRecovery, "", err
+	}
+	return inRecovery, *replayedLSN, nil
+}
+
+// runCheck runs the query after the setup statements and records the first value it returns
+func (drill *restoreDrill) runCheck(database, query string, setup ...string) RestoreDrillCheckResult {
+	result := RestoreDrillCheckResult{Database: database, Query: query}
+	startTime := time.Now()
+	value, err := drill.queryFirstValue(database, query, setup...)
+	result.Duration = time.Since(startTime).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+		tracelog.WarningLogger.Printf("Check %q in database %s failed: %v", query, database, err)
+	} else {
+		result.Result = value
+		tracelog.InfoLogger.Printf("Check %q in database %s: %s", query, database, value)
+	}
+	return result
+}
+
+func (drill *restoreDrill) queryFirstValue(database, query string, setup ...string) (string, error) {
+	conn, err := drill.connect(database)
+	if err != nil {
+		return "", err
+	}
+	defer utility.LoggedClose(conn, "")
+	for _, statement := range setup {
+		_, err = conn.Exec(statement)
+		if err != nil {
+			return "", err
+		}
+	}
+	rows, err := conn.Query(query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	value := ""
+	if rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return "", err
+		}
+		if len(values) > 0 {
+			value = fmt.Sprint(values[0])
+		}
+	}
+	return value, rows.Err()
+}
+
+func (drill *restoreDrill) runChecks() error {
+	for _, query := range drill.config.Checks {
+		drill.report.Checks = append(drill.report.Checks, drill.runCheck(drill.config.Database, query))
+	}
+	if drill.config.Amcheck {
+		databases, err := drill.databases()
+		if err != nil {
+			return err
+		}
+		for _, database := range databases {
+			drill.report.Checks = append(drill.report.Checks,
+				drill.runCheck(database, restoreDrillAmcheckQuery, "CREATE EXTENSION IF NOT EXISTS amcheck"))
+		}
+	}
+	for _, check := range drill.report.Checks {
+		if check.Error != "" {
+			return errors.New("some checks failed")
+		}
+	}
+	return nil
+}
+
+func (drill *restoreDrill) databases() ([]string, error) {
+	conn, err := drill.connect(drill.config.Database)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(conn, "")
+	rows, err := conn.Query("SELECT datname FROM pg_database WHERE datallowconn ORDER BY datname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var databases []string
+	for rows.Next() {
+		var database string
+		err = rows.Scan(&database)
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+	return databases, rows.Err()
+}
+
+func (drill *restoreDrill) run(rootFolder storage.Folder, backup Backup) error {
+	sentinel, err := backup.GetSentinel()
+	if err != nil {
+		return err
+	}
+	err = drill.restore(rootFolder, backup, sentinel)
+	if err != nil {
+		return err
+	}
+	err = drill.start()
+	if drill.serverStarted {
+		defer drill.stop()
+	}
+	if err != nil {
+		return err
+	}
+	err = drill.waitForPromotion(sentinel.PgVersion)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Recovery finished at LSN %s", drill.report.ReplayedLSN)
+	return drill.runChecks()
+}
+
+// HandleRestoreDrill restores the selected backup into a scratch directory, recovers it from the archive
+// with a local server, runs the checks and stores the report in the backup folder
+func HandleRestoreDrill(rootFolder storage.Folder, selector internal.BackupSelector, config RestoreDrillConfig,
+	output io.Writer) error {
+	selected, err := selector.Select(rootFolder)
+	if err != nil {
+		return err
+	}
+	backup := ToPgBackup(selected)
+
+	err = os.MkdirAll(config.ScratchDirectory, 0700)
+	if err != nil {
+		return err
+	}
+	scratch, err := os.MkdirTemp(config.ScratchDirectory, restoreDrillScratchDirPrefix)
+	if err != nil {
+		return err
+	}
+	if config.KeepScratchData {
+		tracelog.InfoLogger.Printf("Scratch directory %s is kept", scratch)
+	} else {
+		defer os.RemoveAll(scratch)
+	}
+	_, targetDescription := config.recoveryTarget()
+	drill := &restoreDrill{
+		config:        &config,
+		scratch:       scratch,
+		dataDirectory: filepath.Join(scratch, "data"),
+		report: RestoreDrillReport{
+			BackupName:     backup.Name,
+			StartTime:      utility.TimeNowCrossPlatformUTC(),
+			RecoveryTarget: targetDescription,
+			Checks:         []RestoreDrillCheckResult{},
+		},
+	}
+	drillErr := os.Mkdir(drill.dataDirectory, 0700)
+	if drillErr == nil {
+		drillErr = drill.run(rootFolder, backup)
+	}
+	drill.report.FinishTime = utility.TimeNowCrossPlatformUTC()
+	drill.report.Success = drillErr == nil
+	if drillErr != nil {
+		drill.report.Error = drillErr.Error()
+	}
+
+	err = uploadRestoreDrillReport(rootFolder, drill.report, config.SigningKey)
+	if err != nil {
+		return err
+	}
+	reportBytes, err := json.MarshalIndent(drill.report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(output, string(reportBytes))
+	if err != nil {
+		return err
+	}
+	if drillErr != nil {
+		return errors.Wrapf(drillErr, "restore drill of backup %s failed", backup.Name)
+	}
+	return nil
+}
+
+func uploadRestoreDrillReport(rootFolder storage.Folder, report RestoreDrillReport, signingKey string) error {
+	if signingKey == "" {
+		tracelog.WarningLogger.Println("The signing key is not set, the drill report is not signed")
+	}
+	object, err := newRestoreDrillReportObject(report, signingKey)
+	if err != nil {
+		return err
+	}
+	reportPath := restoreDrillReportPath(report.BackupName, report.StartTime)
+	err = rootFolder.GetSubFolder(utility.BaseBackupPath).PutObject(reportPath, bytes.NewReader(object))
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload the drill report %s", reportPath)
+	}
+	tracelog.InfoLogger.Printf("Drill report is uploaded to %s", reportPath)
+	return nil
+}
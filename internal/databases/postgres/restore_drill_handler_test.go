@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreDrillReportSignature(t *testing.T) {
+	report := RestoreDrillReport{
+		BackupName: "base_000000010000000000000002",
+		StartTime:  time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Checks:     []RestoreDrillCheckResult{{Database: "postgres", Query: "SELECT 1", Result: "1"}},
+		Success:    true,
+	}
+	object, err := newRestoreDrillReportObject(report, "key")
+	require.NoError(t, err)
+
+	verified, err := VerifyRestoreDrillReport(object, "key")
+	require.NoError(t, err)
+	assert.Equal(t, report.BackupName, verified.BackupName)
+	assert.True(t, verified.Success)
+
+	_, err = VerifyRestoreDrillReport(object, "other key")
+	assert.Error(t, err)
+
+	unsigned, err := newRestoreDrillReportObject(report, "")
+	require.NoError(t, err)
+	_, err = VerifyRestoreDrillReport(unsigned, "key")
+	assert.Error(t, err)
+}
+
+func TestRestoreDrillReportSignature_Tampered(t *testing.T) {
+	object, err := newRestoreDrillReportObject(RestoreDrillReport{BackupName: "base_1", Success: false}, "key")
+	require.NoError(t, err)
+	tampered := bytes.Replace(object, []byte(`"success":false`), []byte(`"success":true`), 1)
+	require.NotEqual(t, object, tampered)
+	_, err = VerifyRestoreDrillReport(tampered, "key")
+	assert.Error(t, err)
+}
+
+func TestRestoreDrillReportPath(t *testing.T) {
+	assert.Equal(t, "base_000000010000000000000002/restore_drill_20240301T120000Z.json",
+		restoreDrillReportPath("base_000000010000000000000002", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestWriteRestoreDrillRecoveryConfig(t *testing.T) {
+	dataDirectory := t.TempDir()
+	autoConfPath := filepath.Join(dataDirectory, "postgresql.auto.conf")
+	require.NoError(t, os.WriteFile(autoConfPath, []byte("work_mem = '4MB'\n"), 0600))
+	config := &RestoreDrillConfig{RestoreCommand: "wal-g wal-fetch \"%f\" \"%p\"", RecoveryTargetLSN: "0/3000060"}
+
+	require.NoError(t, writeRestoreDrillRecoveryConfig(dataDirectory, 160000, config))
+	autoConf, err := os.ReadFile(autoConfPath)
+	require.NoError(t, err)
+	assert.Equal(t, "work_mem = '4MB'\n# added by wal-g restore-drill\n"+
+		"restore_command = 'wal-g wal-fetch \"%f\" \"%p\"'\n"+
+		"recovery_target_lsn = '0/3000060'\n"+
+		"recovery_target_action = 'promote'\n", string(autoConf))
+	assert.FileExists(t, filepath.Join(dataDirectory, recoverySignalFileName))
+}
+
+func TestWriteRestoreDrillRecoveryConfig_RecoveryConf(t *testing.T) {
+	dataDirectory := t.TempDir()
+	config := &RestoreDrillConfig{RestoreCommand: "cp '/archive/%f' %p"}
+
+	require.NoError(t, writeRestoreDrillRecoveryConfig(dataDirectory, 110000, config))
+	recoveryConf, err := os.ReadFile(filepath.Join(dataDirectory, "recovery.conf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(recoveryConf), "restore_command = 'cp ''/archive/%f'' %p'\n")
+	assert.Contains(t, string(recoveryConf), "recovery_target = 'immediate'\n")
+	assert.NoFileExists(t, filepath.Join(dataDirectory, recoverySignalFileName))
+}
+
+func TestRestoreDrillServerOptions(t *testing.T) {
+	options := restoreDrillServerOptions("/scratch/data", "/scratch", 54321, []string{"shared_preload_libraries="})
+	assert.Equal(t, "-p 54321 -c listen_addresses='' -c unix_socket_directories='/scratch' "+
+		"-c hba_file='/scratch/data/pg_hba.conf' -c archive_mode=off -c ssl=off -c shared_preload_libraries=''",
+		options)
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/wal-g/internal"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// UploadRestoredBackup uploads the data directory restored by other means, e.g. from a backup of another tool,
+// as a full backup with the LSNs of the sentinel. The uploader should point to the backups folder.
+func UploadRestoredBackup(uploader internal.Uploader, backupName, dataDirectory string,
+	sentinel BackupSentinelDto, meta ExtendedMetadataDto) error {
+	// the uploader may be shared by several backups, so only the size uploaded for this one is recorded
+	uploadedBefore, err := uploader.UploadedDataSize()
+	if err != nil {
+		return err
+	}
+	sentinel.EncryptionInfo = crypto.GetEncryptionInfo(uploader.Crypter())
+	filesMeta, err := uploadDataDirectory(uploader, backupName, dataDirectory, &sentinel)
+	if err != nil {
+		return err
+	}
+	sentinel.CompressedSize -= uploadedBefore
+
+	meta.UncompressedSize = sentinel.UncompressedSize
+	meta.CompressedSize = sentinel.CompressedSize
+	return uploadBackupMetadata(uploader, backupName, sentinel, filesMeta, meta)
+}
+
+// uploadDataDirectory packs the data directory restored from other backups into the tars of the new backup
+// and records the sizes in the sentinel
+func uploadDataDirectory(uploader internal.Uploader, backupName, dataDirectory string,
+	sentinel *BackupSentinelDto) (FilesMetadataDto, error) {
+	bundle := NewBundle(dataDirectory, uploader.Crypter(), "", nil, nil, false,
+		viper.GetInt64(conf.TarSizeThresholdSetting))
+	err := bundle.StartQueue(internal.NewStorageTarBallMaker(backupName, uploader))
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+	composerMaker, err := NewTarBallComposerMaker(RegularComposer, nil, uploader, backupName,
+		NewTarBallFilePackerOptions(false, false), false)
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+	err = bundle.SetupComposer(composerMaker)
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+
+	err = filepath.Walk(dataDirectory, bundle.HandleWalkedFSObject)
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+	tarFileSets, err := bundle.FinishTarComposer()
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+	err = bundle.FinishQueue()
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+	err = bundle.UploadPgControl(uploader.Compression().FileExtension())
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+
+	uploader.Finish()
+	if uploader.Failed() {
+		return FilesMetadataDto{}, fmt.Errorf("uploading failed during '%s' backup", backupName)
+	}
+	sentinel.UncompressedSize = atomic.LoadInt64(bundle.TarBallQueue.AllTarballsSize)
+	sentinel.DataCatalogSize = atomic.LoadInt64(bundle.DataCatalogSize)
+	sentinel.CompressedSize, err = uploader.UploadedDataSize()
+	if err != nil {
+		return FilesMetadataDto{}, err
+	}
+
+	var filesMeta FilesMetadataDto
+	filesMeta.setFiles(bundle.GetFiles())
+	filesMeta.TarFileSets = tarFileSets.Get()
+	return filesMeta, nil
+}
+
+// uploadBackupMetadata uploads the metadata and, the last, the sentinel of the new backup
+func uploadBackupMetadata(uploader internal.Uploader, backupName string, sentinel BackupSentinelDto,
+	filesMeta FilesMetadataDto, meta ExtendedMetadataDto) error {
+	ctx := context.Background()
+	for path, dto := range map[string]interface{}{
+		storage.JoinPath(backupName, utility.MetadataFileName): meta,
+		getFilesMetadataPath(backupName):                       filesMeta,
+	} {
+		dtoBody, err := json.Marshal(dto)
+		if err != nil {
+			return internal.NewSentinelMarshallingError(path, err)
+		}
+		err = uploader.Upload(ctx, path, bytes.NewReader(dtoBody))
+		if err != nil {
+			return errors.Wrapf(err, "failed to upload %s", path)
+		}
+	}
+	err := internal.UploadSentinel(uploader, NewBackupSentinelDtoV2(sentinel, meta), backupName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload sentinel file for backup %s", backupName)
+	}
+	return nil
+}
@@ -130,7 +130,7 @@ func (bb *StreamingBaseBackup) Upload(ctx context.Context, uploader internal.Upl
 	bb.streamer = NewTarballStreamer(bb, bb.maxTarSize, bundleFiles)
 	for {
 		tbsTar := ioextensions.NewNamedReaderImpl(bb.streamer, bb.FileName())
-		compressedFile := internal.CompressAndEncrypt(tbsTar, bb.uploader.Compression(), internal.ConfigureCrypter())
+		compressedFile := internal.CompressAndEncrypt(tbsTar, bb.uploader.Compression(), bb.uploader.Crypter())
 		dstPath := fmt.Sprintf("%s.%s", bb.Path(), bb.uploader.Compression().FileExtension())
 		err = bb.uploader.Upload(ctx, dstPath, compressedFile)
 		if err != nil {
@@ -154,7 +154,7 @@ func (bb *StreamingBaseBackup) Upload(ctx context.Context, uploader internal.Upl
 	// Upload the extra tar
 	if len(bb.streamer.Tee) > 0 {
 		teeTar := ioextensions.NewNamedReaderImpl(bb.streamer.TeeIo, bb.FileName())
-		teeCompressedFile := internal.CompressAndEncrypt(teeTar, bb.uploader.Compression(), internal.ConfigureCrypter())
+		teeCompressedFile := internal.CompressAndEncrypt(teeTar, bb.uploader.Compression(), bb.uploader.Crypter())
 		teeFileName := fmt.Sprintf("pg_control.tar.%s", bb.uploader.Compression().FileExtension())
 		teeFilePath := storage.JoinPath(bb.BackupName(), internal.TarPartitionFolderName, teeFileName)
 		err = bb.uploader.Upload(ctx, teeFilePath, teeCompressedFile)
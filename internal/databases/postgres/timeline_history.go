@@ -95,6 +95,21 @@ func (tlh TimeLineHistFile) LSNToTimeLine(lsn pglogrepl.LSN) (uint32, error) {
 	return tlh.TimeLineID, nil
 }
 
+// SwitchPoint returns the LSN where the timeline ended and its child timeline started,
+// ok is false if the timeline is not a parent of this history.
+func (tlh TimeLineHistFile) SwitchPoint(timeline uint32) (lsn pglogrepl.LSN, ok bool, err error) {
+	rows, err := tlh.rows()
+	if err != nil {
+		return 0, false, err
+	}
+	for _, row := range rows {
+		if row.TimeLineID == timeline {
+			return row.StartLSN, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
 // Name returns the filename of this wal segment. This is a convenience function used by the WalUploader.
 func (tlh TimeLineHistFile) Name() string {
 	return tlh.Filename
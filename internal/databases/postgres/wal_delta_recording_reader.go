@@ -54,7 +54,7 @@ func NewWalDeltaRecordingReader(walFileReader io.Reader,
 }
 
 func (reader *WalDeltaRecordingReader) Close() error {
-	err := reader.partRecorder.SaveNextWalHead(reader.WalParser.GetCurrentRecordData())
+	err := reader.partRecorder.SaveNextWalHead(reader.WalParser.GetCurrentRecordData(), reader.WalParser.PageMagic())
 	if err != nil {
 		tracelog.WarningLogger.Printf("Failed to save next wal file prefix after end of recording because of: %v", err)
 	}
@@ -100,7 +100,7 @@ func (reader *WalDeltaRecordingReader) RecordBlockLocationsFromPage() error {
 	if len(discardedRecordTail) > 0 || len(records) > 0 {
 		if reader.canParsePreviousRecordTail {
 			reader.canParsePreviousRecordTail = false
-			err = reader.partRecorder.SavePreviousWalTail(discardedRecordTail)
+			err = reader.partRecorder.SavePreviousWalTail(discardedRecordTail, reader.WalParser.PageMagic())
 			if err != nil {
 				return err
 			}
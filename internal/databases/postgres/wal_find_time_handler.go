@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var errStopReadingWal = errors.New("stop reading WAL")
+
+// WalXactRecord is the transaction commit or abort record found in WAL
+type WalXactRecord struct {
+	LSN     string    `json:"lsn"`
+	Xid     uint32    `json:"xid"`
+	Time    time.Time `json:"time"`
+	Status  string    `json:"status"`
+	Segment string    `json:"segment"`
+}
+
+func (record WalXactRecord) String() string {
+	return fmt.Sprintf("%s of xid %d at %s, LSN %s in segment %s",
+		record.Status, record.Xid, record.Time.Format(time.RFC3339Nano), record.LSN, record.Segment)
+}
+
+// WalFindTimeResult holds the last transaction that finished before or at the target time on the timeline
+// and the first one that finished after it
+type WalFindTimeResult struct {
+	Timeline uint32         `json:"timeline"`
+	Before   *WalXactRecord `json:"before,omitempty"`
+	After    *WalXactRecord `json:"after,omitempty"`
+}
+
+func newWalXactRecord(located walparser.LocatedXLogRecord, segment string) (WalXactRecord, bool) {
+	xactTime, ok := located.Record.XactTime()
+	if !ok {
+		return WalXactRecord{}, false
+	}
+	operation, _ := located.Record.XactOperation()
+	status := "commit"
+	if operation == walparser.XLogXactAbort || operation == walparser.XLogXactAbortPrepared {
+		status = "abort"
+	}
+	return WalXactRecord{
+		LSN:     LSN(located.LSN).String(),
+		Xid:     located.Record.Header.XactID,
+		Time:    xactTime,
+		Status:  status,
+		Segment: segment,
+	}, true
+}
+
+// walTimeFinder searches the transaction records of the timeline segments
+type walTimeFinder struct {
+	folderReader internal.StorageFolderReader
+	timeline     uint32
+	segments     []WalSegmentNo
+	// firstXacts caches the first transaction record of the segments, nil if there are none
+	firstXacts map[int]*WalXactRecord
+}
+
+func newWalTimeFinder(folderReader internal.StorageFolderReader, timeline uint32,
+	segments []WalSegmentNo) *walTimeFinder {
+	return &walTimeFinder{
+		folderReader: folderReader,
+		timeline:     timeline,
+		segments:     segments,
+		firstXacts:   make(map[int]*WalXactRecord),
+	}
+}
+
+// readXactRecords reads the transaction records of the segments [from, to) until the function asks to stop
+func (finder *walTimeFinder) readXactRecords(from, to int, function func(record WalXactRecord) bool) error {
+	recordReader := walparser.NewWalRecordReader()
+	for index := from; index < to; index++ {
+		if index > from && finder.segments[index] != finder.segments[index-1].Next() {
+			recordReader.Invalidate()
+		}
+		filename := finder.segments[index].GetFilename(finder.timeline)
+		reader, err := internal.DownloadAndDecompressStorageFile(finder.folderReader, filename)
+		if err != nil {
+			return errors.Wrapf(err, "failed to download WAL segment %s", filename)
+		}
+		err = recordReader.ReadRecords(reader, func(located walparser.LocatedXLogRecord) error {
+			record, ok := newWalXactRecord(located, filename)
+			if ok && !function(record) {
+				return errStopReadingWal
+			}
+			return nil
+		})
+		utility.LoggedClose(reader, "")
+		if err == errStopReadingWal {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read WAL segment %s", filename)
+		}
+	}
+	return nil
+}
+
+// firstXact finds the first transaction record in the segment
+func (finder *walTimeFinder) firstXact(index int) (*WalXactRecord, error) {
+	if record, ok := finder.firstXacts[index]; ok {
+		return record, nil
+	}
+	var first *WalXactRecord
+	err := finder.readXactRecords(index, index+1, func(record WalXactRecord) bool {
+		first = &record
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	finder.firstXacts[index] = first
+	return first, nil
+}
+
+// findLastSegmentBefore finds the last segment which first transaction record is not after the target time.
+// Commit timestamps are supposed to grow along the WAL, so the segments are searched by bisection.
+func (finder *walTimeFinder) findLastSegmentBefore(target time.Time) (int, error) {
+	candidate := -1
+	low, high := 0, len(finder.segments)
+	for low < high {
+		middle := (low + high) / 2
+		// skip the segments without transaction records
+		index := middle
+		var record *WalXactRecord
+		for ; index < high; index++ {
+			var err error
+			record, err = finder.firstXact(index)
+			if err != nil {
+				return 0, err
+			}
+			if record != nil {
+				break
+			}
+		}
+		if record == nil || record.Time.After(target) {
+			high = middle
+		} else {
+			candidate = index
+			low = index + 1
+		}
+	}
+	return candidate, nil
+}
+
+func (finder *walTimeFinder) find(target time.Time) (WalFindTimeResult, error) {
+	result := WalFindTimeResult{Timeline: finder.timeline}
+	candidate, err := finder.findLastSegmentBefore(target)
+	if err != nil {
+		return result, err
+	}
+	err = finder.readXactRecords(max(candidate, 0), len(finder.segments), func(record WalXactRecord) bool {
+		if record.Time.After(target) {
+			result.After = &record
+			return false
+		}
+		result.Before = &record
+		return true
+	})
+	return result, err
+}
+
+// HandleWalFindTime searches the archived WAL of the timelines for the transactions that finished
+// right before and right after the target time. All timelines are searched if none is given.
+func HandleWalFindTime(rootFolder storage.Folder, target time.Time, timelines []uint32,
+	jsonOutput bool, output io.Writer) error {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	filenames, err := getFolderFilenames(walFolder)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the WAL folder")
+	}
+	segmentsByTimelines := groupSegmentsByTimelines(getSegmentsFromFiles(filenames))
+	if len(timelines) == 0 {
+		for timeline := range segmentsByTimelines {
+			timelines = append(timelines, timeline)
+		}
+	}
+	sort.Slice(timelines, func(i, j int) bool { return timelines[i] < timelines[j] })
+
+	results := make([]WalFindTimeResult, 0, len(timelines))
+	for _, timeline := range timelines {
+		sequence, ok := segmentsByTimelines[timeline]
+		if !ok {
+			return fmt.Errorf("no WAL segments of timeline %d found", timeline)
+		}
+		segments := make([]WalSegmentNo, 0, len(sequence.WalSegmentNumbers))
+		for segmentNo := range sequence.WalSegmentNumbers {
+			segments = append(segments, segmentNo)
+		}
+		sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+		tracelog.InfoLogger.Printf("Searching %d WAL segments of timeline %d", len(segments), timeline)
+		result, err := newWalTimeFinder(internal.NewFolderReader(walFolder), timeline, segments).find(target)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if jsonOutput {
+		return json.NewEncoder(output).Encode(results)
+	}
+	for _, result := range results {
+		_, err = fmt.Fprintf(output, "Timeline %d:\n  last before: %s\n  first after: %s\n",
+			result.Timeline, describeWalXactRecord(result.Before), describeWalXactRecord(result.After))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describeWalXactRecord(record *WalXactRecord) string {
+	if record == nil {
+		return "none"
+	}
+	return record.String()
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var findTimeTestStart = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// makeXactSegment builds a WAL segment made of one page with the commit records of the transactions
+func makeXactSegment(pageAddress LSN, firstXid uint32, xactTimes []time.Time) []byte {
+	page := new(bytes.Buffer)
+	_ = binary.Write(page, binary.LittleEndian, struct {
+		Magic            uint16
+		Info             uint16
+		TimeLineID       uint32
+		PageAddress      uint64
+		RemainingDataLen uint32
+		Padding          uint32
+	}{Magic: 0xD10D, TimeLineID: 1, PageAddress: uint64(pageAddress)})
+	const recordLength = walparser.XLogRecordHeaderSize + 2 + 8
+	prevLSN := uint64(0)
+	for i, xactTime := range xactTimes {
+		lsn := uint64(pageAddress) + uint64(page.Len())
+		_ = binary.Write(page, binary.LittleEndian, struct {
+			TotalRecordLength uint32
+			XactID            uint32
+			PrevRecordPtr     uint64
+			Info              uint8
+			ResourceManagerID uint8
+			Padding           uint16
+			Crc32Hash         uint32
+			MainDataBlockID   uint8
+			MainDataLength    uint8
+			XactTime          int64
+			Alignment         [6]byte
+		}{
+			TotalRecordLength: recordLength,
+			XactID:            firstXid + uint32(i),
+			PrevRecordPtr:     prevLSN,
+			Info:              walparser.XLogXactCommit,
+			ResourceManagerID: walparser.RmXactID,
+			MainDataBlockID:   walparser.XlrBlockIDDataShort,
+			MainDataLength:    8,
+			XactTime:          xactTime.Sub(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)).Microseconds(),
+		})
+		prevLSN = lsn
+	}
+	segment := make([]byte, walparser.WalPageSize)
+	copy(segment, page.Bytes())
+	return segment
+}
+
+func newFindTimeTestFolder(t *testing.T) *memory.Folder {
+	folder := memory.NewFolder("", memory.NewKVS())
+	xid := uint32(100)
+	// segments 1-6 of timeline 1 with 3 commits a minute apart each, segment 4 has no commits
+	for segmentNo := WalSegmentNo(1); segmentNo <= 6; segmentNo++ {
+		var xactTimes []time.Time
+		if segmentNo != 4 {
+			for i := 0; i < 3; i++ {
+				xactTimes = append(xactTimes, findTimeTestStart.Add(time.Duration(xid-100)*time.Minute))
+				xid++
+			}
+		}
+		segment := makeXactSegment(segmentNo.firstLsn(), xid-uint32(len(xactTimes)), xactTimes)
+		require.NoError(t, folder.PutObject(utility.WalPath+segmentNo.GetFilename(1), bytes.NewReader(segment)))
+	}
+	return folder
+}
+
+func findTime(t *testing.T, folder *memory.Folder, target time.Time) WalFindTimeResult {
+	var output bytes.Buffer
+	require.NoError(t, HandleWalFindTime(folder, target, nil, true, &output))
+	var results []WalFindTimeResult
+	require.NoError(t, json.Unmarshal(output.Bytes(), &results))
+	require.Len(t, results, 1)
+	return results[0]
+}
+
+func TestHandleWalFindTime(t *testing.T) {
+	folder := newFindTimeTestFolder(t)
+
+	result := findTime(t, folder, findTimeTestStart.Add(7*time.Minute+30*time.Second))
+	require.NotNil(t, result.Before)
+	require.NotNil(t, result.After)
+	assert.Equal(t, uint32(107), result.Before.Xid)
+	assert.Equal(t, "commit", result.Before.Status)
+	assert.Equal(t, "000000010000000000000003", result.Before.Segment)
+	assert.Equal(t, uint32(108), result.After.Xid)
+
+	// the first commit after the time is behind the segment without commits
+	result = findTime(t, folder, findTimeTestStart.Add(8*time.Minute+30*time.Second))
+	assert.Equal(t, uint32(108), result.Before.Xid)
+	assert.Equal(t, uint32(109), result.After.Xid)
+	assert.Equal(t, "000000010000000000000005", result.After.Segment)
+
+	result = findTime(t, folder, findTimeTestStart.Add(-time.Minute))
+	assert.Nil(t, result.Before)
+	assert.Equal(t, uint32(100), result.After.Xid)
+
+	result = findTime(t, folder, findTimeTestStart.Add(time.Hour))
+	assert.Equal(t, uint32(114), result.Before.Xid)
+	assert.Nil(t, result.After)
+
+	result = findTime(t, folder, findTimeTestStart.Add(3*time.Minute))
+	assert.Equal(t, uint32(103), result.Before.Xid, "the commit at the target time is before it")
+	assert.Equal(t, "0/2000018", result.Before.LSN)
+}
+
+func TestHandleWalFindTime_UnknownTimeline(t *testing.T) {
+	folder := newFindTimeTestFolder(t)
+	err := HandleWalFindTime(folder, findTimeTestStart, []uint32{2}, false, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var forkNames = []string{"main", "fsm", "vm", "init"}
+
+// WalInspectFilter selects the WAL records to print, empty fields match any record
+type WalInspectFilter struct {
+	Relation         *walparser.RelFileNode
+	Xid              *uint32
+	ResourceManagers map[uint8]bool
+}
+
+// ParseRelFileNode parses the relation in the tablespace/database/relfilenode format used by pg_waldump
+func ParseRelFileNode(relation string) (walparser.RelFileNode, error) {
+	parts := strings.Split(relation, "/")
+	if len(parts) != 3 {
+		return walparser.RelFileNode{}, fmt.Errorf("relation %q is not in the tablespace/database/relfilenode format",
+			relation)
+	}
+	var oids [3]walparser.Oid
+	for i, part := range parts {
+		oid, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return walparser.RelFileNode{}, errors.Wrapf(err, "invalid oid in relation %q", relation)
+		}
+		oids[i] = walparser.Oid(oid)
+	}
+	return walparser.RelFileNode{SpcNode: oids[0], DBNode: oids[1], RelNode: oids[2]}, nil
+}
+
+func (filter WalInspectFilter) matches(record *walparser.XLogRecord) bool {
+	if filter.Xid != nil && record.Header.XactID != *filter.Xid {
+		return false
+	}
+	if len(filter.ResourceManagers) > 0 && !filter.ResourceManagers[record.Header.ResourceManagerID] {
+		return false
+	}
+	if filter.Relation != nil {
+		for _, block := range record.Blocks {
+			if block.Header.BlockLocation.RelationFileNode == *filter.Relation {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// WalRecordDescription is the WAL record as wal-inspect prints it
+type WalRecordDescription struct {
+	LSN             string                      `json:"lsn"`
+	PrevLSN         string                      `json:"prev_lsn"`
+	ResourceManager string                      `json:"rmgr"`
+	Info            uint8                       `json:"info"`
+	Xid             uint32                      `json:"xid"`
+	TotalLength     uint32                      `json:"total_length"`
+	MainDataLength  uint32                      `json:"main_data_length"`
+	XactTime        *time.Time                  `json:"xact_time,omitempty"`
+	Blocks          []WalRecordBlockDescription `json:"blocks,omitempty"`
+}
+
+// WalRecordBlockDescription is the block reference of the WAL record
+type WalRecordBlockDescription struct {
+	BlockID  uint8         `json:"block_id"`
+	SpcNode  walparser.Oid `json:"spc_node"`
+	DBNode   walparser.Oid `json:"db_node"`
+	RelNode  walparser.Oid `json:"rel_node"`
+	Fork     string        `json:"fork"`
+	BlockNo  uint32        `json:"block_no"`
+	HasImage bool          `json:"has_image"`
+}
+
+func newWalRecordDescription(located walparser.LocatedXLogRecord) WalRecordDescription {
+	record := located.Record
+	description := WalRecordDescription{
+		LSN:             LSN(located.LSN).String(),
+		PrevLSN:         LSN(record.Header.PrevRecordPtr).String(),
+		ResourceManager: walparser.ResourceManagerName(record.Header.ResourceManagerID),
+		Info:            record.Header.Info,
+		Xid:             record.Header.XactID,
+		TotalLength:     record.Header.TotalRecordLength,
+		MainDataLength:  record.MainDataLen,
+	}
+	if xactTime, ok := record.XactTime(); ok {
+		description.XactTime = &xactTime
+	}
+	for _, block := range record.Blocks {
+		fork := strconv.Itoa(int(block.Header.ForkNum()))
+		if int(block.Header.ForkNum()) < len(forkNames) {
+			fork = forkNames[block.Header.ForkNum()]
+		}
+		location := block.Header.BlockLocation
+		description.Blocks = append(description.Blocks, WalRecordBlockDescription{
+			BlockID:  block.Header.BlockID,
+			SpcNode:  location.RelationFileNode.SpcNode,
+			DBNode:   location.RelationFileNode.DBNode,
+			RelNode:  location.RelationFileNode.RelNode,
+			Fork:     fork,
+			BlockNo:  location.BlockNo,
+			HasImage: block.Header.HasImage(),
+		})
+	}
+	return description
+}
+
+// String formats the record the way pg_waldump does, without the rmgr-specific description
+func (description WalRecordDescription) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "rmgr: %-11s len (total): %6d, tx: %10d, lsn: %s, prev %s, desc: info 0x%02X",
+		description.ResourceManager, description.TotalLength, description.Xid,
+		description.LSN, description.PrevLSN, description.Info)
+	if description.XactTime != nil {
+		fmt.Fprintf(&builder, ", time %s", description.XactTime.Format(time.RFC3339Nano))
+	}
+	for _, block := range description.Blocks {
+		fmt.Fprintf(&builder, ", blkref #%d: rel %d/%d/%d fork %s blk %d",
+			block.BlockID, block.SpcNode, block.DBNode, block.RelNode, block.Fork, block.BlockNo)
+		if block.HasImage {
+			builder.WriteString(" FPW")
+		}
+	}
+	return builder.String()
+}
+
+// HandleWalInspect streams the WAL segments from firstSegment to lastSegment from the storage
+// and prints the records that match the filter
+func HandleWalInspect(folder storage.Folder, firstSegment, lastSegment string, filter WalInspectFilter,
+	jsonOutput bool, output io.Writer) error {
+	timeline, firstSegmentNo, err := ParseWALFilename(firstSegment)
+	if err != nil {
+		return err
+	}
+	lastTimeline, lastSegmentNo, err := ParseWALFilename(lastSegment)
+	if err != nil {
+		return err
+	}
+	if lastTimeline != timeline || lastSegmentNo < firstSegmentNo {
+		return fmt.Errorf("segments %s and %s don't make a range of one timeline", firstSegment, lastSegment)
+	}
+
+	folderReader := internal.NewFolderReader(folder.GetSubFolder(utility.WalPath))
+	recordReader := walparser.NewWalRecordReader()
+	encoder := json.NewEncoder(output)
+	printRecord := func(located walparser.LocatedXLogRecord) error {
+		if located.Record.IsZero() || !filter.matches(&located.Record) {
+			return nil
+		}
+		description := newWalRecordDescription(located)
+		if jsonOutput {
+			return encoder.Encode(description)
+		}
+		_, err := fmt.Fprintln(output, description)
+		return err
+	}
+
+	for segmentNo := WalSegmentNo(firstSegmentNo); segmentNo <= WalSegmentNo(lastSegmentNo); segmentNo = segmentNo.Next() {
+		filename := segmentNo.GetFilename(timeline)
+		tracelog.DebugLogger.Printf("Inspecting WAL segment %s", filename)
+		err = inspectWalSegment(folderReader, filename, recordReader, printRecord)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inspectWalSegment(folderReader internal.StorageFolderReader, filename string,
+	recordReader *walparser.WalRecordReader, printRecord func(walparser.LocatedXLogRecord) error) error {
+	reader, err := internal.DownloadAndDecompressStorageFile(folderReader, filename)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download WAL segment %s", filename)
+	}
+	defer utility.LoggedClose(reader, "")
+
+	err = recordReader.ReadRecords(reader, printRecord)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read WAL segment %s", filename)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const walInspectTestSegment = "000000010000000000000078"
+
+func newWalInspectTestFolder(t *testing.T) *memory.Folder {
+	folder := memory.NewFolder("", memory.NewKVS())
+	segment, err := os.ReadFile("../../walparser/testdata/wal_switch_test")
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(utility.WalPath+walInspectTestSegment, bytes.NewReader(segment)))
+	return folder
+}
+
+func TestHandleWalInspect_JSON(t *testing.T) {
+	folder := newWalInspectTestFolder(t)
+	var output bytes.Buffer
+	err := HandleWalInspect(folder, walInspectTestSegment, walInspectTestSegment, WalInspectFilter{
+		ResourceManagers: map[uint8]bool{walparser.RmXactID: true},
+	}, true, &output)
+	require.NoError(t, err)
+
+	var descriptions []WalRecordDescription
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		var description WalRecordDescription
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &description))
+		descriptions = append(descriptions, description)
+	}
+	require.Len(t, descriptions, 3)
+	assert.Equal(t, "Transaction", descriptions[0].ResourceManager)
+	assert.Equal(t, "0/78391178", descriptions[0].LSN)
+	assert.Equal(t, uint32(163652), descriptions[0].Xid)
+	require.NotNil(t, descriptions[0].XactTime)
+	assert.Equal(t, 2018, descriptions[0].XactTime.Year())
+}
+
+func TestHandleWalInspect_TextFilters(t *testing.T) {
+	folder := newWalInspectTestFolder(t)
+	xid := uint32(163653)
+	var output bytes.Buffer
+	err := HandleWalInspect(folder, walInspectTestSegment, walInspectTestSegment,
+		WalInspectFilter{Xid: &xid}, false, &output)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.Contains(t, line, "tx:     163653")
+	}
+	assert.Contains(t, lines[len(lines)-1], "rmgr: Transaction")
+}
+
+func TestHandleWalInspect_MissingSegment(t *testing.T) {
+	folder := newWalInspectTestFolder(t)
+	err := HandleWalInspect(folder, walInspectTestSegment, "000000010000000000000079",
+		WalInspectFilter{}, false, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestParseRelFileNode(t *testing.T) {
+	relFileNode, err := ParseRelFileNode("1663/5/16384")
+	require.NoError(t, err)
+	assert.Equal(t, walparser.RelFileNode{SpcNode: 1663, DBNode: 5, RelNode: 16384}, relFileNode)
+
+	_, err = ParseRelFileNode("5/16384")
+	assert.Error(t, err)
+	_, err = ParseRelFileNode("1663/x/16384")
+	assert.Error(t, err)
+}
@@ -9,10 +9,16 @@ import (
 
 type WalPartDataType uint8
 
+// The part file is a sequence of parts, each one is the type, the id, the data length and the data.
+// PageMagicType keeps the magic of the WAL pages the record parts are taken from (uint16, little endian),
+// since the block image flags of PostgreSQL 15+ records differ. The files written before it was added have no
+// such part and are parsed in the pre-15 format, as well as the files read by such versions: the readers skip
+// the parts of the types they don't know.
 const (
 	PreviousWalHeadType WalPartDataType = 0
 	WalTailType         WalPartDataType = 1
 	WalHeadType         WalPartDataType = 2
+	PageMagicType       WalPartDataType = 3
 )
 
 type WalPart struct {
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"encoding/binary"
 	"io"
 
 	"github.com/pkg/errors"
@@ -11,13 +12,14 @@ type WalPartFile struct {
 	WalTails        [][]byte
 	PreviousWalHead []byte
 	WalHeads        [][]byte
+	// PageMagic is the magic of the WAL pages the parts are taken from, the record format depends on it
+	PageMagic uint16
 }
 
 func NewWalPartFile() *WalPartFile {
 	return &WalPartFile{
-		make([][]byte, WalFileInDelta),
-		nil,
-		make([][]byte, WalFileInDelta),
+		WalTails: make([][]byte, WalFileInDelta),
+		WalHeads: make([][]byte, WalFileInDelta),
 	}
 }
 
@@ -53,6 +55,11 @@ func (partFile *WalPartFile) Save(writer io.Writer) error {
 			walParts = append(walParts, *NewWalPart(WalHeadType, uint8(id), data))
 		}
 	}
+	if partFile.PageMagic != 0 {
+		pageMagic := make([]byte, 2)
+		binary.LittleEndian.PutUint16(pageMagic, partFile.PageMagic)
+		walParts = append(walParts, *NewWalPart(PageMagicType, 0, pageMagic))
+	}
 	return saveWalParts(walParts, writer)
 }
 
@@ -75,7 +82,7 @@ func (partFile *WalPartFile) CombineRecords() ([]walparser.XLogRecord, error) {
 		if len(recordData) == 0 {
 			continue
 		}
-		record, err := walparser.ParseXLogRecordFromBytes(recordData)
+		record, err := walparser.ParseXLogRecordFromBytesWithPageMagic(recordData, partFile.PageMagic)
 		if err != nil {
 			return nil, err
 		}
@@ -92,6 +99,10 @@ func (partFile *WalPartFile) setPart(part WalPart) {
 		partFile.WalTails[part.id] = part.data
 	case WalHeadType:
 		partFile.WalHeads[part.id] = part.data
+	case PageMagicType:
+		if len(part.data) == 2 {
+			partFile.PageMagic = binary.LittleEndian.Uint16(part.data)
+		}
 	}
 }
 
@@ -51,6 +51,7 @@ func TestSaveLoadWalPartFile(t *testing.T) {
 	partFile.PreviousWalHead = []byte{1, 2, 3, 4, 5}
 	partFile.WalHeads[5] = []byte{6, 7, 7, 8, 9}
 	partFile.WalTails[10] = []byte{10, 11, 12, 13, 14}
+	partFile.PageMagic = walparser.XLogPageMagicPg15
 
 	var partFileData bytes.Buffer
 	err := partFile.Save(&partFileData)
@@ -72,3 +73,37 @@ func TestCombineRecords(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []walparser.XLogRecord{xLogRecord}, actualRecords)
 }
+
+func TestLoadWalPartFile_WithoutPageMagic(t *testing.T) {
+	xLogRecord, recordData := testtools.GetXLogRecordData()
+	// the file written by the versions that don't record the page magic
+	var oldPartFileData bytes.Buffer
+	assert.NoError(t, postgres.NewWalPart(postgres.WalTailType, 2, recordData[16:]).Save(&oldPartFileData))
+	assert.NoError(t, postgres.NewWalPart(postgres.WalHeadType, 1, recordData[:16]).Save(&oldPartFileData))
+
+	partFile, err := postgres.LoadPartFile(bytes.NewReader(oldPartFileData.Bytes()))
+	assert.NoError(t, err)
+	assert.Zero(t, partFile.PageMagic)
+	actualRecords, err := partFile.CombineRecords()
+	assert.NoError(t, err)
+	assert.Equal(t, []walparser.XLogRecord{xLogRecord}, actualRecords)
+
+	// without the page magic the file is saved in the old format
+	var partFileData bytes.Buffer
+	assert.NoError(t, partFile.Save(&partFileData))
+	assert.Equal(t, oldPartFileData.Bytes(), partFileData.Bytes())
+}
+
+func TestLoadWalPartFile_SkipsUnknownParts(t *testing.T) {
+	partFile := postgres.NewWalPartFile()
+	partFile.WalHeads[1] = []byte{1, 2, 3}
+	partFile.PageMagic = walparser.XLogPageMagicPg15
+	var partFileData bytes.Buffer
+	assert.NoError(t, partFile.Save(&partFileData))
+	// the readers skip the parts they don't know, as the versions before the page magic skip it
+	assert.NoError(t, postgres.NewWalPart(postgres.WalPartDataType(255), 0, []byte{4, 5}).Save(&partFileData))
+
+	loadedPartFile, err := postgres.LoadPartFile(&partFileData)
+	assert.NoError(t, err)
+	assert.Equal(t, *partFile, *loadedPartFile)
+}
@@ -31,7 +31,7 @@ func NewWalPartRecorder(walFilename string, manager *DeltaFileManager) (*WalPart
 	return &WalPartRecorder{manager, walFilename}, nil
 }
 
-func (recorder *WalPartRecorder) SavePreviousWalTail(tailData []byte) error {
+func (recorder *WalPartRecorder) SavePreviousWalTail(tailData []byte, pageMagic uint16) error {
 	if tailData == nil {
 		tailData = make([]byte, 0)
 	}
@@ -44,10 +44,11 @@ func (recorder *WalPartRecorder) SavePreviousWalTail(tailData []byte) error {
 		return err
 	}
 	partFile.WalTails[GetPositionInDelta(recorder.walFilename)] = tailData
+	setPartFilePageMagic(partFile, pageMagic)
 	return nil
 }
 
-func (recorder *WalPartRecorder) SaveNextWalHead(head []byte) error {
+func (recorder *WalPartRecorder) SaveNextWalHead(head []byte, pageMagic uint16) error {
 	if head == nil {
 		head = make([]byte, 0)
 	}
@@ -58,6 +59,7 @@ func (recorder *WalPartRecorder) SaveNextWalHead(head []byte) error {
 	}
 	positionInDelta := GetPositionInDelta(recorder.walFilename)
 	partFile.WalHeads[positionInDelta] = head
+	setPartFilePageMagic(partFile, pageMagic)
 	if positionInDelta == int(WalFileInDelta)-1 {
 		nextWalFilename, _ := GetNextWalFilename(recorder.walFilename)
 		nextDeltaFilename, _ := GetDeltaFilenameFor(nextWalFilename)
@@ -70,6 +72,13 @@ func (recorder *WalPartRecorder) SaveNextWalHead(head []byte) error {
 	return nil
 }
 
+// setPartFilePageMagic records the page magic unless no page has been parsed yet
+func setPartFilePageMagic(partFile *WalPartFile, pageMagic uint16) {
+	if pageMagic != 0 {
+		partFile.PageMagic = pageMagic
+	}
+}
+
 func (recorder *WalPartRecorder) cancelRecordingWithErr(err error) {
 	tracelog.WarningLogger.Printf("Stopped wal file: '%s' recording because of error: '%v'\n", recorder.walFilename, err)
 	recorder.manager.CancelRecording(recorder.walFilename)
@@ -17,7 +17,7 @@ func TestSavePreviousWalTail(t *testing.T) {
 	walPartRecorder, err := postgres.NewWalPartRecorder(WalFilename, manager)
 	assert.NoError(t, err)
 	previousWalTail := []byte{1, 2, 3, 4, 5}
-	err = walPartRecorder.SavePreviousWalTail(previousWalTail)
+	err = walPartRecorder.SavePreviousWalTail(previousWalTail, 0)
 	assert.NoError(t, err)
 	manager.FlushFiles(context.Background(), nil)
 
@@ -36,7 +36,7 @@ func TestSaveNextWalHead_MiddleWalFile(t *testing.T) {
 	walPartRecorder, err := postgres.NewWalPartRecorder(WalFilename, manager)
 	assert.NoError(t, err)
 	nextWalHead := []byte{1, 2, 3, 4, 5}
-	err = walPartRecorder.SaveNextWalHead(nextWalHead)
+	err = walPartRecorder.SaveNextWalHead(nextWalHead, 0)
 	assert.NoError(t, err)
 	manager.FlushFiles(context.Background(), nil)
 
@@ -55,7 +55,7 @@ func TestSaveNextWalHead_LastWalFile(t *testing.T) {
 	walPartRecorder, err := postgres.NewWalPartRecorder(LastWalFilename, manager)
 	assert.NoError(t, err)
 	nextWalHead := []byte{1, 2, 3, 4, 5}
-	err = walPartRecorder.SaveNextWalHead(nextWalHead)
+	err = walPartRecorder.SaveNextWalHead(nextWalHead, 0)
 	assert.NoError(t, err)
 	manager.FlushFiles(context.Background(), nil)
 
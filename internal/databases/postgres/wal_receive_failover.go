@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	conf "github.com/wal-g/wal-g/internal/config"
+)
+
+// The WalReceiveHost is a candidate server to stream WAL from, the empty host and port
+// are taken from PGHOST and PGPORT.
+type WalReceiveHost struct {
+	Host string
+	Port uint16
+}
+
+func (host WalReceiveHost) String() string {
+	if host.Host == "" && host.Port == 0 {
+		return "PGHOST"
+	}
+	if host.Port == 0 {
+		return host.Host
+	}
+	return net.JoinHostPort(host.Host, strconv.Itoa(int(host.Port)))
+}
+
+// GetWalReceiveHosts reads the candidate hosts of wal-receive, the server from PGHOST is the only one by default
+func GetWalReceiveHosts() ([]WalReceiveHost, error) {
+	return ParseWalReceiveHosts(viper.GetString(conf.PgWalReceiveHosts))
+}
+
+// ParseWalReceiveHosts parses the comma separated list of host[:port] entries
+func ParseWalReceiveHosts(value string) ([]WalReceiveHost, error) {
+	var hosts []WalReceiveHost
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host := WalReceiveHost{Host: entry}
+		// unix socket directories have no port
+		if !strings.HasPrefix(entry, "/") && strings.Contains(entry, ":") {
+			hostName, port, err := net.SplitHostPort(entry)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid wal-receive host %q", entry)
+			}
+			portNumber, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid port of wal-receive host %q", entry)
+			}
+			host = WalReceiveHost{Host: hostName, Port: uint16(portNumber)}
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return []WalReceiveHost{{}}, nil
+	}
+	return hosts, nil
+}
+
+// connectToWalReceiveHost opens the regular connection used to inspect the server and its slot
+func connectToWalReceiveHost(host WalReceiveHost) (*pgx.Conn, error) {
+	config, err := pgx.ParseEnvLibpq()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read environment variables")
+	}
+	if host.Host != "" {
+		config.Host = host.Host
+	}
+	if host.Port != 0 {
+		config.Port = host.Port
+	}
+	return pgx.Connect(config)
+}
+
+// replicationConnString builds the connection string of the replication connection to the host,
+// the settings missing in it are taken from the environment
+func replicationConnString(host WalReceiveHost) string {
+	connString := "replication=yes"
+	if host.Host != "" {
+		connString += " host=" + quoteConnStringValue(host.Host)
+	}
+	if host.Port != 0 {
+		connString += fmt.Sprintf(" port=%d", host.Port)
+	}
+	return connString
+}
+
+func quoteConnStringValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// isWalRemovedError checks if the server has already removed the requested WAL
+func isWalRemovedError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "58P01"
+}
+
+// The WalReceiveGap is the range of WAL removed from the server before wal-receive could stream it,
+// so it is missing in the archive.
+type WalReceiveGap struct {
+	TimeLine        uint32
+	From            pglogrepl.LSN
+	To              pglogrepl.LSN
+	walSegmentBytes uint64
+}
+
+// NewWalReceiveGap creates the gap between the segments starting at from and to.
+// The gap has at least the segment of from, which is removed on the server, even if to is in the same segment.
+func NewWalReceiveGap(timeline uint32, from, to pglogrepl.LSN, walSegmentBytes uint64) WalReceiveGap {
+	gap := WalReceiveGap{
+		TimeLine:        timeline,
+		From:            pglogrepl.LSN(uint64(from) / walSegmentBytes * walSegmentBytes),
+		To:              pglogrepl.LSN(uint64(to) / walSegmentBytes * walSegmentBytes),
+		walSegmentBytes: walSegmentBytes,
+	}
+	if gap.To <= gap.From {
+		gap.To = gap.From + pglogrepl.LSN(walSegmentBytes)
+	}
+	return gap
+}
+
+// NewWalReceiveGaps creates the gaps between the position on the timeline and the position to,
+// one per timeline of the history the range crosses
+func NewWalReceiveGaps(history *TimeLineHistFile, timeline uint32, from, to pglogrepl.LSN,
+	walSegmentBytes uint64) ([]WalReceiveGap, error) {
+	var gaps []WalReceiveGap
+	for history != nil {
+		switchPoint, ok, err := history.SwitchPoint(timeline)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || switchPoint >= to {
+			break
+		}
+		if from < switchPoint {
+			gaps = append(gaps, NewWalReceiveGap(timeline, from, switchPoint, walSegmentBytes))
+			from = switchPoint
+		}
+		timeline, err = history.LSNToTimeLine(switchPoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(gaps, NewWalReceiveGap(timeline, from, to, walSegmentBytes)), nil
+}
+
+// Segments returns the names of the first and the last missing segments
+func (gap WalReceiveGap) Segments() (first, last string) {
+	first = formatWALFileName(gap.TimeLine, uint64(gap.From)/gap.walSegmentBytes)
+	last = formatWALFileName(gap.TimeLine, uint64(gap.To)/gap.walSegmentBytes-1)
+	return first, last
+}
+
+func (gap WalReceiveGap) String() string {
+	first, last := gap.Segments()
+	return fmt.Sprintf("%s - %s (%s - %s)", first, last, gap.From, gap.To)
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWalReceiveHosts(t *testing.T) {
+	hosts, err := ParseWalReceiveHosts("db1:5433, db2,/var/run/postgresql,[::1]:5432")
+	require.NoError(t, err)
+	assert.Equal(t, []WalReceiveHost{
+		{Host: "db1", Port: 5433},
+		{Host: "db2"},
+		{Host: "/var/run/postgresql"},
+		{Host: "::1", Port: 5432},
+	}, hosts)
+	assert.Equal(t, "[::1]:5432", hosts[3].String())
+
+	hosts, err = ParseWalReceiveHosts("")
+	require.NoError(t, err)
+	assert.Equal(t, []WalReceiveHost{{}}, hosts)
+
+	_, err = ParseWalReceiveHosts("db1:port")
+	assert.Error(t, err)
+}
+
+func TestReplicationConnString(t *testing.T) {
+	assert.Equal(t, "replication=yes", replicationConnString(WalReceiveHost{}))
+	assert.Equal(t, "replication=yes host='db1' port=5433", replicationConnString(WalReceiveHost{Host: "db1", Port: 5433}))
+	assert.Equal(t, `replication=yes host='/tmp/it\'s'`, replicationConnString(WalReceiveHost{Host: "/tmp/it's"}))
+}
+
+func TestWalReceiveGap(t *testing.T) {
+	gap := NewWalReceiveGap(2, 0x5000000, 0x7800000, 0x1000000)
+	first, last := gap.Segments()
+	assert.Equal(t, "000000020000000000000005", first)
+	assert.Equal(t, "000000020000000000000006", last)
+	assert.Equal(t, "000000020000000000000005 - 000000020000000000000006 (0/5000000 - 0/7000000)", gap.String())
+
+	gap = NewWalReceiveGap(2, 0x5000028, 0x5800000, 0x1000000)
+	first, last = gap.Segments()
+	assert.Equal(t, "000000020000000000000005", first)
+	assert.Equal(t, "000000020000000000000005", last)
+}
+
+func TestTimeLineHistFileSwitchPoint(t *testing.T) {
+	tlh, err := NewTimeLineHistFile(3, "00000003.history",
+		[]byte("1\t0/2A33FF50\tno recovery target specified\n\n2\t0/3A3400E8\tno recovery target specified\n"))
+	require.NoError(t, err)
+
+	switchPoint, ok, err := tlh.SwitchPoint(2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, pglogrepl.LSN(0x3A3400E8), switchPoint)
+
+	_, ok, err = tlh.SwitchPoint(3)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
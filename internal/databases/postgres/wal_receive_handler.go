@@ -9,8 +9,10 @@ import (
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/ioextensions"
 	"github.com/wal-g/wal-g/utility"
 )
@@ -18,6 +20,8 @@ import (
 const (
 	// Sets standbyMessageTimeout in Streaming Replication Protocol.
 	StandbyMessageTimeout = time.Second * 10
+	// walReceiveReconnectInterval is the pause between the rounds of connection attempts to the candidate hosts
+	walReceiveReconnectInterval = time.Second * 5
 )
 
 /*
@@ -48,131 +52,385 @@ func (err genericWalReceiveError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
-// HandleWALReceive is invoked to receive wal with a replication connection and push
+// HandleWALReceive is invoked to receive wal with a replication connection and push.
+// When the connection is lost, the primary is looked for among the candidate hosts
+// and the streaming continues from the last received segment on the timeline of the new primary.
 func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
-	// Connect to postgres.
-	var XLogPos pglogrepl.LSN
-	var segment *WalSegment
-
 	uploader.ChangeDirectory(utility.WalPath)
 
-	slot, walSegmentBytes, err := getCurrentWalInfo()
+	hosts, err := GetWalReceiveHosts()
+	tracelog.ErrorLogger.FatalOnError(err)
+	failoverTimeout, err := conf.GetDurationSetting(conf.PgWalReceiveFailoverTimeout)
 	tracelog.ErrorLogger.FatalOnError(err)
+
+	receiver := &walReceiver{uploader: uploader, hosts: hosts, slotName: internal.GetPgSlotName()}
+	for {
+		err = receiver.connect(ctx, failoverTimeout)
+		tracelog.ErrorLogger.FatalOnError(err)
+		err = receiver.receive(ctx)
+		receiver.close()
+		tracelog.WarningLogger.Printf("WAL streaming from %s is interrupted: %v", receiver.host, err)
+	}
+}
+
+// The walReceiver keeps the streaming position between the connections to the candidate hosts
+type walReceiver struct {
+	uploader *WalUploader
+	hosts    []WalReceiveHost
+	slotName string
+
+	host             WalReceiveHost
+	conn             *pgconn.PgConn
+	systemIdentifier string
+	walSegmentBytes  uint64
+	// timeline and position are where the next segment is streamed from
+	timeline uint32
+	position pglogrepl.LSN
+	// fallbackTimeline and fallbackPosition are where the streaming restarts if the server has removed
+	// the WAL at position
+	fallbackTimeline uint32
+	fallbackPosition pglogrepl.LSN
+	// history is the latest timeline history of the server, nil on the first timeline
+	history *TimeLineHistFile
+}
+
+// walReceiveServer is the part of the replication protocol the walReceiver uses to find where to stream from
+type walReceiveServer interface {
+	IdentifySystem(ctx context.Context) (pglogrepl.IdentifySystemResult, error)
+	CreatePhysicalSlot(ctx context.Context, slotName string) error
+	AdvanceSlot(slotName string, lsn pglogrepl.LSN) error
+	TimelineHistory(ctx context.Context, timeline uint32) (pglogrepl.TimelineHistoryResult, error)
+}
+
+// replicationServer is the walReceiveServer of the replication connection, the slot is advanced
+// with the regular connection
+type replicationServer struct {
+	conn      *pgconn.PgConn
+	queryConn *pgx.Conn
+}
+
+func (server replicationServer) IdentifySystem(ctx context.Context) (pglogrepl.IdentifySystemResult, error) {
+	return pglogrepl.IdentifySystem(ctx, server.conn)
+}
+
+func (server replicationServer) CreatePhysicalSlot(ctx context.Context, slotName string) error {
+	_, err := pglogrepl.CreateReplicationSlot(ctx, server.conn, slotName, "",
+		pglogrepl.CreateReplicationSlotOptions{Mode: pglogrepl.PhysicalReplication})
+	return err
+}
+
+func (server replicationServer) AdvanceSlot(slotName string, lsn pglogrepl.LSN) error {
+	_, err := server.queryConn.Exec("select pg_replication_slot_advance($1, $2::pg_lsn)", slotName, lsn.String())
+	return err
+}
+
+func (server replicationServer) TimelineHistory(ctx context.Context,
+	timeline uint32) (pglogrepl.TimelineHistoryResult, error) {
+	return pglogrepl.TimelineHistory(ctx, server.conn, int32(timeline))
+}
+
+// connect looks for the server to stream from until the timeout expires,
+// the hosts in recovery are skipped if there are several candidates
+func (r *walReceiver) connect(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, host := range r.hosts {
+			err := r.connectToHost(ctx, host)
+			if err == nil {
+				return nil
+			}
+			tracelog.WarningLogger.Printf("Failed to start WAL streaming from %s: %v", host, err)
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("no server to stream WAL from is found among %v within %s", r.hosts, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(walReceiveReconnectInterval):
+		}
+	}
+}
+
+func (r *walReceiver) connectToHost(ctx context.Context, host WalReceiveHost) error {
+	queryConn, err := connectToWalReceiveHost(host)
+	if err != nil {
+		return err
+	}
+	defer queryConn.Close()
+
+	if len(r.hosts) > 1 {
+		var standby bool
+		err = queryConn.QueryRow("select pg_is_in_recovery()").Scan(&standby)
+		if err != nil {
+			return err
+		}
+		if standby {
+			return errors.New("server is in recovery")
+		}
+	}
+	queryRunner, err := NewPgQueryRunner(queryConn)
+	if err != nil {
+		return err
+	}
+	slot, err := queryRunner.GetPhysicalSlotInfo(r.slotName)
+	if err != nil {
+		return err
+	}
+	walSegmentBytes, err := queryRunner.GetWalSegmentBytes()
+	if err != nil {
+		return err
+	}
+	if r.walSegmentBytes != 0 && r.walSegmentBytes != walSegmentBytes {
+		return errors.Errorf("WAL segment size %d differs from %d", walSegmentBytes, r.walSegmentBytes)
+	}
 	tracelog.DebugLogger.Printf("WAL segment bytes: %d", walSegmentBytes)
 
-	conn, err := pgconn.Connect(context.Background(), "replication=yes")
-	tracelog.ErrorLogger.FatalOnError(err)
-	defer conn.Close(context.Background())
+	conn, err := pgconn.Connect(ctx, replicationConnString(host))
+	if err != nil {
+		return err
+	}
+	err = r.prepareStreaming(ctx, replicationServer{conn: conn, queryConn: queryConn}, slot, walSegmentBytes)
+	if err != nil {
+		conn.Close(context.Background())
+		return err
+	}
+	r.host = host
+	r.conn = conn
+	tracelog.InfoLogger.Printf("Streaming WAL from %s on timeline %d from %s", host, r.timeline, r.position)
+	return nil
+}
 
-	sysident, err := pglogrepl.IdentifySystem(context.Background(), conn)
-	tracelog.ErrorLogger.FatalOnError(err)
+// prepareStreaming creates or advances the slot and finds the position and the timeline to stream from
+func (r *walReceiver) prepareStreaming(ctx context.Context, server walReceiveServer, slot PhysicalSlot,
+	walSegmentBytes uint64) error {
+	sysident, err := server.IdentifySystem(ctx)
+	if err != nil {
+		return err
+	}
+	if r.systemIdentifier != "" && r.systemIdentifier != sysident.SystemID {
+		return errors.Errorf("system identifier %s differs from %s", sysident.SystemID, r.systemIdentifier)
+	}
 
-	if slot.Exists {
-		XLogPos = slot.RestartLSN
-	} else {
+	if !slot.Exists {
 		tracelog.InfoLogger.Println("Trying to create the replication slot")
-		_, err = pglogrepl.CreateReplicationSlot(context.Background(), conn, slot.Name, "",
-			pglogrepl.CreateReplicationSlotOptions{Mode: pglogrepl.PhysicalReplication})
-		tracelog.ErrorLogger.FatalOnError(err)
-		XLogPos = sysident.XLogPos
+		err = server.CreatePhysicalSlot(ctx, slot.Name)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Get timeline for XLogPos from historyfile with helper function
-	timeline, err := getStartTimeline(ctx, conn, uploader, uint32(sysident.Timeline), XLogPos)
-	tracelog.ErrorLogger.FatalOnError(err)
+	// the position taken from the server is on its timeline, only the streamed one may have diverged from it
+	position, positionTimeline := r.position, r.timeline
+	if position == 0 {
+		position, positionTimeline = sysident.XLogPos, 0
+		if slot.Exists {
+			position = slot.RestartLSN
+		}
+	}
+	fallbackPosition := sysident.XLogPos
+	if slot.Exists && slot.RestartLSN > position {
+		fallbackPosition = slot.RestartLSN
+	}
+
+	serverTimeline := uint32(sysident.Timeline)
+	timeline, position, history, err := r.followTimeline(ctx, server, serverTimeline, position, positionTimeline,
+		walSegmentBytes)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		history = r.history
+	}
+	fallbackTimeline, err := timelineOfSegment(history, serverTimeline, fallbackPosition, walSegmentBytes)
+	if err != nil {
+		return err
+	}
+	if slot.Exists && r.position != 0 && slot.RestartLSN < position {
+		// the slot follows the archive, so the server may remove the WAL already streamed from the old primary.
+		// The slot is advanced only to the resolved position, the WAL after the switch point is streamed again.
+		err = server.AdvanceSlot(slot.Name, position)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to advance the replication slot %s: %v", slot.Name, err)
+		}
+	}
+	r.systemIdentifier = sysident.SystemID
+	r.walSegmentBytes = walSegmentBytes
+	r.timeline = timeline
+	r.position = position
+	r.fallbackTimeline = fallbackTimeline
+	r.fallbackPosition = fallbackPosition
+	r.history = history
+	return nil
+}
+
+// followTimeline uploads the history files of the timelines appeared since the last connection and returns
+// the timeline of the position with the latest history, nil if no history is uploaded. The position streamed
+// on positionTimeline moves back to the switch point if the WAL streamed from the old primary has diverged
+// from the timeline of the new one. positionTimeline is 0 if the position is taken from the server.
+func (r *walReceiver) followTimeline(ctx context.Context, server walReceiveServer, serverTimeline uint32,
+	position pglogrepl.LSN, positionTimeline uint32,
+	walSegmentBytes uint64) (uint32, pglogrepl.LSN, *TimeLineHistFile, error) {
+	if serverTimeline < 2 {
+		return 1, position, nil, nil
+	}
+	if r.timeline == serverTimeline {
+		return r.timeline, position, nil, nil
+	}
+	firstTimeline := r.timeline + 1
+	if r.timeline == 0 {
+		firstTimeline = serverTimeline
+	}
+	var history *TimeLineHistFile
+	for timeline := firstTimeline; timeline <= serverTimeline; timeline++ {
+		tlh, err := r.uploadTimelineHistory(ctx, server, timeline)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		history = tlh
+	}
+	if history == nil {
+		return serverTimeline, position, nil, nil
+	}
 
-	segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
-	startReplication(conn, segment, slot.Name)
+	if positionTimeline != 0 && positionTimeline != serverTimeline {
+		switchPoint, ok, err := history.SwitchPoint(positionTimeline)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if ok && position > switchPoint {
+			tracelog.WarningLogger.Printf("WAL of timeline %d after %s has diverged from timeline %d, "+
+				"streaming from the switch point", positionTimeline, switchPoint, serverTimeline)
+			position = switchPoint
+		}
+	}
+	timeline, err := timelineOfSegment(history, serverTimeline, position, walSegmentBytes)
+	return timeline, position, history, err
+}
+
+// timelineOfSegment finds the timeline the segment of the position is streamed from: the segment with
+// the switch point is streamed on the old timeline up to the switch
+func timelineOfSegment(history *TimeLineHistFile, serverTimeline uint32, position pglogrepl.LSN,
+	walSegmentBytes uint64) (uint32, error) {
+	if history == nil {
+		return serverTimeline, nil
+	}
+	return history.LSNToTimeLine(pglogrepl.LSN(uint64(position) / walSegmentBytes * walSegmentBytes))
+}
+
+// uploadTimelineHistory uploads the history file of the timeline, nil is returned if the server has no such file
+func (r *walReceiver) uploadTimelineHistory(ctx context.Context, server walReceiveServer,
+	timeline uint32) (*TimeLineHistFile, error) {
+	timelinehistfile, err := server.TimelineHistory(ctx, timeline)
+	if isWalRemovedError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tlh, err := NewTimeLineHistFile(timeline, timelinehistfile.FileName, timelinehistfile.Content)
+	if err != nil {
+		return nil, err
+	}
+	err = r.uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(tlh, tlh.Name()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to upload %s", tlh.Name())
+	}
+	err = uploadRemoteWalMetadata(ctx, tlh.Name(), r.uploader.Uploader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to upload metadata of %s", tlh.Name())
+	}
+	return &tlh, nil
+}
+
+// receive streams the WAL until the connection fails, the upload failures are fatal
+func (r *walReceiver) receive(ctx context.Context) error {
+	segment := NewWalSegment(r.timeline, r.position, r.walSegmentBytes)
+	err := startReplication(r.conn, segment, r.slotName)
+	if err != nil {
+		return r.handleStreamError(err)
+	}
 	for {
-		streamResult, err := segment.Stream(conn, StandbyMessageTimeout)
-		tracelog.ErrorLogger.FatalOnError(err)
+		streamResult, err := segment.Stream(r.conn, StandbyMessageTimeout)
+		if err != nil {
+			return r.handleStreamError(err)
+		}
 		tracelog.DebugLogger.Printf("Successfully received wal segment %s: ", segment.Name())
 
 		switch streamResult {
 		case ProcessMessageOK:
 			// segment is a regular segemnt. Write, and create a new for this timeline.
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
+			err = r.uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
 			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
+			err = uploadRemoteWalMetadata(ctx, segment.Name(), r.uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
-			XLogPos = segment.endLSN
+			r.position = segment.endLSN
 			segment, err = segment.NextWalSegment()
 			tracelog.ErrorLogger.FatalOnError(err)
 		case ProcessMessageCopyDone:
 			// segment is a partial. Write, and create a new for the next timeline.
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
-			timeline++
-			timelinehistfile, err := pglogrepl.TimelineHistory(context.Background(), conn, int32(timeline))
+			err = r.uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(segment, segment.Name()))
 			tracelog.ErrorLogger.FatalOnError(err)
-			tlh, err := NewTimeLineHistFile(timeline, timelinehistfile.FileName, timelinehistfile.Content)
+			err = uploadRemoteWalMetadata(ctx, segment.Name(), r.uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(tlh, tlh.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(ctx, tlh.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
-			segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
-			startReplication(conn, segment, slot.Name)
+			nextTimeline := segment.nextTimeLine
+			if nextTimeline == 0 {
+				nextTimeline = r.timeline + 1
+			}
+			history, err := r.uploadTimelineHistory(ctx, replicationServer{conn: r.conn}, nextTimeline)
+			if err != nil {
+				return err
+			}
+			if history != nil {
+				r.history = history
+			}
+			r.timeline = nextTimeline
+			segment = NewWalSegment(r.timeline, r.position, r.walSegmentBytes)
+			err = startReplication(r.conn, segment, r.slotName)
+			if err != nil {
+				return r.handleStreamError(err)
+			}
 		default:
 			tracelog.ErrorLogger.FatalOnError(errors.Errorf("Unexpected result from WalSegment.Stream() %v", streamResult))
 		}
 	}
 }
 
-func getStartTimeline(ctx context.Context,
-	conn *pgconn.PgConn,
-	uploader *WalUploader,
-	systemTimeline uint32,
-	xLogPos pglogrepl.LSN) (uint32, error) {
-	if systemTimeline < 2 {
-		return 1, nil
+// handleStreamError reports the gap and moves the position forward if the server has already removed the WAL
+func (r *walReceiver) handleStreamError(err error) error {
+	if !isWalRemovedError(err) {
+		return err
 	}
-	timelinehistfile, err := pglogrepl.TimelineHistory(context.Background(), conn, int32(systemTimeline))
-	if err == nil {
-		tlh, err := NewTimeLineHistFile(systemTimeline, timelinehistfile.FileName, timelinehistfile.Content)
-		tracelog.ErrorLogger.FatalOnError(err)
-		err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(tlh, tlh.Name()))
-		tracelog.ErrorLogger.FatalOnError(err)
-		return tlh.LSNToTimeLine(xLogPos)
+	if r.fallbackPosition <= r.position {
+		tracelog.ErrorLogger.Fatalf("WAL from %s is removed on %s: %v", r.position, r.host, err)
 	}
-	if pgErr, ok := err.(*pgconn.PgError); ok {
-		if pgErr.Code == "58P01" {
-			return systemTimeline, nil
-		}
+	gaps, gapErr := NewWalReceiveGaps(r.history, r.timeline, r.position, r.fallbackPosition, r.walSegmentBytes)
+	if gapErr != nil {
+		return gapErr
+	}
+	for _, gap := range gaps {
+		tracelog.ErrorLogger.Printf("WAL gap: the segments %s are removed on %s before being archived, "+
+			"the streaming continues after the gap", gap, r.host)
+	}
+	r.timeline = r.fallbackTimeline
+	r.position = r.fallbackPosition
+	return err
+}
+
+func (r *walReceiver) close() {
+	if r.conn != nil {
+		r.conn.Close(context.Background())
+		r.conn = nil
 	}
-	return 0, nil
 }
 
-func startReplication(conn *pgconn.PgConn, segment *WalSegment, slotName string) {
+func startReplication(conn *pgconn.PgConn, segment *WalSegment, slotName string) error {
 	tracelog.DebugLogger.Printf("Starting replication from %s: ", segment.StartLSN)
 	err := pglogrepl.StartReplication(context.Background(), conn, slotName, segment.StartLSN,
 		pglogrepl.StartReplicationOptions{Timeline: int32(segment.TimeLine), Mode: pglogrepl.PhysicalReplication})
-	tracelog.ErrorLogger.FatalOnError(err)
-	tracelog.DebugLogger.Println("Started replication")
-}
-
-func getCurrentWalInfo() (slot PhysicalSlot, walSegmentBytes uint64, err error) {
-	slotName := internal.GetPgSlotName()
-
-	// Creating a temporary connection to read slot info and wal_segment_size
-	tmpConn, err := Connect()
 	if err != nil {
-		return
+		return err
 	}
-	defer tmpConn.Close()
-
-	queryRunner, err := NewPgQueryRunner(tmpConn)
-	if err != nil {
-		return
-	}
-
-	slot, err = queryRunner.GetPhysicalSlotInfo(slotName)
-	if err != nil {
-		return
-	}
-
-	walSegmentBytes, err = queryRunner.GetWalSegmentBytes()
-	return
+	tracelog.DebugLogger.Println("Started replication")
+	return nil
 }
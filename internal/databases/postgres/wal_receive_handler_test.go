@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pglogrepl"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	conf "github.com/wal-g/wal-g/internal/config"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+const walReceiveTestSegmentBytes = 0x1000000
+
+// the timeline 1 switches to 2 in the middle of segment 5, and the timeline 2 switches to 3 in segment 7
+var walReceiveTestHistories = map[uint32]string{
+	2: "1\t0/5800000\tno recovery target specified\n",
+	3: "1\t0/5800000\tno recovery target specified\n\n2\t0/7400000\tno recovery target specified\n",
+}
+
+// fakeWalReceiveServer serves the timeline histories and records the slot changes
+type fakeWalReceiveServer struct {
+	sysident  pglogrepl.IdentifySystemResult
+	histories map[uint32]string
+	created   []string
+	advanced  []pglogrepl.LSN
+}
+
+func (server *fakeWalReceiveServer) IdentifySystem(context.Context) (pglogrepl.IdentifySystemResult, error) {
+	return server.sysident, nil
+}
+
+func (server *fakeWalReceiveServer) CreatePhysicalSlot(_ context.Context, slotName string) error {
+	server.created = append(server.created, slotName)
+	return nil
+}
+
+func (server *fakeWalReceiveServer) AdvanceSlot(_ string, lsn pglogrepl.LSN) error {
+	server.advanced = append(server.advanced, lsn)
+	return nil
+}
+
+func (server *fakeWalReceiveServer) TimelineHistory(_ context.Context,
+	timeline uint32) (pglogrepl.TimelineHistoryResult, error) {
+	content, ok := server.histories[timeline]
+	if !ok {
+		return pglogrepl.TimelineHistoryResult{}, &pgconn.PgError{Code: "58P01"}
+	}
+	return pglogrepl.TimelineHistoryResult{FileName: fmt.Sprintf("%08X.history", timeline), Content: []byte(content)}, nil
+}
+
+func newWalReceiveTestReceiver(t *testing.T, timeline uint32, position pglogrepl.LSN) (*walReceiver, *memory.Folder) {
+	viper.Set(conf.UploadWalMetadata, WalNoMetadataLevel)
+	t.Cleanup(func() { viper.Set(conf.UploadWalMetadata, nil) })
+	folder := memory.NewFolder("", memory.NewKVS())
+	uploader := NewWalUploader(internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder), nil)
+	return &walReceiver{uploader: uploader, slotName: "walg", timeline: timeline, position: position,
+		walSegmentBytes: walReceiveTestSegmentBytes}, folder
+}
+
+func walReceiveTestHistory(t *testing.T, timeline uint32) *TimeLineHistFile {
+	history, err := NewTimeLineHistFile(timeline, fmt.Sprintf("%08X.history", timeline),
+		[]byte(walReceiveTestHistories[timeline]))
+	require.NoError(t, err)
+	return &history
+}
+
+func TestWalReceiver_FollowTimeline(t *testing.T) {
+	tests := []struct {
+		name             string
+		timeline         uint32
+		serverTimeline   uint32
+		position         pglogrepl.LSN
+		positionTimeline uint32
+		histories        map[uint32]string
+		wantTimeline     uint32
+		wantPosition     pglogrepl.LSN
+		wantUploaded     []string
+	}{
+		{
+			name: "same timeline", timeline: 2, serverTimeline: 2, position: 0x6000000, positionTimeline: 2,
+			histories: walReceiveTestHistories, wantTimeline: 2, wantPosition: 0x6000000,
+		},
+		{
+			name: "first connection, the slot is on the old timeline", serverTimeline: 2, position: 0x3000000,
+			histories: walReceiveTestHistories, wantTimeline: 1, wantPosition: 0x3000000,
+			wantUploaded: []string{"00000002.history"},
+		},
+		{
+			name: "failover after the switch point", timeline: 1, serverTimeline: 2, position: 0x6000000,
+			positionTimeline: 1, histories: walReceiveTestHistories, wantTimeline: 1, wantPosition: 0x5800000,
+			wantUploaded: []string{"00000002.history"},
+		},
+		{
+			name: "failover before the switch point", timeline: 1, serverTimeline: 2, position: 0x4000000,
+			positionTimeline: 1, histories: walReceiveTestHistories, wantTimeline: 1, wantPosition: 0x4000000,
+			wantUploaded: []string{"00000002.history"},
+		},
+		{
+			name: "position taken from the new primary isn't rewound", timeline: 1, serverTimeline: 2,
+			position: 0x6000000, histories: walReceiveTestHistories, wantTimeline: 2, wantPosition: 0x6000000,
+			wantUploaded: []string{"00000002.history"},
+		},
+		{
+			name: "two switches", timeline: 1, serverTimeline: 3, position: 0x7800000, positionTimeline: 1,
+			histories: walReceiveTestHistories, wantTimeline: 1, wantPosition: 0x5800000,
+			wantUploaded: []string{"00000002.history", "00000003.history"},
+		},
+		{
+			name: "no history on the server", timeline: 1, serverTimeline: 2, position: 0x6000000, positionTimeline: 1,
+			wantTimeline: 2, wantPosition: 0x6000000,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver, folder := newWalReceiveTestReceiver(t, test.timeline, test.position)
+			server := &fakeWalReceiveServer{histories: test.histories}
+
+			timeline, position, _, err := receiver.followTimeline(context.Background(), server, test.serverTimeline,
+				test.position, test.positionTimeline, walReceiveTestSegmentBytes)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantTimeline, timeline)
+			assert.Equal(t, test.wantPosition, position)
+			for _, name := range test.wantUploaded {
+				exists, err := folder.Exists(name + "." + lz4.FileExtension)
+				require.NoError(t, err)
+				assert.True(t, exists, name)
+			}
+		})
+	}
+}
+
+func TestWalReceiver_PrepareStreaming(t *testing.T) {
+	tests := []struct {
+		name                 string
+		timeline             uint32
+		position             pglogrepl.LSN
+		slot                 PhysicalSlot
+		serverTimeline       int32
+		serverPosition       pglogrepl.LSN
+		wantTimeline         uint32
+		wantPosition         pglogrepl.LSN
+		wantFallbackTimeline uint32
+		wantFallbackPosition pglogrepl.LSN
+		wantAdvanced         []pglogrepl.LSN
+		wantCreated          []string
+	}{
+		{
+			name: "first connection", slot: PhysicalSlot{Name: "walg"}, serverTimeline: 2, serverPosition: 0x9000000,
+			wantTimeline: 2, wantPosition: 0x9000000, wantFallbackTimeline: 2, wantFallbackPosition: 0x9000000,
+			wantCreated: []string{"walg"},
+		},
+		{
+			name: "failover", timeline: 1, position: 0x6000000,
+			slot:           PhysicalSlot{Name: "walg", Exists: true, RestartLSN: 0x2000000},
+			serverTimeline: 2, serverPosition: 0x9000000, wantTimeline: 1, wantPosition: 0x5800000,
+			wantFallbackTimeline: 2, wantFallbackPosition: 0x9000000, wantAdvanced: []pglogrepl.LSN{0x5800000},
+		},
+		{
+			name: "failover with a gap", timeline: 1, position: 0x4000000,
+			slot:           PhysicalSlot{Name: "walg", Exists: true, RestartLSN: 0x6800000},
+			serverTimeline: 2, serverPosition: 0x9000000, wantTimeline: 1, wantPosition: 0x4000000,
+			wantFallbackTimeline: 2, wantFallbackPosition: 0x6800000,
+		},
+		{
+			name: "gap crossing a switch", timeline: 1, position: 0x4000000,
+			slot:           PhysicalSlot{Name: "walg", Exists: true, RestartLSN: 0x5400000},
+			serverTimeline: 3, serverPosition: 0x9000000, wantTimeline: 1, wantPosition: 0x4000000,
+			wantFallbackTimeline: 1, wantFallbackPosition: 0x5400000,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver, _ := newWalReceiveTestReceiver(t, test.timeline, test.position)
+			server := &fakeWalReceiveServer{
+				sysident:  pglogrepl.IdentifySystemResult{SystemID: "1", Timeline: test.serverTimeline, XLogPos: test.serverPosition},
+				histories: walReceiveTestHistories,
+			}
+
+			err := receiver.prepareStreaming(context.Background(), server, test.slot, walReceiveTestSegmentBytes)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantTimeline, receiver.timeline)
+			assert.Equal(t, test.wantPosition, receiver.position)
+			assert.Equal(t, test.wantFallbackTimeline, receiver.fallbackTimeline)
+			assert.Equal(t, test.wantFallbackPosition, receiver.fallbackPosition)
+			assert.Equal(t, test.wantAdvanced, server.advanced)
+			assert.Equal(t, test.wantCreated, server.created)
+		})
+	}
+}
+
+func TestWalReceiver_HandleStreamError(t *testing.T) {
+	tests := []struct {
+		name             string
+		historyTimeline  uint32
+		timeline         uint32
+		position         pglogrepl.LSN
+		fallbackTimeline uint32
+		fallbackPosition pglogrepl.LSN
+		wantGaps         []string
+	}{
+		{
+			name: "gap on the timeline", historyTimeline: 2, timeline: 2, position: 0x6000000,
+			fallbackTimeline: 2, fallbackPosition: 0x8800000,
+			wantGaps: []string{"000000020000000000000006 - 000000020000000000000007 (0/6000000 - 0/8000000)"},
+		},
+		{
+			name: "failover with a gap", historyTimeline: 2, timeline: 1, position: 0x4000000,
+			fallbackTimeline: 2, fallbackPosition: 0x6800000,
+			wantGaps: []string{
+				"000000010000000000000004 - 000000010000000000000004 (0/4000000 - 0/5000000)",
+				"000000020000000000000005 - 000000020000000000000005 (0/5000000 - 0/6000000)",
+			},
+		},
+		{
+			name: "gap crossing two switches", historyTimeline: 3, timeline: 1, position: 0x4000000,
+			fallbackTimeline: 3, fallbackPosition: 0x8800000,
+			wantGaps: []string{
+				"000000010000000000000004 - 000000010000000000000004 (0/4000000 - 0/5000000)",
+				"000000020000000000000005 - 000000020000000000000006 (0/5000000 - 0/7000000)",
+				"000000030000000000000007 - 000000030000000000000007 (0/7000000 - 0/8000000)",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver, _ := newWalReceiveTestReceiver(t, test.timeline, test.position)
+			receiver.history = walReceiveTestHistory(t, test.historyTimeline)
+			receiver.fallbackTimeline = test.fallbackTimeline
+			receiver.fallbackPosition = test.fallbackPosition
+
+			gaps, err := NewWalReceiveGaps(receiver.history, receiver.timeline, receiver.position,
+				receiver.fallbackPosition, walReceiveTestSegmentBytes)
+			require.NoError(t, err)
+			gapNames := make([]string, 0, len(gaps))
+			for _, gap := range gaps {
+				gapNames = append(gapNames, gap.String())
+			}
+			assert.Equal(t, test.wantGaps, gapNames)
+
+			streamErr := &pgconn.PgError{Code: "58P01"}
+			assert.Equal(t, streamErr, receiver.handleStreamError(streamErr))
+			assert.Equal(t, test.fallbackTimeline, receiver.timeline)
+			assert.Equal(t, test.fallbackPosition, receiver.position)
+		})
+	}
+}
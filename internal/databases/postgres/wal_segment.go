@@ -32,6 +32,8 @@ type WalSegment struct {
 	readIndex       int
 	writeIndex      int
 	lastMsg         *pgproto3.BackendMessage
+	// nextTimeLine is reported by Postgres when the segment ends with a timeline switch
+	nextTimeLine uint32
 }
 
 // The ProcessMessageResult is an enum representing possible results from the methods
@@ -152,6 +154,8 @@ func (seg *WalSegment) processMessage(message pgproto3.BackendMessage) (ProcessM
 		}
 	case *pgproto3.CopyDone:
 		return ProcessMessageCopyDone, nil
+	case *pgproto3.ErrorResponse:
+		return ProcessMessageUnknown, pgconn.ErrorResponseToPgError(msg)
 	default:
 		return ProcessMessageUnknown, segmentError{errors.Errorf("Received unexpected message: %#v\n", msg)}
 	}
@@ -171,7 +175,9 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 			err = pglogrepl.SendStandbyStatusUpdate(context.Background(),
 				conn,
 				pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN})
-			tracelog.ErrorLogger.FatalOnError(err)
+			if err != nil {
+				return ProcessMessageUnknown, err
+			}
 			tracelog.DebugLogger.Println("Sent Standby status message")
 			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
 		}
@@ -182,7 +188,10 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 		if pgconn.Timeout(err) {
 			continue
 		}
-		tracelog.ErrorLogger.FatalOnError(err)
+		if err != nil {
+			// the connection is lost, the caller may reconnect and stream this segment again
+			return ProcessMessageUnknown, err
+		}
 
 		result, err := seg.processMessage(msg)
 		switch result {
@@ -196,6 +205,9 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 			cdr, err := pglogrepl.SendStandbyCopyDone(context.Background(), conn)
 			tracelog.ErrorLogger.FatalOnError(err)
 			tracelog.DebugLogger.Printf("CopyDoneResult => %v", cdr)
+			if cdr != nil {
+				seg.nextTimeLine = uint32(cdr.Timeline)
+			}
 			return result, nil
 		case ProcessMessageReplyRequested:
 			if seg.isComplete() {
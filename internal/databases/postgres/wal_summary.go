@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/walparser"
+)
+
+const (
+	// walSummariesMinVersion is the first version with the WAL summarizer
+	walSummariesMinVersion = 170000
+	// walSummarizationTimeout limits the wait for the WAL summarizer to reach the backup start
+	walSummarizationTimeout      = time.Minute
+	walSummarizationPollInterval = 100 * time.Millisecond
+	// mainForkNumber is the fork of the relation data, other forks aren't backed up incrementally
+	mainForkNumber = 0
+)
+
+// WalSummary is the range of WAL summarized by the server, see pg_available_wal_summaries()
+type WalSummary struct {
+	Timeline uint32
+	StartLSN LSN
+	EndLSN   LSN
+}
+
+// IsWalSummarizationEnabled checks if the server keeps WAL summaries (PostgreSQL 17+ with summarize_wal)
+func (queryRunner *PgQueryRunner) IsWalSummarizationEnabled() (bool, error) {
+	if queryRunner.Version < walSummariesMinVersion {
+		return false, nil
+	}
+	value, err := queryRunner.GetParameter("summarize_wal")
+	if err != nil {
+		return false, err
+	}
+	return value == "on", nil
+}
+
+// getWalSummarizedLSN reads the position up to which the WAL is summarized
+func (queryRunner *PgQueryRunner) getWalSummarizedLSN() (timeline uint32, lsn LSN, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var tli int64
+	var lsnStr string
+	err = queryRunner.Connection.QueryRow("SELECT summarized_tli, summarized_lsn::text "+
+		"FROM pg_get_wal_summarizer_state()").Scan(&tli, &lsnStr)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "QueryRunner getWalSummarizedLSN: query failed")
+	}
+	lsn, err = ParseLSN(lsnStr)
+	return uint32(tli), lsn, err
+}
+
+// WaitForWalSummarization waits until the WAL summarizer reaches the LSN
+func (queryRunner *PgQueryRunner) WaitForWalSummarization(timeline uint32, lsn LSN, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		summarizedTimeline, summarizedLSN, err := queryRunner.getWalSummarizedLSN()
+		if err != nil {
+			return err
+		}
+		if summarizedTimeline > timeline || summarizedTimeline == timeline && summarizedLSN >= lsn {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("WAL is summarized up to %s on timeline %d, but %s on timeline %d is required",
+				summarizedLSN, summarizedTimeline, lsn, timeline)
+		}
+		time.Sleep(walSummarizationPollInterval)
+	}
+}
+
+// GetWalSummaries lists the WAL summaries of the timeline that intersect with [from, to)
+func (queryRunner *PgQueryRunner) GetWalSummaries(timeline uint32, from, to LSN) ([]WalSummary, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	rows, err := queryRunner.Connection.Query("SELECT tli, start_lsn::text, end_lsn::text "+
+		"FROM pg_available_wal_summaries() "+
+		"WHERE tli = $1 AND end_lsn > $2::text::pg_lsn AND start_lsn < $3::text::pg_lsn",
+		int64(timeline), from.String(), to.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetWalSummaries: query failed")
+	}
+	defer rows.Close()
+
+	var summaries []WalSummary
+	for rows.Next() {
+		var tli int64
+		var startLSN, endLSN string
+		if err := rows.Scan(&tli, &startLSN, &endLSN); err != nil {
+			return nil, errors.Wrap(err, "QueryRunner GetWalSummaries: scan failed")
+		}
+		summary := WalSummary{Timeline: uint32(tli)}
+		if summary.StartLSN, err = ParseLSN(startLSN); err != nil {
+			return nil, err
+		}
+		if summary.EndLSN, err = ParseLSN(endLSN); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// ForEachWalSummaryBlock calls the function for every block of the relation main forks modified within the summary.
+// isLimit means that the relation was truncated to blockNo blocks, so the blocks from blockNo on may be replaced.
+func (queryRunner *PgQueryRunner) ForEachWalSummaryBlock(summary WalSummary,
+	function func(location walparser.BlockLocation, isLimit bool)) error {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	rows, err := queryRunner.Connection.Query("SELECT reltablespace::bigint, reldatabase::bigint, "+
+		"relfilenode::bigint, relblocknumber, is_limit_block "+
+		"FROM pg_wal_summary_contents($1, $2::text::pg_lsn, $3::text::pg_lsn) WHERE relforknumber = $4",
+		int64(summary.Timeline), summary.StartLSN.String(), summary.EndLSN.String(), mainForkNumber)
+	if err != nil {
+		return errors.Wrap(err, "QueryRunner ForEachWalSummaryBlock: query failed")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var spcNode, dbNode, relNode, blockNo int64
+		var isLimit bool
+		if err := rows.Scan(&spcNode, &dbNode, &relNode, &blockNo, &isLimit); err != nil {
+			return errors.Wrap(err, "QueryRunner ForEachWalSummaryBlock: scan failed")
+		}
+		function(walparser.BlockLocation{
+			RelationFileNode: walparser.RelFileNode{
+				SpcNode: walparser.Oid(spcNode),
+				DBNode:  walparser.Oid(dbNode),
+				RelNode: walparser.Oid(relNode),
+			},
+			BlockNo: uint32(blockNo),
+		}, isLimit)
+	}
+	return rows.Err()
+}
+
+// selectCoveringWalSummaries selects the fewest summaries that cover [from, to) without gaps
+func selectCoveringWalSummaries(summaries []WalSummary, from, to LSN) ([]WalSummary, error) {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].StartLSN < summaries[j].StartLSN
+	})
+	var selected []WalSummary
+	covered := from
+	for i := 0; covered < to; {
+		// pick the summary that starts within the covered range and extends it the most
+		best := -1
+		for ; i < len(summaries) && summaries[i].StartLSN <= covered; i++ {
+			if summaries[i].EndLSN > covered && (best < 0 || summaries[i].EndLSN > summaries[best].EndLSN) {
+				best = i
+			}
+		}
+		if best < 0 {
+			if i < len(summaries) {
+				return nil, fmt.Errorf("WAL summaries have a gap from %s to %s", covered, summaries[i].StartLSN)
+			}
+			return nil, fmt.Errorf("WAL summaries cover up to %s, but %s is required", covered, to)
+		}
+		selected = append(selected, summaries[best])
+		covered = summaries[best].EndLSN
+	}
+	return selected, nil
+}
+
+// addLimitBlockToDelta marks the blocks of the relation from the limit block on as changed
+func (deltaMap *PagedFileDeltaMap) addLimitBlockToDelta(location walparser.BlockLocation) {
+	bitmap, ok := (*deltaMap)[location.RelationFileNode]
+	if !ok {
+		bitmap = roaring.New()
+		(*deltaMap)[location.RelationFileNode] = bitmap
+	}
+	bitmap.AddRange(uint64(location.BlockNo), math.MaxUint32+1)
+}
+
+// getWalSummaryDeltaMap builds the delta map of the blocks changed within [firstUsedLSN, firstNotUsedLSN)
+// from the WAL summaries of the server
+func getWalSummaryDeltaMap(queryRunner *PgQueryRunner, timeline uint32,
+	firstUsedLSN, firstNotUsedLSN LSN) (PagedFileDeltaMap, error) {
+	err := queryRunner.WaitForWalSummarization(timeline, firstNotUsedLSN, walSummarizationTimeout)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := queryRunner.GetWalSummaries(timeline, firstUsedLSN, firstNotUsedLSN)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err = selectCoveringWalSummaries(summaries, firstUsedLSN, firstNotUsedLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	deltaMap := NewPagedFileDeltaMap()
+	for _, summary := range summaries {
+		err = queryRunner.ForEachWalSummaryBlock(summary, func(location walparser.BlockLocation, isLimit bool) {
+			if isLimit {
+				deltaMap.addLimitBlockToDelta(location)
+			} else {
+				deltaMap.AddLocationToDelta(location)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		tracelog.InfoLogger.Printf("Read WAL summary %s - %s on timeline %d\n",
+			summary.StartLSN, summary.EndLSN, summary.Timeline)
+	}
+	return deltaMap, nil
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/walparser"
+)
+
+func TestSelectCoveringWalSummaries(t *testing.T) {
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 300, EndLSN: 400},
+		{Timeline: 1, StartLSN: 100, EndLSN: 200},
+		{Timeline: 1, StartLSN: 200, EndLSN: 300},
+		{Timeline: 1, StartLSN: 150, EndLSN: 250},
+	}
+
+	selected, err := selectCoveringWalSummaries(summaries, 150, 350)
+	require.NoError(t, err)
+	assert.Equal(t, []WalSummary{
+		{Timeline: 1, StartLSN: 150, EndLSN: 250},
+		{Timeline: 1, StartLSN: 200, EndLSN: 300},
+		{Timeline: 1, StartLSN: 300, EndLSN: 400},
+	}, selected)
+
+	_, err = selectCoveringWalSummaries(summaries, 150, 500)
+	assert.Error(t, err, "the summaries end before the required LSN")
+
+	_, err = selectCoveringWalSummaries([]WalSummary{
+		{Timeline: 1, StartLSN: 100, EndLSN: 200},
+		{Timeline: 1, StartLSN: 250, EndLSN: 300},
+	}, 100, 300)
+	assert.Error(t, err, "the summaries have a gap")
+
+	_, err = selectCoveringWalSummaries(nil, 100, 300)
+	assert.Error(t, err)
+}
+
+func TestPagedFileDeltaMap_AddLimitBlockToDelta(t *testing.T) {
+	relFileNode := walparser.RelFileNode{SpcNode: DefaultSpcNode, DBNode: 5, RelNode: 16384}
+	deltaMap := NewPagedFileDeltaMap()
+	deltaMap.AddLocationToDelta(walparser.BlockLocation{RelationFileNode: relFileNode, BlockNo: 3})
+	deltaMap.addLimitBlockToDelta(walparser.BlockLocation{RelationFileNode: relFileNode, BlockNo: uint32(BlocksInRelFile) + 10})
+
+	bitmap, err := deltaMap.GetDeltaBitmapFor("base/5/16384")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{3}, bitmap.ToArray())
+
+	bitmap, err = deltaMap.GetDeltaBitmapFor("base/5/16384.1")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(BlocksInRelFile-10), bitmap.GetCardinality())
+	assert.Equal(t, uint32(10), bitmap.Minimum())
+
+	bitmap, err = deltaMap.GetDeltaBitmapFor("base/5/16384.2")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(BlocksInRelFile), bitmap.GetCardinality())
+}
@@ -6,9 +6,11 @@ import (
 	"io"
 	"path"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/asm"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
@@ -74,17 +76,25 @@ func (walUploader *WalUploader) FlushFiles(ctx context.Context) {
 }
 
 func PrepareMultiStorageWalUploader(folder storage.Folder, targetStorage string) (*WalUploader, error) {
-	folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
+	putQuorum := viper.GetInt(conf.PgFailoverStoragesPutQuorum)
 	var err error
-	if targetStorage == "" {
-		folder, err = multistorage.UseFirstAliveStorage(folder)
-	} else {
+	switch {
+	case targetStorage != "":
+		folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
 		folder, err = multistorage.UseSpecificStorage(targetStorage, folder)
+	case putQuorum > 0:
+		quorumPolicies := policies.QuorumWrite
+		quorumPolicies.PutQuorum = putQuorum
+		folder = multistorage.SetPolicies(folder, quorumPolicies)
+		folder, err = multistorage.UseAllAliveStorages(folder)
+	default:
+		folder = multistorage.SetPolicies(folder, policies.TakeFirstStorage)
+		folder, err = multistorage.UseFirstAliveStorage(folder)
 	}
 	if err != nil {
 		return nil, err
 	}
-	tracelog.InfoLogger.Printf("Files will be uploaded to storage: %v", multistorage.UsedStorages(folder)[0])
+	tracelog.InfoLogger.Printf("Files will be uploaded to storages: %v", multistorage.UsedStorages(folder))
 
 	baseUploader, err := internal.ConfigureUploaderToFolder(folder)
 	if err != nil {
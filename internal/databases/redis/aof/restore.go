@@ -6,6 +6,7 @@ import (
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/redis/archive"
 )
 
@@ -39,6 +40,10 @@ func (restoreService *RestoreService) DoRestore(args RestoreArgs) error {
 	if err != nil {
 		return err
 	}
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
 
 	if !args.SkipChecks {
 		ok, err := archive.EnsureRestoreCompatibility(sentinel.Version, args.RestoreVersion)
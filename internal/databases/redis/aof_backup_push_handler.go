@@ -35,7 +35,8 @@ func HandleAOFBackupPush(ctx context.Context, permanent bool, appName string) er
 	processName, _ := conf.GetSetting(conf.RedisServerProcessName)
 	versionParser := archive.NewVersionParser(processName)
 
-	metaConstructor := archive.NewBackupRedisMetaConstructor(ctx, uploader.Folder(), permanent, archive.AOFBackupType, versionParser)
+	metaConstructor := archive.NewBackupRedisMetaConstructor(ctx, uploader.Folder(), permanent, archive.AOFBackupType, versionParser,
+		uploader.Crypter())
 
 	dataPath, _ := conf.GetSetting(conf.RedisDataPath)
 	diskWatcher, err := diskwatcher.NewDiskWatcher(viper.GetInt(conf.RedisDataThreshold), dataPath, viper.GetInt(conf.RedisDataTimeout))
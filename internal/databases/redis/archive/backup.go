@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/printlist"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -29,6 +30,8 @@ type Backup struct {
 	BackupSize      int64       `json:"BackupSize,omitempty"`
 	BackupType      string      `json:"BackupType,omitempty"`
 	Version         string      `json:"Version,omitempty"`
+
+	crypto.EncryptionInfo
 }
 
 func (b Backup) Name() string {
@@ -161,6 +164,7 @@ type RedisMetaConstructor struct {
 	permanent     bool
 	backupType    string
 	versionParser *VersionParser
+	crypter       crypto.Crypter
 }
 
 // Init - required for internal.MetaConstructor
@@ -193,6 +197,7 @@ func (m *RedisMetaConstructor) MetaInfo() interface{} {
 		FinishLocalTime: meta.FinishTime,
 		BackupType:      meta.BackupType,
 		Version:         meta.Version,
+		EncryptionInfo:  crypto.GetEncryptionInfo(m.crypter),
 	}
 }
 
@@ -202,6 +207,7 @@ func (m *RedisMetaConstructor) Finalize(backupName string) error {
 }
 
 func NewBackupRedisMetaConstructor(ctx context.Context, folder storage.Folder, permanent bool, backupType string,
-	versionParser *VersionParser) internal.MetaConstructor {
-	return &RedisMetaConstructor{ctx: ctx, folder: folder, permanent: permanent, backupType: backupType, versionParser: versionParser}
+	versionParser *VersionParser, crypter crypto.Crypter) internal.MetaConstructor {
+	return &RedisMetaConstructor{ctx: ctx, folder: folder, permanent: permanent, backupType: backupType,
+		versionParser: versionParser, crypter: crypter}
 }
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"strings"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/storagetools"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// NewBackupUsageAttributor makes the attributor of the storage objects for the backup-usage report, it marks
+// permanent backups and their type
+func NewBackupUsageAttributor(folder storage.Folder, objects []storage.Object) (storagetools.Attributor, error) {
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	return storagetools.NewBackupDetailsAttributor(objects, func(backupName string) (string, error) {
+		sentinel, err := GetBackupDetails(backupFolder, internal.BackupTime{BackupName: backupName})
+		if err != nil {
+			return "", err
+		}
+		var details []string
+		if sentinel.Permanent {
+			details = append(details, "permanent")
+		}
+		if sentinel.BackupType != "" {
+			details = append(details, sentinel.BackupType)
+		}
+		return strings.Join(details, ", "), nil
+	})
+}
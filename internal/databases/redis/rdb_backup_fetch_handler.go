@@ -5,6 +5,7 @@ import (
 	"os/exec"
 
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/databases/redis/archive"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -16,5 +17,15 @@ func HandleBackupFetch(ctx context.Context, folder storage.Folder, backupName st
 		return err
 	}
 
+	var sentinel archive.Backup
+	err = backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return err
+	}
+	err = crypto.CheckEncryptionInfo(sentinel.EncryptionInfo, internal.ConfigureCrypter())
+	if err != nil {
+		return err
+	}
+
 	return internal.StreamBackupToCommandStdin(restoreCmd, backup)
 }
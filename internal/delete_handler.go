@@ -77,6 +77,14 @@ func IsPermanentFunc(isPermanent func(storage.Object) bool) DeleteHandlerOption
 	}
 }
 
+// ObjectFilter limits the objects deleted by the retention and by DeleteEverything,
+// e.g. to the backups of one database when the folder is shared by several of them
+func ObjectFilter(filter func(storage.Object) bool) DeleteHandlerOption {
+	return func(h *DeleteHandler) {
+		h.objectFilter = filter
+	}
+}
+
 func NewDeleteHandler(
 	folder storage.Folder,
 	backups []BackupObject,
@@ -92,6 +100,8 @@ func NewDeleteHandler(
 		},
 		// by default, all storage objects are impermanent
 		isPermanent: func(storage.Object) bool { return false },
+		// and may be deleted
+		objectFilter: func(storage.Object) bool { return true },
 	}
 
 	for _, option := range options {
@@ -108,7 +118,8 @@ type DeleteHandler struct {
 	less    func(object1, object2 storage.Object) bool
 	greater func(object1, object2 storage.Object) bool
 
-	isPermanent func(object storage.Object) bool
+	isPermanent  func(object storage.Object) bool
+	objectFilter func(object storage.Object) bool
 }
 
 func (h *DeleteHandler) HandleDeleteBefore(args []string, confirmed bool) {
@@ -345,9 +356,8 @@ func (h *DeleteHandler) FindTargetRetainAfterTime(retentionCount int, timeLine t
 }
 
 func (h *DeleteHandler) DeleteEverything(confirmed bool) {
-	filter := func(object storage.Object) bool { return true }
 	folderFilter := func(path string) bool { return true }
-	err := DeleteObjectsWhere(h.Folder, confirmed, filter, folderFilter)
+	err := DeleteObjectsWhere(h.Folder, confirmed, h.objectFilter, folderFilter)
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
@@ -371,7 +381,7 @@ func (h *DeleteHandler) DeleteBeforeTargetWhere(
 	tracelog.InfoLogger.Println("Start delete")
 
 	return DeleteObjectsWhere(h.Folder, confirmed, func(object storage.Object) bool {
-		return objSelector(object) && h.less(object, target) && !h.isPermanent(object)
+		return objSelector(object) && h.objectFilter(object) && h.less(object, target) && !h.isPermanent(object)
 	}, folderFilter)
 }
 
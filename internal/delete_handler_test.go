@@ -68,6 +68,22 @@ func TestDeleteOldObjectsWithFilter(t *testing.T) {
 	assert.Equal(t, expectedOnlyOneSavedObjectName, savedObjects[0].GetName())
 }
 
+func TestDeleteEverythingWithObjectFilter(t *testing.T) {
+	folder := CreateMockStorageFolder()
+	lessFunction := func(object1, object2 storage.Object) bool { return object1.GetName() < object2.GetName() }
+	deleteHandler := NewDeleteHandler(folder, nil, lessFunction, ObjectFilter(func(object storage.Object) bool {
+		return strings.HasPrefix(object.GetName(), "basebackups_005/base_456")
+	}))
+
+	deleteHandler.DeleteEverything(true)
+	savedObjects, err := storage.ListFolderRecursively(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(savedObjects))
+	for _, object := range savedObjects {
+		assert.False(t, strings.HasPrefix(object.GetName(), "basebackups_005/base_456"))
+	}
+}
+
 func TestFindTargetByName(t *testing.T) {
 	mockFolder := CreateMockStorageFolder()
 	objects, _, _ := mockFolder.GetSubFolder("basebackups_005/").ListFolder()
@@ -3,4 +3,8 @@ package consts
 const (
 	DefaultStorage = "default"
 	AllStorages    = "all"
+
+	// ReplicationJournalFolder is the folder in the storage root that keeps objects pending replication to other
+	// storages.
+	ReplicationJournalFolder = "wal-g_replication_journal"
 )
@@ -76,3 +76,17 @@ func ConfigureStorage(name string) (storage.Storage, error) {
 		return nil, fmt.Errorf("storage with name %q is not found, available storages: %v", name, available)
 	}
 }
+
+// ConfigureMultiStorage configures a multi-storage that includes the primary and all failover storages. Storages aren't
+// checked for aliveness, so all of them are considered alive.
+func ConfigureMultiStorage() (*multistorage.Storage, error) {
+	primary, err := internal.ConfigureStorage()
+	if err != nil {
+		return nil, fmt.Errorf("configure primary storage: %w", err)
+	}
+	failover, err := internal.ConfigureFailoverStorages()
+	if err != nil {
+		return nil, fmt.Errorf("configure failover storages: %w", err)
+	}
+	return multistorage.NewStorage(&multistorage.Config{}, primary, failover)
+}
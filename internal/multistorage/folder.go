@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/wal-g/tracelog"
@@ -259,6 +260,8 @@ func ReadObject(folder storage.Folder, objectRelativePath string) (io.ReadCloser
 		return mf.ReadObjectFromFirst(objectRelativePath)
 	case policies.ReadPolicyFoundFirst:
 		return mf.ReadObjectFoundFirst(objectRelativePath)
+	case policies.ReadPolicyHealthiest:
+		return mf.ReadObjectFromHealthiest(objectRelativePath)
 	default:
 		panic(fmt.Sprintf("unknown read object policy %d", mf.policies.Read))
 	}
@@ -308,6 +311,55 @@ func (mf Folder) ReadObjectFoundFirst(objectRelativePath string) (io.ReadCloser,
 	return nil, consts.AllStorages, storage.NewObjectNotFoundError(objectRelativePath)
 }
 
+// ReadObjectFromHealthiest reads the object from the healthiest storage where it is found. If reading from a storage
+// fails, the next healthiest storage is tried.
+func (mf Folder) ReadObjectFromHealthiest(objectRelativePath string) (io.ReadCloser, string, error) {
+	healthiest, err := mf.statsCollector.HealthiestStorages()
+	if err != nil {
+		return nil, "", fmt.Errorf("select healthiest storages: %w", err)
+	}
+	var errs []error
+	for _, f := range mf.usedFoldersInOrder(healthiest) {
+		file, err := f.ReadObject(objectRelativePath)
+		if _, ok := err.(storage.ObjectNotFoundError); ok {
+			mf.statsCollector.ReportOperationResult(f.StorageName, stats.OperationRead(0), true)
+			continue
+		}
+		if err != nil {
+			mf.statsCollector.ReportOperationResult(f.StorageName, stats.OperationRead(0), false)
+			errs = append(errs, fmt.Errorf("read object from %q: %w", f.StorageName, err))
+			continue
+		}
+		reportFile := newReportReadCloser(file, mf.statsCollector, f.StorageName)
+		return reportFile, f.StorageName, nil
+	}
+	if len(errs) > 0 {
+		return nil, consts.AllStorages, errors.Join(errs...)
+	}
+	return nil, consts.AllStorages, storage.NewObjectNotFoundError(objectRelativePath)
+}
+
+// usedFoldersInOrder sorts used folders in the order of the provided storage names. Folders from storages that aren't
+// provided are placed last.
+func (mf Folder) usedFoldersInOrder(storageNames []string) []NamedFolder {
+	priorities := make(map[string]int, len(storageNames))
+	for i, name := range storageNames {
+		priorities[name] = i
+	}
+	priority := func(f NamedFolder) int {
+		if p, ok := priorities[f.StorageName]; ok {
+			return p
+		}
+		return len(storageNames)
+	}
+	sorted := make([]NamedFolder, len(mf.usedFolders))
+	copy(sorted, mf.usedFolders)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return priority(sorted[i]) < priority(sorted[j])
+	})
+	return sorted
+}
+
 // ListFolder lists the folder in multiple storages. A specific implementation is selected using policies.Policies
 func (mf Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
 	switch mf.policies.List {
@@ -437,6 +489,8 @@ func (mf Folder) PutObjectWithContext(ctx context.Context, name string, content
 		return mf.PutObjectToAll(ctx, name, content)
 	case policies.PutPolicyUpdateAllFound:
 		return mf.PutObjectOrUpdateAllFound(ctx, name, content)
+	case policies.PutPolicyQuorum:
+		return mf.PutObjectWithQuorum(ctx, name, content)
 	default:
 		panic(fmt.Sprintf("unknown put policy %d", mf.policies.Put))
 	}
@@ -560,6 +614,130 @@ func (mf Folder) PutObjectOrUpdateAllFound(ctx context.Context, name string, con
 	return nil
 }
 
+// PutObjectWithQuorum puts the object to all used storages concurrently. The put is successful as soon as a quorum of
+// configured storages has acknowledged it, the uploads to slower storages are canceled then. Configured storages
+// that haven't got the object are recorded to the replication journal, so they can be caught up later with
+// DrainReplicationJournal. The uploads never outlive the call: the partial objects left by failed and canceled
+// uploads are deleted, and so is the spooled content.
+func (mf Folder) PutObjectWithQuorum(ctx context.Context, name string, content io.Reader) error {
+	quorum := mf.putQuorum()
+	if quorum > len(mf.configuredRootFolders) {
+		return fmt.Errorf("put quorum %d is greater than the number of configured storages %d",
+			quorum, len(mf.configuredRootFolders))
+	}
+	if len(mf.usedFolders) < quorum {
+		return fmt.Errorf("%w: only %d storages are used, but %d are required", ErrQuorumNotReached,
+			len(mf.usedFolders), quorum)
+	}
+
+	contentSpool, err := newSpool(content)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := contentSpool.close(); err != nil {
+			tracelog.WarningLogger.Printf("Failed to remove spooled content of %q: %v", name, err)
+		}
+	}()
+
+	type putResult struct {
+		folder NamedFolder
+		err    error
+	}
+	results := make(chan putResult, len(mf.usedFolders))
+	putCtx, cancelPuts := context.WithCancel(ctx)
+	defer cancelPuts()
+	for _, f := range mf.usedFolders {
+		go func(f NamedFolder) {
+			countContent := newCountReader(contentSpool.newReader())
+			err := f.PutObjectWithContext(putCtx, name, countContent)
+			mf.statsCollector.ReportOperationResult(f.StorageName, stats.OperationPut(countContent.ReadBytes()), err == nil)
+			if err != nil {
+				err = fmt.Errorf("put object to storage %q: %w", f.StorageName, err)
+			}
+			results <- putResult{f, err}
+		}(f)
+	}
+
+	var acknowledged []string
+	var failed []NamedFolder
+	var errs []error
+	receive := func() {
+		result := <-results
+		if result.err != nil {
+			failed = append(failed, result.folder)
+			errs = append(errs, result.err)
+			return
+		}
+		acknowledged = append(acknowledged, result.folder.StorageName)
+	}
+	received := 0
+	for ; len(acknowledged) < quorum && len(failed) <= len(mf.usedFolders)-quorum; received++ {
+		receive()
+	}
+	// The rest of the uploads are canceled, the ones completed meanwhile are still counted.
+	contentSpool.abort()
+	cancelPuts()
+	for ; received < len(mf.usedFolders); received++ {
+		receive()
+	}
+	mf.deletePartialObjects(name, failed)
+
+	if len(acknowledged) < quorum {
+		return fmt.Errorf("%w: %d of %d required storages acknowledged the put: %w", ErrQuorumNotReached,
+			len(acknowledged), quorum, errors.Join(errs...))
+	}
+
+	sort.Strings(acknowledged)
+	lagging := mf.storagesExcept(acknowledged)
+	if len(lagging) == 0 {
+		return nil
+	}
+	objectPath := storage.JoinPath(mf.path, name)
+	tracelog.WarningLogger.Printf("Object %q is put to storages %v, it's pending replication to %v",
+		objectPath, acknowledged, lagging)
+	err = mf.recordPendingReplication(objectPath, acknowledged, lagging)
+	if err != nil {
+		// The object has already reached the quorum, so the put isn't failed because of the journal.
+		tracelog.ErrorLogger.Printf("Failed to record pending replication: %v", err)
+	}
+	return nil
+}
+
+// deletePartialObjects removes the object from the storages where its upload has failed or has been canceled:
+// the storages that don't write objects atomically may keep a truncated one under the final name.
+func (mf Folder) deletePartialObjects(name string, folders []NamedFolder) {
+	for _, f := range folders {
+		err := f.DeleteObjects([]string{name})
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to delete partial object %q from storage %q: %v", name, f.StorageName, err)
+		}
+	}
+}
+
+func (mf Folder) putQuorum() int {
+	if mf.policies.PutQuorum > 0 {
+		return mf.policies.PutQuorum
+	}
+	return len(mf.configuredRootFolders)/2 + 1
+}
+
+// storagesExcept provides sorted names of configured storages that aren't in the provided list.
+func (mf Folder) storagesExcept(storageNames []string) []string {
+	excluded := make(map[string]bool, len(storageNames))
+	for _, name := range storageNames {
+		excluded[name] = true
+	}
+	var rest []string
+	for name := range mf.configuredRootFolders {
+		if !excluded[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return rest
+}
+
 // DeleteObjects deletes the objects from multiple storages. A specific implementation is selected using
 // policies.Policies
 func (mf Folder) DeleteObjects(objectRelativePaths []string) error {
@@ -671,6 +849,7 @@ func (mf Folder) Validate() error {
 }
 
 var (
-	ErrNoUsedStorages  = fmt.Errorf("no storages are used")
-	ErrNoAliveStorages = fmt.Errorf("no alive storages")
+	ErrNoUsedStorages   = fmt.Errorf("no storages are used")
+	ErrNoAliveStorages  = fmt.Errorf("no alive storages")
+	ErrQuorumNotReached = fmt.Errorf("put quorum is not reached")
 )
@@ -2,11 +2,14 @@ package multistorage
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
@@ -122,3 +125,165 @@ func TestPutObject(t *testing.T) {
 		assert.Equal(t, "new_content", string(content))
 	})
 }
+
+func TestPutObjectWithQuorum(t *testing.T) {
+	t.Run("put to all storages", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.policies.PutQuorum = 3
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		for _, f := range folder.usedFolders {
+			reader, err := f.ReadObject("a/b/c/file")
+			require.NoError(t, err)
+			content, _ := io.ReadAll(reader)
+			assert.Equal(t, "abc", string(content))
+
+			exists, err := f.Exists(consts.ReplicationJournalFolder)
+			require.NoError(t, err)
+			assert.False(t, exists)
+		}
+	})
+
+	t.Run("record failed storage to replication journal", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.usedFolders[1].Folder = failingFolder{folder.usedFolders[1].Folder}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		journal := folder.usedFolders[0].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s2")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("record storages that are not used to replication journal", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2")
+		folder.policies.Put = policies.PutPolicyQuorum
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		journal := folder.usedFolders[0].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s3")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("fail if quorum is not reached", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.usedFolders[0].Folder = failingFolder{folder.usedFolders[0].Folder}
+		folder.usedFolders[2].Folder = failingFolder{folder.usedFolders[2].Folder}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		assert.ErrorIs(t, err, ErrQuorumNotReached)
+	})
+
+	t.Run("fail if not enough storages are used", func(t *testing.T) {
+		folder := newTestFolder(t, "s1")
+		folder.policies.Put = policies.PutPolicyQuorum
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		assert.ErrorIs(t, err, ErrQuorumNotReached)
+	})
+
+	t.Run("cancel put to hung storage", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.usedFolders[1].Folder = blockingFolder{folder.usedFolders[1].Folder, make(chan struct{})}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		journal := folder.usedFolders[0].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s2")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		exists, err := folder.usedFolders[1].Exists("a/b/c/file")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		spooled, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, spooled, "the spooled content is removed before the put returns")
+	})
+
+	t.Run("delete partial object of canceled put", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.usedFolders[2].Folder = partialFolder{folder.usedFolders[2].Folder}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+
+		exists, err := folder.usedFolders[2].Exists("a/b/c/file")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("do not fail put if journal is not recorded", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2")
+		folder.policies.Put = policies.PutPolicyQuorum
+		for name, root := range folder.configuredRootFolders {
+			folder.configuredRootFolders[name] = failingSubFolders{root}
+		}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+	})
+
+	t.Run("use explicit quorum", func(t *testing.T) {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Put = policies.PutPolicyQuorum
+		folder.policies.PutQuorum = 3
+		folder.usedFolders[2].Folder = failingFolder{folder.usedFolders[2].Folder}
+
+		err := folder.PutObject("a/b/c/file", bytes.NewBufferString("abc"))
+		assert.ErrorIs(t, err, ErrQuorumNotReached)
+	})
+}
+
+// blockingFolder is a storage.Folder that puts objects only after unblock is closed, unless the put is canceled.
+type blockingFolder struct {
+	storage.Folder
+	unblock chan struct{}
+}
+
+func (f blockingFolder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	select {
+	case <-f.unblock:
+		return f.Folder.PutObjectWithContext(ctx, name, content)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// partialFolder is a storage.Folder that writes a truncated object and fails when the put is canceled.
+type partialFolder struct {
+	storage.Folder
+}
+
+func (f partialFolder) PutObjectWithContext(ctx context.Context, name string, _ io.Reader) error {
+	err := f.Folder.PutObjectWithContext(ctx, name, bytes.NewBufferString("a"))
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// failingSubFolders is a storage.Folder whose subfolders fail to put and read objects.
+type failingSubFolders struct {
+	storage.Folder
+}
+
+func (f failingSubFolders) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return failingFolder{f.Folder.GetSubFolder(subFolderRelativePath)}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
@@ -94,3 +95,57 @@ func TestReadObject(t *testing.T) {
 		assert.Equal(t, "all", storageName)
 	})
 }
+
+func TestReadObjectFromHealthiest(t *testing.T) {
+	newHealthTestFolder := func(t *testing.T, healthiest ...string) Folder {
+		folder := newTestFolder(t, "s1", "s2", "s3")
+		folder.policies.Read = policies.ReadPolicyHealthiest
+		folder.statsCollector.(*stats.MockCollector).EXPECT().HealthiestStorages().Return(healthiest, nil).AnyTimes()
+		_ = folder.usedFolders[0].PutObject("aaa", bytes.NewBufferString("1"))
+		_ = folder.usedFolders[2].PutObject("aaa", bytes.NewBufferString("3"))
+		return folder
+	}
+
+	t.Run("read from healthiest storage", func(t *testing.T) {
+		folder := newHealthTestFolder(t, "s3", "s2", "s1")
+
+		reader, storageName, err := ReadObject(folder, "aaa")
+		require.NoError(t, err)
+		assert.Equal(t, "s3", storageName)
+		content, _ := io.ReadAll(reader)
+		assert.Equal(t, "3", string(content))
+	})
+
+	t.Run("skip storages without object", func(t *testing.T) {
+		folder := newHealthTestFolder(t, "s2", "s1", "s3")
+
+		_, storageName, err := ReadObject(folder, "aaa")
+		require.NoError(t, err)
+		assert.Equal(t, "s1", storageName)
+	})
+
+	t.Run("fall back to next storage on error", func(t *testing.T) {
+		folder := newHealthTestFolder(t, "s3", "s1", "s2")
+		folder.usedFolders[2].Folder = failingFolder{folder.usedFolders[2].Folder}
+
+		_, storageName, err := ReadObject(folder, "aaa")
+		require.NoError(t, err)
+		assert.Equal(t, "s1", storageName)
+	})
+
+	t.Run("try storages missing in health list last", func(t *testing.T) {
+		folder := newHealthTestFolder(t, "s2")
+
+		_, storageName, err := ReadObject(folder, "aaa")
+		require.NoError(t, err)
+		assert.Equal(t, "s1", storageName)
+	})
+
+	t.Run("not found anywhere", func(t *testing.T) {
+		folder := newHealthTestFolder(t, "s1", "s2", "s3")
+
+		_, storageName, err := ReadObject(folder, "bbb")
+		assert.ErrorAs(t, err, &storage.ObjectNotFoundError{})
+		assert.Equal(t, "all", storageName)
+	})
+}
@@ -11,6 +11,7 @@ type StorageTeller interface {
 }
 
 var _ StorageTeller = multiObject{}
+var _ storage.StorageClassTeller = multiObject{}
 
 // multiObject is an internal implementation of MultiObject that is provided from multistorage.Folder methods
 // instead of the simple storage.Object.
@@ -31,3 +32,8 @@ func GetStorage(obj storage.Object) string {
 	}
 	return consts.DefaultStorage
 }
+
+// GetStorageClass forwards the storage class of the object listed from the particular storage.
+func (mo multiObject) GetStorageClass() string {
+	return storage.GetStorageClass(mo.Object)
+}
@@ -33,6 +33,18 @@ var TakeFirstStorage = Policies{
 	Copy:   CopyPolicyFirst,
 }
 
+// QuorumWrite implies that objects are put to all used storages and the put is considered successful when at least
+// a quorum of storages has the object. Storages that haven't got the object are caught up later using the replication
+// journal. Objects are read from the healthiest storage where they are found.
+var QuorumWrite = Policies{
+	Exists: ExistsPolicyAny,
+	Read:   ReadPolicyHealthiest,
+	List:   ListPolicyFoundFirst,
+	Put:    PutPolicyQuorum,
+	Delete: DeletePolicyAll,
+	Copy:   CopyPolicyAll,
+}
+
 // Policies define the behavior of the multi-storage folder in terms of selecting which underlying storages should be
 // used to perform different operations.
 type Policies struct {
@@ -42,6 +54,10 @@ type Policies struct {
 	Put    PutPolicy
 	Delete DeletePolicy
 	Copy   CopyPolicy
+
+	// PutQuorum is the number of storages that must acknowledge a put with PutPolicyQuorum. If it's 0, the majority
+	// of configured storages is required.
+	PutQuorum int
 }
 
 type ExistsPolicy int
@@ -57,6 +73,7 @@ type ReadPolicy int
 const (
 	ReadPolicyFirst ReadPolicy = iota
 	ReadPolicyFoundFirst
+	ReadPolicyHealthiest
 )
 
 type ListPolicy int
@@ -74,6 +91,7 @@ const (
 	PutPolicyUpdateFirstFound
 	PutPolicyAll
 	PutPolicyUpdateAllFound
+	PutPolicyQuorum
 )
 
 type DeletePolicy int
@@ -0,0 +1,202 @@
+package multistorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// The replication journal keeps objects that were put to some storages, but are still missing in others. It's stored
+// in the storages that have the objects, in the following layout:
+// <storage root>/wal-g_replication_journal/<target storage name>/<entry name>.json
+
+type replicationJournalEntry struct {
+	Path    string    `json:"path"`
+	Created time.Time `json:"created"`
+}
+
+// recordPendingReplication records the object to the replication journal of the first source storage where it's
+// possible, for each of the target storages.
+func (mf Folder) recordPendingReplication(objectPath string, sources, targets []string) error {
+	entry, err := json.Marshal(replicationJournalEntry{
+		Path:    objectPath,
+		Created: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal replication journal entry: %w", err)
+	}
+	entryName := replicationJournalEntryName(objectPath)
+
+	var errs []error
+	for _, source := range sources {
+		journal := mf.configuredRootFolders[source].GetSubFolder(consts.ReplicationJournalFolder)
+		err = putJournalEntries(journal, entryName, entry, targets)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("record to storage %q: %w", source, err))
+	}
+	return fmt.Errorf("record pending replication of %q to %v: %w", objectPath, targets, errors.Join(errs...))
+}
+
+func putJournalEntries(journal storage.Folder, entryName string, entry []byte, targets []string) error {
+	for _, target := range targets {
+		err := journal.GetSubFolder(target).PutObject(entryName, bytes.NewReader(entry))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicationJournalEntryName is derived from the object path, so that subsequent puts of the same object don't
+// produce multiple entries.
+func replicationJournalEntryName(objectPath string) string {
+	hash := sha256.Sum256([]byte(objectPath))
+	return hex.EncodeToString(hash[:16]) + ".json"
+}
+
+// DrainReplicationJournal copies objects recorded in the replication journals of all alive storages to the storages
+// that missed them. If targetStorage is not empty, only this storage is caught up. Journal entries are removed once
+// the object is copied, or if it doesn't exist in the source storage anymore. Storages that are still dead are skipped.
+// It returns the number of replicated objects.
+func DrainReplicationJournal(ctx context.Context, folder storage.Folder, targetStorage string) (int, error) {
+	mf, ok := folder.(Folder)
+	if !ok {
+		return 0, nil
+	}
+
+	sources := make([]string, 0, len(mf.configuredRootFolders))
+	for name := range mf.configuredRootFolders {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+
+	replicated := 0
+	var errs []error
+	for _, source := range sources {
+		if !mf.storageIsAlive(source) {
+			tracelog.WarningLogger.Printf("Skip replication journal of storage %q as it's dead", source)
+			continue
+		}
+		count, err := mf.drainJournalOfSource(ctx, source, targetStorage)
+		replicated += count
+		if err != nil {
+			errs = append(errs, fmt.Errorf("drain replication journal of storage %q: %w", source, err))
+		}
+	}
+	return replicated, errors.Join(errs...)
+}
+
+func (mf Folder) drainJournalOfSource(ctx context.Context, source, targetStorage string) (int, error) {
+	sourceRoot := mf.configuredRootFolders[source]
+	journal := sourceRoot.GetSubFolder(consts.ReplicationJournalFolder)
+	_, targetFolders, err := journal.ListFolder()
+	if err != nil {
+		return 0, fmt.Errorf("list replication journal: %w", err)
+	}
+
+	replicated := 0
+	var errs []error
+	for _, targetFolder := range targetFolders {
+		target := path.Base(targetFolder.GetPath())
+		if targetStorage != "" && target != targetStorage {
+			continue
+		}
+		targetRoot, ok := mf.configuredRootFolders[target]
+		if !ok {
+			tracelog.WarningLogger.Printf("Skip objects pending replication to unknown storage %q", target)
+			continue
+		}
+		if !mf.storageIsAlive(target) {
+			tracelog.WarningLogger.Printf("Skip objects pending replication to storage %q as it's dead", target)
+			continue
+		}
+
+		entries, _, err := targetFolder.ListFolder()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("list objects pending replication to %q: %w", target, err))
+			continue
+		}
+		for _, entry := range entries {
+			copied, err := mf.replicateJournalEntry(ctx, targetFolder, entry.GetName(), sourceRoot, NamedFolder{
+				Folder:      targetRoot,
+				StorageName: target,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("replicate to %q: %w", target, err))
+				continue
+			}
+			if copied {
+				replicated++
+			}
+		}
+	}
+	return replicated, errors.Join(errs...)
+}
+
+// replicateJournalEntry copies the object recorded in the journal entry to the target storage and removes the entry.
+// It returns false if the object doesn't exist in the source storage anymore, so there's nothing to copy.
+func (mf Folder) replicateJournalEntry(
+	ctx context.Context,
+	journal storage.Folder,
+	entryName string,
+	sourceRoot storage.Folder,
+	target NamedFolder,
+) (bool, error) {
+	entryReader, err := journal.ReadObject(entryName)
+	if err != nil {
+		return false, fmt.Errorf("read journal entry %q: %w", entryName, err)
+	}
+	entryBytes, err := io.ReadAll(entryReader)
+	_ = entryReader.Close()
+	if err != nil {
+		return false, fmt.Errorf("read journal entry %q: %w", entryName, err)
+	}
+	var entry replicationJournalEntry
+	err = json.Unmarshal(entryBytes, &entry)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal journal entry %q: %w", entryName, err)
+	}
+
+	object, err := sourceRoot.ReadObject(entry.Path)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		tracelog.InfoLogger.Printf("Object %q pending replication is already deleted from the source", entry.Path)
+		return false, journal.DeleteObjects([]string{entryName})
+	}
+	if err != nil {
+		return false, fmt.Errorf("read object %q: %w", entry.Path, err)
+	}
+	countObject := newCountReader(object)
+	err = target.PutObjectWithContext(ctx, entry.Path, countObject)
+	_ = object.Close()
+	mf.statsCollector.ReportOperationResult(target.StorageName, stats.OperationPut(countObject.ReadBytes()), err == nil)
+	if err != nil {
+		return false, fmt.Errorf("put object %q: %w", entry.Path, err)
+	}
+	tracelog.DebugLogger.Printf("Object %q is replicated to storage %q", entry.Path, target.StorageName)
+
+	return true, journal.DeleteObjects([]string{entryName})
+}
+
+func (mf Folder) storageIsAlive(name string) bool {
+	alive, err := mf.statsCollector.SpecificStorage(name)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to check if storage %q is alive: %v", name, err)
+		return false
+	}
+	return alive
+}
@@ -0,0 +1,117 @@
+package multistorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/multistorage/consts"
+	"github.com/wal-g/wal-g/internal/multistorage/policies"
+	"github.com/wal-g/wal-g/internal/multistorage/stats"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestDrainReplicationJournal(t *testing.T) {
+	newQuorumTestFolder := func(t *testing.T) Folder {
+		folder := newTestFolder(t, "s1", "s2")
+		folder.policies.Put = policies.PutPolicyQuorum
+		err := folder.GetSubFolder("a/b").PutObject("file", bytes.NewBufferString("abc"))
+		require.NoError(t, err)
+		return folder
+	}
+
+	t.Run("replicate pending objects to caught up storage", func(t *testing.T) {
+		folder := newQuorumTestFolder(t)
+		folder.statsCollector.(*stats.MockCollector).EXPECT().SpecificStorage(gomock.Any()).Return(true, nil).AnyTimes()
+
+		replicated, err := DrainReplicationJournal(context.Background(), folder, "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, replicated)
+
+		reader, err := folder.configuredRootFolders["s3"].ReadObject("a/b/file")
+		require.NoError(t, err)
+		content, _ := io.ReadAll(reader)
+		assert.Equal(t, "abc", string(content))
+
+		journal := folder.configuredRootFolders["s1"].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s3")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("skip dead target storage", func(t *testing.T) {
+		folder := newQuorumTestFolder(t)
+		collector := folder.statsCollector.(*stats.MockCollector)
+		collector.EXPECT().SpecificStorage("s3").Return(false, nil).AnyTimes()
+		collector.EXPECT().SpecificStorage(gomock.Any()).Return(true, nil).AnyTimes()
+
+		replicated, err := DrainReplicationJournal(context.Background(), folder, "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, replicated)
+
+		journal := folder.configuredRootFolders["s1"].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s3")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("skip other target storages", func(t *testing.T) {
+		folder := newQuorumTestFolder(t)
+		folder.statsCollector.(*stats.MockCollector).EXPECT().SpecificStorage(gomock.Any()).Return(true, nil).AnyTimes()
+
+		replicated, err := DrainReplicationJournal(context.Background(), folder, "s2")
+		require.NoError(t, err)
+		assert.Equal(t, 0, replicated)
+	})
+
+	t.Run("remove entry if object is deleted from source", func(t *testing.T) {
+		folder := newQuorumTestFolder(t)
+		folder.statsCollector.(*stats.MockCollector).EXPECT().SpecificStorage(gomock.Any()).Return(true, nil).AnyTimes()
+		err := folder.configuredRootFolders["s1"].DeleteObjects([]string{"a/b/file"})
+		require.NoError(t, err)
+
+		replicated, err := DrainReplicationJournal(context.Background(), folder, "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, replicated)
+
+		exists, err := folder.configuredRootFolders["s3"].Exists("a/b/file")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		journal := folder.configuredRootFolders["s1"].GetSubFolder(consts.ReplicationJournalFolder).GetSubFolder("s3")
+		entries, _, err := journal.ListFolder()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("do nothing if folder is not multistorage", func(t *testing.T) {
+		replicated, err := DrainReplicationJournal(context.Background(), memory.NewFolder("", memory.NewKVS()), "")
+		require.NoError(t, err)
+		assert.Equal(t, 0, replicated)
+	})
+}
+
+var errTestStorage = errors.New("test storage error")
+
+// failingFolder is a storage.Folder that fails to put and read objects.
+type failingFolder struct {
+	storage.Folder
+}
+
+func (f failingFolder) PutObject(_ string, _ io.Reader) error {
+	return errTestStorage
+}
+
+func (f failingFolder) PutObjectWithContext(_ context.Context, _ string, _ io.Reader) error {
+	return errTestStorage
+}
+
+func (f failingFolder) ReadObject(_ string) (io.ReadCloser, error) {
+	return nil, errTestStorage
+}
@@ -0,0 +1,127 @@
+package multistorage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const spoolChunkSize = 1 << 20
+
+var errSpoolAborted = errors.New("spool is aborted")
+
+// spool streams content to a temporary file and lets several readers follow it concurrently. Each reader goes at its
+// own pace, so the content isn't kept in memory and a stuck reader doesn't block the others.
+type spool struct {
+	file *os.File
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	written int64
+	done    bool
+	aborted bool
+	err     error
+	filled  chan struct{}
+}
+
+func newSpool(content io.Reader) (*spool, error) {
+	file, err := os.CreateTemp("", "wal-g-multistorage-")
+	if err != nil {
+		return nil, fmt.Errorf("create temporary file to spool content: %w", err)
+	}
+	s := &spool{
+		file:   file,
+		filled: make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.fill(content)
+	return s, nil
+}
+
+func (s *spool) fill(content io.Reader) {
+	defer close(s.filled)
+	buffer := make([]byte, spoolChunkSize)
+	var offset int64
+	for {
+		n, err := content.Read(buffer)
+		if n > 0 {
+			if _, writeErr := s.file.WriteAt(buffer[:n], offset); writeErr != nil {
+				err = fmt.Errorf("write content to temporary file: %w", writeErr)
+			} else {
+				offset += int64(n)
+			}
+		}
+
+		s.mu.Lock()
+		s.written = offset
+		if err == nil && s.aborted {
+			err = errSpoolAborted
+		}
+		if err != nil {
+			s.done = true
+			if !errors.Is(err, io.EOF) {
+				s.err = err
+			}
+		}
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// newReader provides a reader of the whole content that blocks until the next part of it is spooled.
+func (s *spool) newReader() io.Reader {
+	return &spoolReader{spool: s}
+}
+
+// abort stops reading the source content and waits until it's done, the readers get an error after that.
+func (s *spool) abort() {
+	s.mu.Lock()
+	s.aborted = true
+	s.mu.Unlock()
+	<-s.filled
+}
+
+// close removes the temporary file, it must be called after all readers are done.
+func (s *spool) close() error {
+	s.abort()
+	name := s.file.Name()
+	closeErr := s.file.Close()
+	return errors.Join(closeErr, os.Remove(name))
+}
+
+type spoolReader struct {
+	spool  *spool
+	offset int64
+}
+
+func (r *spoolReader) Read(p []byte) (int, error) {
+	s := r.spool
+	s.mu.Lock()
+	for r.offset >= s.written && !s.done {
+		s.cond.Wait()
+	}
+	written, err := s.written, s.err
+	s.mu.Unlock()
+
+	if r.offset >= written {
+		if err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if available := written - r.offset; int64(len(p)) > available {
+		p = p[:available]
+	}
+	n, err := s.file.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package multistorage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_spool(t *testing.T) {
+	t.Run("provides whole content to concurrent readers", func(t *testing.T) {
+		content := bytes.Repeat([]byte("0123456789"), spoolChunkSize/4)
+		s, err := newSpool(bytes.NewReader(content))
+		require.NoError(t, err)
+
+		results := make([][]byte, 3)
+		wg := sync.WaitGroup{}
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = io.ReadAll(s.newReader())
+			}(i)
+		}
+		wg.Wait()
+		require.NoError(t, s.close())
+
+		for _, result := range results {
+			assert.Equal(t, content, result)
+		}
+	})
+
+	t.Run("does not wait for stuck reader", func(t *testing.T) {
+		pipeReader, pipeWriter := io.Pipe()
+		s, err := newSpool(pipeReader)
+		require.NoError(t, err)
+		stuck := s.newReader()
+
+		go func() {
+			_, _ = pipeWriter.Write([]byte("abc"))
+			_ = pipeWriter.Close()
+		}()
+		result, err := io.ReadAll(s.newReader())
+		require.NoError(t, err)
+		assert.Equal(t, "abc", string(result))
+
+		result, err = io.ReadAll(stuck)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", string(result))
+		require.NoError(t, s.close())
+	})
+
+	t.Run("passes content error to readers", func(t *testing.T) {
+		testErr := errors.New("test error")
+		s, err := newSpool(io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(testErr)))
+		require.NoError(t, err)
+
+		result, err := io.ReadAll(s.newReader())
+		assert.ErrorIs(t, err, testErr)
+		assert.Equal(t, "abc", string(result))
+		require.NoError(t, s.close())
+	})
+}
@@ -11,6 +11,9 @@ type Cache interface {
 	// Read the cached state for storages with specified names and split them by relevance according to the cache TTL.
 	Read(storageNames ...string) (relevant, outdated AliveMap, err error)
 
+	// ReadHealth provides the cached aliveness factors of storages with specified names regardless of the cache TTL.
+	ReadHealth(storageNames ...string) (HealthMap, error)
+
 	// ApplyExplicitCheckResult with specifying which checked storages were alive, and return the new cached state for
 	// all storages with specified names.
 	ApplyExplicitCheckResult(checkResult AliveMap, checkTime time.Time, storageNames ...string) (AliveMap, error)
@@ -113,6 +116,17 @@ func (c *cache) Read(storageNames ...string) (relevant, outdated AliveMap, err e
 	return relevantAliveMap, outdatedAliveMap, nil
 }
 
+func (c *cache) ReadHealth(storageNames ...string) (HealthMap, error) {
+	c.shMem.Lock()
+	defer c.shMem.Unlock()
+
+	storageKeys, err := c.correspondingKeys(storageNames...)
+	if err != nil {
+		return nil, err
+	}
+	return c.shMem.Statuses.filter(storageKeys).healthMap(c.emaParams), nil
+}
+
 func (c *cache) ApplyExplicitCheckResult(checkResult AliveMap, checkTime time.Time, storageNames ...string) (AliveMap, error) {
 	c.shMem.Lock()
 	defer c.shMem.Unlock()
@@ -0,0 +1,25 @@
+package cache
+
+import "sort"
+
+// HealthMap shows how much storages are alive, by their names. The value is the ratio of the actual aliveness to
+// the potential one, so it's 1 for fully alive storages and 0 for fully dead ones.
+type HealthMap map[string]float64
+
+// SortByHealth orders storages from the healthiest to the least healthy one. Storages with equal health keep their
+// order of priority. Storages that aren't presented in HealthMap are placed last.
+func (hm HealthMap) SortByHealth(namesInOrder []string) []string {
+	sorted := make([]string, len(namesInOrder))
+	copy(sorted, namesInOrder)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return hm.health(sorted[i]) > hm.health(sorted[j])
+	})
+	return sorted
+}
+
+func (hm HealthMap) health(name string) float64 {
+	if health, ok := hm[name]; ok {
+		return health
+	}
+	return -1
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthMap_SortByHealth(t *testing.T) {
+	healthMap := HealthMap{
+		"a": 0.5,
+		"b": 0.9,
+		"c": 0.5,
+		"d": 0,
+	}
+
+	t.Run("healthiest first", func(t *testing.T) {
+		assert.Equal(t, []string{"b", "a", "c", "d", "e"}, healthMap.SortByHealth([]string{"a", "b", "c", "d", "e"}))
+	})
+
+	t.Run("equal health keeps priority order", func(t *testing.T) {
+		assert.Equal(t, []string{"b", "c", "a", "d", "e"}, healthMap.SortByHealth([]string{"e", "d", "c", "b", "a"}))
+	})
+
+	t.Run("does not modify input", func(t *testing.T) {
+		names := []string{"a", "b"}
+		_ = healthMap.SortByHealth(names)
+		assert.Equal(t, []string{"a", "b"}, names)
+	})
+}
@@ -291,3 +291,11 @@ var DefaultEMAParams = EMAParams{
 		Max: 0.5,
 	},
 }
+
+func (ss storageStatuses) healthMap(p *EMAParams) HealthMap {
+	healthMap := make(HealthMap, len(ss))
+	for key, status := range ss {
+		healthMap[key.Name] = status.alivenessFactor(p)
+	}
+	return healthMap
+}
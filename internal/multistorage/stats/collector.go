@@ -15,6 +15,7 @@ type Collector interface {
 	AllAliveStorages() ([]string, error)
 	FirstAliveStorage() (*string, error)
 	SpecificStorage(name string) (bool, error)
+	HealthiestStorages() ([]string, error)
 	ReportOperationResult(storage string, op OperationWeight, success bool)
 	Close() error
 }
@@ -111,6 +112,20 @@ func (c *collector) SpecificStorage(name string) (bool, error) {
 	return afterRecheck[name], nil
 }
 
+// HealthiestStorages provides all alive storages ordered from the healthiest to the least healthy one, according to
+// the aliveness collected from the results of performed operations.
+func (c *collector) HealthiestStorages() ([]string, error) {
+	alive, err := c.AllAliveStorages()
+	if err != nil {
+		return nil, err
+	}
+	health, err := c.cache.ReadHealth(alive...)
+	if err != nil {
+		return nil, fmt.Errorf("read storages health: %w", err)
+	}
+	return health.SortByHealth(alive), nil
+}
+
 func (c *collector) ReportOperationResult(storage string, opWeight OperationWeight, success bool) {
 	c.cache.ApplyOperationResult(storage, success, float64(opWeight))
 }
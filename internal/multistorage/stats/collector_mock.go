@@ -77,6 +77,21 @@ func (mr *MockCollectorMockRecorder) FirstAliveStorage() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FirstAliveStorage", reflect.TypeOf((*MockCollector)(nil).FirstAliveStorage))
 }
 
+// HealthiestStorages mocks base method.
+func (m *MockCollector) HealthiestStorages() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HealthiestStorages")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HealthiestStorages indicates an expected call of HealthiestStorages.
+func (mr *MockCollectorMockRecorder) HealthiestStorages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthiestStorages", reflect.TypeOf((*MockCollector)(nil).HealthiestStorages))
+}
+
 // ReportOperationResult mocks base method.
 func (m *MockCollector) ReportOperationResult(storage string, op OperationWeight, success bool) {
 	m.ctrl.T.Helper()
@@ -191,6 +191,34 @@ func Test_collector_SpecificStorage(t *testing.T) {
 	})
 }
 
+func Test_collector_HealthiestStorages(t *testing.T) {
+	t.Run("orders alive storages by health", func(t *testing.T) {
+		col := newTestCollector(t, 3)
+		for _, name := range []string{"stor_1", "stor_2", "stor_3"} {
+			setInCache(t, col, name, true, true)
+			for i := 0; i < 100; i++ {
+				col.ReportOperationResult(name, OperationExists, true)
+			}
+		}
+		col.ReportOperationResult("stor_1", OperationExists, false)
+
+		healthiest, err := col.HealthiestStorages()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"stor_2", "stor_3", "stor_1"}, healthiest)
+	})
+
+	t.Run("does not provide dead storages", func(t *testing.T) {
+		col := newTestCollector(t, 3, 2)
+		setInCache(t, col, "stor_1", true, true)
+		setInCache(t, col, "stor_2", false, true)
+		setInCache(t, col, "stor_3", true, true)
+
+		healthiest, err := col.HealthiestStorages()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"stor_1", "stor_3"}, healthiest)
+	})
+}
+
 func newTestCollector(t *testing.T, storages int, deadOnCheck ...int) *collector {
 	var names []string
 	for i := 1; i <= storages; i++ {
@@ -30,6 +30,10 @@ func (nc *nopCollector) SpecificStorage(name string) (bool, error) {
 	return false, fmt.Errorf("unknown storage %q", name)
 }
 
+func (nc *nopCollector) HealthiestStorages() ([]string, error) {
+	return nc.storagesInOrder, nil
+}
+
 func (nc *nopCollector) ReportOperationResult(_ string, _ OperationWeight, _ bool) {
 	// Nothing to report
 }
@@ -48,4 +48,10 @@ func Test_nopCollector(t *testing.T) {
 		assert.Error(t, err)
 		assert.Equal(t, false, got)
 	})
+
+	t.Run("HealthiestStorages returns storages in order", func(t *testing.T) {
+		got, err := c.HealthiestStorages()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"default", "failover_1", "failover_2", "failover_3"}, got)
+	})
 }
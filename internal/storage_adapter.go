@@ -4,12 +4,14 @@ import (
 	"github.com/spf13/viper"
 	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/pkg/storages/azure"
+	"github.com/wal-g/wal-g/pkg/storages/chaos"
 	"github.com/wal-g/wal-g/pkg/storages/fs"
 	"github.com/wal-g/wal-g/pkg/storages/gcs"
 	"github.com/wal-g/wal-g/pkg/storages/s3"
 	"github.com/wal-g/wal-g/pkg/storages/sh"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/pkg/storages/swift"
+	"github.com/wal-g/wal-g/pkg/storages/webdav"
 )
 
 type StorageAdapter struct {
@@ -29,9 +31,13 @@ func (adapter *StorageAdapter) PrefixSettingKey() string {
 }
 
 func (adapter *StorageAdapter) loadSettings(config *viper.Viper) map[string]string {
+	return loadStorageSettings(config, adapter.settingNames)
+}
+
+func loadStorageSettings(config *viper.Viper, settingNames []string) map[string]string {
 	settings := make(map[string]string)
 
-	for _, settingName := range adapter.settingNames {
+	for _, settingName := range settingNames {
 		settingValue := config.GetString(settingName)
 		if config.IsSet(settingName) {
 			settings[settingName] = settingValue
@@ -57,4 +63,11 @@ var StorageAdapters = []StorageAdapter{
 	{"AZ", azure.SettingList, azure.ConfigureStorage},
 	{"SWIFT", swift.SettingList, swift.ConfigureStorage},
 	{"SSH", sh.SettingList, sh.ConfigureStorage},
+	{"WEBDAV", webdav.SettingList, webdav.ConfigureStorage},
+}
+
+// configureChaos wraps the storage with fault injection if it's enabled in the config. This is only meant for testing.
+func configureChaos(st storage.HashableStorage, config *viper.Viper) (storage.HashableStorage, error) {
+	settings := loadStorageSettings(config, chaos.SettingList)
+	return chaos.ConfigureStorage(st, settings)
 }
@@ -3,7 +3,9 @@ package internal
 import (
 	"io"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
+	conf "github.com/wal-g/wal-g/internal/config"
 	"github.com/wal-g/wal-g/internal/multistorage"
 	"github.com/wal-g/wal-g/internal/multistorage/policies"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
@@ -27,7 +29,11 @@ func (fsr *FolderReaderImpl) SubFolder(subFolderRelativePath string) StorageFold
 }
 
 func PrepareMultiStorageFolderReader(folder storage.Folder, targetStorage string) (StorageFolderReader, error) {
-	folder = multistorage.SetPolicies(folder, policies.MergeAllStorages)
+	readPolicies := policies.MergeAllStorages
+	if viper.GetBool(conf.PgFailoverStoragesReadHealthiest) {
+		readPolicies.Read = policies.ReadPolicyHealthiest
+	}
+	folder = multistorage.SetPolicies(folder, readPolicies)
 	var err error
 	if targetStorage == "" {
 		folder, err = multistorage.UseAllAliveStorages(folder)
@@ -0,0 +1,241 @@
+package storagetools
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/printlist"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// FsckIssue is an inconsistency of the storage layout found by a FsckCheck
+type FsckIssue struct {
+	Check string `json:"check"`
+	// Subject is the backup, the log range, the restore point, etc. that has the issue
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+	// Garbage are the objects that are safe to delete in order to repair the issue.
+	// Issues without garbage can't be repaired automatically.
+	Garbage []string `json:"garbage,omitempty"`
+}
+
+func (issue FsckIssue) PrintableFields() []printlist.TableField {
+	return []printlist.TableField{
+		{Name: "check", PrettyName: "Check", Value: issue.Check},
+		{Name: "subject", PrettyName: "Subject", Value: issue.Subject},
+		{Name: "description", PrettyName: "Description", Value: issue.Description},
+		{Name: "garbage", PrettyName: "Garbage objects", Value: strconv.Itoa(len(issue.Garbage))},
+	}
+}
+
+// FsckCheck checks the storage for inconsistencies of a particular kind
+type FsckCheck interface {
+	Name() string
+	// Check looks for issues, objects are the recursive listing of the storage root folder
+	Check(folder storage.Folder, objects []storage.Object) ([]FsckIssue, error)
+}
+
+// DefaultFsckChecks are the checks that are applicable to the storages of all databases.
+// Backup data without a sentinel is considered orphaned if it's not modified for minOrphanAge.
+func DefaultFsckChecks(minOrphanAge time.Duration) []FsckCheck {
+	return []FsckCheck{&BackupFilesCheck{MinOrphanAge: minOrphanAge}}
+}
+
+// BackupFilesCheck finds sentinels without backup data and backup data without sentinels in all backup folders
+// of the storage, including the nested ones (e.g. the backup folders of Greenplum segments).
+type BackupFilesCheck struct {
+	// DatalessSentinelFolders are the backup folders where the backups consist of the sentinel only,
+	// e.g. the Greenplum coordinator backups
+	DatalessSentinelFolders []string
+	// SharedDataDirs are the subfolders of the backup folders that don't belong to a particular backup
+	SharedDataDirs []string
+	// MinOrphanAge is the minimum age of the backup data without a sentinel to consider it orphaned,
+	// so the backups that are being made aren't reported
+	MinOrphanAge time.Duration
+}
+
+func (c *BackupFilesCheck) Name() string {
+	return "backup_files"
+}
+
+type checkedBackupFiles struct {
+	sentinel     string
+	data         []string
+	lastModified time.Time
+}
+
+func (c *BackupFilesCheck) Check(_ storage.Folder, objects []storage.Object) ([]FsckIssue, error) {
+	backups := map[string]*checkedBackupFiles{}
+	for _, object := range objects {
+		backupsFolder, fileName, ok := SplitBackupPath(object.GetName())
+		if !ok {
+			continue
+		}
+		dir, file := path.Split(fileName)
+		var backupName string
+		switch {
+		case dir == "" && strings.HasSuffix(file, utility.SentinelSuffix):
+			backupName = strings.TrimSuffix(file, utility.SentinelSuffix)
+		case dir != "":
+			backupName = dir[:strings.Index(dir, "/")]
+			if slices.Contains(c.SharedDataDirs, backupName) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		key := backupsFolder + backupName
+		backup, ok := backups[key]
+		if !ok {
+			backup = &checkedBackupFiles{}
+			backups[key] = backup
+		}
+		if dir == "" {
+			backup.sentinel = object.GetName()
+		} else {
+			backup.data = append(backup.data, object.GetName())
+		}
+		if object.GetLastModified().After(backup.lastModified) {
+			backup.lastModified = object.GetLastModified()
+		}
+	}
+
+	var issues []FsckIssue
+	for key, backup := range backups {
+		backupsFolder := strings.TrimSuffix(key, path.Base(key))
+		switch {
+		case backup.sentinel != "" && len(backup.data) == 0:
+			if slices.Contains(c.DatalessSentinelFolders, backupsFolder) {
+				continue
+			}
+			issues = append(issues, FsckIssue{
+				Check:       c.Name(),
+				Subject:     key,
+				Description: "backup sentinel exists, but the backup data is missing",
+				Garbage:     []string{backup.sentinel},
+			})
+		case backup.sentinel == "" && time.Since(backup.lastModified) >= c.MinOrphanAge:
+			sort.Strings(backup.data)
+			issues = append(issues, FsckIssue{
+				Check:   c.Name(),
+				Subject: key,
+				Description: fmt.Sprintf("%d backup data object(s) without sentinel, last modified at %s",
+					len(backup.data), backup.lastModified.Format(time.RFC3339)),
+				Garbage: backup.data,
+			})
+		}
+	}
+	return issues, nil
+}
+
+// SplitBackupPath splits the object path into the backup folder path and the path inside it.
+// Backup folders can be nested, e.g. "segments_005/seg0/basebackups_005/".
+func SplitBackupPath(objectPath string) (backupsFolder, fileName string, ok bool) {
+	for offset := 0; offset < len(objectPath); {
+		idx := strings.Index(objectPath[offset:], utility.BaseBackupPath)
+		if idx < 0 {
+			return "", "", false
+		}
+		idx += offset
+		if idx == 0 || objectPath[idx-1] == '/' {
+			end := idx + len(utility.BaseBackupPath)
+			return objectPath[:end], objectPath[end:], true
+		}
+		offset = idx + 1
+	}
+	return "", "", false
+}
+
+// HandleFsck runs the checks against the storage and prints the issues found. If repair is set,
+// the garbage of the issues is deleted. Returns an error if there are issues that haven't been repaired.
+func HandleFsck(folder storage.Folder, checks []FsckCheck, repair, pretty, json bool, output io.Writer) error {
+	objects, err := storage.ListFolderRecursively(folder)
+	if err != nil {
+		return fmt.Errorf("list storage: %w", err)
+	}
+	tracelog.InfoLogger.Printf("Checking %d objects", len(objects))
+
+	var issues []FsckIssue
+	for _, check := range checks {
+		checkIssues, err := check.Check(folder, objects)
+		if err != nil {
+			return fmt.Errorf("run %s check: %w", check.Name(), err)
+		}
+		tracelog.InfoLogger.Printf("Check %s found %d issue(s)", check.Name(), len(checkIssues))
+		issues = append(issues, checkIssues...)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Check != issues[j].Check {
+			return issues[i].Check < issues[j].Check
+		}
+		return issues[i].Subject < issues[j].Subject
+	})
+
+	entities := make([]printlist.Entity, len(issues))
+	for i := range issues {
+		entities[i] = issues[i]
+	}
+	err = printlist.List(entities, output, pretty, json)
+	if err != nil {
+		return fmt.Errorf("print issues: %w", err)
+	}
+
+	unrepaired := len(issues)
+	if repair {
+		unrepaired, err = repairFsckIssues(folder, issues)
+		if err != nil {
+			return err
+		}
+	}
+	if unrepaired > 0 {
+		return fmt.Errorf("storage check found %d issue(s) that haven't been repaired", unrepaired)
+	}
+	return nil
+}
+
+func repairFsckIssues(folder storage.Folder, issues []FsckIssue) (unrepaired int, err error) {
+	garbage := map[string]bool{}
+	for _, issue := range issues {
+		if len(issue.Garbage) == 0 {
+			unrepaired++
+			continue
+		}
+		for _, name := range issue.Garbage {
+			garbage[name] = true
+		}
+	}
+	if len(garbage) == 0 {
+		return unrepaired, nil
+	}
+
+	// sentinels are deleted first, so an interrupted repair doesn't leave backups with missing data
+	var sentinels, others []string
+	for name := range garbage {
+		if strings.HasSuffix(name, utility.SentinelSuffix) {
+			sentinels = append(sentinels, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+	for _, batch := range [][]string{sentinels, others} {
+		if len(batch) == 0 {
+			continue
+		}
+		sort.Strings(batch)
+		err = folder.DeleteObjects(batch)
+		if err != nil {
+			return 0, fmt.Errorf("delete garbage: %w", err)
+		}
+	}
+	tracelog.InfoLogger.Printf("Deleted %d garbage object(s)", len(garbage))
+	return unrepaired, nil
+}
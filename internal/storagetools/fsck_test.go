@@ -0,0 +1,123 @@
+package storagetools
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestSplitBackupPath(t *testing.T) {
+	folder, fileName, ok := SplitBackupPath("basebackups_005/base_1/metadata.json")
+	assert.True(t, ok)
+	assert.Equal(t, "basebackups_005/", folder)
+	assert.Equal(t, "base_1/metadata.json", fileName)
+
+	folder, fileName, ok = SplitBackupPath("segments_005/seg0/basebackups_005/base_1_backup_stop_sentinel.json")
+	assert.True(t, ok)
+	assert.Equal(t, "segments_005/seg0/basebackups_005/", folder)
+	assert.Equal(t, "base_1_backup_stop_sentinel.json", fileName)
+
+	_, _, ok = SplitBackupPath("wal_005/000000010000000000000001.br")
+	assert.False(t, ok)
+	_, _, ok = SplitBackupPath("old_basebackups_005/base_1/metadata.json")
+	assert.False(t, ok)
+}
+
+func TestBackupFilesCheck(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	objects := []storage.Object{
+		storage.NewLocalObject("basebackups_005/base_1_backup_stop_sentinel.json", old, 1),
+		storage.NewLocalObject("basebackups_005/base_1/metadata.json", old, 1),
+		storage.NewLocalObject("basebackups_005/base_2_backup_stop_sentinel.json", old, 1),
+		storage.NewLocalObject("basebackups_005/base_3/tar_partitions/part_1.tar.br", old, 1),
+		storage.NewLocalObject("basebackups_005/base_4/tar_partitions/part_1.tar.br", time.Now(), 1),
+		storage.NewLocalObject("segments_005/seg0/basebackups_005/aosegments/1.br", old, 1),
+		storage.NewLocalObject("segments_005/seg0/basebackups_005/base_5_backup_stop_sentinel.json", old, 1),
+	}
+
+	check := &BackupFilesCheck{MinOrphanAge: 24 * time.Hour}
+	issues, err := check.Check(nil, objects)
+	require.NoError(t, err)
+	bySubject := map[string]FsckIssue{}
+	for _, issue := range issues {
+		bySubject[issue.Subject] = issue
+	}
+	assert.Len(t, issues, 4)
+	assert.Equal(t, []string{"basebackups_005/base_2_backup_stop_sentinel.json"}, bySubject["basebackups_005/base_2"].Garbage)
+	assert.Equal(t, []string{"basebackups_005/base_3/tar_partitions/part_1.tar.br"},
+		bySubject["basebackups_005/base_3"].Garbage)
+	assert.Contains(t, bySubject, "segments_005/seg0/basebackups_005/aosegments")
+	assert.Contains(t, bySubject, "segments_005/seg0/basebackups_005/base_5")
+
+	t.Run("dataless sentinels and shared dirs", func(t *testing.T) {
+		check := &BackupFilesCheck{
+			DatalessSentinelFolders: []string{"basebackups_005/"},
+			SharedDataDirs:          []string{"aosegments"},
+			MinOrphanAge:            24 * time.Hour,
+		}
+		issues, err := check.Check(nil, objects)
+		require.NoError(t, err)
+		subjects := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			subjects = append(subjects, issue.Subject)
+		}
+		assert.ElementsMatch(t, []string{"basebackups_005/base_3", "segments_005/seg0/basebackups_005/base_5"}, subjects)
+	})
+}
+
+type staticFsckCheck struct {
+	issues []FsckIssue
+}
+
+func (c staticFsckCheck) Name() string {
+	return "static"
+}
+
+func (c staticFsckCheck) Check(storage.Folder, []storage.Object) ([]FsckIssue, error) {
+	return c.issues, nil
+}
+
+func TestHandleFsck(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	_ = folder.PutObject("basebackups_005/base_1_backup_stop_sentinel.json", strings.NewReader("{}"))
+	_ = folder.PutObject("basebackups_005/base_1/metadata.json", strings.NewReader("{}"))
+	_ = folder.PutObject("basebackups_005/base_2_backup_stop_sentinel.json", strings.NewReader("{}"))
+	_ = folder.PutObject("basebackups_005/base_3/tar_partitions/part_1.tar.br", strings.NewReader("data"))
+
+	var output bytes.Buffer
+	err := HandleFsck(folder, DefaultFsckChecks(0), false, false, true, &output)
+	assert.Error(t, err)
+	var issues []FsckIssue
+	require.NoError(t, json.Unmarshal(output.Bytes(), &issues))
+	require.Len(t, issues, 2)
+	assert.Equal(t, "basebackups_005/base_2", issues[0].Subject)
+	assert.Equal(t, "basebackups_005/base_3", issues[1].Subject)
+
+	t.Run("repair deletes garbage", func(t *testing.T) {
+		output.Reset()
+		require.NoError(t, HandleFsck(folder, DefaultFsckChecks(0), true, false, false, &output))
+		for name, exists := range map[string]bool{
+			"basebackups_005/base_1_backup_stop_sentinel.json":    true,
+			"basebackups_005/base_1/metadata.json":                true,
+			"basebackups_005/base_2_backup_stop_sentinel.json":    false,
+			"basebackups_005/base_3/tar_partitions/part_1.tar.br": false,
+		} {
+			actual, err := folder.Exists(name)
+			require.NoError(t, err)
+			assert.Equal(t, exists, actual, name)
+		}
+		require.NoError(t, HandleFsck(folder, DefaultFsckChecks(0), false, false, false, &output))
+	})
+
+	t.Run("issues without garbage can't be repaired", func(t *testing.T) {
+		checks := []FsckCheck{staticFsckCheck{issues: []FsckIssue{{Check: "static", Subject: "gap"}}}}
+		assert.Error(t, HandleFsck(folder, checks, true, false, false, &output))
+	})
+}
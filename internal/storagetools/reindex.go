@@ -0,0 +1,62 @@
+package storagetools
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/catalog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// HandleReindex rebuilds the backup catalogs of all backups folders of the storage,
+// including the nested ones (e.g. the backup folders of Greenplum segments)
+func HandleReindex(folder storage.Folder) error {
+	backupsFolders, err := FindBackupsFolders(folder)
+	if err != nil {
+		return err
+	}
+	for _, backupsFolder := range backupsFolders {
+		subFolder := folder.GetSubFolder(backupsFolder)
+		backupCatalog, err := catalog.Rebuild(subFolder)
+		if err != nil {
+			return fmt.Errorf("rebuild backup catalog in %s: %w", backupsFolder, err)
+		}
+		err = catalog.Write(subFolder, backupCatalog)
+		if err != nil {
+			return fmt.Errorf("write backup catalog in %s: %w", backupsFolder, err)
+		}
+		tracelog.InfoLogger.Printf("Rebuilt backup catalog in %s: %d backup(s)",
+			backupsFolder, len(backupCatalog.Entries()))
+	}
+	return nil
+}
+
+// FindBackupsFolders provides the paths of the backups folders relative to the folder.
+// The backups folders and the log folders aren't looked into.
+func FindBackupsFolders(folder storage.Folder) ([]string, error) {
+	var backupsFolders []string
+	queue := []string{""}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		_, subFolders, err := folder.GetSubFolder(current).ListFolder()
+		if err != nil {
+			return nil, fmt.Errorf("list folder %q: %w", current, err)
+		}
+		for _, subFolder := range subFolders {
+			subFolderPath := current + path.Base(subFolder.GetPath()) + "/"
+			switch {
+			case path.Base(subFolderPath)+"/" == utility.BaseBackupPath:
+				backupsFolders = append(backupsFolders, subFolderPath)
+			case slices.Contains(usageLogPrefixes, subFolderPath):
+				// the log folders are large and don't contain backups
+			default:
+				queue = append(queue, subFolderPath)
+			}
+		}
+	}
+	return backupsFolders, nil
+}
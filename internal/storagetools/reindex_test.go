@@ -0,0 +1,43 @@
+package storagetools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/catalog"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func TestHandleReindex(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	for _, name := range []string{
+		"basebackups_005/base_1_backup_stop_sentinel.json",
+		"basebackups_005/base_1/metadata.json",
+		"basebackups_005/base_1/tar_partitions/part_1.tar.br",
+		"basebackups_005/base_2/tar_partitions/part_1.tar.br",
+		"segments_005/seg0/basebackups_005/base_1_D_1_backup_stop_sentinel.json",
+		"wal_005/000000010000000000000001.br",
+	} {
+		require.NoError(t, folder.PutObject(name, strings.NewReader(`{"IsPermanent":true}`)))
+	}
+
+	backupsFolders, err := FindBackupsFolders(folder)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"basebackups_005/", "segments_005/seg0/basebackups_005/"}, backupsFolders)
+
+	require.NoError(t, HandleReindex(folder))
+
+	backupCatalog, err := catalog.Read(folder.GetSubFolder("basebackups_005"))
+	require.NoError(t, err)
+	require.Len(t, backupCatalog.Entries(), 1)
+	entry := backupCatalog.Entries()[0]
+	assert.Equal(t, "base_1", entry.BackupName)
+	assert.NotNil(t, entry.Metadata)
+	assert.True(t, *entry.Summary().IsPermanent)
+
+	backupCatalog, err = catalog.Read(folder.GetSubFolder("segments_005/seg0/basebackups_005"))
+	require.NoError(t, err)
+	assert.Len(t, backupCatalog.Entries(), 1)
+}
@@ -3,6 +3,7 @@ package transfer
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/copy"
 	"github.com/wal-g/wal-g/internal/multistorage/exec"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -31,6 +33,8 @@ type HandlerConfig struct {
 	Concurrency              int
 	AppearanceChecks         uint
 	AppearanceChecksInterval time.Duration
+	// Recoder re-encodes the files instead of moving them byte-for-byte, if set
+	Recoder *copy.Recoder
 }
 
 func NewHandler(
@@ -47,14 +51,18 @@ func NewHandler(
 		return nil, fmt.Errorf("configure target storage folder: %w", err)
 	}
 
+	return newHandler(source.RootFolder(), target.RootFolder(), fileLister, cfg), nil
+}
+
+func newHandler(source, target storage.Folder, fileLister FileLister, cfg *HandlerConfig) *Handler {
 	return &Handler{
-		source:          source.RootFolder(),
-		target:          target.RootFolder(),
+		source:          source,
+		target:          target,
 		fileLister:      fileLister,
 		cfg:             cfg,
 		fileStatuses:    new(sync.Map),
 		jobRequirements: map[jobKey][]jobRequirement{},
-	}, nil
+	}
 }
 
 func (h *Handler) Handle() error {
@@ -64,7 +72,18 @@ func (h *Handler) Handle() error {
 	}
 
 	workersNum := utility.Min(h.cfg.Concurrency, len(files))
-	return h.transferConcurrently(workersNum, files, filesNum)
+	err = h.transferConcurrently(workersNum, files, filesNum)
+	if err != nil {
+		return err
+	}
+
+	if h.cfg.Recoder != nil {
+		err = h.cfg.Recoder.UpdateCompressedSizes()
+		if err != nil {
+			return fmt.Errorf("update compressed size of recoded backups: %w", err)
+		}
+	}
+	return nil
 }
 
 type transferJob struct {
@@ -136,8 +155,8 @@ func (h *Handler) transferConcurrently(workers int, files []FilesGroup, filesNum
 
 	errs := make(chan error, len(files))
 
-	workersCtx, cancelWorkers := context.WithCancel(context.Background())
-	cancelOnSignal(cancelWorkers)
+	workersCtx, cancelWorkers := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancelWorkers()
 	workersWG := new(sync.WaitGroup)
 	workersWG.Add(workers)
 	for i := 0; i < workers; i++ {
@@ -194,15 +213,6 @@ func (h *Handler) saveRequirements(file FileToMove) {
 	}
 }
 
-func cancelOnSignal(cancel context.CancelFunc) {
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, os.Interrupt)
-	go func() {
-		<-sigs
-		cancel()
-	}()
-}
-
 func (h *Handler) transferFilesWorker(
 	ctx context.Context,
 	jobsQueue chan transferJob,
@@ -292,10 +302,22 @@ func (h *Handler) copyFile(job transferJob) (newJob *transferJob, err error) {
 	}
 	defer utility.LoggedClose(content, "close object content read from the source storage")
 
-	err = h.target.PutObject(job.key.filePath, content)
+	var targetContent io.Reader = content
+	if h.cfg.Recoder != nil {
+		targetContent, err = h.cfg.Recoder.Transform(job.key.filePath, content)
+		if err != nil {
+			return nil, fmt.Errorf("recode file: %w", err)
+		}
+	}
+
+	targetPath := h.targetPath(job.key.filePath)
+	err = h.target.PutObject(targetPath, targetContent)
 	if err != nil {
 		return nil, fmt.Errorf("write file to the target storage: %w", err)
 	}
+	if h.cfg.Recoder != nil {
+		h.cfg.Recoder.TrackSentinel(h.target, targetPath)
+	}
 
 	h.fileStatuses.Store(job.key.filePath, transferStatusCopied)
 	job.key.jobType = jobTypeWait
@@ -355,13 +377,32 @@ func (h *Handler) checkForAppearance(prevCheck time.Time, filePath string) (appe
 		time.Sleep(waitTime)
 	}
 
-	appeared, err = h.target.Exists(filePath)
+	appeared, err = h.target.Exists(h.targetPath(filePath))
 	if err != nil {
 		return false, fmt.Errorf("check if file exists in the target storage: %w", err)
 	}
 	return appeared, nil
 }
 
+func (h *Handler) targetPath(filePath string) string {
+	if h.cfg.Recoder == nil {
+		return filePath
+	}
+	return h.cfg.Recoder.Rename(filePath)
+}
+
+// transferredFiles returns the files that have been copied to the target storage and appeared there
+func (h *Handler) transferredFiles() []string {
+	var files []string
+	h.fileStatuses.Range(func(key, value any) bool {
+		if value.(transferStatus) >= transferStatusAppeared {
+			files = append(files, key.(string))
+		}
+		return true
+	})
+	return files
+}
+
 func (h *Handler) deleteFile(job transferJob) error {
 	err := h.source.DeleteObjects([]string{job.key.filePath})
 	if err != nil {
@@ -14,6 +14,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/compression/lzma"
+	"github.com/wal-g/wal-g/internal/copy"
 	"github.com/wal-g/wal-g/pkg/storages/memory"
 	"github.com/wal-g/wal-g/pkg/storages/memory/mock"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
@@ -352,6 +357,7 @@ func TestTransferHandler_copyFile(t *testing.T) {
 			source:       memory.NewFolder("source/", memory.NewKVS()),
 			target:       memory.NewFolder("target/", memory.NewKVS()),
 			fileStatuses: new(sync.Map),
+			cfg:          &HandlerConfig{},
 		}
 	}
 
@@ -440,6 +446,35 @@ func TestTransferHandler_copyFile(t *testing.T) {
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "read file")
 	})
+
+	t.Run("recompress file", func(t *testing.T) {
+		h := defaultHandler()
+		h.cfg.Recoder = copy.NewRecoder(nil, nil, compression.Compressors[lzma.AlgorithmName])
+
+		_ = h.source.PutObject("1.lz4", internal.CompressAndEncrypt(
+			bytes.NewBufferString("source"), compression.Compressors[lz4.AlgorithmName], nil))
+
+		job := transferJob{
+			key: jobKey{
+				filePath: "1.lz4",
+				jobType:  jobTypeCopy,
+			},
+		}
+
+		_, err := h.copyFile(job)
+		require.NoError(t, err)
+
+		exists, err := h.target.Exists("1.lz4")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		file, err := h.target.ReadObject("1.lzma")
+		require.NoError(t, err)
+		decompressed, err := lzma.Decompressor{}.Decompress(file)
+		require.NoError(t, err)
+		content, _ := io.ReadAll(decompressed)
+		assert.Equal(t, "source", string(content))
+	})
 }
 
 func TestTransferHandler_aitFile(t *testing.T) {
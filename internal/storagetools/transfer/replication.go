@@ -0,0 +1,390 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/multistorage/exec"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// ReplicationCursorName is the name of the file in the target storage, where the replication state is persisted
+const ReplicationCursorName = "walg_replication_cursor.json"
+
+// ReplicationCursor is the state of the replication that is kept between the runs. Its size doesn't depend on
+// the number of replicated files.
+type ReplicationCursor struct {
+	// Watermarks are the modification times of the newest replicated files per folder: each log folder, the backups
+	// folder and the root folder for the other files. Only the files modified after the watermark of their folder are
+	// checked on the next pass, so the files left for the next pass in one folder don't hold back the others.
+	Watermarks map[string]time.Time `json:"watermarks,omitempty"`
+	// CopiedBackups are the backups copied to the target storage by the replication. Only their deletion from the
+	// source storage is replicated, so backups that have been in the target storage already are never deleted.
+	// The backups are tracked only when the deletions are applied.
+	CopiedBackups map[string]bool `json:"copied_backups,omitempty"`
+	// FirstCopiedLogs are the names of the oldest log files copied by the replication per log folder. The deletion
+	// of the oldest logs from the source storage, e.g. by retention, is replicated from them, so older logs that
+	// have been in the target storage already are never deleted.
+	FirstCopiedLogs map[string]string `json:"first_copied_logs,omitempty"`
+	// PendingBackupDeletions are the copied backups missing in the source storage, with the time they have been
+	// noticed missing. They are deleted from the target storage after the grace period.
+	PendingBackupDeletions map[string]time.Time `json:"pending_backup_deletions,omitempty"`
+	// PendingLogDeletions are the deletions of the oldest logs from the source storage per log folder, in the order
+	// they have been noticed. The copied logs are deleted from the target storage after the grace period.
+	PendingLogDeletions map[string][]LogDeletion `json:"pending_log_deletions,omitempty"`
+}
+
+// LogDeletion is the deletion of the logs before the oldest one left in the source storage
+type LogDeletion struct {
+	Before  string    `json:"before"`
+	Noticed time.Time `json:"noticed"`
+}
+
+func newReplicationCursor() *ReplicationCursor {
+	return &ReplicationCursor{
+		Watermarks:             map[string]time.Time{},
+		CopiedBackups:          map[string]bool{},
+		FirstCopiedLogs:        map[string]string{},
+		PendingBackupDeletions: map[string]time.Time{},
+		PendingLogDeletions:    map[string][]LogDeletion{},
+	}
+}
+
+type ReplicatorConfig struct {
+	Interval            time.Duration
+	ApplyDeletions      bool
+	DeletionGracePeriod time.Duration
+	MaxFiles            int
+	Handler             *HandlerConfig
+}
+
+// Replicator continuously replicates the source storage to the target one. It copies the new complete objects
+// in the dependency order and applies the deletions from the source storage after the grace period.
+type Replicator struct {
+	source storage.Folder
+	target storage.Folder
+	cfg    *ReplicatorConfig
+	cursor *ReplicationCursor
+	// cursorChanged shows that the cursor must be saved at the end of the pass
+	cursorChanged bool
+}
+
+func NewReplicator(sourceStorage, targetStorage string, cfg *ReplicatorConfig) (*Replicator, error) {
+	source, err := exec.ConfigureStorage(sourceStorage)
+	if err != nil {
+		return nil, fmt.Errorf("configure source storage folder: %w", err)
+	}
+	target, err := exec.ConfigureStorage(targetStorage)
+	if err != nil {
+		return nil, fmt.Errorf("configure target storage folder: %w", err)
+	}
+	return newReplicator(source.RootFolder(), target.RootFolder(), cfg), nil
+}
+
+func newReplicator(source, target storage.Folder, cfg *ReplicatorConfig) *Replicator {
+	// files must stay in the source storage, the replication only copies them
+	handlerCfg := *cfg.Handler
+	handlerCfg.PreserveInSource = true
+	cfg.Handler = &handlerCfg
+
+	return &Replicator{
+		source: source,
+		target: target,
+		cfg:    cfg,
+	}
+}
+
+// Run replicates the storages until the context is canceled
+func (r *Replicator) Run(ctx context.Context) error {
+	for {
+		err := r.Sync()
+		if err != nil {
+			tracelog.ErrorLogger.Printf("Replication failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.cfg.Interval):
+		}
+	}
+}
+
+// Sync performs a single replication pass
+func (r *Replicator) Sync() error {
+	if r.cursor == nil {
+		cursor, err := r.loadCursor()
+		if err != nil {
+			return err
+		}
+		r.cursor = cursor
+	}
+
+	lister := NewReplicationFileLister(r.cursor.Watermarks, r.cfg.MaxFiles)
+	handler := newHandler(r.source, r.target, lister, r.cfg.Handler)
+	transferErr := handler.Handle()
+
+	if lister.sourceFiles == nil {
+		// the storages haven't been listed
+		return transferErr
+	}
+	r.trackCopied(handler.transferredFiles())
+	if transferErr == nil && !maps.Equal(lister.next, r.cursor.Watermarks) {
+		// the failed files are retried on the next pass, so the watermarks aren't moved
+		r.cursor.Watermarks = lister.next
+		r.cursorChanged = true
+	}
+
+	var deletionErr error
+	if r.cfg.ApplyDeletions {
+		deletionErr = r.applyDeletions(lister, time.Now())
+	}
+
+	if r.cursorChanged {
+		err := r.saveCursor()
+		if err != nil {
+			return err
+		}
+		r.cursorChanged = false
+	}
+	if transferErr != nil {
+		return transferErr
+	}
+	return deletionErr
+}
+
+func (r *Replicator) trackCopied(transferred []string) {
+	tracelog.InfoLogger.Printf("Files replicated in this pass: %d", len(transferred))
+	if !r.cfg.ApplyDeletions {
+		return
+	}
+	for _, name := range transferred {
+		if category, backupName := categoriseReplicatedFile(name); category == fileCategorySentinel {
+			r.cursor.CopiedBackups[backupName] = true
+			r.cursorChanged = true
+			continue
+		}
+		if logPrefix, ok := findLogPrefix(name); ok {
+			first, ok := r.cursor.FirstCopiedLogs[logPrefix]
+			if !ok || name < first {
+				r.cursor.FirstCopiedLogs[logPrefix] = name
+				r.cursorChanged = true
+			}
+		}
+	}
+}
+
+func (r *Replicator) applyDeletions(lister *ReplicationFileLister, now time.Time) error {
+	err := r.applyBackupDeletions(lister.sourceFiles, now)
+	if err != nil {
+		return err
+	}
+	return r.applyLogDeletions(lister.sourceFiles, now)
+}
+
+func (r *Replicator) applyBackupDeletions(sourceFiles map[string]storage.Object, now time.Time) error {
+	for name := range r.cursor.CopiedBackups {
+		_, inSource := sourceFiles[sentinelPath(name)]
+		_, pending := r.cursor.PendingBackupDeletions[name]
+		if inSource && pending {
+			delete(r.cursor.PendingBackupDeletions, name)
+			r.cursorChanged = true
+		}
+		if !inSource && !pending {
+			r.cursor.PendingBackupDeletions[name] = now
+			r.cursorChanged = true
+		}
+	}
+
+	var toDelete []string
+	for name, noticed := range r.cursor.PendingBackupDeletions {
+		if !r.cursor.CopiedBackups[name] {
+			delete(r.cursor.PendingBackupDeletions, name)
+			r.cursorChanged = true
+			continue
+		}
+		if now.Sub(noticed) >= r.cfg.DeletionGracePeriod {
+			toDelete = append(toDelete, name)
+		}
+	}
+	sort.Strings(toDelete)
+	for _, name := range toDelete {
+		err := deleteBackup(r.target, name)
+		if err != nil {
+			return fmt.Errorf("delete backup %q from the target storage: %w", name, err)
+		}
+		delete(r.cursor.CopiedBackups, name)
+		delete(r.cursor.PendingBackupDeletions, name)
+		r.cursorChanged = true
+	}
+	if len(toDelete) > 0 {
+		tracelog.InfoLogger.Printf("Backups deleted from the target storage after the source: %d", len(toDelete))
+	}
+	return nil
+}
+
+// deleteBackup deletes the sentinel first, so the target storage doesn't have backups with missing data
+func deleteBackup(folder storage.Folder, name string) error {
+	err := folder.DeleteObjects([]string{sentinelPath(name)})
+	if err != nil {
+		return err
+	}
+	data, err := storage.ListFolderRecursivelyWithPrefix(folder, utility.BaseBackupPath+name+"/")
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(data))
+	for _, file := range data {
+		names = append(names, file.GetName())
+	}
+	return folder.DeleteObjects(names)
+}
+
+func sentinelPath(backupName string) string {
+	return utility.BaseBackupPath + backupName + utility.SentinelSuffix
+}
+
+// applyLogDeletions replicates the deletion of the oldest logs, which is the way the retention deletes them. The logs
+// are kept if the log folder of the source storage is empty, e.g. when it's being recreated.
+func (r *Replicator) applyLogDeletions(sourceFiles map[string]storage.Object, now time.Time) error {
+	oldestLogs := map[string]string{}
+	for name := range sourceFiles {
+		if logPrefix, ok := findLogPrefix(name); ok {
+			if oldest, ok := oldestLogs[logPrefix]; !ok || name < oldest {
+				oldestLogs[logPrefix] = name
+			}
+		}
+	}
+
+	for logPrefix, first := range r.cursor.FirstCopiedLogs {
+		oldest, ok := oldestLogs[logPrefix]
+		if !ok {
+			continue
+		}
+		deletions := r.pendingLogDeletions(logPrefix, first, oldest, now)
+
+		applied := 0
+		for applied < len(deletions) && now.Sub(deletions[applied].Noticed) >= r.cfg.DeletionGracePeriod {
+			applied++
+		}
+		if applied > 0 {
+			before := deletions[applied-1].Before
+			err := deleteLogs(r.target, logPrefix, first, before)
+			if err != nil {
+				return fmt.Errorf("delete logs from the target storage: %w", err)
+			}
+			r.cursor.FirstCopiedLogs[logPrefix] = before
+			deletions = deletions[applied:]
+			r.cursorChanged = true
+		}
+		if len(deletions) > 0 {
+			r.cursor.PendingLogDeletions[logPrefix] = deletions
+		} else {
+			delete(r.cursor.PendingLogDeletions, logPrefix)
+		}
+	}
+	return nil
+}
+
+// pendingLogDeletions notices the deletion of the copied logs before the oldest log of the source storage, and
+// cancels the deletions of the logs that have reappeared in it
+func (r *Replicator) pendingLogDeletions(logPrefix, first, oldest string, now time.Time) []LogDeletion {
+	var deletions []LogDeletion
+	for _, deletion := range r.cursor.PendingLogDeletions[logPrefix] {
+		if deletion.Before <= oldest {
+			deletions = append(deletions, deletion)
+		}
+	}
+	if first < oldest && (len(deletions) == 0 || deletions[len(deletions)-1].Before < oldest) {
+		deletions = append(deletions, LogDeletion{Before: oldest, Noticed: now})
+	}
+	if len(deletions) != len(r.cursor.PendingLogDeletions[logPrefix]) {
+		r.cursorChanged = true
+	}
+	return deletions
+}
+
+// deleteLogs deletes the logs in the range [from, before) from the log folder
+func deleteLogs(folder storage.Folder, logPrefix, from, before string) error {
+	logs, err := storage.ListFolderRecursivelyWithPrefix(folder, logPrefix)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, file := range logs {
+		if file.GetName() >= from && file.GetName() < before {
+			names = append(names, file.GetName())
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	err = folder.DeleteObjects(names)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Logs deleted from the target storage after the source: %d", len(names))
+	return nil
+}
+
+func (r *Replicator) loadCursor() (*ReplicationCursor, error) {
+	exists, err := r.target.Exists(ReplicationCursorName)
+	if err != nil {
+		return nil, fmt.Errorf("check replication cursor existence: %w", err)
+	}
+	if !exists {
+		tracelog.InfoLogger.Printf("No replication cursor found, starting from scratch")
+		return newReplicationCursor(), nil
+	}
+
+	reader, err := r.target.ReadObject(ReplicationCursorName)
+	if err != nil {
+		return nil, fmt.Errorf("read replication cursor: %w", err)
+	}
+	defer utility.LoggedClose(reader, "close replication cursor")
+
+	cursor := newReplicationCursor()
+	err = json.NewDecoder(reader).Decode(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal replication cursor: %w", err)
+	}
+	if cursor.Watermarks == nil {
+		cursor.Watermarks = map[string]time.Time{}
+	}
+	if cursor.CopiedBackups == nil {
+		cursor.CopiedBackups = map[string]bool{}
+	}
+	if cursor.FirstCopiedLogs == nil {
+		cursor.FirstCopiedLogs = map[string]string{}
+	}
+	if cursor.PendingBackupDeletions == nil {
+		cursor.PendingBackupDeletions = map[string]time.Time{}
+	}
+	if cursor.PendingLogDeletions == nil {
+		cursor.PendingLogDeletions = map[string][]LogDeletion{}
+	}
+	tracelog.InfoLogger.Printf("Resuming replication of the folders: %d", len(cursor.Watermarks))
+	return cursor, nil
+}
+
+func (r *Replicator) saveCursor() error {
+	data, err := json.Marshal(r.cursor)
+	if err != nil {
+		return fmt.Errorf("marshal replication cursor: %w", err)
+	}
+	err = r.target.PutObject(ReplicationCursorName, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("save replication cursor: %w", err)
+	}
+	return nil
+}
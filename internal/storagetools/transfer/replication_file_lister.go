@@ -0,0 +1,361 @@
+package transfer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// logPrefixes are the folders of the continuous logs (PostgreSQL WAL, MySQL binlogs and MongoDB oplog archives).
+// Their files are replicated in the order of their names, so the target storage never has gaps in the logs.
+var logPrefixes = []string{
+	utility.WalPath,
+	"binlog_" + utility.VersionStr + "/",
+	"oplog_" + utility.VersionStr + "/",
+}
+
+// replicationCursorOverlap is the time before the replication watermark, when the files are still considered new. The
+// modification time of a file can be the start of its upload, so the file may appear after the watermark has passed it.
+const replicationCursorOverlap = 5 * time.Minute
+
+// ReplicationFileLister lists the files of the source storage that are missing in the target one. Only the files modified
+// at or after the watermark of their folder are considered new, so the target storage isn't listed on every pass.
+// The data of a backup is listed only when the backup is complete and new, i.e. its sentinel file is new, so the folders
+// of the old backups aren't listed at all. The listed source files are kept, so they can be used to find the backups
+// and logs deleted from the source storage.
+type ReplicationFileLister struct {
+	MaxFiles int
+	// Since are the replication watermarks per folder (see watermarkFolder), the files modified before them are
+	// considered replicated
+	Since map[string]time.Time
+
+	// sourceFiles are the logs, backup sentinels and other files of the source storage, without the backup data
+	sourceFiles map[string]storage.Object
+	// next are the watermarks for the next pass, they don't pass the new files that haven't been listed to move
+	next map[string]time.Time
+}
+
+func NewReplicationFileLister(since map[string]time.Time, maxFiles int) *ReplicationFileLister {
+	return &ReplicationFileLister{MaxFiles: maxFiles, Since: since}
+}
+
+func (l *ReplicationFileLister) ListFilesToMove(source, target storage.Folder) (files []FilesGroup, num int, err error) {
+	l.sourceFiles, err = listReplicatedFiles(source)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list files in the source storage: %w", err)
+	}
+
+	l.next = make(map[string]time.Time, len(l.Since))
+	for folder, since := range l.Since {
+		l.next[folder] = since
+	}
+	backups := map[string]backupFiles{}
+	logs := map[string][]string{}
+	var others []string
+	for name, file := range l.sourceFiles {
+		folder := watermarkFolder(name)
+		if !l.isNew(folder, file) {
+			continue
+		}
+		if file.GetLastModified().After(l.next[folder]) {
+			l.next[folder] = file.GetLastModified()
+		}
+		if category, backupName := categoriseReplicatedFile(name); category == fileCategorySentinel {
+			backups[backupName] = backupFiles{sentinel: file}
+			continue
+		}
+		if logPrefix, ok := findLogPrefix(name); ok {
+			logs[logPrefix] = append(logs[logPrefix], name)
+			continue
+		}
+		others = append(others, name)
+	}
+
+	isMissing, err := l.targetChecker(target)
+	if err != nil {
+		return nil, 0, err
+	}
+	for logPrefix, names := range logs {
+		logs[logPrefix], err = filterMissing(names, isMissing)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	others, err = filterMissing(others, isMissing)
+	if err != nil {
+		return nil, 0, err
+	}
+	for name, backup := range backups {
+		backup, err = missingBackupFiles(source, target, name, backup, isMissing)
+		if err != nil {
+			return nil, 0, err
+		}
+		if backup.sentinel == nil {
+			delete(backups, name)
+			continue
+		}
+		backups[name] = backup
+	}
+
+	files, num = l.groupFiles(backups, logs, others)
+	tracelog.InfoLogger.Printf("Files will be replicated: %d", num)
+	return files, num, nil
+}
+
+func (l *ReplicationFileLister) isNew(folder string, file storage.Object) bool {
+	since := l.Since[folder]
+	if since.IsZero() {
+		return true
+	}
+	return !file.GetLastModified().Before(since.Add(-replicationCursorOverlap))
+}
+
+// targetChecker checks if files are missing in the target storage. The whole target storage is listed only on the first
+// pass, when all source files are new, and existence of each file is checked after that.
+func (l *ReplicationFileLister) targetChecker(target storage.Folder) (func(name string) (bool, error), error) {
+	if len(l.Since) > 0 {
+		return func(name string) (bool, error) {
+			exists, err := target.Exists(name)
+			if err != nil {
+				return false, fmt.Errorf("check if file exists in the target storage: %w", err)
+			}
+			return !exists, nil
+		}, nil
+	}
+	targetFiles, err := listFiles(target)
+	if err != nil {
+		return nil, fmt.Errorf("list files in the target storage: %w", err)
+	}
+	return func(name string) (bool, error) {
+		_, ok := targetFiles[name]
+		return !ok, nil
+	}, nil
+}
+
+func filterMissing(names []string, isMissing func(name string) (bool, error)) ([]string, error) {
+	missing := names[:0]
+	for _, name := range names {
+		ok, err := isMissing(name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// missingBackupFiles lists the data of the backup in the source storage and leaves only the files that are missing in
+// the target storage. The backup is skipped if its sentinel is already in the target storage.
+func missingBackupFiles(
+	source, target storage.Folder,
+	name string,
+	backup backupFiles,
+	isMissing func(name string) (bool, error),
+) (backupFiles, error) {
+	missing, err := isMissing(backup.sentinel.GetName())
+	if err != nil {
+		return backupFiles{}, err
+	}
+	if !missing {
+		return backupFiles{}, nil
+	}
+
+	backupPath := utility.BaseBackupPath + name + "/"
+	sourceData, err := storage.ListFolderRecursivelyWithPrefix(source, backupPath)
+	if err != nil {
+		return backupFiles{}, fmt.Errorf("list backup %q in the source storage: %w", name, err)
+	}
+	targetData, err := storage.ListFolderRecursivelyWithPrefix(target, backupPath)
+	if err != nil {
+		return backupFiles{}, fmt.Errorf("list backup %q in the target storage: %w", name, err)
+	}
+	existing := make(map[string]bool, len(targetData))
+	for _, file := range targetData {
+		existing[file.GetName()] = true
+	}
+	backup.backupData = make([]storage.Object, 0, len(sourceData))
+	for _, file := range sourceData {
+		if !existing[file.GetName()] {
+			backup.backupData = append(backup.backupData, file)
+		}
+	}
+	return backup, nil
+}
+
+func (l *ReplicationFileLister) groupFiles(
+	backups map[string]backupFiles,
+	logs map[string][]string,
+	others []string,
+) (files []FilesGroup, num int) {
+	canAdd := func(group FilesGroup) bool {
+		return num+len(group) <= l.MaxFiles
+	}
+
+	// logs go first, since backups can't be restored without them
+	for _, logPrefix := range logPrefixes {
+		group := chainGroup(logs[logPrefix])
+		if !canAdd(group) {
+			// the chain can be cut at any file without making gaps
+			l.holdCursor(group[l.MaxFiles-num:])
+			group = group[:l.MaxFiles-num]
+		}
+		if len(group) == 0 {
+			continue
+		}
+		files = append(files, group)
+		num += len(group)
+	}
+
+	backupNames := make([]string, 0, len(backups))
+	for name := range backups {
+		backupNames = append(backupNames, name)
+	}
+	sort.Strings(backupNames)
+	for _, name := range backupNames {
+		group := linkGroup(backups[name])
+		if !canAdd(group) {
+			// a part of the backup data is copied, and the rest of it and the sentinel are left for the next passes,
+			// so a backup with more files than the limit is copied too
+			l.holdCursor(group)
+			group = group[:l.MaxFiles-num]
+			for i := range group {
+				group[i].deleteAfter = nil
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		files = append(files, group)
+		num += len(group)
+	}
+
+	sort.Strings(others)
+	for _, name := range others {
+		group := FilesGroup{FileToMove{path: name}}
+		if !canAdd(group) {
+			l.holdCursor(group)
+			continue
+		}
+		files = append(files, group)
+		num++
+	}
+	return files, num
+}
+
+// holdCursor keeps the watermarks for the next pass before the files that are left for it. Only the listed source
+// files matter, since the backup data is listed whenever its sentinel is new.
+func (l *ReplicationFileLister) holdCursor(group FilesGroup) {
+	for _, file := range group {
+		sourceFile, ok := l.sourceFiles[file.path]
+		if !ok {
+			continue
+		}
+		folder := watermarkFolder(file.path)
+		if sourceFile.GetLastModified().Before(l.next[folder]) {
+			l.next[folder] = sourceFile.GetLastModified()
+		}
+	}
+}
+
+// chainGroup makes a group of log files, where each file is copied only after the previous one.
+func chainGroup(names []string) FilesGroup {
+	sort.Strings(names)
+	group := make(FilesGroup, 0, len(names))
+	for i, name := range names {
+		file := FileToMove{path: name}
+		if i > 0 {
+			file.copyAfter = []string{names[i-1]}
+		}
+		group = append(group, file)
+	}
+	return group
+}
+
+// categoriseReplicatedFile is similar to categoriseFile, but recognizes backups with any names,
+// since backups of MySQL and MongoDB aren't prefixed with "base_".
+func categoriseReplicatedFile(filePath string) (category fileCategory, backupName string) {
+	if !strings.HasPrefix(filePath, utility.BaseBackupPath) {
+		return fileCategoryOther, ""
+	}
+	dir, fileName := path.Split(strings.TrimPrefix(filePath, utility.BaseBackupPath))
+	if dir == "" {
+		if strings.HasSuffix(fileName, utility.SentinelSuffix) {
+			return fileCategorySentinel, strings.TrimSuffix(fileName, utility.SentinelSuffix)
+		}
+		return fileCategoryOther, ""
+	}
+	return fileCategoryBackupData, dir[:strings.Index(dir, "/")]
+}
+
+func findLogPrefix(filePath string) (string, bool) {
+	for _, logPrefix := range logPrefixes {
+		if strings.HasPrefix(filePath, logPrefix) {
+			return logPrefix, true
+		}
+	}
+	return "", false
+}
+
+// watermarkFolder returns the folder, which replication watermark the file belongs to: a log folder, the backups
+// folder or the root folder for the other files
+func watermarkFolder(filePath string) string {
+	if logPrefix, ok := findLogPrefix(filePath); ok {
+		return logPrefix
+	}
+	if strings.HasPrefix(filePath, utility.BaseBackupPath) {
+		return utility.BaseBackupPath
+	}
+	return ""
+}
+
+// listReplicatedFiles lists the source storage recursively, except for the folders of the backups, where only the
+// sentinels and other files on the top level are listed.
+func listReplicatedFiles(folder storage.Folder) (map[string]storage.Object, error) {
+	objects, subFolders, err := folder.ListFolder()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]storage.Object, len(objects))
+	for _, object := range objects {
+		files[object.GetName()] = object
+	}
+	for _, subFolder := range subFolders {
+		subFolderPath := strings.TrimPrefix(subFolder.GetPath(), folder.GetPath())
+		var subObjects []storage.Object
+		if subFolderPath == utility.BaseBackupPath {
+			subObjects, _, err = subFolder.ListFolder()
+			for i, object := range subObjects {
+				subObjects[i] = storage.NewLocalObject(subFolderPath+object.GetName(), object.GetLastModified(), object.GetSize())
+			}
+		} else {
+			subObjects, err = storage.ListFolderRecursivelyWithPrefix(folder, subFolderPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list folder %q: %w", subFolderPath, err)
+		}
+		for _, object := range subObjects {
+			files[object.GetName()] = object
+		}
+	}
+	return files, nil
+}
+
+func listFiles(folder storage.Folder) (map[string]storage.Object, error) {
+	objects, err := storage.ListFolderRecursively(folder)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]storage.Object, len(objects))
+	for _, object := range objects {
+		files[object.GetName()] = object
+	}
+	return files, nil
+}
@@ -0,0 +1,249 @@
+package transfer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func testReplicatorConfig() *ReplicatorConfig {
+	return &ReplicatorConfig{
+		Interval:            time.Minute,
+		ApplyDeletions:      true,
+		DeletionGracePeriod: time.Hour,
+		MaxFiles:            100,
+		Handler: &HandlerConfig{
+			Concurrency: 4,
+		},
+	}
+}
+
+func assertExist(t *testing.T, folder storage.Folder, exist bool, names ...string) {
+	for _, name := range names {
+		exists, err := folder.Exists(name)
+		require.NoError(t, err)
+		assert.Equal(t, exist, exists, name)
+	}
+}
+
+func TestReplicationFileLister_ListFilesToMove(t *testing.T) {
+	source := memory.NewFolder("source/", memory.NewKVS())
+	target := memory.NewFolder("target/", memory.NewKVS())
+
+	_ = source.PutObject("wal_005/000000010000000000000001.br", &bytes.Buffer{})
+	_ = source.PutObject("wal_005/000000010000000000000002.br", &bytes.Buffer{})
+	_ = source.PutObject("wal_005/000000010000000000000003.br", &bytes.Buffer{})
+	_ = target.PutObject("wal_005/000000010000000000000001.br", &bytes.Buffer{})
+	_ = source.PutObject("basebackups_005/stream_1_backup_stop_sentinel.json", &bytes.Buffer{})
+	_ = source.PutObject("basebackups_005/stream_1/stream.br", &bytes.Buffer{})
+	_ = source.PutObject("basebackups_005/stream_2/stream.br", &bytes.Buffer{})
+	_ = source.PutObject("other", &bytes.Buffer{})
+
+	lister := NewReplicationFileLister(nil, 100)
+	groups, num, err := lister.ListFilesToMove(source, target)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, num)
+	require.Len(t, groups, 3)
+	assert.Equal(t, FilesGroup{
+		{path: "wal_005/000000010000000000000002.br"},
+		{path: "wal_005/000000010000000000000003.br", copyAfter: []string{"wal_005/000000010000000000000002.br"}},
+	}, groups[0])
+	assert.Equal(t, FilesGroup{
+		{path: "basebackups_005/stream_1/stream.br", deleteAfter: []string{"basebackups_005/stream_1_backup_stop_sentinel.json"}},
+		{path: "basebackups_005/stream_1_backup_stop_sentinel.json", copyAfter: []string{"basebackups_005/stream_1/stream.br"}},
+	}, groups[1])
+	assert.Equal(t, FilesGroup{{path: "other"}}, groups[2])
+
+	t.Run("cut log chain by max files", func(t *testing.T) {
+		lister := NewReplicationFileLister(nil, 1)
+		groups, num, err := lister.ListFilesToMove(source, target)
+		require.NoError(t, err)
+		assert.Equal(t, 1, num)
+		assert.Equal(t, []FilesGroup{{{path: "wal_005/000000010000000000000002.br"}}}, groups)
+		// the watermark doesn't pass the files left for the next pass
+		assert.False(t, lister.next["wal_005/"].After(lister.sourceFiles["wal_005/000000010000000000000003.br"].GetLastModified()))
+	})
+
+	t.Run("split backup by max files", func(t *testing.T) {
+		_ = target.PutObject("wal_005/000000010000000000000002.br", &bytes.Buffer{})
+		_ = target.PutObject("wal_005/000000010000000000000003.br", &bytes.Buffer{})
+		_ = source.PutObject("basebackups_005/stream_1/stream_2.br", &bytes.Buffer{})
+		lister := NewReplicationFileLister(nil, 1)
+		groups, num, err := lister.ListFilesToMove(source, target)
+		require.NoError(t, err)
+		assert.Equal(t, 1, num)
+		assert.Equal(t, []FilesGroup{{{path: "basebackups_005/stream_1/stream.br"}}}, groups)
+		// the sentinel is left for the next pass
+		sentinel := lister.sourceFiles["basebackups_005/stream_1_backup_stop_sentinel.json"]
+		assert.False(t, lister.next["basebackups_005/"].After(sentinel.GetLastModified()))
+		// the watermark of the other folders isn't held
+		assert.Contains(t, lister.next, "wal_005/")
+	})
+}
+
+func TestReplicationFileLister_ListNewFiles(t *testing.T) {
+	now := time.Now()
+	sourceTime := now.Add(-time.Hour)
+	source := memory.NewFolder("source/", memory.NewKVS(memory.WithCustomTime(func() time.Time { return sourceTime })))
+	target := memory.NewFolder("target/", memory.NewKVS())
+
+	_ = source.PutObject("wal_005/000000010000000000000001.br", &bytes.Buffer{})
+	_ = source.PutObject("basebackups_005/base_1/tar_partitions/part_1.tar.br", &bytes.Buffer{})
+	sourceTime = now
+	_ = source.PutObject("wal_005/000000010000000000000002.br", &bytes.Buffer{})
+	_ = source.PutObject("wal_005/000000010000000000000003.br", &bytes.Buffer{})
+	_ = source.PutObject("basebackups_005/base_1_backup_stop_sentinel.json", &bytes.Buffer{})
+	_ = target.PutObject("wal_005/000000010000000000000003.br", &bytes.Buffer{})
+
+	lister := NewReplicationFileLister(map[string]time.Time{"wal_005/": now, "basebackups_005/": now}, 100)
+	groups, num, err := lister.ListFilesToMove(source, target)
+	require.NoError(t, err)
+
+	// the old WAL file isn't new, but the old data of the backup with a new sentinel is
+	assert.Equal(t, 3, num)
+	require.Len(t, groups, 2)
+	assert.Equal(t, FilesGroup{{path: "wal_005/000000010000000000000002.br"}}, groups[0])
+	assert.Equal(t, FilesGroup{
+		{path: "basebackups_005/base_1/tar_partitions/part_1.tar.br",
+			deleteAfter: []string{"basebackups_005/base_1_backup_stop_sentinel.json"}},
+		{path: "basebackups_005/base_1_backup_stop_sentinel.json",
+			copyAfter: []string{"basebackups_005/base_1/tar_partitions/part_1.tar.br"}},
+	}, groups[1])
+	assert.Equal(t, lister.sourceFiles["wal_005/000000010000000000000002.br"].GetLastModified(), lister.next["wal_005/"])
+	// the backup data isn't listed with the other source files
+	assert.NotContains(t, lister.sourceFiles, "basebackups_005/base_1/tar_partitions/part_1.tar.br")
+}
+
+func TestReplicator_Sync(t *testing.T) {
+	source := memory.NewFolder("source/", memory.NewKVS())
+	target := memory.NewFolder("target/", memory.NewKVS())
+
+	_ = source.PutObject("wal_005/000000010000000000000001.br", bytes.NewBufferString("wal 1"))
+	_ = source.PutObject("basebackups_005/base_1_backup_stop_sentinel.json", bytes.NewBufferString("{}"))
+	_ = source.PutObject("basebackups_005/base_1/tar_partitions/part_1.tar.br", bytes.NewBufferString("data"))
+	_ = source.PutObject("basebackups_005/base_2/tar_partitions/part_1.tar.br", bytes.NewBufferString("in progress"))
+
+	replicator := newReplicator(source, target, testReplicatorConfig())
+	require.NoError(t, replicator.Sync())
+
+	assertExist(t, target, true,
+		"wal_005/000000010000000000000001.br",
+		"basebackups_005/base_1_backup_stop_sentinel.json",
+		"basebackups_005/base_1/tar_partitions/part_1.tar.br",
+		ReplicationCursorName,
+	)
+	assertExist(t, target, false, "basebackups_005/base_2/tar_partitions/part_1.tar.br")
+	assertExist(t, source, true, "wal_005/000000010000000000000001.br")
+
+	t.Run("copy new files", func(t *testing.T) {
+		_ = source.PutObject("wal_005/000000010000000000000002.br", bytes.NewBufferString("wal 2"))
+		_ = source.PutObject("basebackups_005/base_2_backup_stop_sentinel.json", bytes.NewBufferString("{}"))
+
+		require.NoError(t, replicator.Sync())
+		assertExist(t, target, true,
+			"wal_005/000000010000000000000002.br",
+			"basebackups_005/base_2_backup_stop_sentinel.json",
+			"basebackups_005/base_2/tar_partitions/part_1.tar.br",
+		)
+	})
+
+	t.Run("apply backup deletions after grace period", func(t *testing.T) {
+		_ = target.PutObject("target_only", &bytes.Buffer{})
+		require.NoError(t, source.DeleteObjects([]string{
+			"basebackups_005/base_1_backup_stop_sentinel.json",
+			"basebackups_005/base_1/tar_partitions/part_1.tar.br",
+		}))
+
+		require.NoError(t, replicator.Sync())
+		assertExist(t, target, true, "basebackups_005/base_1_backup_stop_sentinel.json")
+
+		// the new replicator resumes from the cursor, so the grace period started by the previous one is kept
+		replicator = newReplicator(source, target, testReplicatorConfig())
+		require.NoError(t, replicator.Sync())
+		require.Len(t, replicator.cursor.PendingBackupDeletions, 1)
+		replicator.cursor.PendingBackupDeletions["base_1"] = time.Now().Add(-2 * time.Hour)
+
+		require.NoError(t, replicator.Sync())
+		assertExist(t, target, false,
+			"basebackups_005/base_1_backup_stop_sentinel.json",
+			"basebackups_005/base_1/tar_partitions/part_1.tar.br",
+		)
+		assertExist(t, target, true, "target_only", "basebackups_005/base_2_backup_stop_sentinel.json")
+		assert.Empty(t, replicator.cursor.PendingBackupDeletions)
+		assert.Equal(t, map[string]bool{"base_2": true}, replicator.cursor.CopiedBackups)
+	})
+
+	t.Run("keep backups that have been in the target storage", func(t *testing.T) {
+		_ = source.PutObject("basebackups_005/base_3_backup_stop_sentinel.json", bytes.NewBufferString("{}"))
+		_ = target.PutObject("basebackups_005/base_3_backup_stop_sentinel.json", bytes.NewBufferString("{}"))
+		require.NoError(t, replicator.Sync())
+		assert.NotContains(t, replicator.cursor.CopiedBackups, "base_3")
+
+		require.NoError(t, source.DeleteObjects([]string{"basebackups_005/base_3_backup_stop_sentinel.json"}))
+		require.NoError(t, replicator.Sync())
+		assert.Empty(t, replicator.cursor.PendingBackupDeletions)
+	})
+
+	t.Run("cancel deletion of reappeared backup", func(t *testing.T) {
+		require.NoError(t, source.DeleteObjects([]string{"basebackups_005/base_2_backup_stop_sentinel.json"}))
+		require.NoError(t, replicator.Sync())
+		require.Contains(t, replicator.cursor.PendingBackupDeletions, "base_2")
+
+		_ = source.PutObject("basebackups_005/base_2_backup_stop_sentinel.json", bytes.NewBufferString("{}"))
+		require.NoError(t, replicator.Sync())
+		assert.Empty(t, replicator.cursor.PendingBackupDeletions)
+	})
+}
+
+func TestReplicator_SyncLogDeletions(t *testing.T) {
+	source := memory.NewFolder("source/", memory.NewKVS())
+	target := memory.NewFolder("target/", memory.NewKVS())
+
+	// the old log has been in the target storage before the replication
+	_ = source.PutObject("wal_005/000000010000000000000001.br", bytes.NewBufferString("wal 1"))
+	_ = target.PutObject("wal_005/000000010000000000000001.br", bytes.NewBufferString("wal 1"))
+	_ = source.PutObject("wal_005/000000010000000000000002.br", bytes.NewBufferString("wal 2"))
+	_ = source.PutObject("wal_005/000000010000000000000003.br", bytes.NewBufferString("wal 3"))
+	_ = source.PutObject("wal_005/000000010000000000000004.br", bytes.NewBufferString("wal 4"))
+
+	replicator := newReplicator(source, target, testReplicatorConfig())
+	require.NoError(t, replicator.Sync())
+	assert.Equal(t, map[string]string{"wal_005/": "wal_005/000000010000000000000002.br"}, replicator.cursor.FirstCopiedLogs)
+
+	// the retention deletes the oldest logs
+	require.NoError(t, source.DeleteObjects([]string{
+		"wal_005/000000010000000000000001.br",
+		"wal_005/000000010000000000000002.br",
+	}))
+	require.NoError(t, replicator.Sync())
+	require.Len(t, replicator.cursor.PendingLogDeletions["wal_005/"], 1)
+	assertExist(t, target, true, "wal_005/000000010000000000000002.br")
+
+	t.Run("cancel deletion of reappeared logs", func(t *testing.T) {
+		_ = source.PutObject("wal_005/000000010000000000000002.br", bytes.NewBufferString("wal 2"))
+		require.NoError(t, replicator.Sync())
+		assert.Empty(t, replicator.cursor.PendingLogDeletions)
+		require.NoError(t, source.DeleteObjects([]string{"wal_005/000000010000000000000002.br"}))
+		require.NoError(t, replicator.Sync())
+	})
+
+	require.NoError(t, source.DeleteObjects([]string{"wal_005/000000010000000000000003.br"}))
+	require.NoError(t, replicator.Sync())
+	deletions := replicator.cursor.PendingLogDeletions["wal_005/"]
+	require.Len(t, deletions, 2)
+	// only the first deletion has passed the grace period
+	deletions[0].Noticed = time.Now().Add(-2 * time.Hour)
+
+	require.NoError(t, replicator.Sync())
+	assertExist(t, target, true, "wal_005/000000010000000000000001.br", "wal_005/000000010000000000000003.br",
+		"wal_005/000000010000000000000004.br")
+	assertExist(t, target, false, "wal_005/000000010000000000000002.br")
+	assert.Equal(t, "wal_005/000000010000000000000003.br", replicator.cursor.FirstCopiedLogs["wal_005/"])
+	assert.Len(t, replicator.cursor.PendingLogDeletions["wal_005/"], 1)
+}
@@ -0,0 +1,395 @@
+package storagetools
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/printlist"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type UsageCategory string
+
+const (
+	UsageBackup       UsageCategory = "backup"
+	UsageLog          UsageCategory = "log"
+	UsageRestorePoint UsageCategory = "restore_point"
+	UsageGarbage      UsageCategory = "garbage"
+	UsageOther        UsageCategory = "other"
+
+	RestorePointSuffix = "_restore_point.json"
+)
+
+// usageLogPrefixes are the folders of the continuous logs (PostgreSQL WAL, MySQL binlogs and MongoDB oplog archives)
+var usageLogPrefixes = []string{
+	utility.WalPath,
+	"binlog_" + utility.VersionStr + "/",
+	"oplog_" + utility.VersionStr + "/",
+}
+
+// Attribution tells what a storage object belongs to
+type Attribution struct {
+	Category UsageCategory
+	// Group is the name of the backup, the log range, etc. that the object belongs to
+	Group   string
+	Details string
+}
+
+// Attributor attributes storage objects to backups, logs, restore points and garbage
+type Attributor interface {
+	Attribute(object storage.Object) Attribution
+}
+
+// DefaultAttributor attributes objects by the storage layout, which is common for all databases.
+// Backup files without a sentinel are considered garbage left by the failed or running backups.
+type DefaultAttributor struct {
+	completeBackups map[string]bool
+}
+
+func NewDefaultAttributor(objects []storage.Object) *DefaultAttributor {
+	completeBackups := map[string]bool{}
+	for _, object := range objects {
+		name := object.GetName()
+		if !strings.HasPrefix(name, utility.BaseBackupPath) {
+			continue
+		}
+		fileName := strings.TrimPrefix(name, utility.BaseBackupPath)
+		if !strings.Contains(fileName, "/") && strings.HasSuffix(fileName, utility.SentinelSuffix) {
+			completeBackups[strings.TrimSuffix(fileName, utility.SentinelSuffix)] = true
+		}
+	}
+	return &DefaultAttributor{completeBackups: completeBackups}
+}
+
+func (a *DefaultAttributor) Attribute(object storage.Object) Attribution {
+	name := object.GetName()
+	if strings.HasPrefix(name, utility.BaseBackupPath) {
+		return a.attributeBackupFile(strings.TrimPrefix(name, utility.BaseBackupPath))
+	}
+	for _, logPrefix := range usageLogPrefixes {
+		if strings.HasPrefix(name, logPrefix) {
+			return Attribution{Category: UsageLog, Group: LogGroup(logPrefix, strings.TrimPrefix(name, logPrefix))}
+		}
+	}
+	return Attribution{Category: UsageOther, Group: strings.SplitN(name, "/", 2)[0]}
+}
+
+func (a *DefaultAttributor) attributeBackupFile(fileName string) Attribution {
+	dir, file := path.Split(fileName)
+	if dir == "" {
+		switch {
+		case strings.HasSuffix(file, utility.SentinelSuffix):
+			return Attribution{Category: UsageBackup, Group: strings.TrimSuffix(file, utility.SentinelSuffix)}
+		case strings.HasSuffix(file, RestorePointSuffix):
+			return Attribution{Category: UsageRestorePoint, Group: strings.TrimSuffix(file, RestorePointSuffix)}
+		}
+		return Attribution{Category: UsageOther, Group: utility.BaseBackupPath}
+	}
+	backupName := dir[:strings.Index(dir, "/")]
+	if !a.completeBackups[backupName] {
+		return Attribution{Category: UsageGarbage, Group: backupName, Details: "incomplete backup"}
+	}
+	return Attribution{Category: UsageBackup, Group: backupName}
+}
+
+// CompleteBackups returns the names of the backups with sentinels
+func (a *DefaultAttributor) CompleteBackups() []string {
+	names := make([]string, 0, len(a.completeBackups))
+	for name := range a.completeBackups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackupDescriber describes a complete backup in the usage report, e.g. "permanent, full"
+type BackupDescriber func(backupName string) (string, error)
+
+// BackupDetailsAttributor adds the descriptions of the complete backups to the default attribution
+type BackupDetailsAttributor struct {
+	*DefaultAttributor
+	backupDetails map[string]string
+}
+
+func NewBackupDetailsAttributor(objects []storage.Object, describe BackupDescriber) (*BackupDetailsAttributor, error) {
+	attributor := &BackupDetailsAttributor{
+		DefaultAttributor: NewDefaultAttributor(objects),
+		backupDetails:     map[string]string{},
+	}
+	for _, backupName := range attributor.CompleteBackups() {
+		details, err := describe(backupName)
+		if err != nil {
+			return nil, fmt.Errorf("describe backup %s: %w", backupName, err)
+		}
+		attributor.backupDetails[backupName] = details
+	}
+	return attributor, nil
+}
+
+func (a *BackupDetailsAttributor) Attribute(object storage.Object) Attribution {
+	attribution := a.DefaultAttributor.Attribute(object)
+	if attribution.Category == UsageBackup {
+		attribution.Details = a.backupDetails[attribution.Group]
+	}
+	return attribution
+}
+
+// MetadataBackupDescriber describes the backups by their generic metadata: the permanence, and whether the backup is
+// full or delta with its chain
+func MetadataBackupDescriber(backupFolder storage.Folder, fetcher internal.GenericMetaFetcher) BackupDescriber {
+	return func(backupName string) (string, error) {
+		meta, err := fetcher.Fetch(backupName, backupFolder)
+		if err != nil {
+			return "", err
+		}
+		var details []string
+		if meta.IsPermanent {
+			details = append(details, "permanent")
+		}
+		isIncremental, increment, err := meta.IncrementDetails.Fetch()
+		if err != nil {
+			return "", err
+		}
+		details = append(details, DescribeBackupType(isIncremental, increment))
+		return strings.Join(details, ", "), nil
+	}
+}
+
+func DescribeBackupType(isIncremental bool, increment internal.IncrementDetails) string {
+	if !isIncremental {
+		return "full"
+	}
+	return fmt.Sprintf("delta #%d from %s, chain of %s",
+		increment.IncrementCount, increment.IncrementFrom, increment.IncrementFullName)
+}
+
+func isLogFile(name string) bool {
+	for _, logPrefix := range usageLogPrefixes {
+		if strings.HasPrefix(name, logPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogGroup makes the name of the group of the log file. PostgreSQL WAL is grouped by timelines,
+// other logs make a single group.
+func LogGroup(logPrefix, fileName string) string {
+	logType := strings.TrimSuffix(logPrefix, "_"+utility.VersionStr+"/")
+	if logPrefix == utility.WalPath && len(fileName) >= 8 {
+		if _, err := strconv.ParseUint(fileName[:8], 16, 32); err == nil {
+			return fmt.Sprintf("%s timeline %s", logType, fileName[:8])
+		}
+	}
+	return logType
+}
+
+// StoragePrices are the monthly prices of storing a gigabyte per storage class
+type StoragePrices struct {
+	PerGBMonth map[string]float64
+	// Default is used for objects with an unknown or empty storage class
+	Default float64
+}
+
+// DefaultStoragePrices are the AWS S3 prices in the us-east-1 region, USD per GB-month
+func DefaultStoragePrices() StoragePrices {
+	return StoragePrices{
+		PerGBMonth: map[string]float64{
+			"STANDARD":            0.023,
+			"REDUCED_REDUNDANCY":  0.024,
+			"STANDARD_IA":         0.0125,
+			"ONEZONE_IA":          0.01,
+			"INTELLIGENT_TIERING": 0.023,
+			"GLACIER_IR":          0.004,
+			"GLACIER":             0.0036,
+			"DEEP_ARCHIVE":        0.00099,
+		},
+		Default: 0.023,
+	}
+}
+
+// ParsePrices overrides the prices with the values in the "CLASS=price" format.
+// The price for the "default" class is used for objects with an unknown storage class.
+func (p StoragePrices) ParsePrices(values []string) (StoragePrices, error) {
+	prices := StoragePrices{PerGBMonth: make(map[string]float64, len(p.PerGBMonth)), Default: p.Default}
+	for class, price := range p.PerGBMonth {
+		prices.PerGBMonth[class] = price
+	}
+	for _, value := range values {
+		class, priceStr, ok := strings.Cut(value, "=")
+		if !ok {
+			return StoragePrices{}, fmt.Errorf("invalid price %q, expected CLASS=price", value)
+		}
+		price, err := strconv.ParseFloat(priceStr, 64)
+		if err != nil || price < 0 {
+			return StoragePrices{}, fmt.Errorf("invalid price %q: must be a non-negative number", value)
+		}
+		if strings.EqualFold(class, "default") {
+			prices.Default = price
+			continue
+		}
+		prices.PerGBMonth[strings.ToUpper(class)] = price
+	}
+	return prices, nil
+}
+
+func (p StoragePrices) MonthlyCost(storageClass string, size int64) float64 {
+	price, ok := p.PerGBMonth[storageClass]
+	if !ok {
+		price = p.Default
+	}
+	return float64(size) / (1 << 30) * price
+}
+
+// UsageItem is the usage of storage by a group of objects of the same storage class
+type UsageItem struct {
+	Category     UsageCategory `json:"category"`
+	Name         string        `json:"name"`
+	StorageClass string        `json:"storage_class"`
+	Objects      int           `json:"objects"`
+	Size         int64         `json:"size"`
+	MonthlyCost  float64       `json:"monthly_cost"`
+	First        string        `json:"first,omitempty"`
+	Last         string        `json:"last,omitempty"`
+	Details      string        `json:"details,omitempty"`
+}
+
+func (item UsageItem) PrintableFields() []printlist.TableField {
+	prettySize := prettyByteSize(item.Size)
+	logRange := ""
+	if item.First != "" {
+		logRange = item.First + " - " + item.Last
+	}
+	return []printlist.TableField{
+		{Name: "category", PrettyName: "Category", Value: string(item.Category)},
+		{Name: "name", PrettyName: "Name", Value: item.Name},
+		{Name: "storage_class", PrettyName: "Storage class", Value: item.StorageClass},
+		{Name: "objects", PrettyName: "Objects", Value: strconv.Itoa(item.Objects)},
+		{Name: "size", PrettyName: "Size", Value: strconv.FormatInt(item.Size, 10), PrettyValue: &prettySize},
+		{Name: "monthly_cost", PrettyName: "Monthly cost", Value: strconv.FormatFloat(item.MonthlyCost, 'f', 4, 64)},
+		{Name: "range", PrettyName: "Range", Value: logRange},
+		{Name: "details", PrettyName: "Details", Value: item.Details},
+	}
+}
+
+func prettyByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// BuildUsageReport attributes the objects and sums up their sizes and costs per group and storage class.
+// If byCategory is set, the groups of the same category are merged.
+func BuildUsageReport(objects []storage.Object, attributor Attributor, prices StoragePrices, byCategory bool) []UsageItem {
+	type itemKey struct {
+		category     UsageCategory
+		name         string
+		storageClass string
+	}
+	items := map[itemKey]*UsageItem{}
+	for _, object := range objects {
+		attribution := attributor.Attribute(object)
+		key := itemKey{attribution.Category, attribution.Group, storage.GetStorageClass(object)}
+		if byCategory {
+			key.name = ""
+		}
+		item, ok := items[key]
+		if !ok {
+			item = &UsageItem{Category: key.category, Name: key.name, StorageClass: key.storageClass}
+			if !byCategory {
+				item.Details = attribution.Details
+			}
+			items[key] = item
+		}
+		item.Objects++
+		item.Size += object.GetSize()
+		if !byCategory && isLogFile(object.GetName()) {
+			fileName := path.Base(object.GetName())
+			if item.First == "" || fileName < item.First {
+				item.First = fileName
+			}
+			if fileName > item.Last {
+				item.Last = fileName
+			}
+		}
+	}
+
+	report := make([]UsageItem, 0, len(items))
+	for _, item := range items {
+		item.MonthlyCost = prices.MonthlyCost(item.StorageClass, item.Size)
+		report = append(report, *item)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Category != report[j].Category {
+			return report[i].Category < report[j].Category
+		}
+		if report[i].Name != report[j].Name {
+			return report[i].Name < report[j].Name
+		}
+		return report[i].StorageClass < report[j].StorageClass
+	})
+	return report
+}
+
+// UsageTotal sums up the report
+func UsageTotal(report []UsageItem) UsageItem {
+	total := UsageItem{Category: "total"}
+	for _, item := range report {
+		total.Objects += item.Objects
+		total.Size += item.Size
+		total.MonthlyCost += item.MonthlyCost
+	}
+	return total
+}
+
+// WriteUsageReport prints the report followed by the total line
+func WriteUsageReport(report []UsageItem, output io.Writer, pretty, json bool) error {
+	entities := make([]printlist.Entity, 0, len(report)+1)
+	for i := range report {
+		entities = append(entities, report[i])
+	}
+	entities = append(entities, UsageTotal(report))
+	return printlist.List(entities, output, pretty, json)
+}
+
+// HandleStorageUsage reports how the storage space is used by backups, logs, restore points and garbage
+func HandleStorageUsage(
+	folder storage.Folder,
+	newAttributor func(objects []storage.Object) (Attributor, error),
+	prices StoragePrices,
+	byCategory, pretty, json bool,
+	output io.Writer,
+) error {
+	objects, err := storage.ListFolderRecursively(folder)
+	if err != nil {
+		return fmt.Errorf("list storage: %w", err)
+	}
+	if newAttributor == nil {
+		newAttributor = func(objects []storage.Object) (Attributor, error) {
+			return NewDefaultAttributor(objects), nil
+		}
+	}
+	attributor, err := newAttributor(objects)
+	if err != nil {
+		return fmt.Errorf("attribute storage objects: %w", err)
+	}
+	report := BuildUsageReport(objects, attributor, prices, byCategory)
+	err = WriteUsageReport(report, output, pretty, json)
+	if err != nil {
+		return fmt.Errorf("write usage report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package storagetools
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestBuildUsageReport(t *testing.T) {
+	objects := []storage.Object{
+		storage.NewLocalObject("basebackups_005/base_1_backup_stop_sentinel.json", testTime, 10),
+		storage.NewLocalObject("basebackups_005/base_1/tar_partitions/part_1.tar.br", testTime, 1000),
+		storage.NewLocalObject("basebackups_005/base_2/tar_partitions/part_1.tar.br", testTime, 500),
+		storage.NewLocalObject("basebackups_005/point_1_restore_point.json", testTime, 5),
+		storage.NewLocalObject("wal_005/000000010000000000000001.br", testTime, 100),
+		storage.NewLocalObject("wal_005/000000010000000000000002.br", testTime, 100),
+		storage.NewLocalObject("wal_005/000000020000000000000003.br", testTime, 100),
+		storage.NewLocalObject("binlog_005/mysql-bin.000001.br", testTime, 50),
+		storage.NewLocalObject("unknown/file", testTime, 1),
+	}
+	prices := StoragePrices{Default: 1}
+
+	report := BuildUsageReport(objects, NewDefaultAttributor(objects), prices, false)
+	assert.Equal(t, []UsageItem{
+		{Category: UsageBackup, Name: "base_1", Objects: 2, Size: 1010, MonthlyCost: 1010.0 / (1 << 30)},
+		{Category: UsageGarbage, Name: "base_2", Objects: 1, Size: 500, MonthlyCost: 500.0 / (1 << 30),
+			Details: "incomplete backup"},
+		{Category: UsageLog, Name: "binlog", Objects: 1, Size: 50, MonthlyCost: 50.0 / (1 << 30),
+			First: "mysql-bin.000001.br", Last: "mysql-bin.000001.br"},
+		{Category: UsageLog, Name: "wal timeline 00000001", Objects: 2, Size: 200, MonthlyCost: 200.0 / (1 << 30),
+			First: "000000010000000000000001.br", Last: "000000010000000000000002.br"},
+		{Category: UsageLog, Name: "wal timeline 00000002", Objects: 1, Size: 100, MonthlyCost: 100.0 / (1 << 30),
+			First: "000000020000000000000003.br", Last: "000000020000000000000003.br"},
+		{Category: UsageOther, Name: "unknown", Objects: 1, Size: 1, MonthlyCost: 1.0 / (1 << 30)},
+		{Category: UsageRestorePoint, Name: "point_1", Objects: 1, Size: 5, MonthlyCost: 5.0 / (1 << 30)},
+	}, report)
+
+	t.Run("by category", func(t *testing.T) {
+		report := BuildUsageReport(objects, NewDefaultAttributor(objects), prices, true)
+		require.Len(t, report, 5)
+		assert.Equal(t, UsageLog, report[2].Category)
+		assert.Equal(t, 4, report[2].Objects)
+		assert.Equal(t, int64(350), report[2].Size)
+
+		total := UsageTotal(report)
+		assert.Equal(t, len(objects), total.Objects)
+		assert.Equal(t, int64(1866), total.Size)
+	})
+}
+
+type testMetaFetcher map[string]internal.GenericMetadata
+
+func (f testMetaFetcher) Fetch(backupName string, _ storage.Folder) (internal.GenericMetadata, error) {
+	return f[backupName], nil
+}
+
+type testIncrementDetails internal.IncrementDetails
+
+func (d testIncrementDetails) Fetch() (bool, internal.IncrementDetails, error) {
+	return true, internal.IncrementDetails(d), nil
+}
+
+func TestBackupDetailsAttributor(t *testing.T) {
+	objects := []storage.Object{
+		storage.NewLocalObject("basebackups_005/full_backup_stop_sentinel.json", testTime, 10),
+		storage.NewLocalObject("basebackups_005/full/stream.br", testTime, 1000),
+		storage.NewLocalObject("basebackups_005/delta_backup_stop_sentinel.json", testTime, 10),
+		storage.NewLocalObject("basebackups_005/incomplete/stream.br", testTime, 1000),
+	}
+	fetcher := testMetaFetcher{
+		"full": {IsPermanent: true, IncrementDetails: &internal.NopIncrementDetailsFetcher{}},
+		"delta": {IncrementDetails: testIncrementDetails{
+			IncrementFrom: "full", IncrementFullName: "full", IncrementCount: 1,
+		}},
+	}
+	attributor, err := NewBackupDetailsAttributor(objects, MetadataBackupDescriber(nil, fetcher))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"delta", "full"}, attributor.CompleteBackups())
+	assert.Equal(t, Attribution{Category: UsageBackup, Group: "full", Details: "permanent, full"},
+		attributor.Attribute(objects[1]))
+	assert.Equal(t, Attribution{Category: UsageBackup, Group: "delta", Details: "delta #1 from full, chain of full"},
+		attributor.Attribute(objects[2]))
+	assert.Equal(t, UsageGarbage, attributor.Attribute(objects[3]).Category)
+}
+
+func TestBuildUsageReport_StorageClasses(t *testing.T) {
+	objects := []storage.Object{
+		classifiedObject{storage.NewLocalObject("wal_005/000000010000000000000001.br", testTime, 1<<30), "STANDARD"},
+		classifiedObject{storage.NewLocalObject("wal_005/000000010000000000000002.br", testTime, 1<<30), "GLACIER"},
+		classifiedObject{storage.NewLocalObject("wal_005/000000010000000000000003.br", testTime, 1<<30), "UNKNOWN"},
+	}
+
+	report := BuildUsageReport(objects, NewDefaultAttributor(objects), DefaultStoragePrices(), true)
+	require.Len(t, report, 3)
+	assert.Equal(t, "GLACIER", report[0].StorageClass)
+	assert.InDelta(t, 0.0036, report[0].MonthlyCost, 1e-9)
+	assert.Equal(t, "STANDARD", report[1].StorageClass)
+	assert.InDelta(t, 0.023, report[1].MonthlyCost, 1e-9)
+	assert.Equal(t, "UNKNOWN", report[2].StorageClass)
+	assert.InDelta(t, 0.023, report[2].MonthlyCost, 1e-9)
+}
+
+func TestStoragePrices_ParsePrices(t *testing.T) {
+	prices, err := DefaultStoragePrices().ParsePrices([]string{"standard=0.5", "default=0.1", "COLD=0.01"})
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, prices.PerGBMonth["STANDARD"])
+	assert.Equal(t, 0.01, prices.PerGBMonth["COLD"])
+	assert.Equal(t, 0.1, prices.Default)
+	assert.Equal(t, 0.023, DefaultStoragePrices().PerGBMonth["STANDARD"])
+
+	_, err = DefaultStoragePrices().ParsePrices([]string{"STANDARD"})
+	assert.Error(t, err)
+	_, err = DefaultStoragePrices().ParsePrices([]string{"STANDARD=-1"})
+	assert.Error(t, err)
+}
+
+func TestHandleStorageUsage(t *testing.T) {
+	folder := memory.NewFolder("test/", memory.NewKVS())
+	_ = folder.PutObject("basebackups_005/base_1_backup_stop_sentinel.json", strings.NewReader("{}"))
+	_ = folder.PutObject("wal_005/000000010000000000000001.br", strings.NewReader("wal"))
+
+	var output bytes.Buffer
+	require.NoError(t, HandleStorageUsage(folder, nil, DefaultStoragePrices(), false, false, true, &output))
+
+	var items []UsageItem
+	require.NoError(t, json.Unmarshal(output.Bytes(), &items))
+	require.Len(t, items, 3)
+	assert.Equal(t, UsageBackup, items[0].Category)
+	assert.Equal(t, UsageLog, items[1].Category)
+	assert.Equal(t, UsageCategory("total"), items[2].Category)
+	assert.Equal(t, 2, items[2].Objects)
+}
+
+type classifiedObject struct {
+	*storage.LocalObject
+	storageClass string
+}
+
+func (o classifiedObject) GetStorageClass() string {
+	return o.storageClass
+}
@@ -22,18 +22,29 @@ const (
 // PushStream compresses a stream and push it
 func (uploader *RegularUploader) PushStream(ctx context.Context, stream io.Reader) (string, error) {
 	backupName := StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat)
-	dstPath := GetStreamName(backupName, uploader.Compressor.FileExtension())
-	err := uploader.PushStreamToDestination(ctx, stream, dstPath)
+	err := uploader.PushNamedStream(ctx, stream, backupName)
 
 	return backupName, err
 }
 
+// PushNamedStream is PushStream with the backup name chosen by the caller
+func (uploader *RegularUploader) PushNamedStream(ctx context.Context, stream io.Reader, backupName string) error {
+	dstPath := GetStreamName(backupName, uploader.Compressor.FileExtension())
+	return uploader.PushStreamToDestination(ctx, stream, dstPath)
+}
+
 // TODO : unit tests
 // returns backup_prefix
 // (Note: individual parition names are built by adding '_0000.br' or '_0000_0000.br' suffix)
 func (uploader *SplitStreamUploader) PushStream(ctx context.Context, stream io.Reader) (string, error) {
 	backupName := StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat)
+	err := uploader.PushNamedStream(ctx, stream, backupName)
 
+	return backupName, err
+}
+
+// PushNamedStream is PushStream with the backup name chosen by the caller
+func (uploader *SplitStreamUploader) PushNamedStream(ctx context.Context, stream io.Reader, backupName string) error {
 	// Upload Stream:
 	errGroup, ctx := errgroup.WithContext(ctx)
 	var readers = splitmerge.SplitReader(ctx, stream, uploader.partitions, uploader.blockSize)
@@ -72,7 +83,7 @@ func (uploader *SplitStreamUploader) PushStream(ctx context.Context, stream io.R
 	// Wait for upload finished:
 	if err := errGroup.Wait(); err != nil {
 		tracelog.WarningLogger.Printf("Failed to upload part of backup: %v", err)
-		return backupName, err
+		return err
 	}
 
 	// Upload StreamMetadata
@@ -84,9 +95,7 @@ func (uploader *SplitStreamUploader) PushStream(ctx context.Context, stream io.R
 	}
 	uploaderClone := uploader.Clone()
 	uploaderClone.DisableSizeTracking() // don't count metadata.json in backup size
-	err := UploadBackupStreamMetadata(uploader, meta, backupName)
-
-	return backupName, err
+	return UploadBackupStreamMetadata(uploader, meta, backupName)
 }
 
 // TODO : unit tests
@@ -95,7 +104,7 @@ func (uploader *RegularUploader) PushStreamToDestination(ctx context.Context, st
 	if uploader.dataSize != nil {
 		stream = utility.NewWithSizeReader(stream, uploader.dataSize)
 	}
-	compressed := CompressAndEncrypt(stream, uploader.Compressor, ConfigureCrypter())
+	compressed := CompressAndEncrypt(stream, uploader.Compressor, uploader.Crypter())
 	err := uploader.Upload(ctx, dstPath, compressed)
 	tracelog.InfoLogger.Println("FILE PATH:", dstPath)
 
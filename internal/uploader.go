@@ -13,6 +13,7 @@ import (
 
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/crypto"
 	"github.com/wal-g/wal-g/internal/ioextensions"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
@@ -24,8 +25,10 @@ type Uploader interface {
 	Upload(ctx context.Context, path string, content io.Reader) error
 	UploadFile(ctx context.Context, file ioextensions.NamedReader) error
 	PushStream(ctx context.Context, stream io.Reader) (string, error)
+	PushNamedStream(ctx context.Context, stream io.Reader, backupName string) error
 	PushStreamToDestination(ctx context.Context, stream io.Reader, dstPath string) error
 	Compression() compression.Compressor
+	Crypter() crypto.Crypter
 	DisableSizeTracking()
 	UploadedDataSize() (int64, error)
 	RawDataSize() (int64, error)
@@ -45,10 +48,25 @@ type RegularUploader struct {
 	failed          *abool.AtomicBool
 	tarSize         *int64
 	dataSize        *int64
+	crypter         *lazyCrypter
 }
 
 var _ Uploader = &RegularUploader{}
 
+// lazyCrypter is configured on the first use and shared by the clones of an uploader, so a push configures
+// its crypter and fetches the keys only once.
+type lazyCrypter struct {
+	once    sync.Once
+	crypter crypto.Crypter
+}
+
+func (lazy *lazyCrypter) get() crypto.Crypter {
+	lazy.once.Do(func() {
+		lazy.crypter = ConfigureCrypter()
+	})
+	return lazy.crypter
+}
+
 // SplitStreamUploader - new Uploader implementation that enable us to split upload streams into blocks
 //
 //	of blockSize bytes, then puts it in at most `partitions` streams that are compressed and pushed to storage
@@ -78,6 +96,7 @@ func NewRegularUploader(
 		tarSize:         new(int64),
 		dataSize:        new(int64),
 		failed:          abool.New(),
+		crypter:         &lazyCrypter{},
 	}
 	return uploader
 }
@@ -135,6 +154,7 @@ func (uploader *RegularUploader) Clone() Uploader {
 		failed:          abool.NewBool(uploader.Failed()),
 		tarSize:         uploader.tarSize,
 		dataSize:        uploader.dataSize,
+		crypter:         uploader.crypter,
 	}
 }
 
@@ -147,7 +167,7 @@ func (uploader *RegularUploader) UploadFile(ctx context.Context, file ioextensio
 	if uploader.dataSize != nil {
 		fileReader = utility.NewWithSizeReader(fileReader, uploader.dataSize)
 	}
-	compressedFile := CompressAndEncrypt(fileReader, uploader.Compressor, ConfigureCrypter())
+	compressedFile := CompressAndEncrypt(fileReader, uploader.Compressor, uploader.Crypter())
 	dstPath := utility.SanitizePath(filepath.Base(filename) + "." + uploader.Compressor.FileExtension())
 
 	err := uploader.Upload(ctx, dstPath, compressedFile)
@@ -166,6 +186,11 @@ func (uploader *RegularUploader) Compression() compression.Compressor {
 	return uploader.Compressor
 }
 
+// Crypter returns the crypter configured once for the uploader and its clones
+func (uploader *RegularUploader) Crypter() crypto.Crypter {
+	return uploader.crypter.get()
+}
+
 // TODO : unit tests
 func (uploader *RegularUploader) Upload(ctx context.Context, path string, content io.Reader) error {
 	uploader.waitGroup.Add(1)
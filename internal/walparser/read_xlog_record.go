@@ -45,13 +45,19 @@ func readRelFileNode(reader io.Reader) (*RelFileNode, error) {
 	return &relFileNode, nil
 }
 
+// ParseXLogRecordFromBytes parses the record of WAL written by PostgreSQL before 15
 func ParseXLogRecordFromBytes(data []byte) (*XLogRecord, error) {
+	return ParseXLogRecordFromBytesWithPageMagic(data, 0)
+}
+
+// ParseXLogRecordFromBytesWithPageMagic parses the record in the format of the WAL version given by the page magic
+func ParseXLogRecordFromBytesWithPageMagic(data []byte, pageMagic uint16) (*XLogRecord, error) {
 	reader := bytes.NewReader(data)
 	header, err := readXLogRecordHeader(reader)
 	if err != nil {
 		return nil, err
 	}
-	return readXLogRecordBody(header, reader)
+	return readXLogRecordBody(header, reader, pageMagic)
 }
 
 func readXLogRecordBlockDataAndImages(record *XLogRecord, reader io.Reader) error {
@@ -75,8 +81,8 @@ func readXLogRecordBlockDataAndImages(record *XLogRecord, reader io.Reader) erro
 	return nil
 }
 
-func readXLogRecordBlockImageHeader(reader io.Reader) (*XLogRecordBlockImageHeader, error) {
-	blockImageHeader := XLogRecordBlockImageHeader{}
+func readXLogRecordBlockImageHeader(reader io.Reader, pageMagic uint16) (*XLogRecordBlockImageHeader, error) {
+	blockImageHeader := XLogRecordBlockImageHeader{pg15Flags: pageMagic >= XLogPageMagicPg15}
 	err := parsingutil.ParseMultipleFieldsFromReader([]parsingutil.FieldToParse{
 		{Field: &blockImageHeader.ImageLength, Name: "imageLength"},
 		{Field: &blockImageHeader.HoleOffset, Name: "imageHoleOffset"},
@@ -127,7 +133,8 @@ func readBlockLocation(blockHasSameRel bool,
 }
 
 func readXLogRecordBlockHeader(lastRelFileNode *RelFileNode,
-	blockID uint8, maxReadBlockID *int, reader *ShrinkableReader) (*XLogRecordBlockHeader, *RelFileNode, error) {
+	blockID uint8, maxReadBlockID *int, reader *ShrinkableReader,
+	pageMagic uint16) (*XLogRecordBlockHeader, *RelFileNode, error) {
 	if blockID > XlrMaxBlockID {
 		return nil, nil, NewInvalidRecordBlockIDError(blockID)
 	}
@@ -154,7 +161,7 @@ func readXLogRecordBlockHeader(lastRelFileNode *RelFileNode,
 	}
 
 	if blockHeader.HasImage() {
-		imageHeader, err := readXLogRecordBlockImageHeader(reader)
+		imageHeader, err := readXLogRecordBlockImageHeader(reader, pageMagic)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -174,7 +181,7 @@ func readXLogRecordBlockHeader(lastRelFileNode *RelFileNode,
 	return blockHeader, lastRelFileNode, nil
 }
 
-func readXLogRecordBlockHeaderPart(record *XLogRecord, reader io.Reader) error {
+func readXLogRecordBlockHeaderPart(record *XLogRecord, reader io.Reader, pageMagic uint16) error {
 	var lastRelFileNode *RelFileNode
 	maxReadBlockID := -1
 	headerReader := &ShrinkableReader{reader, int(record.Header.TotalRecordLength - XLogRecordHeaderSize)}
@@ -213,7 +220,7 @@ func readXLogRecordBlockHeaderPart(record *XLogRecord, reader io.Reader) error {
 		default:
 			var blockHeader *XLogRecordBlockHeader
 			blockHeader, lastRelFileNode, err = readXLogRecordBlockHeader(
-				lastRelFileNode, blockID, &maxReadBlockID, headerReader)
+				lastRelFileNode, blockID, &maxReadBlockID, headerReader, pageMagic)
 			if err != nil {
 				return err
 			}
@@ -229,9 +236,9 @@ func readXLogRecordMainData(mainDataLen uint32, reader io.Reader) ([]byte, error
 	return mainData, errors.WithStack(err)
 }
 
-func readXLogRecordBody(header *XLogRecordHeader, reader io.Reader) (*XLogRecord, error) {
+func readXLogRecordBody(header *XLogRecordHeader, reader io.Reader, pageMagic uint16) (*XLogRecord, error) {
 	record := NewXLogRecord(*header)
-	err := readXLogRecordBlockHeaderPart(record, reader)
+	err := readXLogRecordBlockHeaderPart(record, reader, pageMagic)
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +31,7 @@ func TestReadXLogRecordBlockHeader(t *testing.T) {
 		0x00, 0x15, 0x40, 0x00, 0x00, 0xe4, 0x18, 0x00, 0x00,
 	}
 	reader := ShrinkableReader{bytes.NewReader(headerData), len(headerData) + 0x1cd4}
-	header, lastRelFileNode, err := readXLogRecordBlockHeader(nil, 0, &maxReadBlockId, &reader)
+	header, lastRelFileNode, err := readXLogRecordBlockHeader(nil, 0, &maxReadBlockId, &reader, 0)
 	assert.NoError(t, err)
 	assert.Equal(t, *lastRelFileNode, header.BlockLocation.RelationFileNode)
 	assert.Equal(t, header.BlockID, uint8(0))
@@ -68,7 +68,7 @@ func TestReadXLogRecordBlockImageHeader_NotCompressed(t *testing.T) {
 		0x42, 0x10, 0x30, 0x00, 0x05,
 	}
 	reader := bytes.NewReader(data)
-	header, err := readXLogRecordBlockImageHeader(reader)
+	header, err := readXLogRecordBlockImageHeader(reader, 0)
 	assert.NoError(t, err)
 	assert.Equal(t, header.ImageLength, uint16(0x1042))
 	assert.Equal(t, header.HoleOffset, uint16(0x0030))
@@ -82,7 +82,7 @@ func TestReadXLogRecordBlockImageHeader_Compressed(t *testing.T) {
 		0x42, 0x10, 0x30, 0x00, 0x07, 0x92, 0x00,
 	}
 	reader := bytes.NewReader(data)
-	header, err := readXLogRecordBlockImageHeader(reader)
+	header, err := readXLogRecordBlockImageHeader(reader, 0)
 	assert.NoError(t, err)
 	assert.Equal(t, header.ImageLength, uint16(0x1042))
 	assert.Equal(t, header.HoleOffset, uint16(0x0030))
@@ -91,10 +91,36 @@ func TestReadXLogRecordBlockImageHeader_Compressed(t *testing.T) {
 	AssertReaderIsEmpty(t, reader)
 }
 
+func TestReadXLogRecordBlockImageHeader_Pg15NotCompressed(t *testing.T) {
+	data := []byte{
+		0x42, 0x10, 0x30, 0x00, 0x03,
+	}
+	reader := bytes.NewReader(data)
+	header, err := readXLogRecordBlockImageHeader(reader, XLogPageMagicPg15)
+	assert.NoError(t, err)
+	assert.False(t, header.IsCompressed())
+	assert.True(t, header.ApplyImage())
+	assert.Equal(t, header.HoleLength, uint16(0x0fbe))
+	AssertReaderIsEmpty(t, reader)
+}
+
+func TestReadXLogRecordBlockImageHeader_Pg15Compressed(t *testing.T) {
+	data := []byte{
+		0x42, 0x10, 0x30, 0x00, 0x09, 0x92, 0x00,
+	}
+	reader := bytes.NewReader(data)
+	header, err := readXLogRecordBlockImageHeader(reader, XLogPageMagicPg15)
+	assert.NoError(t, err)
+	assert.True(t, header.IsCompressed())
+	assert.False(t, header.ApplyImage())
+	assert.Equal(t, header.HoleLength, uint16(0x0092))
+	AssertReaderIsEmpty(t, reader)
+}
+
 func testReadXLogRecordBlockHeaderPartLogic(t *testing.T, data []byte, blockDataLen uint32) *XLogRecord {
 	reader := bytes.NewReader(data)
 	record := NewXLogRecord(XLogRecordHeader{TotalRecordLength: XLogRecordHeaderSize + uint32(len(data)) + blockDataLen})
-	err := readXLogRecordBlockHeaderPart(record, reader)
+	err := readXLogRecordBlockHeaderPart(record, reader, 0)
 	assert.NoError(t, err)
 	AssertReaderIsEmpty(t, reader)
 	return record
@@ -233,7 +259,7 @@ func TestReadXLogRecordBody(t *testing.T) {
 	expectedMainDataLen := uint32(0x04)
 	expectedImageLength := uint16(0x000a)
 	reader := bytes.NewReader(data)
-	record, err := readXLogRecordBody(&XLogRecordHeader{TotalRecordLength: uint32(int(XLogRecordHeaderSize) + len(data))}, reader)
+	record, err := readXLogRecordBody(&XLogRecordHeader{TotalRecordLength: uint32(int(XLogRecordHeaderSize) + len(data))}, reader, 0)
 	assert.NoError(t, err)
 	assert.Equal(t, record.Origin, expectedOrigin)
 	assert.Equal(t, record.MainDataLen, expectedMainDataLen)
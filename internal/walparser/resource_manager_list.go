@@ -1,5 +1,10 @@
 package walparser
 
+import (
+	"fmt"
+	"strings"
+)
+
 /* List of postgres resource managers, for clarification you can look at postgres code:
  * src/include/access/rmgrlist.h
  */
@@ -30,3 +35,27 @@ const (
 
 	RmNextFreeID
 )
+
+var resourceManagerNames = [RmNextFreeID]string{
+	"XLOG", "Transaction", "Storage", "CLOG", "Database", "Tablespace", "MultiXact", "RelMap", "Standby", "Heap2",
+	"Heap", "Btree", "Hash", "Gin", "Gist", "Sequence", "SPGist", "BRIN", "CommitTs", "ReplicationOrigin",
+	"Generic", "LogicalMessage",
+}
+
+// ResourceManagerName returns the name of the resource manager as pg_waldump prints it
+func ResourceManagerName(resourceManagerID uint8) string {
+	if resourceManagerID >= RmNextFreeID {
+		return fmt.Sprintf("UNKNOWN(%d)", resourceManagerID)
+	}
+	return resourceManagerNames[resourceManagerID]
+}
+
+// ResourceManagerIDByName finds the resource manager by its name, case-insensitively
+func ResourceManagerIDByName(name string) (uint8, bool) {
+	for id, resourceManagerName := range resourceManagerNames {
+		if strings.EqualFold(resourceManagerName, name) {
+			return uint8(id), true
+		}
+	}
+	return 0, false
+}
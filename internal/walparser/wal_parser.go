@@ -56,10 +56,11 @@ func (err PartialPageError) Error() string {
 type WalParser struct {
 	currentRecordData         []byte
 	hasCurrentRecordBeginning bool
+	pageMagic                 uint16
 }
 
 func NewWalParser() *WalParser {
-	return &WalParser{make([]byte, 0), false}
+	return &WalParser{currentRecordData: make([]byte, 0)}
 }
 
 func (parser *WalParser) setCurrentRecordData(data []byte) {
@@ -98,7 +99,7 @@ func (parser *WalParser) ParseRecordsFromPage(reader io.Reader) (prevRecordTail
 	if header.TotalRecordLength != uint32(len(currentRecordData)) {
 		return nil, nil, NewContinuationNotFoundError()
 	}
-	currentRecord, err := ParseXLogRecordFromBytes(currentRecordData)
+	currentRecord, err := ParseXLogRecordFromBytesWithPageMagic(currentRecordData, page.Header.Magic)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -124,6 +125,7 @@ func (parser *WalParser) parsePage(reader io.Reader) (*XLogPage, error) {
 		}
 		return nil, err
 	}
+	parser.pageMagic = pageHeader.Magic
 	err = alignedReader.ReadToAlignment()
 	if err != nil {
 		return nil, err
@@ -138,7 +140,8 @@ func (parser *WalParser) parsePage(reader io.Reader) (*XLogPage, error) {
 	}
 	// if remainingData can be a part of WAL-switch record and we can check it
 	if parser.hasCurrentRecordBeginning {
-		record, err := ParseXLogRecordFromBytes(concatByteSlices(parser.currentRecordData, remainingData))
+		record, err := ParseXLogRecordFromBytesWithPageMagic(concatByteSlices(parser.currentRecordData, remainingData),
+			pageHeader.Magic)
 		if err != nil {
 			return nil, err
 		}
@@ -159,7 +162,7 @@ func readXLogPage(alignedReader *AlignedReader, pageHeader *XLogPageHeader, rema
 		}
 		if wholeRecord {
 			// The header was previously validated being zero, so now it doesn't need to. However we do this for code robustness.
-			record, err := ParseXLogRecordFromBytes(recordData)
+			record, err := ParseXLogRecordFromBytesWithPageMagic(recordData, pageHeader.Magic)
 			if err != nil {
 				return checkPartialPage(alignedReader,
 					&XLogPage{Header: *pageHeader, PrevRecordTrailingData: remainingData, Records: pageRecords}, err)
@@ -205,6 +208,11 @@ func (parser *WalParser) GetCurrentRecordData() []byte {
 	return parser.currentRecordData
 }
 
+// PageMagic returns the magic of the last parsed page, which tells the WAL version
+func (parser *WalParser) PageMagic() uint16 {
+	return parser.pageMagic
+}
+
 func LoadWalParser(reader io.Reader) (*WalParser, error) {
 	var dataLen uint32
 	err := parsingutil.NewFieldToParse(&dataLen, "record data prefix len").ParseFrom(reader)
@@ -216,9 +224,9 @@ func LoadWalParser(reader io.Reader) (*WalParser, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return &WalParser{data, len(data) > 0}, nil
+	return &WalParser{currentRecordData: data, hasCurrentRecordBeginning: len(data) > 0}, nil
 }
 
 func LoadWalParserFromCurrentRecordHead(currentRecordHead []byte) *WalParser {
-	return &WalParser{currentRecordHead, true}
+	return &WalParser{currentRecordData: currentRecordHead, hasCurrentRecordBeginning: true}
 }
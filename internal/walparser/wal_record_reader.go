@@ -0,0 +1,110 @@
+package walparser
+
+import (
+	"bytes"
+	"io"
+)
+
+const (
+	xLogShortPageHeaderSize = 24
+	xLogLongPageHeaderSize  = 40
+)
+
+// LocatedXLogRecord is the record along with the positions of its beginning and end in WAL
+type LocatedXLogRecord struct {
+	LSN    XLogRecordPtr
+	EndLSN XLogRecordPtr
+	Record XLogRecord
+}
+
+// WalRecordReader reads the records of consecutive WAL segments and locates them by the page addresses.
+// The records that begin before the first read segment are skipped.
+type WalRecordReader struct {
+	parser           *WalParser
+	currentRecordLSN XLogRecordPtr
+}
+
+func NewWalRecordReader() *WalRecordReader {
+	return &WalRecordReader{parser: NewWalParser()}
+}
+
+// Invalidate drops the record that is being read, so that the next segment doesn't need to follow the previous one
+func (reader *WalRecordReader) Invalidate() {
+	reader.parser.Invalidate()
+}
+
+// ReadRecords calls the function for every record that ends in the WAL file
+func (reader *WalRecordReader) ReadRecords(walFile io.Reader, function func(record LocatedXLogRecord) error) error {
+	pageReader := NewWalPageReader(walFile)
+	for {
+		data, err := pageReader.ReadPageData()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		records, err := reader.readPageRecords(data)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			err = function(record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (reader *WalRecordReader) readPageRecords(data []byte) ([]LocatedXLogRecord, error) {
+	hadRecordBeginning := reader.parser.hasCurrentRecordBeginning
+	_, records, err := reader.parser.ParseRecordsFromPage(bytes.NewReader(data))
+	switch err.(type) {
+	case nil:
+	case PartialPageError:
+	case ZeroPageError:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	pageHeader, err := readXLogPageHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := uint64(xLogShortPageHeaderSize)
+	if pageHeader.IsLong() {
+		headerSize = xLogLongPageHeaderSize
+	}
+	located := make([]LocatedXLogRecord, 0, len(records))
+	pageRecords := records
+	if hadRecordBeginning && len(records) > 0 {
+		// the first record is the continuation of the one that began on the previous pages
+		located = append(located, LocatedXLogRecord{
+			LSN:    reader.currentRecordLSN,
+			EndLSN: pageHeader.PageAddress + XLogRecordPtr(headerSize+uint64(pageHeader.RemainingDataLen)),
+			Record: records[0],
+		})
+		pageRecords = records[1:]
+	}
+	offset := headerSize + alignUp(uint64(pageHeader.RemainingDataLen))
+	for _, record := range pageRecords {
+		lsn := pageHeader.PageAddress + XLogRecordPtr(offset)
+		located = append(located, LocatedXLogRecord{
+			LSN:    lsn,
+			EndLSN: lsn + XLogRecordPtr(record.Header.TotalRecordLength),
+			Record: record,
+		})
+		offset += alignUp(uint64(record.Header.TotalRecordLength))
+	}
+	if reader.parser.hasCurrentRecordBeginning && (!hadRecordBeginning || len(records) > 0) {
+		// the record that continues on the next pages begins on this page
+		reader.currentRecordLSN = pageHeader.PageAddress + XLogRecordPtr(offset)
+	}
+	return located, nil
+}
+
+func alignUp(length uint64) uint64 {
+	return (length + XLogRecordAlignment - 1) / XLogRecordAlignment * XLogRecordAlignment
+}
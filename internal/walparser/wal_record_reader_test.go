@@ -0,0 +1,54 @@
+package walparser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func readLocatedRecords(t *testing.T, filename string) []LocatedXLogRecord {
+	walFile, err := os.Open(filename)
+	require.NoError(t, err)
+	defer utility.LoggedClose(walFile, "")
+
+	var records []LocatedXLogRecord
+	err = NewWalRecordReader().ReadRecords(walFile, func(record LocatedXLogRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	require.NoError(t, err)
+	return records
+}
+
+func TestWalRecordReader_LocatesRecords(t *testing.T) {
+	for _, filename := range []string{WalSwitchTestPath, LongRecordTestPath, PartialTestPath} {
+		records := readLocatedRecords(t, filename)
+		require.NotEmpty(t, records, filename)
+		for i := 1; i < len(records); i++ {
+			assert.Equal(t, records[i-1].LSN, records[i].Record.Header.PrevRecordPtr,
+				"%s: record %d should point to the previous one", filename, i)
+			assert.Zero(t, records[i].LSN%XLogRecordAlignment)
+			assert.Equal(t, records[i].LSN, XLogRecordPtr(alignUp(uint64(records[i-1].EndLSN))),
+				"%s: record %d should follow the previous one", filename, i)
+		}
+	}
+}
+
+func TestXLogRecord_XactTime(t *testing.T) {
+	var commitTimes []time.Time
+	for _, record := range readLocatedRecords(t, WalSwitchTestPath) {
+		if commitTime, ok := record.Record.XactTime(); ok {
+			commitTimes = append(commitTimes, commitTime)
+		}
+	}
+	require.Len(t, commitTimes, 3)
+	assert.Equal(t, time.Date(2018, time.July, 16, 4, 37, 22, 114778000, time.UTC), commitTimes[0])
+	assert.Equal(t, "Transaction", ResourceManagerName(RmXactID))
+	id, ok := ResourceManagerIDByName("heap2")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(RmHeap2ID), id)
+}
@@ -0,0 +1,46 @@
+package walparser
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+/* Transaction record operations, for clarification you can look at postgres code:
+ * src/include/access/xact.h
+ */
+const (
+	XLogXactCommit         = 0x00
+	XLogXactPrepare        = 0x10
+	XLogXactAbort          = 0x20
+	XLogXactCommitPrepared = 0x30
+	XLogXactAbortPrepared  = 0x40
+	XLogXactAssignment     = 0x50
+	XLogXactInvalidations  = 0x60
+	XLogXactOpMask         = 0x70
+)
+
+// postgresEpoch is the origin of TimestampTz, which counts microseconds since it
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// XactOperation returns the operation of the transaction record
+func (record *XLogRecord) XactOperation() (operation uint8, ok bool) {
+	if record.Header.ResourceManagerID != RmXactID {
+		return 0, false
+	}
+	return record.Header.Info & XLogXactOpMask, true
+}
+
+// XactTime returns the time of the commit or abort record: xl_xact_commit and xl_xact_abort begin with it
+func (record *XLogRecord) XactTime() (time.Time, bool) {
+	operation, ok := record.XactOperation()
+	if !ok || len(record.MainData) < 8 {
+		return time.Time{}, false
+	}
+	switch operation {
+	case XLogXactCommit, XLogXactAbort, XLogXactCommitPrepared, XLogXactAbortPrepared:
+		microseconds := int64(binary.LittleEndian.Uint64(record.MainData))
+		return postgresEpoch.Add(time.Duration(microseconds) * time.Microsecond), true
+	default:
+		return time.Time{}, false
+	}
+}
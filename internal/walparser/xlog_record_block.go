@@ -1,7 +1,34 @@
 package walparser
 
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
 type XLogRecordBlock struct {
 	Header XLogRecordBlockHeader
 	Image  []byte
 	Data   []byte
 }
+
+// PageImage restores the page from the full-page image of the block, the hole is filled with zeros.
+// Compressed images are not supported.
+func (block *XLogRecordBlock) PageImage() ([]byte, error) {
+	if !block.Header.HasImage() {
+		return nil, errors.New("block has no full-page image")
+	}
+	imageHeader := block.Header.ImageHeader
+	if imageHeader.IsCompressed() {
+		return nil, errors.New("compressed full-page images are not supported")
+	}
+	holeOffset, holeLength := int(imageHeader.HoleOffset), int(imageHeader.HoleLength)
+	if len(block.Image)+holeLength != int(BlockSize) || holeOffset > len(block.Image) {
+		return nil, fmt.Errorf("full-page image of length %d with hole at %d of length %d doesn't make a page",
+			len(block.Image), holeOffset, holeLength)
+	}
+	page := make([]byte, BlockSize)
+	copy(page, block.Image[:holeOffset])
+	copy(page[holeOffset+holeLength:], block.Image[holeOffset:])
+	return page, nil
+}
@@ -11,6 +11,15 @@ const (
 	BkpImageHasHole      uint8 = 0x01
 	BkpImageIsCompressed uint8 = 0x02
 	BkpImageApply        uint8 = 0x04
+
+	// PostgreSQL 15 moved the apply flag and added the compression methods
+	BkpImageApplyPg15        uint8 = 0x02
+	BkpImageCompressPglzPg15 uint8 = 0x04
+	BkpImageCompressLz4Pg15  uint8 = 0x08
+	BkpImageCompressZstdPg15 uint8 = 0x10
+
+	// XLogPageMagicPg15 is the WAL page magic of PostgreSQL 15, the later versions have greater ones
+	XLogPageMagicPg15 uint16 = 0xD110
 )
 
 type InconsistentBlockImageHoleStateError struct {
@@ -55,6 +64,9 @@ type XLogRecordBlockImageHeader struct {
 	HoleOffset  uint16
 	HoleLength  uint16
 	Info        uint8
+
+	// pg15Flags tells that Info has the flags of PostgreSQL 15 and later
+	pg15Flags bool
 }
 
 func (imageHeader *XLogRecordBlockImageHeader) HasHole() bool {
@@ -62,10 +74,16 @@ func (imageHeader *XLogRecordBlockImageHeader) HasHole() bool {
 }
 
 func (imageHeader *XLogRecordBlockImageHeader) IsCompressed() bool {
+	if imageHeader.pg15Flags {
+		return (imageHeader.Info & (BkpImageCompressPglzPg15 | BkpImageCompressLz4Pg15 | BkpImageCompressZstdPg15)) != 0
+	}
 	return (imageHeader.Info & BkpImageIsCompressed) != 0
 }
 
 func (imageHeader *XLogRecordBlockImageHeader) ApplyImage() bool {
+	if imageHeader.pg15Flags {
+		return (imageHeader.Info & BkpImageApplyPg15) != 0
+	}
 	return (imageHeader.Info & BkpImageApply) != 0
 }
 
@@ -0,0 +1,49 @@
+package walparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXLogRecordBlock_PageImage(t *testing.T) {
+	image := append(bytes.Repeat([]byte{1}, 0x30), bytes.Repeat([]byte{2}, int(BlockSize)-0x130)...)
+	block := XLogRecordBlock{
+		Header: XLogRecordBlockHeader{
+			ForkFlags: BkpBlockHasImage,
+			ImageHeader: XLogRecordBlockImageHeader{
+				ImageLength: uint16(len(image)),
+				HoleOffset:  0x30,
+				HoleLength:  0x100,
+				Info:        BkpImageHasHole | BkpImageApply,
+			},
+		},
+		Image: image,
+	}
+	page, err := block.PageImage()
+	require.NoError(t, err)
+	assert.Equal(t, int(BlockSize), len(page))
+	assert.Equal(t, bytes.Repeat([]byte{1}, 0x30), page[:0x30])
+	assert.Equal(t, make([]byte, 0x100), page[0x30:0x130])
+	assert.Equal(t, image[0x30:], page[0x130:])
+}
+
+func TestXLogRecordBlock_PageImage_Compressed(t *testing.T) {
+	block := XLogRecordBlock{
+		Header: XLogRecordBlockHeader{
+			ForkFlags:   BkpBlockHasImage,
+			ImageHeader: XLogRecordBlockImageHeader{ImageLength: 0x10, Info: BkpImageIsCompressed},
+		},
+		Image: make([]byte, 0x10),
+	}
+	_, err := block.PageImage()
+	assert.Error(t, err)
+}
+
+func TestXLogRecordBlock_PageImage_NoImage(t *testing.T) {
+	block := XLogRecordBlock{Data: []byte{1, 2, 3}}
+	_, err := block.PageImage()
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package chaos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
+)
+
+const (
+	operationsSetting       = "WALG_CHAOS_OPERATIONS"
+	seedSetting             = "WALG_CHAOS_SEED"
+	errorRateSetting        = "WALG_CHAOS_ERROR_RATE"
+	throttleRateSetting     = "WALG_CHAOS_THROTTLE_RATE"
+	truncateRateSetting     = "WALG_CHAOS_TRUNCATE_RATE"
+	latencySetting          = "WALG_CHAOS_LATENCY"
+	consistencyDelaySetting = "WALG_CHAOS_CONSISTENCY_DELAY"
+)
+
+var SettingList = []string{
+	operationsSetting,
+	seedSetting,
+	errorRateSetting,
+	throttleRateSetting,
+	truncateRateSetting,
+	latencySetting,
+	consistencyDelaySetting,
+}
+
+// ConfigureStorage wraps the storage with fault injection if any of the chaos settings is provided. Otherwise, the
+// storage is returned as is. The same rule is applied to all operations listed in WALG_CHAOS_OPERATIONS, or to all
+// operations if it isn't set.
+func ConfigureStorage(
+	underlying storage.HashableStorage,
+	settings map[string]string,
+) (storage.HashableStorage, error) {
+	if !isConfigured(settings) {
+		return underlying, nil
+	}
+
+	rule, err := parseRule(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := setting.Int64Optional(settings, seedSetting, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := parseOperations(settings[operationsSetting])
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Seed:  seed,
+		Rules: make(map[Operation]Rule, len(operations)),
+	}
+	for _, op := range operations {
+		config.Rules[op] = rule
+	}
+
+	st, err := NewStorage(underlying, config)
+	if err != nil {
+		return nil, fmt.Errorf("create chaos storage: %w", err)
+	}
+	return st, nil
+}
+
+func isConfigured(settings map[string]string) bool {
+	for _, name := range SettingList {
+		if _, ok := settings[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRule(settings map[string]string) (rule Rule, err error) {
+	rule.ErrorRate, err = setting.FloatOptional(settings, errorRateSetting, 0)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.ThrottleRate, err = setting.FloatOptional(settings, throttleRateSetting, 0)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.TruncateRate, err = setting.FloatOptional(settings, truncateRateSetting, 0)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.Latency, err = setting.DurationOptional(settings, latencySetting, 0)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.ConsistencyDelay, err = setting.DurationOptional(settings, consistencyDelaySetting, 0)
+	if err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+func parseOperations(value string) ([]Operation, error) {
+	if strings.TrimSpace(value) == "" {
+		return AllOperations, nil
+	}
+
+	var operations []Operation
+	for _, name := range strings.Split(value, ",") {
+		op := Operation(strings.ToLower(strings.TrimSpace(name)))
+		if !isKnownOperation(op) {
+			return nil, fmt.Errorf("setting %q: unknown operation %q, supported operations are: %v",
+				operationsSetting, name, AllOperations)
+		}
+		operations = append(operations, op)
+	}
+	return operations, nil
+}
+
+func isKnownOperation(op Operation) bool {
+	for _, known := range AllOperations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package chaos
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// InjectedError is returned when an operation is chosen to fail. Its message mimics a broken connection, so the code
+// that retries on such errors treats it as a real network failure.
+type InjectedError struct {
+	error
+}
+
+func newInjectedError(op Operation, path string) InjectedError {
+	return InjectedError{errors.Errorf("chaos: %s %q failed: connection reset by peer", op, path)}
+}
+
+func (err InjectedError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// ThrottlingError is returned when an operation is chosen to be throttled, like cloud storages do when the request rate
+// is too high.
+type ThrottlingError struct {
+	error
+}
+
+func newThrottlingError(op Operation, path string) ThrottlingError {
+	return ThrottlingError{errors.Errorf("chaos: %s %q throttled: SlowDown: Please reduce your request rate", op, path)}
+}
+
+func (err ThrottlingError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
@@ -0,0 +1,165 @@
+package chaos
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.Folder = &Folder{}
+
+// Folder passes all calls to the underlying folder, injecting the faults configured for each operation.
+type Folder struct {
+	underlying storage.Folder
+	injector   *injector
+}
+
+func newFolder(underlying storage.Folder, injector *injector) *Folder {
+	return &Folder{underlying, injector}
+}
+
+func (f *Folder) GetPath() string {
+	return f.underlying.GetPath()
+}
+
+func (f *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	err = f.injector.inject(context.Background(), OpList, f.GetPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	underlyingObjects, underlyingSubFolders, err := f.underlying.ListFolder()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, object := range underlyingObjects {
+		if f.injector.isVisible(OpList, f.objectPath(object.GetName())) {
+			objects = append(objects, object)
+		}
+	}
+	for _, subFolder := range underlyingSubFolders {
+		subFolders = append(subFolders, newFolder(subFolder, f.injector))
+	}
+	return objects, subFolders, nil
+}
+
+func (f *Folder) DeleteObjects(objectRelativePaths []string) error {
+	err := f.injector.inject(context.Background(), OpDelete, f.GetPath())
+	if err != nil {
+		return err
+	}
+
+	err = f.underlying.DeleteObjects(objectRelativePaths)
+	if err != nil {
+		return err
+	}
+	for _, objectPath := range objectRelativePaths {
+		f.injector.recordDelete(f.objectPath(objectPath))
+	}
+	return nil
+}
+
+func (f *Folder) Exists(objectRelativePath string) (bool, error) {
+	objectPath := f.objectPath(objectRelativePath)
+	err := f.injector.inject(context.Background(), OpExists, objectPath)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := f.underlying.Exists(objectRelativePath)
+	if err != nil || !exists {
+		return exists, err
+	}
+	return f.injector.isVisible(OpExists, objectPath), nil
+}
+
+func (f *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return newFolder(f.underlying.GetSubFolder(subFolderRelativePath), f.injector)
+}
+
+func (f *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	objectPath := f.objectPath(objectRelativePath)
+	err := f.injector.inject(context.Background(), OpRead, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !f.injector.isVisible(OpRead, objectPath) {
+		return nil, storage.NewObjectNotFoundError(objectPath)
+	}
+
+	readCloser, err := f.underlying.ReadObject(objectRelativePath)
+	if err != nil || !f.injector.shouldTruncate() {
+		return readCloser, err
+	}
+	size, err := f.objectSize(objectRelativePath)
+	if err != nil {
+		_ = readCloser.Close()
+		return nil, err
+	}
+	return newTruncatedReader(readCloser, f.injector.truncateAt(size)), nil
+}
+
+// objectSize finds the size of the object in the listing of its folder, so the truncated read doesn't need to buffer
+// the object. The size is 0 if the object isn't listed.
+func (f *Folder) objectSize(objectRelativePath string) (int64, error) {
+	dir, name := path.Split(strings.TrimPrefix(objectRelativePath, "/"))
+	objects, _, err := f.underlying.GetSubFolder(dir).ListFolder()
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range objects {
+		if object.GetName() == name {
+			return object.GetSize(), nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *Folder) PutObject(name string, content io.Reader) error {
+	return f.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (f *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	objectPath := f.objectPath(name)
+	err := f.injector.inject(ctx, OpPut, objectPath)
+	if err != nil {
+		return err
+	}
+
+	err = f.underlying.PutObjectWithContext(ctx, name, content)
+	if err != nil {
+		return err
+	}
+	f.injector.recordWrite(objectPath)
+	return nil
+}
+
+func (f *Folder) CopyObject(srcPath string, dstPath string) error {
+	srcObjectPath := f.objectPath(srcPath)
+	err := f.injector.inject(context.Background(), OpCopy, srcObjectPath)
+	if err != nil {
+		return err
+	}
+	if !f.injector.isVisible(OpCopy, srcObjectPath) {
+		return storage.NewObjectNotFoundError(srcObjectPath)
+	}
+
+	err = f.underlying.CopyObject(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	f.injector.recordWrite(f.objectPath(dstPath))
+	return nil
+}
+
+func (f *Folder) Validate() error {
+	return f.underlying.Validate()
+}
+
+func (f *Folder) objectPath(objectRelativePath string) string {
+	return storage.JoinPath(f.GetPath(), objectRelativePath)
+}
@@ -0,0 +1,114 @@
+package chaos
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func newTestFolder(config *Config, now func() time.Time) storage.Folder {
+	noSleep := func(context.Context, time.Duration) error { return nil }
+	injector := newInjector(config, rand.New(rand.NewSource(config.Seed)), now, noSleep)
+	return newFolder(memory.NewFolder("in_memory/", memory.NewKVS()), injector)
+}
+
+func TestChaosFolder_NoFaults(t *testing.T) {
+	storage.RunFolderTest(newTestFolder(&Config{}, time.Now), t)
+}
+
+func TestChaosFolder_Errors(t *testing.T) {
+	folder := newTestFolder(&Config{Rules: map[Operation]Rule{
+		OpPut:  {ErrorRate: 1},
+		OpList: {ThrottleRate: 1},
+	}}, time.Now)
+
+	err := folder.PutObject("file", strings.NewReader("data"))
+	assert.ErrorAs(t, err, &InjectedError{})
+	assert.Contains(t, err.Error(), "connection reset by peer")
+
+	_, _, err = folder.ListFolder()
+	assert.ErrorAs(t, err, &ThrottlingError{})
+
+	_, err = folder.Exists("file")
+	assert.NoError(t, err)
+}
+
+func TestChaosFolder_TruncatedRead(t *testing.T) {
+	folder := newTestFolder(&Config{Rules: map[Operation]Rule{
+		OpRead: {TruncateRate: 1},
+	}}, time.Now)
+
+	require.NoError(t, folder.PutObject("file", strings.NewReader("some data to be truncated")))
+
+	reader, err := folder.ReadObject("file")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Less(t, len(data), len("some data to be truncated"))
+	assert.True(t, strings.HasPrefix("some data to be truncated", string(data)))
+	assert.NoError(t, reader.Close())
+}
+
+func TestChaosFolder_EventualConsistency(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	folder := newTestFolder(&Config{Rules: map[Operation]Rule{
+		OpRead:   {ConsistencyDelay: time.Minute},
+		OpExists: {ConsistencyDelay: time.Minute},
+		OpList:   {ConsistencyDelay: time.Minute},
+	}}, func() time.Time { return now })
+
+	require.NoError(t, folder.PutObject("file", strings.NewReader("data")))
+
+	exists, err := folder.Exists("file")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = folder.ReadObject("file")
+	assert.ErrorAs(t, err, &storage.ObjectNotFoundError{})
+	objects, _, err := folder.ListFolder()
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+
+	now = now.Add(time.Minute)
+
+	exists, err = folder.Exists("file")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	objects, _, err = folder.ListFolder()
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+}
+
+func TestConfigureStorage(t *testing.T) {
+	underlying := memory.NewStorage("in_memory/", memory.NewKVS())
+
+	st, err := ConfigureStorage(underlying, map[string]string{})
+	assert.NoError(t, err)
+	assert.Same(t, underlying, st)
+
+	st, err = ConfigureStorage(underlying, map[string]string{
+		operationsSetting: "put, read",
+		errorRateSetting:  "1",
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, underlying.ConfigHash(), st.ConfigHash())
+	err = st.RootFolder().PutObject("file", strings.NewReader("data"))
+	assert.ErrorAs(t, err, &InjectedError{})
+	_, err = st.RootFolder().Exists("file")
+	assert.NoError(t, err)
+
+	_, err = ConfigureStorage(underlying, map[string]string{operationsSetting: "rename"})
+	assert.Error(t, err)
+
+	_, err = ConfigureStorage(underlying, map[string]string{truncateRateSetting: "1.5"})
+	assert.Error(t, err)
+	_, err = ConfigureStorage(underlying, map[string]string{errorRateSetting: "-0.1"})
+	assert.Error(t, err)
+}
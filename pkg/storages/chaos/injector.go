@@ -0,0 +1,146 @@
+package chaos
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// injector decides which faults happen to the operations. It is shared by all folders of a storage, so that the
+// eventual consistency is emulated for the whole storage rather than for a single folder handle.
+type injector struct {
+	config *Config
+
+	mu      sync.Mutex
+	random  *rand.Rand
+	written map[string]time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newInjector(
+	config *Config,
+	random *rand.Rand,
+	now func() time.Time,
+	sleep func(ctx context.Context, d time.Duration) error,
+) *injector {
+	return &injector{
+		config:  config,
+		random:  random,
+		written: map[string]time.Time{},
+		now:     now,
+		sleep:   sleep,
+	}
+}
+
+func (i *injector) rule(op Operation) (Rule, bool) {
+	rule, ok := i.config.Rules[op]
+	return rule, ok
+}
+
+func (i *injector) happens(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.random.Float64() < probability
+}
+
+// inject waits for the configured latency and returns an error if the operation is chosen to fail.
+func (i *injector) inject(ctx context.Context, op Operation, path string) error {
+	rule, ok := i.rule(op)
+	if !ok {
+		return nil
+	}
+
+	if rule.Latency > 0 {
+		if err := i.sleep(ctx, rule.Latency); err != nil {
+			return err
+		}
+	}
+
+	if i.happens(rule.ThrottleRate) {
+		return newThrottlingError(op, path)
+	}
+	if i.happens(rule.ErrorRate) {
+		return newInjectedError(op, path)
+	}
+	return nil
+}
+
+func (i *injector) recordWrite(path string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.written[path] = i.now()
+}
+
+func (i *injector) recordDelete(path string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.written, path)
+}
+
+// isVisible reports whether the object written by the path can already be seen by the operation.
+func (i *injector) isVisible(op Operation, path string) bool {
+	rule, ok := i.rule(op)
+	if !ok || rule.ConsistencyDelay <= 0 {
+		return true
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	writtenAt, ok := i.written[path]
+	if !ok {
+		return true
+	}
+	return !i.now().Before(writtenAt.Add(rule.ConsistencyDelay))
+}
+
+// truncateAt chooses the position to cut the object of the size at if the read is chosen to be truncated.
+func (i *injector) truncateAt(size int64) int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if size <= 0 {
+		return 0
+	}
+	return i.random.Int63n(size)
+}
+
+// shouldTruncate reports whether the read is chosen to be truncated.
+func (i *injector) shouldTruncate() bool {
+	rule, ok := i.rule(OpRead)
+	return ok && i.happens(rule.TruncateRate)
+}
+
+// truncatedReader returns io.ErrUnexpectedEOF instead of io.EOF, like a connection broken in the middle of a download.
+type truncatedReader struct {
+	reader io.Reader
+	io.Closer
+}
+
+func newTruncatedReader(readCloser io.ReadCloser, cutAt int64) *truncatedReader {
+	return &truncatedReader{reader: io.LimitReader(readCloser, cutAt), Closer: readCloser}
+}
+
+func (r *truncatedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.HashableStorage = &Storage{}
+
+// Storage wraps any other storage and injects faults into the operations performed on its folders. It is intended to be
+// used in tests and CI to check how the code handles unreliable storages without a live cloud.
+type Storage struct {
+	underlying storage.Storage
+	rootFolder storage.Folder
+	hash       string
+}
+
+type Operation string
+
+const (
+	OpList   Operation = "list"
+	OpRead   Operation = "read"
+	OpPut    Operation = "put"
+	OpDelete Operation = "delete"
+	OpExists Operation = "exists"
+	OpCopy   Operation = "copy"
+)
+
+var AllOperations = []Operation{OpList, OpRead, OpPut, OpDelete, OpExists, OpCopy}
+
+type Config struct {
+	// Seed initializes the pseudo-random generator deciding which calls fail. The same seed and the same sequence of
+	// calls produce the same faults.
+	Seed int64
+
+	// Rules define the faults injected per operation. Operations without a rule are passed to the underlying storage.
+	Rules map[Operation]Rule
+}
+
+type Rule struct {
+	// ErrorRate is a probability in [0, 1] that the operation fails with InjectedError.
+	ErrorRate float64
+
+	// ThrottleRate is a probability in [0, 1] that the operation fails with ThrottlingError.
+	ThrottleRate float64
+
+	// TruncateRate is a probability in [0, 1] that a read object is cut off and io.ErrUnexpectedEOF is returned. It is
+	// only applicable to OpRead.
+	TruncateRate float64
+
+	// Latency is added to every call of the operation.
+	Latency time.Duration
+
+	// ConsistencyDelay hides objects written less than this duration ago from the operation, as an eventually
+	// consistent storage would do. It is only applicable to OpList, OpRead, OpExists and OpCopy.
+	ConsistencyDelay time.Duration
+}
+
+func NewStorage(underlying storage.Storage, config *Config) (*Storage, error) {
+	err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	underlyingHash := ""
+	if hashable, ok := underlying.(storage.HashableStorage); ok {
+		underlyingHash = hashable.ConfigHash()
+	}
+
+	hash, err := storage.ComputeConfigHash("chaos", struct {
+		Underlying string
+		Config     *Config
+	}{underlyingHash, config})
+	if err != nil {
+		return nil, fmt.Errorf("compute config hash: %w", err)
+	}
+
+	injector := newInjector(config, rand.New(rand.NewSource(config.Seed)), time.Now, sleepWithContext)
+	folder := newFolder(underlying.RootFolder(), injector)
+
+	return &Storage{underlying, folder, hash}, nil
+}
+
+func (config *Config) validate() error {
+	for op, rule := range config.Rules {
+		for name, rate := range map[string]float64{
+			"error rate":    rule.ErrorRate,
+			"throttle rate": rule.ThrottleRate,
+			"truncate rate": rule.TruncateRate,
+		} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("%s %v of operation %q is out of [0, 1]", name, rate, op)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Storage) RootFolder() storage.Folder {
+	return s.rootFolder
+}
+
+func (s *Storage) ConfigHash() string {
+	return s.hash
+}
+
+func (s *Storage) Close() error {
+	return s.underlying.Close()
+}
@@ -135,7 +135,8 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 				continue
 			}
 			objectRelativePath := strings.TrimPrefix(*object.Key, folder.path)
-			objects = append(objects, storage.NewLocalObject(objectRelativePath, *object.LastModified, *object.Size))
+			objects = append(objects,
+				NewObject(objectRelativePath, *object.LastModified, *object.Size, aws.StringValue(object.StorageClass)))
 		}
 	}
 
@@ -0,0 +1,26 @@
+package s3
+
+import (
+	"time"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.StorageClassTeller = &Object{}
+
+// Object is an S3 object that knows its storage class in addition to the common properties.
+type Object struct {
+	*storage.LocalObject
+	storageClass string
+}
+
+func NewObject(name string, lastModified time.Time, size int64, storageClass string) *Object {
+	return &Object{
+		LocalObject:  storage.NewLocalObject(name, lastModified, size),
+		storageClass: storageClass,
+	}
+}
+
+func (object *Object) GetStorageClass() string {
+	return object.storageClass
+}
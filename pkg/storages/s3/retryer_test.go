@@ -5,12 +5,15 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/pkg/storages/chaos"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
 )
 
 func TestConnResetRetryerRetry(t *testing.T) {
@@ -47,3 +50,27 @@ func TestConnResetRetryerThrottling(t *testing.T) {
 	resp := &http.Response{StatusCode: 429}
 	assert.True(t, retryer.ShouldRetry(&request.Request{HTTPResponse: resp}))
 }
+
+func TestConnResetRetryerChaosStorage(t *testing.T) {
+	st, err := chaos.NewStorage(memory.NewStorage("in_memory/", memory.NewKVS()), &chaos.Config{
+		Seed:  2,
+		Rules: map[chaos.Operation]chaos.Rule{chaos.OpPut: {ErrorRate: 0.5}},
+	})
+	assert.NoError(t, err)
+	retryer := NewConnResetRetryer(client.DefaultRetryer{NumMaxRetries: 15})
+
+	retries := 0
+	for {
+		err = st.RootFolder().PutObject("file", strings.NewReader("data"))
+		if err == nil || !retryer.ShouldRetry(&request.Request{Error: err}) || retries == retryer.MaxRetries() {
+			break
+		}
+		retries++
+	}
+	assert.NoError(t, err)
+	assert.Greater(t, retries, 0, "the seed is expected to fail the first put")
+
+	exists, err := st.RootFolder().Exists("file")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
 )
 
 // TODO: Merge the settings and their default values with ones defined in internal/config.go
@@ -13,6 +14,10 @@ const (
 	passwordSetting       = "SSH_PASSWORD"
 	usernameSetting       = "SSH_USERNAME"
 	privateKeyPathSetting = "SSH_PRIVATE_KEY_PATH"
+	maxConnectionsSetting = "SSH_MAX_CONNECTIONS"
+	maxRetriesSetting     = "SSH_MAX_RETRIES"
+	maxPacketSizeSetting  = "SSH_MAX_PACKET_SIZE"
+	maxRequestsSetting    = "SSH_MAX_CONCURRENT_REQUESTS"
 )
 
 var SettingList = []string{
@@ -20,11 +25,22 @@ var SettingList = []string{
 	passwordSetting,
 	usernameSetting,
 	privateKeyPathSetting,
+	maxConnectionsSetting,
+	maxRetriesSetting,
+	maxPacketSizeSetting,
+	maxRequestsSetting,
 }
 
-const defaultPort = "22"
+const (
+	defaultPort           = "22"
+	defaultMaxConnections = 4
+	defaultMaxRetries     = 3
+	defaultMaxPacketSize  = 1 << 15
+	defaultMaxRequests    = 64
+	// maxMaxPacketSize is the largest SFTP packet payload all servers have to accept
+	maxMaxPacketSize = 1 << 15
+)
 
-// TODO: Unit tests
 func ConfigureStorage(
 	prefix string,
 	settings map[string]string,
@@ -40,6 +56,36 @@ func ConfigureStorage(
 		port = p
 	}
 
+	maxConnections, err := setting.IntOptional(settings, maxConnectionsSetting, defaultMaxConnections)
+	if err != nil {
+		return nil, err
+	}
+	maxRetries, err := setting.IntOptional(settings, maxRetriesSetting, defaultMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	maxPacketSize, err := setting.IntOptional(settings, maxPacketSizeSetting, defaultMaxPacketSize)
+	if err != nil {
+		return nil, err
+	}
+	maxRequests, err := setting.IntOptional(settings, maxRequestsSetting, defaultMaxRequests)
+	if err != nil {
+		return nil, err
+	}
+	if maxConnections < 1 {
+		return nil, fmt.Errorf("%s must be positive, got %d", maxConnectionsSetting, maxConnections)
+	}
+	if maxRetries < 0 {
+		return nil, fmt.Errorf("%s must not be negative, got %d", maxRetriesSetting, maxRetries)
+	}
+	if maxPacketSize < 1 || maxPacketSize > maxMaxPacketSize {
+		return nil, fmt.Errorf("%s must be between 1 and %d, got %d",
+			maxPacketSizeSetting, maxMaxPacketSize, maxPacketSize)
+	}
+	if maxRequests < 1 {
+		return nil, fmt.Errorf("%s must be positive, got %d", maxRequestsSetting, maxRequests)
+	}
+
 	config := &Config{
 		Secrets: &Secrets{
 			Password: settings[passwordSetting],
@@ -49,6 +95,11 @@ func ConfigureStorage(
 		RootPath:       folderPath,
 		User:           settings[usernameSetting],
 		PrivateKeyPath: settings[privateKeyPathSetting],
+
+		MaxConnections:        maxConnections,
+		MaxRetries:            maxRetries,
+		MaxPacketSize:         maxPacketSize,
+		MaxConcurrentRequests: maxRequests,
 	}
 
 	st, err := NewStorage(config, rootWraps...)
@@ -0,0 +1,27 @@
+package sh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureStorage(t *testing.T) {
+	st, err := ConfigureStorage("ssh://localhost/walg", map[string]string{maxPacketSizeSetting: "16384"})
+	require.NoError(t, err)
+	assert.NoError(t, st.Close())
+
+	for name, settings := range map[string]map[string]string{
+		"zero packet size":      {maxPacketSizeSetting: "0"},
+		"oversized packet size": {maxPacketSizeSetting: "65536"},
+		"zero connections":      {maxConnectionsSetting: "0"},
+		"negative retries":      {maxRetriesSetting: "-1"},
+		"zero requests":         {maxRequestsSetting: "0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ConfigureStorage("ssh://localhost/walg", settings)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -3,28 +3,33 @@ package sh
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
-	"github.com/pkg/errors"
+	"github.com/google/uuid"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal/contextio"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 )
 
-// TODO: Unit tests
+// tmpFilePrefix marks files that are being uploaded. They are renamed to the final name after the upload completes,
+// so readers never see partially written objects, and objects sharing an inode via hard links are never modified.
+const tmpFilePrefix = ".walg-tmp-"
+
 type Folder struct {
-	sftpLazy *SFTPLazy
-	path     string
+	pool *SFTPPool
+	path string
 }
 
-func NewFolder(sftpLazy *SFTPLazy, path string) *Folder {
+func NewFolder(pool *SFTPPool, path string) *Folder {
 	return &Folder{
-		sftpLazy: sftpLazy,
-		path:     path,
+		pool: pool,
+		path: path,
 	}
 }
 
@@ -33,152 +38,203 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
-	client, err := folder.sftpLazy.Client()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	filesInfo, err := client.ReadDir(folder.path)
+	err = folder.pool.Do(func(client SFTPClient) error {
+		objects, subFolders = nil, nil
 
-	if os.IsNotExist(err) {
-		// The folder does not exist, it means there are no objects in it
-		tracelog.DebugLogger.Println("\tnonexistent skipped " + folder.path + ": " + err.Error())
-		return nil, nil, nil
-	}
+		filesInfo, err := client.ReadDir(folder.path)
 
-	if err != nil {
-		return nil, nil, fmt.Errorf("read SFTP folder %q: %w", folder.path, err)
-	}
-
-	for _, fileInfo := range filesInfo {
-		if fileInfo.IsDir() {
-			subFolder := NewFolder(folder.sftpLazy, client.Join(folder.path, fileInfo.Name()))
-			subFolders = append(subFolders, subFolder)
-			// Folder is not object, just skip it
-			continue
+		if os.IsNotExist(err) {
+			// The folder does not exist, it means there are no objects in it
+			tracelog.DebugLogger.Println("\tnonexistent skipped " + folder.path + ": " + err.Error())
+			return nil
 		}
 
-		object := storage.NewLocalObject(
-			fileInfo.Name(),
-			fileInfo.ModTime(),
-			fileInfo.Size(),
-		)
-		objects = append(objects, object)
-	}
-
-	return objects, subFolders, err
-}
-
-func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
-	client, err := folder.sftpLazy.Client()
-	if err != nil {
-		return err
-	}
-
-	for _, relativePath := range objectRelativePaths {
-		objPath := client.Join(folder.path, relativePath)
-
-		stat, err := client.Stat(objPath)
-		if errors.Is(err, os.ErrNotExist) {
-			// Don't throw error if the file doesn't exist, to follow the storage.Folder contract
-			continue
-		}
 		if err != nil {
-			return fmt.Errorf("get stats of object %q via SFTP: %w", objPath, err)
+			return fmt.Errorf("read SFTP folder %q: %w", folder.path, err)
 		}
 
-		// Do not try to remove directory. It may be not empty. TODO: remove if empty
-		if stat.IsDir() {
-			continue
-		}
-
-		err = client.Remove(objPath)
-		if errors.Is(err, os.ErrNotExist) {
-			// Don't throw error if the file doesn't exist, to follow the storage.Folder contract
-			continue
-		}
-		if err != nil {
-			return fmt.Errorf("delete object %q via SFTP: %w", objPath, err)
+		for _, fileInfo := range filesInfo {
+			if fileInfo.IsDir() {
+				subFolder := NewFolder(folder.pool, client.Join(folder.path, fileInfo.Name()))
+				subFolders = append(subFolders, subFolder)
+				// Folder is not object, just skip it
+				continue
+			}
+
+			if strings.HasPrefix(fileInfo.Name(), tmpFilePrefix) {
+				// Unfinished upload
+				continue
+			}
+
+			object := storage.NewLocalObject(
+				fileInfo.Name(),
+				fileInfo.ModTime(),
+				fileInfo.Size(),
+			)
+			objects = append(objects, object)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return nil
+	return objects, subFolders, nil
 }
 
-func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
-	client, err := folder.sftpLazy.Client()
-	if err != nil {
-		return false, err
-	}
+func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.pool.Do(func(client SFTPClient) error {
+		for _, relativePath := range objectRelativePaths {
+			objPath := client.Join(folder.path, relativePath)
+
+			stat, err := client.Stat(objPath)
+			if errors.Is(err, os.ErrNotExist) {
+				// Don't throw error if the file doesn't exist, to follow the storage.Folder contract
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("get stats of object %q via SFTP: %w", objPath, err)
+			}
+
+			// Do not try to remove directory. It may be not empty. TODO: remove if empty
+			if stat.IsDir() {
+				continue
+			}
+
+			err = client.Remove(objPath)
+			if errors.Is(err, os.ErrNotExist) {
+				// Don't throw error if the file doesn't exist, to follow the storage.Folder contract
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("delete object %q via SFTP: %w", objPath, err)
+			}
+		}
+		return nil
+	})
+}
 
+func (folder *Folder) Exists(objectRelativePath string) (exists bool, err error) {
 	objPath := filepath.Join(folder.path, objectRelativePath)
-	_, err = client.Stat(objPath)
+	err = folder.pool.Do(func(client SFTPClient) error {
+		_, err := client.Stat(objPath)
 
-	if os.IsNotExist(err) {
-		return false, nil
-	}
+		if os.IsNotExist(err) {
+			exists = false
+			return nil
+		}
 
-	if err != nil {
-		return false, fmt.Errorf("check file %q existence via SFTP: %w", objPath, err)
-	}
+		if err != nil {
+			return fmt.Errorf("check file %q existence via SFTP: %w", objPath, err)
+		}
 
-	return true, nil
+		exists = true
+		return nil
+	})
+	return exists, err
 }
 
 func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
-	return NewFolder(folder.sftpLazy, path.Join(folder.path, subFolderRelativePath))
+	return NewFolder(folder.pool, path.Join(folder.path, subFolderRelativePath))
 }
 
 const defaultBufferSize = 64 * 1024 * 1024
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
-	client, err := folder.sftpLazy.Client()
-	if err != nil {
-		return nil, err
-	}
-
 	objPath := path.Join(folder.path, objectRelativePath)
-	file, err := client.Open(objPath)
+	var reader io.ReadCloser
+	err := folder.pool.Do(func(client SFTPClient) error {
+		file, err := client.Open(objPath)
+		if isConnectionError(err) {
+			return err
+		}
+		if err != nil {
+			return storage.NewObjectNotFoundError(objPath)
+		}
+
+		reader = struct {
+			io.Reader
+			io.Closer
+		}{bufio.NewReaderSize(file, defaultBufferSize), file}
+		return nil
+	})
 	if err != nil {
-		return nil, storage.NewObjectNotFoundError(objPath)
+		return nil, err
 	}
-
-	return struct {
-		io.Reader
-		io.Closer
-	}{bufio.NewReaderSize(file, defaultBufferSize), file}, nil
+	return reader, nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
-	client, err := folder.sftpLazy.Client()
-	if err != nil {
-		return err
-	}
-
 	absolutePath := filepath.Join(folder.path, name)
+	tmpPath := filepath.Join(filepath.Dir(absolutePath), tmpFilePrefix+uuid.New().String())
+
+	contentReader := &consumptionTrackingReader{Reader: content}
+	return folder.pool.Do(func(client SFTPClient) error {
+		err := writeFile(client, tmpPath, contentReader)
+		if err != nil {
+			if contentReader.consumed {
+				// The content can't be read again, so retrying with another connection is impossible
+				return noRetryError{err}
+			}
+			return err
+		}
 
-	dirPath := filepath.Dir(absolutePath)
-	err = client.MkdirAll(dirPath)
+		err = replaceFile(client, tmpPath, absolutePath)
+		if err != nil {
+			return noRetryError{err}
+		}
+		return nil
+	})
+}
+
+func writeFile(client SFTPClient, filePath string, content io.Reader) error {
+	dirPath := filepath.Dir(filePath)
+	err := client.MkdirAll(dirPath)
 	if err != nil {
 		return fmt.Errorf("create directory %q via SFTP: %w", dirPath, err)
 	}
 
-	file, err := client.Create(absolutePath)
+	file, err := client.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
-		return fmt.Errorf("create file %q via SFTP: %w", absolutePath, err)
+		return fmt.Errorf("create file %q via SFTP: %w", filePath, err)
 	}
 
-	_, err = io.Copy(file, content)
+	// ReadFrom pipelines the write requests, which is much faster than sequential writes on high latency links
+	_, err = file.ReadFrom(content)
 	if err != nil {
 		closerErr := file.Close()
 		if closerErr != nil {
 			tracelog.InfoLogger.Println("Error during closing failed upload ", closerErr)
 		}
-		return fmt.Errorf("write data to file %q via SFTP: %w", absolutePath, err)
+		removeErr := client.Remove(filePath)
+		if removeErr != nil {
+			tracelog.InfoLogger.Println("Error during removing failed upload ", removeErr)
+		}
+		return fmt.Errorf("write data to file %q via SFTP: %w", filePath, err)
 	}
 	err = file.Close()
 	if err != nil {
-		return fmt.Errorf("close file %q opened via SFTP: %w", absolutePath, err)
+		return fmt.Errorf("close file %q opened via SFTP: %w", filePath, err)
+	}
+	return nil
+}
+
+// replaceFile atomically renames the file, overwriting the destination if it exists. If the server doesn't support
+// the posix-rename extension, the destination is removed before the rename.
+func replaceFile(client SFTPClient, srcPath, dstPath string) error {
+	err := client.PosixRename(srcPath, dstPath)
+	if err == nil {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("POSIX rename of %q is not available, falling back to remove and rename: %v",
+		srcPath, err)
+
+	err = client.Remove(dstPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove file %q via SFTP: %w", dstPath, err)
+	}
+	err = client.Rename(srcPath, dstPath)
+	if err != nil {
+		return fmt.Errorf("rename file %q to %q via SFTP: %w", srcPath, dstPath, err)
 	}
 	return nil
 }
@@ -188,6 +244,8 @@ func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, con
 	return folder.PutObject(name, ctxReader)
 }
 
+// CopyObject makes a hard link to the source file if the server supports it. Otherwise, the file is copied through
+// the client. Hard links are safe, since files are never modified in place: uploads always create new files.
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	if exists, err := folder.Exists(srcPath); !exists {
 		if err == nil {
@@ -195,10 +253,22 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 		}
 		return fmt.Errorf("copy via SFTP: check if source file %q exists: %w", srcPath, err)
 	}
+
+	absoluteSrcPath := filepath.Join(folder.path, srcPath)
+	absoluteDstPath := filepath.Join(folder.path, dstPath)
+	err := folder.pool.Do(func(client SFTPClient) error {
+		return linkFile(client, absoluteSrcPath, absoluteDstPath)
+	})
+	if err == nil {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Hard link of %q via SFTP failed, falling back to copying: %v", srcPath, err)
+
 	file, err := folder.ReadObject(srcPath)
 	if err != nil {
 		return fmt.Errorf("copy via SFTP: read source file %q: %w", srcPath, err)
 	}
+	defer file.Close()
 	err = folder.PutObject(dstPath, file)
 	if err != nil {
 		return fmt.Errorf("copy via SFTP: write destination file %q: %w", dstPath, err)
@@ -206,6 +276,40 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	return nil
 }
 
+// linkFile creates a hard link at a temporary path and then renames it, so that the destination is replaced atomically.
+func linkFile(client SFTPClient, srcPath, dstPath string) error {
+	dirPath := filepath.Dir(dstPath)
+	err := client.MkdirAll(dirPath)
+	if err != nil {
+		return fmt.Errorf("create directory %q via SFTP: %w", dirPath, err)
+	}
+
+	tmpPath := filepath.Join(dirPath, tmpFilePrefix+uuid.New().String())
+	err = client.Link(srcPath, tmpPath)
+	if err != nil {
+		return fmt.Errorf("link file %q to %q via SFTP: %w", srcPath, tmpPath, err)
+	}
+	err = replaceFile(client, tmpPath, dstPath)
+	if err != nil {
+		_ = client.Remove(tmpPath)
+		return noRetryError{err}
+	}
+	return nil
+}
+
 func (folder *Folder) Validate() error {
 	return nil
 }
+
+type consumptionTrackingReader struct {
+	io.Reader
+	consumed bool
+}
+
+func (r *consumptionTrackingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.consumed = true
+	}
+	return n, err
+}
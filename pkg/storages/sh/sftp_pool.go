@@ -0,0 +1,202 @@
+package sh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/crypto/ssh"
+)
+
+type SFTPClient interface {
+	ReadDir(path string) ([]os.FileInfo, error)
+	Join(elem ...string) string
+	Remove(path string) error
+	Stat(p string) (os.FileInfo, error)
+	Open(path string) (*sftp.File, error)
+	OpenFile(path string, f int) (*sftp.File, error)
+	MkdirAll(path string) error
+	Link(oldname, newname string) error
+	Rename(oldname, newname string) error
+	PosixRename(oldname, newname string) error
+	Close() error
+}
+
+// Dialer establishes a new SFTP connection. The returned closer must release all resources of the connection.
+type Dialer func() (client SFTPClient, closer io.Closer, err error)
+
+// SFTPPool keeps several SFTP connections, so that concurrent operations don't share a single SSH channel. Connections
+// are established lazily on the first use, and are re-established if they break.
+type SFTPPool struct {
+	dial       Dialer
+	maxRetries int
+	retryDelay time.Duration
+
+	mu    sync.Mutex
+	slots []*poolSlot
+	next  int
+}
+
+type poolSlot struct {
+	mu     sync.Mutex
+	client SFTPClient
+	closer io.Closer
+}
+
+const defaultRetryDelay = 100 * time.Millisecond
+
+func NewSFTPPool(dial Dialer, maxConnections, maxRetries int) *SFTPPool {
+	if maxConnections < 1 {
+		maxConnections = 1
+	}
+	slots := make([]*poolSlot, maxConnections)
+	for i := range slots {
+		slots[i] = &poolSlot{}
+	}
+	return &SFTPPool{
+		dial:       dial,
+		maxRetries: maxRetries,
+		retryDelay: defaultRetryDelay,
+		slots:      slots,
+	}
+}
+
+func NewSSHDialer(addr string, config *ssh.ClientConfig, opts ...sftp.ClientOption) Dialer {
+	return func() (SFTPClient, io.Closer, error) {
+		sshClient, err := ssh.Dial("tcp", addr, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to %s via SSH: %w", addr, err)
+		}
+
+		sftpClient, err := sftp.NewClient(sshClient, opts...)
+		if err != nil {
+			_ = sshClient.Close()
+			return nil, nil, fmt.Errorf("failed to connect to %s via SFTP: %w", addr, err)
+		}
+
+		return sftpClient, sshClient, nil
+	}
+}
+
+// Do runs the operation with one of the pooled connections. If the connection turns out to be broken, it is closed and
+// the operation is retried with a new connection, up to maxRetries times.
+func (p *SFTPPool) Do(operation func(client SFTPClient) error) error {
+	delay := p.retryDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		slot := p.nextSlot()
+
+		var client SFTPClient
+		client, err = slot.get(p.dial)
+		if err == nil {
+			err = operation(client)
+			if err == nil || !isConnectionError(err) {
+				return err
+			}
+			slot.reset(client)
+		}
+
+		if attempt >= p.maxRetries {
+			return err
+		}
+		tracelog.WarningLogger.Printf("SFTP connection failed, reconnecting (attempt %d of %d): %v",
+			attempt+1, p.maxRetries, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (p *SFTPPool) nextSlot() *poolSlot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	slot := p.slots[p.next]
+	p.next = (p.next + 1) % len(p.slots)
+	return slot
+}
+
+// Close closes all established connections.
+func (p *SFTPPool) Close() error {
+	var errs []error
+	for _, slot := range p.slots {
+		slot.mu.Lock()
+		if slot.client != nil {
+			errs = append(errs, closeConnection(slot.client, slot.closer))
+			slot.client, slot.closer = nil, nil
+		}
+		slot.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (s *poolSlot) get(dial Dialer) (SFTPClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	client, closer, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("SSH connection error: %w", err)
+	}
+	s.client, s.closer = client, closer
+	return client, nil
+}
+
+// reset drops the broken connection, unless it was already replaced by another goroutine.
+func (s *poolSlot) reset(broken SFTPClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != broken {
+		return
+	}
+	err := closeConnection(s.client, s.closer)
+	if err != nil {
+		tracelog.DebugLogger.Printf("Failed to close broken SFTP connection: %v", err)
+	}
+	s.client, s.closer = nil, nil
+}
+
+func closeConnection(client SFTPClient, closer io.Closer) error {
+	err := client.Close()
+	if closer != nil {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// noRetryError marks an error after which the operation can't be retried even if the connection broke, e.g. because
+// the uploaded stream was partially consumed.
+type noRetryError struct {
+	error
+}
+
+func (err noRetryError) Unwrap() error {
+	return err.error
+}
+
+func isConnectionError(err error) bool {
+	if err == nil || errors.As(err, &noRetryError{}) {
+		return false
+	}
+	var netErr net.Error
+	// The SFTP client doesn't wrap errors of writing to the connection, so they can only be recognized by the message
+	return strings.Contains(err.Error(), "failed to send packet") ||
+		errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, sftp.ErrSSHFxNoConnection) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
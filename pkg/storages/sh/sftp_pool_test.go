@@ -0,0 +1,100 @@
+package sh
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type testServer struct {
+	handlers sftp.Handlers
+	dials    atomic.Int32
+	conns    []net.Conn
+}
+
+func newTestServer() *testServer {
+	return &testServer{handlers: sftp.InMemHandler()}
+}
+
+func (s *testServer) dial() (SFTPClient, io.Closer, error) {
+	s.dials.Add(1)
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, s.handlers)
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.conns = append(s.conns, clientConn)
+	return client, server, nil
+}
+
+func TestSFTPPoolFolder(t *testing.T) {
+	server := newTestServer()
+	pool := NewSFTPPool(server.dial, 2, 1)
+	defer pool.Close()
+
+	storage.RunFolderTest(NewFolder(pool, "/walg/"), t)
+	assert.Equal(t, int32(2), server.dials.Load())
+}
+
+func TestSFTPPoolReconnect(t *testing.T) {
+	server := newTestServer()
+	pool := NewSFTPPool(server.dial, 1, 2)
+	pool.retryDelay = 0
+	defer pool.Close()
+
+	folder := NewFolder(pool, "/walg/")
+	require.NoError(t, folder.PutObject("file", strings.NewReader("data")))
+	require.Len(t, server.conns, 1)
+
+	// Break the only connection, the next operation must reconnect
+	require.NoError(t, server.conns[0].Close())
+
+	exists, err := folder.Exists("file")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int32(2), server.dials.Load())
+}
+
+func TestSFTPPoolNoRetryAfterConsumedContent(t *testing.T) {
+	server := newTestServer()
+	pool := NewSFTPPool(server.dial, 1, 2)
+	pool.retryDelay = 0
+	defer pool.Close()
+
+	folder := NewFolder(pool, "/walg/")
+	require.NoError(t, folder.PutObject("dummy", strings.NewReader("data")))
+
+	content := &breakingReader{data: strings.Repeat("x", 1<<20), breakConn: server.conns[0]}
+	err := folder.PutObject("file", content)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), server.dials.Load())
+}
+
+// breakingReader closes the connection after the first chunk of data was read.
+type breakingReader struct {
+	data      string
+	breakConn net.Conn
+	read      int
+}
+
+func (r *breakingReader) Read(p []byte) (int, error) {
+	if r.read > 0 {
+		_ = r.breakConn.Close()
+	}
+	if r.read >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.read:])
+	r.read += n
+	return n, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/pkg/sftp"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"golang.org/x/crypto/ssh"
@@ -12,9 +13,9 @@ import (
 var _ storage.HashableStorage = &Storage{}
 
 type Storage struct {
-	sftpClientLazy *SFTPLazy
-	rootFolder     storage.Folder
-	hash           string
+	pool       *SFTPPool
+	rootFolder storage.Folder
+	hash       string
 }
 
 type Config struct {
@@ -24,13 +25,20 @@ type Config struct {
 	RootPath       string
 	User           string
 	PrivateKeyPath string
+	// MaxConnections limits the number of SSH connections used by concurrent operations.
+	MaxConnections int
+	// MaxRetries is the number of reconnection attempts made if an operation fails due to a broken connection.
+	MaxRetries int
+	// MaxPacketSize is the size of data sent in one SFTP read or write request.
+	MaxPacketSize int
+	// MaxConcurrentRequests limits the number of pipelined SFTP requests to a single file.
+	MaxConcurrentRequests int
 }
 
 type Secrets struct {
 	Password string
 }
 
-// TODO: Unit tests
 func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage, error) {
 	var authMethods []ssh.AuthMethod
 	if config.PrivateKeyPath != "" {
@@ -57,10 +65,14 @@ func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
 	address := fmt.Sprint(config.Host, ":", config.Port)
-	client := NewSFTPLazy(address, sshConfig)
+	dialer := NewSSHDialer(address, sshConfig,
+		sftp.MaxPacket(config.MaxPacketSize),
+		sftp.MaxConcurrentRequestsPerFile(config.MaxConcurrentRequests),
+	)
+	pool := NewSFTPPool(dialer, config.MaxConnections, config.MaxRetries)
 
 	path := storage.AddDelimiterToPath(config.RootPath)
-	var folder storage.Folder = NewFolder(client, path)
+	var folder storage.Folder = NewFolder(pool, path)
 
 	for _, wrap := range rootWraps {
 		folder = wrap(folder)
@@ -71,7 +83,7 @@ func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage,
 		return nil, fmt.Errorf("compute config hash: %w", err)
 	}
 
-	return &Storage{client, folder, hash}, nil
+	return &Storage{pool, folder, hash}, nil
 }
 
 func (s *Storage) RootFolder() storage.Folder {
@@ -83,15 +95,9 @@ func (s *Storage) ConfigHash() string {
 }
 
 func (s *Storage) Close() error {
-	client, connErr := s.sftpClientLazy.Client()
-	// Don't try to close the client if the initial connection failed
-	if connErr != nil {
-		tracelog.DebugLogger.Printf("SSH storage isn't closed due to the initial connection error: %v", connErr)
-		return nil
-	}
-	err := client.Close()
+	err := s.pool.Close()
 	if err != nil {
-		return fmt.Errorf("close SFTP client: %w", err)
+		return fmt.Errorf("close SFTP connections: %w", err)
 	}
 	tracelog.DebugLogger.Printf("SSH storage closed")
 	return nil
@@ -11,3 +11,16 @@ type Object interface {
 	GetLastModified() time.Time
 	GetSize() int64
 }
+
+// StorageClassTeller is implemented by objects that know the storage class they are stored with.
+type StorageClassTeller interface {
+	GetStorageClass() string
+}
+
+// GetStorageClass provides the storage class of the object, or an empty string if the object can't tell it.
+func GetStorageClass(obj Object) string {
+	if teller, ok := obj.(StorageClassTeller); ok {
+		return teller.GetStorageClass()
+	}
+	return ""
+}
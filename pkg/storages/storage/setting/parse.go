@@ -3,6 +3,7 @@ package setting
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 // TODO: Instead of reading settings from viper as strings and then parsing them here, read them already parsed using
@@ -56,6 +57,30 @@ func Int64Optional(settings map[string]string, key string, defaultVal int64) (in
 	return defaultVal, nil
 }
 
+func FloatOptional(settings map[string]string, key string, defaultVal float64) (float64, error) {
+	strVal, ok := settings[key]
+	if ok {
+		val, err := strconv.ParseFloat(strVal, 64)
+		if err != nil {
+			return 0, fmt.Errorf("setting %q must be a float: %w", key, err)
+		}
+		return val, nil
+	}
+	return defaultVal, nil
+}
+
+func DurationOptional(settings map[string]string, key string, defaultVal time.Duration) (time.Duration, error) {
+	strVal, ok := settings[key]
+	if ok {
+		val, err := time.ParseDuration(strVal)
+		if err != nil {
+			return 0, fmt.Errorf("setting %q must be a duration: %w", key, err)
+		}
+		return val, nil
+	}
+	return defaultVal, nil
+}
+
 func FirstDefined(settings map[string]string, keys ...string) string {
 	for _, key := range keys {
 		if value, ok := settings[key]; ok {
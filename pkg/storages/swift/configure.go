@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
 )
 
 const (
@@ -12,6 +13,18 @@ const (
 	osAuthURLSetting    = "OS_AUTH_URL"
 	osTenantNameSetting = "OS_TENANT_NAME"
 	osRegionNameSetting = "OS_REGION_NAME"
+
+	segmentSizeSetting        = "SWIFT_SEGMENT_SIZE"
+	segmentConcurrencySetting = "SWIFT_SEGMENT_CONCURRENCY"
+	segmentContainerSetting   = "SWIFT_SEGMENT_CONTAINER"
+)
+
+const (
+	// defaultSegmentSize is close to the default 5 GB object size limit of Swift, so only the objects that can't be
+	// uploaded with a single request become large objects
+	defaultSegmentSize        = 5 << 30
+	defaultSegmentConcurrency = 4
+	segmentContainerSuffix    = "_segments"
 )
 
 var SettingList = []string{
@@ -20,6 +33,9 @@ var SettingList = []string{
 	osAuthURLSetting,
 	osTenantNameSetting,
 	osRegionNameSetting,
+	segmentSizeSetting,
+	segmentConcurrencySetting,
+	segmentContainerSetting,
 }
 
 // TODO: Unit tests
@@ -34,7 +50,18 @@ func ConfigureStorage(
 	}
 	rootPath = storage.AddDelimiterToPath(rootPath)
 
-	publicEnv := settings
+	uploaderConfig, err := configureUploader(container, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	publicEnv := map[string]string{}
+	for name, value := range settings {
+		publicEnv[name] = value
+	}
+	delete(publicEnv, segmentSizeSetting)
+	delete(publicEnv, segmentConcurrencySetting)
+	delete(publicEnv, segmentContainerSetting)
 	secretEnv := map[string]string{}
 	if pass, ok := settings[osPasswordSetting]; ok {
 		secretEnv[osPasswordSetting] = pass
@@ -46,6 +73,7 @@ func ConfigureStorage(
 		RootPath:           rootPath,
 		EnvVariables:       publicEnv,
 		SecretEnvVariables: secretEnv,
+		Uploader:           uploaderConfig,
 	}
 
 	st, err := NewStorage(config, rootWraps...)
@@ -54,3 +82,29 @@ func ConfigureStorage(
 	}
 	return st, nil
 }
+
+func configureUploader(container string, settings map[string]string) (*UploaderConfig, error) {
+	segmentSize, err := setting.Int64Optional(settings, segmentSizeSetting, defaultSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	if segmentSize <= 0 {
+		return nil, fmt.Errorf("%s must be positive, got %d", segmentSizeSetting, segmentSize)
+	}
+	segmentConcurrency, err := setting.IntOptional(settings, segmentConcurrencySetting, defaultSegmentConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if segmentConcurrency <= 0 {
+		return nil, fmt.Errorf("%s must be positive, got %d", segmentConcurrencySetting, segmentConcurrency)
+	}
+	segmentContainer, ok := settings[segmentContainerSetting]
+	if !ok {
+		segmentContainer = container + segmentContainerSuffix
+	}
+	return &UploaderConfig{
+		SegmentSize:        segmentSize,
+		SegmentConcurrency: segmentConcurrency,
+		SegmentContainer:   segmentContainer,
+	}, nil
+}
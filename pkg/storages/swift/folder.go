@@ -2,8 +2,11 @@ package swift
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/wal-g/tracelog"
@@ -17,12 +20,13 @@ type Folder struct {
 	connection *swift.Connection
 	container  swift.Container
 	path       string
+	uploader   *Uploader
 }
 
-func NewFolder(connection *swift.Connection, container swift.Container, path string) *Folder {
+func NewFolder(connection *swift.Connection, container swift.Container, path string, uploader *Uploader) *Folder {
 	// Trim leading slash because there's no difference between absolute and relative paths in Swift.
 	path = strings.TrimPrefix(path, "/")
-	return &Folder{connection, container, path}
+	return &Folder{connection, container, path, uploader}
 }
 
 func (folder *Folder) GetPath() string {
@@ -56,7 +60,7 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 			for _, objectName := range objectNames {
 				if strings.HasSuffix(objectName, "/") {
 					//It is a subFolder name
-					subFolders = append(subFolders, NewFolder(folder.connection, folder.container, objectName))
+					subFolders = append(subFolders, NewFolder(folder.connection, folder.container, objectName, folder.uploader))
 				} else {
 					//It is a storage object name
 					obj, _, err := folder.connection.Object(ctx, folder.container.Name, objectName)
@@ -84,7 +88,12 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 }
 
 func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
-	return NewFolder(folder.connection, folder.container, storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)))
+	return NewFolder(
+		folder.connection,
+		folder.container,
+		storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)),
+		folder.uploader,
+	)
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
@@ -108,8 +117,8 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
 	path := storage.JoinPath(folder.path, name)
-	//put the object in the cloud using full path
-	_, err := folder.connection.ObjectPut(ctx, folder.container.Name, path, content, false, "", "", nil)
+	//put the object in the cloud using full path, big objects are split into segments
+	err := folder.uploader.Upload(ctx, folder.container.Name, path, content)
 	if err != nil {
 		return fmt.Errorf("put Swift object %q: %w", path, err)
 	}
@@ -126,7 +135,16 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	}
 	srcPath = storage.JoinPath(folder.path, srcPath)
 	dstPath = storage.JoinPath(folder.path, dstPath)
-	_, err := folder.connection.ObjectCopy(context.Background(), folder.container.Name, srcPath, folder.container.Name, dstPath, nil)
+	_, headers, err := folder.connection.Object(context.Background(), folder.container.Name, srcPath)
+	if err != nil {
+		return fmt.Errorf("get Swift object stats %q: %w", srcPath, err)
+	}
+	if headers.IsLargeObject() {
+		// Server-side copy of a large object would produce a single object that may exceed the size limit,
+		// and copying the manifest would make both objects share the segments.
+		return folder.copyLargeObject(srcPath, dstPath)
+	}
+	_, err = folder.connection.ObjectCopy(context.Background(), folder.container.Name, srcPath, folder.container.Name, dstPath, nil)
 	if err != nil {
 		return fmt.Errorf("copy Swift object %q -> %q: %w", srcPath, dstPath, err)
 	}
@@ -137,7 +155,7 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 	for _, objectRelativePath := range objectRelativePaths {
 		path := storage.JoinPath(folder.path, objectRelativePath)
 		tracelog.DebugLogger.Printf("Delete object %v\n", path)
-		err := folder.connection.ObjectDelete(context.Background(), folder.container.Name, path)
+		err := folder.deleteObject(path)
 		if err == swift.ObjectNotFound {
 			continue
 		}
@@ -148,6 +166,51 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 	return nil
 }
 
+// deleteObject deletes an object together with its segments if it's a static large object.
+// The multipart-manifest=delete request removes the segments of a manifest and works as a plain delete
+// for other objects, so the object is only inspected if the cluster rejects that request.
+func (folder *Folder) deleteObject(path string) error {
+	ctx := context.Background()
+	_, _, err := folder.connection.Call(ctx, folder.connection.StorageUrl, swift.RequestOpts{
+		Container:  folder.container.Name,
+		ObjectName: path,
+		Operation:  "DELETE",
+		Parameters: url.Values{"multipart-manifest": {"delete"}},
+		NoResponse: true,
+	})
+	var swiftErr *swift.Error
+	if errors.As(err, &swiftErr) && swiftErr.StatusCode == http.StatusNotFound {
+		return swift.ObjectNotFound
+	}
+	if err == nil {
+		return nil
+	}
+
+	tracelog.WarningLogger.Printf("Swift rejected the manifest-aware delete of %q, inspecting the object: %v", path, err)
+	_, headers, err := folder.connection.Object(ctx, folder.container.Name, path)
+	if err != nil {
+		return err
+	}
+	if headers.IsLargeObject() {
+		return folder.connection.LargeObjectDelete(ctx, folder.container.Name, path)
+	}
+	return folder.connection.ObjectDelete(ctx, folder.container.Name, path)
+}
+
+func (folder *Folder) copyLargeObject(srcPath, dstPath string) error {
+	ctx := context.Background()
+	readContents, _, err := folder.connection.ObjectOpen(ctx, folder.container.Name, srcPath, false, nil)
+	if err != nil {
+		return fmt.Errorf("open Swift object %q: %w", srcPath, err)
+	}
+	defer readContents.Close()
+	err = folder.uploader.Upload(ctx, folder.container.Name, dstPath, readContents)
+	if err != nil {
+		return fmt.Errorf("copy Swift large object %q -> %q: %w", srcPath, dstPath, err)
+	}
+	return nil
+}
+
 func (folder *Folder) Validate() error {
 	return nil
 }
@@ -25,6 +25,8 @@ type Config struct {
 
 	// SecretEnvVariables are like EnvVariables but require to be kept in secret.
 	SecretEnvVariables map[string]string `json:"-"`
+
+	Uploader *UploaderConfig
 }
 
 // TODO: Unit tests
@@ -58,7 +60,7 @@ func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage,
 		return nil, fmt.Errorf("get container by name: %w", err)
 	}
 
-	var folder storage.Folder = NewFolder(connection, container, config.RootPath)
+	var folder storage.Folder = NewFolder(connection, container, config.RootPath, NewUploader(connection, config.Uploader))
 
 	for _, wrap := range rootWraps {
 		folder = wrap(folder)
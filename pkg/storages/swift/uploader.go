@@ -0,0 +1,241 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ncw/swift/v2"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
+)
+
+type UploaderConfig struct {
+	// SegmentSize is the size of a static large object segment. Objects not bigger than this are stored as regular
+	// objects. It must not exceed the object size limit of the cluster.
+	SegmentSize int64
+	// SegmentConcurrency limits the number of segment PUT requests of a single object in flight.
+	SegmentConcurrency int
+	// SegmentContainer is a container where segments are stored. It's created on the first segmented upload.
+	SegmentContainer string
+}
+
+// maxBufferedObjectSize is the size of the objects that are read into memory and put with a single request
+const maxBufferedObjectSize = 16 << 20
+
+// Uploader uploads objects, splitting the big ones into segments of a static large object (SLO), since Swift limits
+// the size of a single object (5 GB by default). Only the small objects are kept in memory, the segments are streamed.
+// The object itself is written last, so it never holds partial content.
+type Uploader struct {
+	connection *swift.Connection
+	config     *UploaderConfig
+	// bufferSize is the size of the objects put with a single request, the bigger ones go through the segments
+	bufferSize int64
+}
+
+func NewUploader(connection *swift.Connection, config *UploaderConfig) *Uploader {
+	return &Uploader{connection, config, min(config.SegmentSize, maxBufferedObjectSize)}
+}
+
+// sloSegment is an entry of the SLO manifest as it's uploaded.
+type sloSegment struct {
+	Path string `json:"path"`
+	Etag string `json:"etag"`
+	Size int64  `json:"size_bytes"`
+}
+
+// Upload puts the small objects with a single request and the others segment by segment to the segment container.
+func (u *Uploader) Upload(ctx context.Context, container, objectPath string, content io.Reader) error {
+	head, err := io.ReadAll(io.LimitReader(content, u.bufferSize))
+	if err != nil {
+		return fmt.Errorf("read object content: %w", err)
+	}
+	content, more, err := hasMoreContent(content)
+	if err != nil {
+		return err
+	}
+	if !more {
+		_, err = u.connection.ObjectPut(ctx, container, objectPath, bytes.NewReader(head), false, "", "", nil)
+		return err
+	}
+	return u.uploadSegmented(ctx, container, objectPath, io.MultiReader(bytes.NewReader(head), content))
+}
+
+// uploadSegmented uploads the content to the segment container. The object is put after all the segments:
+// it's the SLO manifest, or the copy of the segment if the content fits into a single one.
+func (u *Uploader) uploadSegmented(ctx context.Context, container, objectPath string, content io.Reader) error {
+	err := u.connection.ContainerCreate(ctx, u.config.SegmentContainer, nil)
+	if err != nil {
+		return fmt.Errorf("create segment container %q: %w", u.config.SegmentContainer, err)
+	}
+
+	uploadID := uuid.New().String()
+	segments, err := u.uploadSegments(ctx, segmentPrefix(objectPath, uploadID), content)
+	if err != nil {
+		u.deleteSegments(segments)
+		return err
+	}
+
+	if len(segments) == 1 {
+		segmentName := strings.TrimPrefix(segments[0].Path, u.config.SegmentContainer+"/")
+		_, err = u.connection.ObjectCopy(ctx, u.config.SegmentContainer, segmentName, container, objectPath, nil)
+		u.deleteSegments(segments)
+		if err != nil {
+			return fmt.Errorf("copy segment %q to the object: %w", segmentName, err)
+		}
+	} else {
+		err = u.putManifest(ctx, container, objectPath, segments)
+		if err != nil {
+			u.deleteSegments(segments)
+			return err
+		}
+		tracelog.DebugLogger.Printf("Uploaded %q as SLO with %d segments", objectPath, len(segments))
+	}
+
+	u.deleteStaleSegments(objectPath, uploadID)
+	return nil
+}
+
+func (u *Uploader) putManifest(ctx context.Context, container, objectPath string, segments []*sloSegment) error {
+	manifest, err := json.Marshal(segments)
+	if err != nil {
+		return fmt.Errorf("marshal SLO manifest: %w", err)
+	}
+	_, _, err = u.connection.Call(ctx, u.connection.StorageUrl, swift.RequestOpts{
+		Container:  container,
+		ObjectName: objectPath,
+		Operation:  "PUT",
+		Parameters: url.Values{"multipart-manifest": []string{"put"}},
+		Headers:    swift.Headers{"Content-Length": strconv.Itoa(len(manifest))},
+		Body:       bytes.NewReader(manifest),
+		NoResponse: true,
+	})
+	if err != nil {
+		return fmt.Errorf("put SLO manifest: %w", err)
+	}
+	return nil
+}
+
+// uploadSegments streams the content to the segments. A segment is read from the content while the requests of
+// the previous ones are being completed, up to the segment concurrency requests are in flight. In case of an error,
+// it returns segments that were started so far, so that they can be cleaned up.
+func (u *Uploader) uploadSegments(ctx context.Context, prefix string, content io.Reader) ([]*sloSegment, error) {
+	errGroup, ctx := errgroup.WithContext(ctx)
+	errGroup.SetLimit(u.config.SegmentConcurrency)
+	var segments []*sloSegment
+	var readErr error
+	for index := 0; ; index++ {
+		segmentName := fmt.Sprintf("%s%08d", prefix, index)
+		segment := &sloSegment{Path: u.config.SegmentContainer + "/" + segmentName}
+		segments = append(segments, segment)
+
+		pipeReader, pipeWriter := io.Pipe()
+		errGroup.Go(func() error {
+			headers, err := u.connection.ObjectPut(ctx, u.config.SegmentContainer, segmentName, pipeReader,
+				false, "", "", nil)
+			pipeReader.CloseWithError(err)
+			if err != nil {
+				return fmt.Errorf("put SLO segment %q: %w", segmentName, err)
+			}
+			segment.Etag = headers["Etag"]
+			return nil
+		})
+		segment.Size, readErr = io.CopyN(pipeWriter, content, u.config.SegmentSize)
+		if readErr == io.EOF {
+			readErr = nil
+		}
+		pipeWriter.CloseWithError(readErr)
+		if readErr != nil || segment.Size < u.config.SegmentSize {
+			break
+		}
+		var more bool
+		content, more, readErr = hasMoreContent(content)
+		if readErr != nil || !more {
+			break
+		}
+	}
+	err := errGroup.Wait()
+	if err != nil {
+		return segments, err
+	}
+	if readErr != nil {
+		return segments, fmt.Errorf("read object content: %w", readErr)
+	}
+	return segments, nil
+}
+
+// deleteSegments removes segments of a failed upload. It's the best effort: leftovers are also cleaned up by
+// the next successful upload of the same object.
+func (u *Uploader) deleteSegments(segments []*sloSegment) {
+	prefix := u.config.SegmentContainer + "/"
+	for _, segment := range segments {
+		name := strings.TrimPrefix(segment.Path, prefix)
+		err := u.connection.ObjectDelete(context.Background(), u.config.SegmentContainer, name)
+		if err != nil && !errors.Is(err, swift.ObjectNotFound) {
+			tracelog.WarningLogger.Printf("Failed to delete SLO segment %q: %v", segment.Path, err)
+		}
+	}
+}
+
+// deleteStaleSegments removes segments that belong to the object but were left by other uploads: by aborted
+// uploads and by previous versions of the object that were overwritten.
+func (u *Uploader) deleteStaleSegments(objectPath, currentUploadID string) {
+	ctx := context.Background()
+	objectPrefix := objectPath + "/"
+	names, err := u.connection.ObjectNamesAll(ctx, u.config.SegmentContainer, &swift.ObjectsOpts{Prefix: objectPrefix})
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list SLO segments of %q: %v", objectPath, err)
+		return
+	}
+	for _, name := range names {
+		uploadID, ok := parseSegmentName(strings.TrimPrefix(name, objectPrefix))
+		if !ok || uploadID == currentUploadID {
+			continue
+		}
+		err = u.connection.ObjectDelete(ctx, u.config.SegmentContainer, name)
+		if err != nil && !errors.Is(err, swift.ObjectNotFound) {
+			tracelog.WarningLogger.Printf("Failed to delete stale SLO segment %q: %v", name, err)
+		}
+	}
+}
+
+// segmentPrefix builds a prefix for the names of the segments: <object path>/<upload ID>/
+func segmentPrefix(objectPath, uploadID string) string {
+	return objectPath + "/" + uploadID + "/"
+}
+
+var segmentNumberRegexp = regexp.MustCompile(`^[0-9]{8}$`)
+
+// parseSegmentName extracts an upload ID from a segment name relative to the object path. It doesn't match
+// the segments of the other objects that have the current object path as a prefix.
+func parseSegmentName(name string) (uploadID string, ok bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 || !segmentNumberRegexp.MatchString(parts[1]) {
+		return "", false
+	}
+	if _, err := uuid.Parse(parts[0]); err != nil {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// hasMoreContent checks if the content has not ended yet. The returned reader has to be used instead of the content.
+func hasMoreContent(content io.Reader) (io.Reader, bool, error) {
+	var firstByte [1]byte
+	_, err := io.ReadFull(content, firstByte[:])
+	if err == io.EOF {
+		return content, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read object content: %w", err)
+	}
+	return io.MultiReader(bytes.NewReader(firstByte[:]), content), true, nil
+}
@@ -0,0 +1,216 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/uuid"
+	"github.com/ncw/swift/v2"
+	"github.com/ncw/swift/v2/swifttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const testSegmentSize = 1024
+
+func newTestFolder(t *testing.T) (*Folder, *swift.Connection) {
+	server, err := swifttest.NewSwiftServer("localhost")
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	connection := &swift.Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  server.AuthURL,
+	}
+	require.NoError(t, connection.Authenticate(ctx))
+	proxy := httptest.NewServer(newSLODeleteProxy(connection))
+	t.Cleanup(proxy.Close)
+	storageURL, err := url.Parse(connection.StorageUrl)
+	require.NoError(t, err)
+	connection = &swift.Connection{
+		StorageUrl: proxy.URL + storageURL.Path,
+		AuthToken:  connection.AuthToken,
+	}
+	require.NoError(t, connection.ContainerCreate(ctx, "test", nil))
+	container, _, err := connection.Container(ctx, "test")
+	require.NoError(t, err)
+
+	uploader := NewUploader(connection, &UploaderConfig{
+		SegmentSize:        testSegmentSize,
+		SegmentConcurrency: 2,
+		SegmentContainer:   "test" + segmentContainerSuffix,
+	})
+	return NewFolder(connection, container, "root/", uploader), connection
+}
+
+// newSLODeleteProxy forwards requests to the emulator and serves multipart-manifest=delete requests like Swift does,
+// swifttest ignores that parameter and leaves the segments behind.
+func newSLODeleteProxy(direct *swift.Connection) http.Handler {
+	target, _ := url.Parse(direct.StorageUrl)
+	storagePath := target.Path
+	target.Path = ""
+	forward := httputil.NewSingleHostReverseProxy(target)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Query().Get("multipart-manifest") != "delete" {
+			forward.ServeHTTP(w, r)
+			return
+		}
+		// the path is the storage path followed by /container/object
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, storagePath+"/"), "/", 2)
+		ctx := r.Context()
+		_, headers, err := direct.Object(ctx, parts[0], parts[1])
+		if err == nil {
+			if headers.IsLargeObjectSLO() {
+				err = direct.LargeObjectDelete(ctx, parts[0], parts[1])
+			} else {
+				err = direct.ObjectDelete(ctx, parts[0], parts[1])
+			}
+		}
+		switch {
+		case err == swift.ObjectNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func segmentNames(t *testing.T, connection *swift.Connection) []string {
+	names, err := connection.ObjectNamesAll(context.Background(), "test"+segmentContainerSuffix, nil)
+	if err == swift.ContainerNotFound {
+		return nil
+	}
+	require.NoError(t, err)
+	return names
+}
+
+func TestSwiftFolder(t *testing.T) {
+	folder, _ := newTestFolder(t)
+	storage.RunFolderTest(folder, t)
+}
+
+func TestSwiftFolder_SegmentedUpload(t *testing.T) {
+	folder, connection := newTestFolder(t)
+	content := bytes.Repeat([]byte("0123456789"), testSegmentSize/2)
+
+	require.NoError(t, folder.PutObject("big", bytes.NewReader(content)))
+	assert.Len(t, segmentNames(t, connection), 5)
+
+	_, headers, err := connection.Object(context.Background(), "test", "root/big")
+	require.NoError(t, err)
+	assert.True(t, headers.IsLargeObjectSLO())
+
+	reader, err := folder.ReadObject("big")
+	require.NoError(t, err)
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, actual)
+
+	require.NoError(t, folder.CopyObject("big", "copy"))
+	reader, err = folder.ReadObject("copy")
+	require.NoError(t, err)
+	actual, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, actual)
+	assert.Len(t, segmentNames(t, connection), 10)
+
+	require.NoError(t, folder.DeleteObjects([]string{"big", "copy"}))
+	assert.Empty(t, segmentNames(t, connection))
+	exists, err := folder.Exists("big")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestSwiftFolder_SmallObjectIsNotSegmented(t *testing.T) {
+	folder, connection := newTestFolder(t)
+
+	require.NoError(t, folder.PutObject("small", strings.NewReader("small content")))
+	assert.Empty(t, segmentNames(t, connection))
+
+	_, headers, err := connection.Object(context.Background(), "test", "root/small")
+	require.NoError(t, err)
+	assert.False(t, headers.IsLargeObject())
+}
+
+func TestSwiftFolder_SingleSegmentIsCopied(t *testing.T) {
+	folder, connection := newTestFolder(t)
+	folder.uploader.bufferSize = 100
+	content := bytes.Repeat([]byte("x"), testSegmentSize)
+
+	require.NoError(t, folder.PutObject("medium", bytes.NewReader(content)))
+	assert.Empty(t, segmentNames(t, connection))
+
+	_, headers, err := connection.Object(context.Background(), "test", "root/medium")
+	require.NoError(t, err)
+	assert.False(t, headers.IsLargeObject())
+	reader, err := folder.ReadObject("medium")
+	require.NoError(t, err)
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, actual)
+}
+
+func TestSwiftFolder_FailedUploadLeavesNoObject(t *testing.T) {
+	folder, connection := newTestFolder(t)
+	content := io.MultiReader(bytes.NewReader(bytes.Repeat([]byte("x"), 3*testSegmentSize)),
+		iotest.ErrReader(errors.New("read failure")))
+
+	err := folder.PutObject("big", content)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read failure")
+	exists, err := folder.Exists("big")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, segmentNames(t, connection))
+}
+
+func TestSwiftFolder_StaleSegmentsAreDeleted(t *testing.T) {
+	folder, connection := newTestFolder(t)
+	ctx := context.Background()
+	segmentContainer := "test" + segmentContainerSuffix
+	require.NoError(t, connection.ContainerCreate(ctx, segmentContainer, nil))
+
+	// Segments of an aborted upload of the same object and a segment of another object with a similar name.
+	abortedSegment := segmentPrefix("root/big", uuid.New().String()) + "00000000"
+	otherSegment := segmentPrefix("root/big/other", uuid.New().String()) + "00000000"
+	for _, name := range []string{abortedSegment, otherSegment} {
+		require.NoError(t, connection.ObjectPutBytes(ctx, segmentContainer, name, []byte("data"), ""))
+	}
+
+	content := bytes.Repeat([]byte("x"), 2*testSegmentSize)
+	require.NoError(t, folder.PutObject("big", bytes.NewReader(content)))
+
+	names := segmentNames(t, connection)
+	assert.NotContains(t, names, abortedSegment)
+	assert.Contains(t, names, otherSegment)
+	assert.Len(t, names, 3)
+}
+
+func TestParseSegmentName(t *testing.T) {
+	uploadID := uuid.New().String()
+
+	actual, ok := parseSegmentName(uploadID + "/00000001")
+	assert.True(t, ok)
+	assert.Equal(t, uploadID, actual)
+
+	_, ok = parseSegmentName("other/" + uploadID + "/00000001")
+	assert.False(t, ok)
+	_, ok = parseSegmentName("not-uuid/00000001")
+	assert.False(t, ok)
+	_, ok = parseSegmentName(uploadID + "/1")
+	assert.False(t, ok)
+}
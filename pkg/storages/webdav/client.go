@@ -0,0 +1,297 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client performs WebDAV requests to the server. Paths passed to it are relative to the endpoint.
+type Client struct {
+	httpClient *http.Client
+	endpoint   *url.URL
+	username   string
+	secrets    *Secrets
+
+	// createdCollections caches collections that are known to exist, so that MKCOL isn't sent before every PUT.
+	createdCollections sync.Map
+}
+
+func NewClient(httpClient *http.Client, endpoint *url.URL, username string, secrets *Secrets) *Client {
+	return &Client{
+		httpClient: httpClient,
+		endpoint:   endpoint,
+		username:   username,
+		secrets:    secrets,
+	}
+}
+
+// StatusError is returned when the server responds with an unexpected status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (err StatusError) Error() string {
+	return fmt.Sprintf("WebDAV %s %s: unexpected status %d %s",
+		err.Method, err.URL, err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+func (c *Client) url(relativePath string) *url.URL {
+	u := *c.endpoint
+	u.Path = path.Join(c.endpoint.Path, relativePath)
+	if strings.HasSuffix(relativePath, "/") || relativePath == "" {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	return &u
+}
+
+func (c *Client) newRequest(ctx context.Context, method, relativePath string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(relativePath).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.secrets.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.secrets.BearerToken)
+	case c.username != "" || c.secrets.Password != "":
+		req.SetBasicAuth(c.username, c.secrets.Password)
+	}
+	return req, nil
+}
+
+func (c *Client) do(req *http.Request, expectedStatuses ...int) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range expectedStatuses {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	drainAndClose(resp)
+	return nil, StatusError{req.Method, req.URL.Redacted(), resp.StatusCode}
+}
+
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+func isStatus(err error, status int) bool {
+	statusErr, ok := err.(StatusError)
+	return ok && statusErr.StatusCode == status
+}
+
+type resource struct {
+	name         string
+	isCollection bool
+	size         int64
+	lastModified time.Time
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// List returns direct members of the collection. Returns nil without any error if the collection doesn't exist.
+func (c *Client) List(ctx context.Context, collectionPath string) ([]resource, error) {
+	collectionPath = strings.TrimSuffix(collectionPath, "/") + "/"
+	req, err := c.newRequest(ctx, "PROPFIND", collectionPath, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := c.do(req, http.StatusMultiStatus)
+	if isStatus(err, http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp)
+
+	var result multistatus
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("decode PROPFIND response: %w", err)
+	}
+
+	collectionURLPath := c.url(collectionPath).Path
+	var resources []resource
+	for _, response := range result.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			return nil, fmt.Errorf("parse href %q: %w", response.Href, err)
+		}
+		name := strings.Trim(strings.TrimPrefix(href.Path, collectionURLPath), "/")
+		if name == "" || strings.Contains(name, "/") {
+			// The collection itself, or something not belonging to it.
+			continue
+		}
+
+		res := resource{name: name}
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			res.isCollection = res.isCollection || prop.ResourceType.Collection != nil
+			if prop.ContentLength != 0 {
+				res.size = prop.ContentLength
+			}
+			if prop.LastModified != "" {
+				res.lastModified, _ = http.ParseTime(prop.LastModified)
+			}
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// Exists checks if a resource exists by the path.
+func (c *Client) Exists(ctx context.Context, resourcePath string) (bool, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, resourcePath, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if isStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	drainAndClose(resp)
+	return true, nil
+}
+
+// Get downloads a resource. The caller must close the returned body.
+func (c *Client) Get(ctx context.Context, resourcePath string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, resourcePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put uploads a resource, creating parent collections if needed.
+func (c *Client) Put(ctx context.Context, resourcePath string, content io.Reader) error {
+	err := c.MakeCollections(ctx, path.Dir(resourcePath))
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, resourcePath, content)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	drainAndClose(resp)
+	return nil
+}
+
+// Delete removes a resource. It's not an error if the resource doesn't exist.
+func (c *Client) Delete(ctx context.Context, resourcePath string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, resourcePath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	drainAndClose(resp)
+	return nil
+}
+
+// Copy makes a server-side copy of a resource, overwriting the destination, and creating its parent collections.
+func (c *Client) Copy(ctx context.Context, srcPath, dstPath string) error {
+	err := c.MakeCollections(ctx, path.Dir(dstPath))
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, "COPY", srcPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Destination", c.url(dstPath).String())
+	req.Header.Set("Overwrite", "T")
+	resp, err := c.do(req, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	drainAndClose(resp)
+	return nil
+}
+
+// MakeCollections creates the collection and all its parents up to the endpoint, like `mkdir -p` does.
+func (c *Client) MakeCollections(ctx context.Context, collectionPath string) error {
+	collectionPath = strings.Trim(collectionPath, "/")
+	// path.Dir returns "." for the top-level collections
+	if collectionPath == "." {
+		collectionPath = ""
+	}
+	if _, ok := c.createdCollections.Load(collectionPath); ok {
+		return nil
+	}
+
+	if collectionPath != "" {
+		err := c.MakeCollections(ctx, path.Dir(collectionPath))
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := c.newRequest(ctx, "MKCOL", collectionPath, nil)
+	if err != nil {
+		return err
+	}
+	// 405 Method Not Allowed means that the collection already exists.
+	resp, err := c.do(req, http.StatusCreated, http.StatusOK, http.StatusMethodNotAllowed)
+	if err != nil {
+		return fmt.Errorf("create collection %q: %w", collectionPath, err)
+	}
+	drainAndClose(resp)
+
+	c.createdCollections.Store(collectionPath, true)
+	return nil
+}
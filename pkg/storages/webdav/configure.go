@@ -0,0 +1,105 @@
+package webdav
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/pkg/storages/storage/setting"
+)
+
+const (
+	usernameSetting           = "WEBDAV_USERNAME"
+	passwordSetting           = "WEBDAV_PASSWORD"
+	bearerTokenSetting        = "WEBDAV_BEARER_TOKEN"
+	caCertFileSetting         = "WEBDAV_CA_CERT_FILE"
+	clientCertFileSetting     = "WEBDAV_CLIENT_CERT_FILE"
+	clientKeyFileSetting      = "WEBDAV_CLIENT_KEY_FILE"
+	insecureSkipVerifySetting = "WEBDAV_INSECURE_SKIP_VERIFY"
+	timeoutSetting            = "WEBDAV_TIMEOUT"
+)
+
+var SettingList = []string{
+	usernameSetting,
+	passwordSetting,
+	bearerTokenSetting,
+	caCertFileSetting,
+	clientCertFileSetting,
+	clientKeyFileSetting,
+	insecureSkipVerifySetting,
+	timeoutSetting,
+}
+
+const (
+	defaultInsecureSkipVerify = false
+	defaultTimeout            = 0
+)
+
+// schemeAliases allow to use webdav:// and webdavs:// prefixes to distinguish WebDAV from other storages in configs.
+var schemeAliases = map[string]string{
+	"webdav":  "http",
+	"webdavs": "https",
+	"http":    "http",
+	"https":   "https",
+}
+
+func ConfigureStorage(
+	prefix string,
+	settings map[string]string,
+	rootWraps ...storage.WrapRootFolder,
+) (storage.HashableStorage, error) {
+	endpoint, err := parsePrefix(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("parse WebDAV storage prefix %q: %w", prefix, err)
+	}
+
+	insecureSkipVerify, err := setting.BoolOptional(settings, insecureSkipVerifySetting, defaultInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := setting.DurationOptional(settings, timeoutSetting, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Secrets: &Secrets{
+			Password:    settings[passwordSetting],
+			BearerToken: settings[bearerTokenSetting],
+		},
+		Endpoint:           endpoint,
+		Username:           settings[usernameSetting],
+		CACertFile:         settings[caCertFileSetting],
+		ClientCertFile:     settings[clientCertFileSetting],
+		ClientKeyFile:      settings[clientKeyFileSetting],
+		InsecureSkipVerify: insecureSkipVerify,
+		Timeout:            timeout,
+	}
+
+	st, err := NewStorage(config, rootWraps...)
+	if err != nil {
+		return nil, fmt.Errorf("create WebDAV storage: %w", err)
+	}
+	return st, nil
+}
+
+func parsePrefix(prefix string) (string, error) {
+	endpoint, err := url.Parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	scheme, ok := schemeAliases[strings.ToLower(endpoint.Scheme)]
+	if !ok {
+		return "", fmt.Errorf("unsupported url scheme %q, expected one of: webdav, webdavs, http, https", endpoint.Scheme)
+	}
+	if endpoint.Host == "" {
+		return "", fmt.Errorf("missing url host")
+	}
+	endpoint.Scheme = scheme
+	endpoint.Path = storage.AddDelimiterToPath(endpoint.Path)
+	if endpoint.Path == "" {
+		endpoint.Path = "/"
+	}
+	return endpoint.String(), nil
+}
@@ -0,0 +1,114 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.Folder = &Folder{}
+
+type Folder struct {
+	client *Client
+	path   string
+}
+
+func NewFolder(client *Client, path string) *Folder {
+	return &Folder{
+		client: client,
+		path:   path,
+	}
+}
+
+func (folder *Folder) GetPath() string {
+	return folder.path
+}
+
+func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	resources, err := folder.client.List(context.Background(), folder.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list WebDAV folder %q: %w", folder.path, err)
+	}
+
+	for _, res := range resources {
+		if res.isCollection {
+			subFolders = append(subFolders, folder.GetSubFolder(res.name))
+			continue
+		}
+		objects = append(objects, storage.NewLocalObject(res.name, res.lastModified, res.size))
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	for _, relativePath := range objectRelativePaths {
+		objPath := storage.JoinPath(folder.path, relativePath)
+		err := folder.client.Delete(context.Background(), objPath)
+		if err != nil {
+			return fmt.Errorf("delete object %q via WebDAV: %w", objPath, err)
+		}
+	}
+	return nil
+}
+
+func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	objPath := storage.JoinPath(folder.path, objectRelativePath)
+	exists, err := folder.client.Exists(context.Background(), objPath)
+	if err != nil {
+		return false, fmt.Errorf("check object %q existence via WebDAV: %w", objPath, err)
+	}
+	return exists, nil
+}
+
+func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return NewFolder(folder.client, storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)))
+}
+
+func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	objPath := storage.JoinPath(folder.path, objectRelativePath)
+	body, err := folder.client.Get(context.Background(), objPath)
+	if isStatus(err, http.StatusNotFound) {
+		return nil, storage.NewObjectNotFoundError(objPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read object %q via WebDAV: %w", objPath, err)
+	}
+	return body, nil
+}
+
+func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	objPath := storage.JoinPath(folder.path, name)
+	err := folder.client.Put(ctx, objPath, content)
+	if err != nil {
+		return fmt.Errorf("put object %q via WebDAV: %w", objPath, err)
+	}
+	return nil
+}
+
+func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
+	srcObjPath := storage.JoinPath(folder.path, srcPath)
+	dstObjPath := storage.JoinPath(folder.path, dstPath)
+	err := folder.client.Copy(context.Background(), srcObjPath, dstObjPath)
+	if isStatus(err, http.StatusNotFound) {
+		return storage.NewObjectNotFoundError(srcObjPath)
+	}
+	if err != nil {
+		return fmt.Errorf("copy object %q to %q via WebDAV: %w", srcObjPath, dstObjPath, err)
+	}
+	return nil
+}
+
+func (folder *Folder) Validate() error {
+	_, err := folder.client.List(context.Background(), folder.path)
+	if err != nil {
+		return fmt.Errorf("bad WebDAV folder %q: %w", folder.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package webdav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/net/webdav"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	handler := &webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "walg" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebDAVFolder(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := ConfigureStorage(server.URL+"/walg", map[string]string{
+		usernameSetting: "walg",
+		passwordSetting: "secret",
+	})
+	require.NoError(t, err)
+	defer st.Close()
+
+	storage.RunFolderTest(st.RootFolder(), t)
+}
+
+func TestWebDAVFolder_DottedNames(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := ConfigureStorage(server.URL+"/walg", map[string]string{
+		usernameSetting: "walg",
+		passwordSetting: "secret",
+	})
+	require.NoError(t, err)
+	defer st.Close()
+
+	folder := st.RootFolder().GetSubFolder(".hidden.").GetSubFolder("v1.")
+	require.NoError(t, folder.PutObject("file.", strings.NewReader("data")))
+	exists, err := st.RootFolder().GetSubFolder(".hidden./v1.").Exists("file.")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestWebDAVFolder_Unauthorized(t *testing.T) {
+	server := newTestServer(t)
+
+	st, err := ConfigureStorage(strings.Replace(server.URL, "http://", "webdav://", 1)+"/walg", map[string]string{
+		bearerTokenSetting: "token",
+	})
+	require.NoError(t, err)
+
+	err = st.RootFolder().Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestParsePrefix(t *testing.T) {
+	endpoint, err := parsePrefix("webdavs://nas.local:8443/dav/walg")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://nas.local:8443/dav/walg/", endpoint)
+
+	endpoint, err = parsePrefix("http://nas.local")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://nas.local/", endpoint)
+
+	_, err = parsePrefix("ftp://nas.local/walg")
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package webdav
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+var _ storage.HashableStorage = &Storage{}
+
+type Storage struct {
+	client     *Client
+	rootFolder storage.Folder
+	hash       string
+}
+
+type Config struct {
+	Secrets *Secrets `json:"-"`
+	// Endpoint is the URL of the WebDAV collection where the storage is located, e.g. https://nas.local/dav/backups/
+	Endpoint           string
+	Username           string
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+type Secrets struct {
+	Password    string
+	BearerToken string
+}
+
+func NewStorage(config *Config, rootWraps ...storage.WrapRootFolder) (*Storage, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint %q: %w", config.Endpoint, err)
+	}
+
+	tlsConfig, err := configureTLS(config)
+	if err != nil {
+		return nil, fmt.Errorf("configure TLS: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	httpClient := &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}
+
+	client := NewClient(httpClient, endpoint, config.Username, config.Secrets)
+
+	var folder storage.Folder = NewFolder(client, "")
+
+	for _, wrap := range rootWraps {
+		folder = wrap(folder)
+	}
+
+	hash, err := storage.ComputeConfigHash("webdav", config)
+	if err != nil {
+		return nil, fmt.Errorf("compute config hash: %w", err)
+	}
+
+	return &Storage{client, folder, hash}, nil
+}
+
+func configureTLS(config *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify, //nolint:gosec // explicitly requested by the user
+	}
+
+	if config.CACertFile != "" {
+		caCert, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA cert file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in CA cert file %q", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (s *Storage) RootFolder() storage.Folder {
+	return s.rootFolder
+}
+
+func (s *Storage) ConfigHash() string {
+	return s.hash
+}
+
+func (s *Storage) Close() error {
+	s.client.httpClient.CloseIdleConnections()
+	return nil
+}
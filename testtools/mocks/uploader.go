@@ -12,6 +12,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	internal "github.com/wal-g/wal-g/internal"
 	compression "github.com/wal-g/wal-g/internal/compression"
+	crypto "github.com/wal-g/wal-g/internal/crypto"
 	ioextensions "github.com/wal-g/wal-g/internal/ioextensions"
 	storage "github.com/wal-g/wal-g/pkg/storages/storage"
 )
@@ -79,6 +80,20 @@ func (mr *MockUploaderMockRecorder) Compression() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Compression", reflect.TypeOf((*MockUploader)(nil).Compression))
 }
 
+// Crypter mocks base method.
+func (m *MockUploader) Crypter() crypto.Crypter {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Crypter")
+	ret0, _ := ret[0].(crypto.Crypter)
+	return ret0
+}
+
+// Crypter indicates an expected call of Crypter.
+func (mr *MockUploaderMockRecorder) Crypter() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Crypter", reflect.TypeOf((*MockUploader)(nil).Crypter))
+}
+
 // DisableSizeTracking mocks base method.
 func (m *MockUploader) DisableSizeTracking() {
 	m.ctrl.T.Helper()
@@ -131,6 +146,20 @@ func (mr *MockUploaderMockRecorder) Folder() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Folder", reflect.TypeOf((*MockUploader)(nil).Folder))
 }
 
+// PushNamedStream mocks base method.
+func (m *MockUploader) PushNamedStream(ctx context.Context, stream io.Reader, backupName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushNamedStream", ctx, stream, backupName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushNamedStream indicates an expected call of PushNamedStream.
+func (mr *MockUploaderMockRecorder) PushNamedStream(ctx, stream, backupName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushNamedStream", reflect.TypeOf((*MockUploader)(nil).PushNamedStream), ctx, stream, backupName)
+}
+
 // PushStream mocks base method.
 func (m *MockUploader) PushStream(ctx context.Context, stream io.Reader) (string, error) {
 	m.ctrl.T.Helper()